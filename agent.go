@@ -0,0 +1,417 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/rakyll/boom/boomer"
+)
+
+// controllerFlags are the flags handled by the controller of a
+// distributed run rather than passed on to its agents.
+var controllerFlags = map[string]bool{
+	"f":              true,
+	"agents":         true,
+	"agent-token":    true,
+	"k8s":            true,
+	"k8s-template":   true,
+	"o":              true,
+	"n":              true,
+	"c":              true,
+	"qps":            true,
+	"cpus":           true,
+	"threshold":      true,
+	"baseline":       true,
+	"max-regression": true,
+	"pushgateway":    true,
+}
+
+// agentFlags are the flags agents accept from a controller. Flags
+// naming files to read or write, listening, or sending the agent's
+// environment elsewhere, such as templates, secrets, identity tokens
+// and AWS credentials, are left out, as they would let any client of
+// the agent reach into its host.
+var agentFlags = map[string]bool{
+	"m": true, "h": true, "d": true, "A": true, "T": true, "a": true,
+	"readall": true, "check-etag": true, "compression-stats": true,
+	"check-consistency": true, "abort": true, "slow-rate": true,
+	"churn": true, "c": true, "n": true, "q": true, "qps": true, "t": true,
+	"z": true, "max-workers": true, "burst": true, "poisson": true,
+	"warmup": true, "ramp-up": true, "ramp-down": true, "ramp-from": true,
+	"stages": true, "shape": true, "allow-insecure": true, "host": true,
+	"sni": true, "disable-compression": true, "accept-encoding": true,
+	"no-decompress": true, "disable-keepalive": true, "cookie-jar": true,
+	"cookie": true, "retries": true, "retry-on": true,
+	"retry-backoff": true, "no-redirects": true, "max-redirects": true,
+	"last-hop-latency": true, "h2": true, "grpc": true,
+	"grpc-stream": true, "stream-messages": true, "ws": true,
+	"ws-message": true, "graphql": true, "graphql-op": true, "x": true,
+	"proxy": true, "idle-timeout": true, "dial-timeout": true,
+	"tls-timeout": true, "header-timeout": true, "grace": true,
+	"max-idle-per-host": true, "max-conns-per-host": true,
+	"resolve": true, "dns-ttl": true, "4": true, "6": true,
+	"schema-sample": true, "proto-message": true, "id-offset": true,
+	"max-body": true, "max-mem": true, "interval": true, "statsd": true,
+	"statsd-prefix": true, "statsd-tags": true, "dogstatsd": true,
+	"autoscale": true, "autoscale-cpu": true, "stall-after": true,
+	"latency-budget": true, "keep-latencies": true, "slo": true,
+	"slo-period": true, "redact": true, "hc": true, "fc": true, "bc": true,
+}
+
+// agentRun is the body of a request to an agent to make a run.
+type agentRun struct {
+	Args []string `json:"args"`
+}
+
+// agent makes the runs requested by a controller, one at a time, by
+// running boom with their arguments and replying with the JSON report.
+type agent struct {
+	exe   string
+	token string
+
+	mu  sync.Mutex
+	cmd *exec.Cmd
+}
+
+// agentMain runs "boom agent", which serves the runs of a controller
+// started with -agents.
+func agentMain(args []string) {
+	fs := flag.NewFlagSet("agent", flag.ExitOnError)
+	fs.Usage = flag.Usage
+	listen := fs.String("listen", ":7070", "")
+	token := fs.String("token", "", "")
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		usageAndExit("agent takes no arguments.")
+	}
+	*listen = localListen("agent", *listen, *token)
+	exe, err := os.Executable()
+	printErr(err)
+	a := &agent{exe: exe, token: *token}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/run", a.run)
+	mux.HandleFunc("/interrupt", a.interrupt)
+	fmt.Fprintf(os.Stderr, "Agent listening on %s.\n", *listen)
+	printErr(http.ListenAndServe(*listen, mux))
+}
+
+// localListen returns the address cmd listens on: listen, or its
+// loopback address if no host is given and there is no token. Without
+// a token anyone reaching cmd could make runs from it, so only local
+// clients are served.
+func localListen(cmd, listen, token string) string {
+	if token != "" {
+		return listen
+	}
+	host, port, err := net.SplitHostPort(listen)
+	if err != nil {
+		usageAndExit(fmt.Sprintf("invalid -listen: %v", err))
+	}
+	switch ip := net.ParseIP(host); {
+	case host == "":
+		return net.JoinHostPort("127.0.0.1", port)
+	case host != "localhost" && (ip == nil || !ip.IsLoopback()):
+		usageAndExit(cmd + " requires -token to listen on other than localhost.")
+	}
+	return listen
+}
+
+// authorized reports whether r is a POST carrying the token of the
+// agent, replying with an error if not.
+func (a *agent) authorized(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != "POST" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return false
+	}
+	return checkToken(a.token, w, r)
+}
+
+// checkToken reports whether r carries token as a bearer token,
+// replying with an error if not. Any request is accepted if token is
+// empty.
+func checkToken(token string, w http.ResponseWriter, r *http.Request) bool {
+	got := []byte(r.Header.Get("Authorization"))
+	if token != "" && subtle.ConstantTimeCompare(got, []byte("Bearer "+token)) != 1 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// run makes the run of the request and replies with its JSON report.
+// The run is killed if the controller goes away.
+func (a *agent) run(w http.ResponseWriter, r *http.Request) {
+	if !a.authorized(w, r) {
+		return
+	}
+	var run agentRun
+	if err := json.NewDecoder(r.Body).Decode(&run); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := checkAgentArgs(run.Args); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(r.Context(), a.exe, append([]string{"-o=json"}, run.Args...)...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	a.mu.Lock()
+	if a.cmd != nil {
+		a.mu.Unlock()
+		http.Error(w, "agent is busy with another run", http.StatusConflict)
+		return
+	}
+	err := cmd.Start()
+	if err == nil {
+		a.cmd = cmd
+	}
+	a.mu.Unlock()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	err = cmd.Wait()
+	a.mu.Lock()
+	a.cmd = nil
+	a.mu.Unlock()
+
+	// An interrupted run exits with 130 after printing its report.
+	var exit *exec.ExitError
+	if err != nil && !(errors.As(err, &exit) && exit.ExitCode() == 130) {
+		http.Error(w, strings.TrimSpace(stderr.String()), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(stdout.Bytes())
+}
+
+// interrupt interrupts the current run, which replies with the report
+// of the requests made until then.
+func (a *agent) interrupt(w http.ResponseWriter, r *http.Request) {
+	if !a.authorized(w, r) {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.cmd != nil {
+		a.cmd.Process.Signal(syscall.SIGINT)
+	}
+}
+
+// agentArgs returns the arguments of the run of agent i of agents:
+// those of the command line without the flags handled by the
+// controller, the share of the agent of the requests, workers and rate
+// of the run, and an -id-offset past the {{seq}} and {{counter}}
+// values of the agents before it.
+func agentArgs(args []string, i, agents, num, conc, qps int) []string {
+	var flags []string
+	var idOffset int64
+	workers := conc
+	if qps > workers {
+		workers = qps
+	}
+	for len(args) > 0 {
+		arg := args[0]
+		if arg == "--" || arg == "-" || !strings.HasPrefix(arg, "-") {
+			break
+		}
+		args = args[1:]
+		name := strings.TrimLeft(arg, "-")
+		value, hasValue := "", false
+		if j := strings.Index(name, "="); j >= 0 {
+			name, value, hasValue = name[:j], name[j+1:], true
+		}
+		if f := flag.Lookup(name); !hasValue && f != nil && !isBoolFlag(f) && len(args) > 0 {
+			value, hasValue, args = args[0], true, args[1:]
+		}
+		switch name {
+		case "id-offset":
+			idOffset, _ = strconv.ParseInt(value, 10, 64)
+			continue
+		case "max-workers", "autoscale":
+			if w, _ := strconv.Atoi(value); w > workers {
+				workers = w
+			}
+		}
+		if controllerFlags[name] {
+			continue
+		}
+		if hasValue {
+			flags = append(flags, "-"+name+"="+value)
+		} else {
+			flags = append(flags, "-"+name)
+		}
+	}
+	flags = append(flags, "-c="+strconv.Itoa(share(conc, i, agents)))
+	if num > 0 {
+		flags = append(flags, "-n="+strconv.Itoa(share(num, i, agents)))
+	}
+	if qps > 0 {
+		flags = append(flags, "-qps="+strconv.Itoa(share(qps, i, agents)))
+	}
+	// An agent's values span its workers, each making up to its n
+	// requests, or 1<<32 for runs of a duration.
+	perWorker := int64(1) << 32
+	if num > 0 {
+		perWorker = int64(num)
+	}
+	flags = append(flags, "-id-offset="+strconv.FormatInt(idOffset+int64(i)*int64(workers)*perWorker, 10))
+	return append(flags, args...)
+}
+
+// checkAgentArgs returns an error if args hold a flag agents do not
+// accept, a cmd: secret or a ${file:} reference.
+func checkAgentArgs(args []string) error {
+	for _, arg := range args {
+		if strings.Contains(arg, "${") {
+			return errors.New("agents do not substitute ${} references")
+		}
+	}
+	for len(args) > 0 {
+		arg := args[0]
+		if arg == "--" || arg == "-" || !strings.HasPrefix(arg, "-") {
+			break
+		}
+		args = args[1:]
+		name := strings.TrimLeft(arg, "-")
+		value, hasValue := "", false
+		if j := strings.Index(name, "="); j >= 0 {
+			name, value, hasValue = name[:j], name[j+1:], true
+		}
+		if !agentFlags[name] {
+			return fmt.Errorf("agents do not accept -%s", name)
+		}
+		if f := flag.Lookup(name); !hasValue && f != nil && !isBoolFlag(f) && len(args) > 0 {
+			value, args = args[0], args[1:]
+		}
+		switch name {
+		case "a":
+			if _, ok, _ := parseSecret(value, 0); ok {
+				return errors.New("agents do not fetch secrets")
+			}
+		case "graphql":
+			if strings.HasPrefix(value, "@") {
+				return errors.New("agents do not read -graphql files")
+			}
+		}
+	}
+	return nil
+}
+
+// share returns the share of agent i of agents of total, splitting it
+// as evenly as possible.
+func share(total, i, agents int) int {
+	s := total / agents
+	if i < total%agents {
+		s++
+	}
+	return s
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// runAgents makes the run described by args on every agent, each
+// making its share of the requests, and returns their reports. Once
+// ctx is done the agents are interrupted and report the requests made
+// until then.
+func runAgents(ctx context.Context, agents []string, token string, args []string, num, conc, qps int) ([]*boomer.Report, error) {
+	post := func(addr, path string, body []byte) (*http.Response, error) {
+		if !strings.Contains(addr, "://") {
+			addr = "http://" + addr
+		}
+		req, err := http.NewRequest("POST", strings.TrimSuffix(addr, "/")+path, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		return http.DefaultClient.Do(req)
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			for _, addr := range agents {
+				if res, err := post(addr, "/interrupt", nil); err == nil {
+					res.Body.Close()
+				}
+			}
+		case <-done:
+		}
+	}()
+
+	if err := checkAgentArgs(agentArgs(args, 0, len(agents), num, conc, qps)); err != nil {
+		return nil, err
+	}
+	reports := make([]*boomer.Report, len(agents))
+	errs := make([]error, len(agents))
+	var wg sync.WaitGroup
+	for i, addr := range agents {
+		wg.Add(1)
+		go func(i int, addr string) {
+			defer wg.Done()
+			body, _ := json.Marshal(agentRun{Args: agentArgs(args, i, len(agents), num, conc, qps)})
+			res, err := post(addr, "/run", body)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			defer res.Body.Close()
+			if res.StatusCode != http.StatusOK {
+				var msg bytes.Buffer
+				msg.ReadFrom(res.Body)
+				errs[i] = fmt.Errorf("agent %s: %s", addr, strings.TrimSpace(msg.String()))
+				return
+			}
+			var r boomer.Report
+			if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+				errs[i] = fmt.Errorf("agent %s: invalid report: %v", addr, err)
+				return
+			}
+			r.Name = addr
+			reports[i] = &r
+		}(i, addr)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return reports, nil
+}
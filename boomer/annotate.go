@@ -0,0 +1,103 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteGitHubAnnotations writes the failed checks of the run to w as
+// GitHub Actions error commands, and those that passed near their
+// limit as warnings, so they are shown on the pull request.
+func (r *Report) WriteGitHubAnnotations(w io.Writer) error {
+	ew := &errWriter{w: w}
+	for _, o := range r.Outcomes() {
+		if o.Verdict == Pass {
+			continue
+		}
+		level := "error"
+		if o.Verdict == Warn {
+			level = "warning"
+		}
+		ew.printf("::%s title=%s::%s\n", level, githubEscape(r.annotationTitle(o), true), githubEscape(o.Message, false))
+	}
+	return ew.err
+}
+
+func (r *Report) annotationTitle(o Outcome) string {
+	if r.Name != "" {
+		return "boom " + r.Name + ": " + o.Check
+	}
+	return "boom: " + o.Check
+}
+
+// githubEscape escapes s as the message, or a property if prop is set,
+// of a workflow command.
+func githubEscape(s string, prop bool) string {
+	pairs := []string{"%", "%25", "\r", "%0D", "\n", "%0A"}
+	if prop {
+		pairs = append(pairs, ":", "%3A", ",", "%2C")
+	}
+	return strings.NewReplacer(pairs...).Replace(s)
+}
+
+// codeQualityIssue is an issue of a GitLab Code Quality report.
+type codeQualityIssue struct {
+	Description string `json:"description"`
+	CheckName   string `json:"check_name"`
+	Fingerprint string `json:"fingerprint"`
+	Severity    string `json:"severity"`
+	Location    struct {
+		Path  string `json:"path"`
+		Lines struct {
+			Begin int `json:"begin"`
+		} `json:"lines"`
+	} `json:"location"`
+}
+
+// WriteGitLabCodeQuality writes the failed checks of the run to w as a
+// GitLab Code Quality report, with major severity, and those that
+// passed near their limit as minor issues, so they are shown on the
+// merge request. The issues are located at path, e.g. the file
+// defining the load test.
+func (r *Report) WriteGitLabCodeQuality(w io.Writer, path string) error {
+	issues := []codeQualityIssue{}
+	for _, o := range r.Outcomes() {
+		if o.Verdict == Pass {
+			continue
+		}
+		issue := codeQualityIssue{
+			Description: fmt.Sprintf("%s: %s", r.annotationTitle(o), o.Message),
+			CheckName:   o.Check,
+			Severity:    "major",
+		}
+		if o.Verdict == Warn {
+			issue.Severity = "minor"
+		}
+		sum := sha256.Sum256([]byte(r.Name + "\x00" + o.Check))
+		issue.Fingerprint = hex.EncodeToString(sum[:16])
+		issue.Location.Path = path
+		issue.Location.Lines.Begin = 1
+		issues = append(issues, issue)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(issues)
+}
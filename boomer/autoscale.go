@@ -0,0 +1,223 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"math"
+	"net/http"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// AutoScale configures growing and shrinking the number of workers
+// during a run, starting from C. Workers are added while the CPU used
+// by the process stays under CPUThreshold and the rate of requests is
+// below Qps, or Qps is not set. Workers are removed while the CPU used
+// exceeds CPUThreshold, as the generator is then saturated and its
+// measurements skewed.
+type AutoScale struct {
+	// MaxWorkers is the most workers run at once.
+	MaxWorkers int
+
+	// CPUThreshold is the fraction, between 0 and 1, of the available
+	// CPU the process may use. Defaults to 0.8. The CPU used is not
+	// measured on all platforms, in which case only the rate is.
+	CPUThreshold float64
+
+	// Interval is how often the number of workers is adjusted, a second
+	// by default.
+	Interval time.Duration
+}
+
+// ScaleInterval describes the workers running during an interval of
+// an auto-scaled run.
+type ScaleInterval struct {
+	// Second is the time the interval ended, in seconds since the
+	// start of the run.
+	Second float64 `json:"second"`
+
+	Workers int `json:"workers"`
+
+	// CPU is the fraction of the available CPU used by the process.
+	CPU float64 `json:"cpu"`
+
+	// RPS is the rate of requests completed.
+	RPS float64 `json:"rps"`
+}
+
+// maxWorkers returns the most workers the run may use.
+func (b *Boomer) maxWorkers() int {
+	if b.openModel() {
+		if b.MaxWorkers > 0 {
+			return b.MaxWorkers
+		}
+		if b.Qps > b.C {
+			return b.Qps
+		}
+		return b.C
+	}
+	if b.AutoScale != nil && b.AutoScale.MaxWorkers > b.C {
+		return b.AutoScale.MaxWorkers
+	}
+	return b.C
+}
+
+// scaler starts and retires workers.
+type scaler struct {
+	b       *Boomer
+	workers []*worker
+	wg      *sync.WaitGroup
+	ch      chan *http.Request
+
+	// retired receives a value for every worker to retire.
+	retired chan struct{}
+
+	// idle receives the workers that returned, to start again. They
+	// carry on counting their requests, keeping the values of seq
+	// distinct.
+	idle chan *worker
+
+	// active is the number of workers started and not asked to retire,
+	// peak the most there were at once.
+	active, peak int
+
+	// started is the number of workers started for the first time.
+	started int
+}
+
+// newScaler returns a scaler of workers taking their requests from ch.
+// Workers can only be retired if retire is set.
+func newScaler(b *Boomer, workers []*worker, wg *sync.WaitGroup, ch chan *http.Request, retire bool) *scaler {
+	s := &scaler{b: b, workers: workers, wg: wg, ch: ch}
+	if retire {
+		s.retired = make(chan struct{}, len(workers))
+		s.idle = make(chan *worker, len(workers))
+	}
+	return s
+}
+
+// start starts n more workers, restarting retired ones before fresh
+// ones. Retired workers still completing a request are not waited for.
+func (s *scaler) start(n int) {
+	for ; n > 0 && s.active < len(s.workers); n-- {
+		var w *worker
+		select {
+		case w = <-s.idle:
+		default:
+			if s.started == len(s.workers) {
+				return
+			}
+			w = s.workers[s.started]
+			s.started++
+		}
+		go s.run(w)
+		s.active++
+	}
+	if s.active > s.peak {
+		s.peak = s.active
+	}
+}
+
+func (s *scaler) run(w *worker) {
+	s.b.runWorker(w, s.wg, s.ch, s.retired)
+	if s.idle != nil {
+		s.idle <- w
+	}
+}
+
+// retire asks n workers to stop once idle, keeping at least one.
+func (s *scaler) retire(n int) {
+	for ; n > 0 && s.active > 1; n-- {
+		s.retired <- struct{}{}
+		s.active--
+	}
+}
+
+// mergeScaling sums the workers and rates of series of concurrent runs
+// by second.
+func mergeScaling(series ...[]ScaleInterval) []ScaleInterval {
+	seconds := make(map[float64]*ScaleInterval)
+	for _, s := range series {
+		for _, iv := range s {
+			sec := math.Round(iv.Second)
+			m, ok := seconds[sec]
+			if !ok {
+				m = &ScaleInterval{Second: sec}
+				seconds[sec] = m
+			}
+			m.Workers += iv.Workers
+			m.RPS += iv.RPS
+			// The runs share the process.
+			if iv.CPU > m.CPU {
+				m.CPU = iv.CPU
+			}
+		}
+	}
+	var merged []ScaleInterval
+	for _, iv := range seconds {
+		merged = append(merged, *iv)
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].Second < merged[j].Second })
+	return merged
+}
+
+// autoScale adjusts the number of workers every interval until stop is
+// closed, returning the series of intervals.
+func (s *scaler) autoScale(stop chan struct{}) []ScaleInterval {
+	cfg := s.b.AutoScale
+	threshold := cfg.CPUThreshold
+	if threshold <= 0 {
+		threshold = 0.8
+	}
+	interval := cfg.Interval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	start := time.Now()
+	last, lastCPU, lastDone := start, cpuTime(), atomic.LoadInt64(&s.b.completed)
+	var series []ScaleInterval
+	for {
+		select {
+		case <-stop:
+			return series
+		case <-t.C:
+		}
+		now, cpu, done := time.Now(), cpuTime(), atomic.LoadInt64(&s.b.completed)
+		elapsed := now.Sub(last).Seconds()
+		iv := ScaleInterval{
+			Second:  now.Sub(start).Seconds(),
+			Workers: s.active,
+			RPS:     float64(done-lastDone) / elapsed,
+		}
+		if cpu >= 0 && lastCPU >= 0 {
+			iv.CPU = (cpu - lastCPU).Seconds() / elapsed / float64(runtime.GOMAXPROCS(0))
+		}
+		series = append(series, iv)
+		last, lastCPU, lastDone = now, cpu, done
+
+		switch {
+		case iv.CPU > threshold:
+			s.retire((s.active + 9) / 10)
+		case s.b.Qps == 0 || iv.RPS < 0.95*float64(s.b.Qps):
+			s.start((s.active + 3) / 4)
+		}
+	}
+}
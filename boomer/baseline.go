@@ -0,0 +1,105 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"io"
+	"text/tabwriter"
+)
+
+// Comparison compares a metric of a run to that of a baseline run.
+type Comparison struct {
+	// Metric is the name of the metric: "average", "p50", "p90" and
+	// "p99" for latencies, in ms, "rps" or "error_rate".
+	Metric   string  `json:"metric"`
+	Baseline float64 `json:"baseline"`
+	Value    float64 `json:"value"`
+
+	// Change is how much worse the metric got, negative if it improved:
+	// relative to the baseline for latencies and the rate, e.g. 0.1 for
+	// 10% slower, and the difference for the error rate.
+	Change float64 `json:"change"`
+
+	// Regressed reports whether Change exceeds Max, the maximum
+	// regression.
+	Max       float64 `json:"max"`
+	Regressed bool    `json:"regressed"`
+}
+
+// Compare compares the latencies, rate and error rate of r to those of
+// baseline, flagging the metrics that got worse by more than max, e.g.
+// 0.1. Latencies are skipped if either run had no successful requests.
+func Compare(baseline, r *Report, max float64) []Comparison {
+	var cs []Comparison
+	add := func(metric string, base, value, change float64) {
+		cs = append(cs, Comparison{
+			Metric:    metric,
+			Baseline:  base,
+			Value:     value,
+			Change:    change,
+			Max:       max,
+			Regressed: change > max,
+		})
+	}
+	if baseline.hasLatencies() && r.hasLatencies() {
+		slower := func(metric string, base, value float64) {
+			if base > 0 {
+				add(metric, base, value, (value-base)/base)
+			}
+		}
+		slower("average", baseline.Average*1000, r.Average*1000)
+		for _, p := range []struct {
+			metric string
+			p      float64
+		}{{"p50", 50}, {"p90", 90}, {"p99", 99}} {
+			slower(p.metric, baseline.Percentile(p.p), r.Percentile(p.p))
+		}
+	}
+	if baseline.RPS > 0 {
+		add("rps", baseline.RPS, r.RPS, (baseline.RPS-r.RPS)/baseline.RPS)
+	}
+	base, value := baseline.ErrorRate(), r.ErrorRate()
+	add("error_rate", base, value, value-base)
+	return cs
+}
+
+// WriteDiff writes a table of the comparisons of a run to an earlier
+// one to w, a row per metric with its value in both runs and how much
+// it changed: relative to the earlier run, e.g. "+10.0%", and in
+// percentage points for the error rate.
+func WriteDiff(w io.Writer, cs []Comparison) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	ew := &errWriter{w: tw}
+	ew.printf("Metric\tBefore\tAfter\tChange\t\t\n")
+	for _, c := range cs {
+		regressed := ""
+		if c.Regressed {
+			regressed = "regressed"
+		}
+		switch c.Metric {
+		case "error_rate":
+			ew.printf("%s\t%4.2f%%\t%4.2f%%\t%+.2f pts\t%s\t\n", c.Metric, c.Baseline*100, c.Value*100, c.Change*100, regressed)
+		case "rps":
+			// Change counts a lower rate as worse.
+			ew.printf("%s\t%4.4f\t%4.4f\t%+.1f%%\t%s\t\n", c.Metric, c.Baseline, c.Value, -c.Change*100, regressed)
+		default:
+			ew.printf("%s\t%4.4f secs.\t%4.4f secs.\t%+.1f%%\t%s\t\n", c.Metric, c.Baseline/1000, c.Value/1000, c.Change*100, regressed)
+		}
+	}
+	if ew.err != nil {
+		return ew.err
+	}
+	return tw.Flush()
+}
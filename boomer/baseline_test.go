@@ -0,0 +1,94 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"bytes"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	baseline := &Report{
+		Lats:        []float64{10, 10, 10, 10, 20},
+		Average:     0.012,
+		RPS:         100,
+		StatusCodes: []StatusCode{{Code: 200, Count: 5}},
+	}
+	r := &Report{
+		Lats:        []float64{10, 10, 10, 10, 30},
+		Average:     0.014,
+		RPS:         95,
+		StatusCodes: []StatusCode{{Code: 200, Count: 5}},
+		Errors:      []Error{{Error: "refused", Count: 1}},
+	}
+	want := map[string]struct {
+		change    float64
+		regressed bool
+	}{
+		"average":    {1.0 / 6, true},
+		"p50":        {0, false},
+		"p90":        {0.5, true},
+		"p99":        {0.5, true},
+		"rps":        {0.05, false},
+		"error_rate": {1.0 / 6, true},
+	}
+	cs := Compare(baseline, r, 0.1)
+	if len(cs) != len(want) {
+		t.Fatalf("expected %v comparisons, found %+v", len(want), cs)
+	}
+	for _, c := range cs {
+		w := want[c.Metric]
+		if math.Abs(c.Change-w.change) > 1e-9 || c.Regressed != w.regressed {
+			t.Errorf("%v: expected change %v regressed %v, found %+v", c.Metric, w.change, w.regressed, c)
+		}
+	}
+	if r.Regressed() {
+		t.Errorf("expected no regression before the report is compared")
+	}
+}
+
+func TestBaseline(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	// A baseline that was much faster.
+	baseline := &Report{Lats: []float64{1e-6}, Average: 1e-9, RPS: 1e9}
+	r := runBoomer(t, &Boomer{Request: req, N: 10, C: 1, Baseline: baseline, MaxRegression: 0.1})
+	if !r.Regressed() {
+		t.Errorf("expected the run to regress, found %+v", r.Baseline)
+	}
+}
+
+func TestWriteDiff(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteDiff(&buf, []Comparison{
+		{Metric: "p99", Baseline: 200, Value: 250, Change: 0.25, Max: 0.1, Regressed: true},
+		{Metric: "rps", Baseline: 100, Value: 110, Change: -0.1, Max: 0.1},
+		{Metric: "error_rate", Baseline: 0.01, Value: 0.005, Change: -0.005, Max: 0.1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"0.2500 secs.", "+25.0%  regressed", "+10.0%", "0.50%  -0.50 pts"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected %q in the diff:\n%s", want, buf.String())
+		}
+	}
+}
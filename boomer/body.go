@@ -0,0 +1,172 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"crypto/sha256"
+	"hash"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+)
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// acceptEncoding returns the Accept-Encoding to send req with if it has
+// none: AcceptEncoding, the encodings MeasureCompression decodes, or
+// gzip where the transport would ask for it. Asking explicitly stops the
+// transport from transparently decompressing responses, for consume to
+// count both their size on the wire and decoded.
+func (b *Boomer) acceptEncoding(req *http.Request) string {
+	switch {
+	case req.Header.Get("Accept-Encoding") != "":
+		return ""
+	case b.AcceptEncoding != "":
+		return b.AcceptEncoding
+	case b.MeasureCompression:
+		return "gzip, deflate"
+	case b.DisableCompression || req.Method == "HEAD" || req.Header.Get("Range") != "":
+		return ""
+	}
+	return "gzip"
+}
+
+// consume reads as much of the response body as the enabled options
+// require and records what was learned about it in res.
+func (b *Boomer) consume(req *http.Request, resp *http.Response, res *result) error {
+	validate := b.Schema != nil && (b.SchemaSample <= 0 || rand.Float64() < b.SchemaSample)
+	if !b.ReadAll && b.validators == nil && !b.MeasureCompression && !b.HashBodies && b.SlowRate <= 0 && !validate && b.Proto == nil && !b.GRPC && !b.GraphQL && !res.keepBody && len(b.BodyChecks) == 0 {
+		return nil
+	}
+
+	var body io.Reader = resp.Body
+	if b.SlowRate > 0 {
+		body = newSlowReader(body, b.SlowRate)
+	}
+	var limit *io.LimitedReader
+	if b.MaxBody > 0 {
+		limit = &io.LimitedReader{R: body, N: b.MaxBody}
+		body = limit
+	}
+	wire := &countingReader{r: body}
+	body = wire
+	// A compressed body cut off by MaxBody leaves its decoder short of
+	// input, which is the truncation rather than an error.
+	cutOff := func(err error) bool {
+		return err == io.ErrUnexpectedEOF && limit != nil && limit.N == 0
+	}
+	res.encoding = resp.Header.Get("Content-Encoding")
+	res.compressionMeasured = b.MeasureCompression
+	if !b.NoDecompress {
+		var err error
+		switch res.encoding {
+		case "gzip":
+			body, err = gzip.NewReader(wire)
+		case "deflate":
+			body, err = zlib.NewReader(wire)
+		}
+		if err == io.EOF {
+			// An empty body.
+			body, err = wire, nil
+		}
+		if cutOff(err) {
+			res.truncated, res.wireBytes = true, wire.n
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	var h hash.Hash
+	var buf *bytes.Buffer
+	var dst []io.Writer
+	if b.validators != nil || b.HashBodies {
+		h = sha256.New()
+		dst = append(dst, h)
+	}
+	if validate || b.Proto != nil || b.GraphQL || res.keepBody || len(b.BodyChecks) > 0 {
+		buf = new(bytes.Buffer)
+		dst = append(dst, buf)
+	}
+	if len(dst) == 0 {
+		dst = append(dst, ioutil.Discard)
+	}
+	n, err := io.Copy(io.MultiWriter(dst...), body)
+	switch {
+	case cutOff(err):
+		res.truncated = true
+	case err != nil:
+		return err
+	case limit != nil && limit.N == 0:
+		// The cap was reached; the body was truncated if there is more.
+		var p [1]byte
+		if m, _ := limit.R.Read(p[:]); m > 0 {
+			res.truncated = true
+		}
+	}
+
+	res.wireBytes, res.bodyBytes = wire.n, n
+	if res.keepBody {
+		res.body = buf.Bytes()
+	}
+	if h != nil {
+		copy(res.bodySum[:], h.Sum(nil))
+		res.hashed = true
+	}
+	if b.GraphQL {
+		if err := graphqlError(buf.Bytes()); err != nil {
+			return err
+		}
+	}
+	if len(b.BodyChecks) > 0 {
+		res.bodyChecked = true
+		res.failedBodyChecks = b.checkBody(buf.Bytes())
+	}
+	if validate {
+		res.schemaChecked = true
+		res.schemaErr = b.Schema.ValidateJSON(buf.Bytes())
+	}
+	if b.Proto != nil {
+		data := buf.Bytes()
+		if b.GRPC {
+			data = grpcMessage(data)
+		}
+		doc, err := b.Proto.Decode(data)
+		if err != nil {
+			res.decodeErr = true
+		} else {
+			res.failedFieldChecks = b.checkFields(doc)
+		}
+	}
+	if b.validators != nil {
+		res.validatorMismatch = !b.validators.check(req.URL.String(), resp.Header, res.bodySum)
+	}
+	return nil
+}
@@ -12,31 +12,167 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-// Package boomer provides commands to run load tests and display results.
+// Package boomer runs HTTP load tests and reports their results. A
+// Boomer configures a run, Run makes it and returns its Report, and the
+// Write methods of the report render it, e.g.
+//
+//	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+//	b := &boomer.Boomer{Request: req, N: 1000, C: 50}
+//	report, err := b.Run(ctx)
+//	if err != nil {
+//		...
+//	}
+//	report.WriteText(os.Stdout)
+//
+// The package prints nothing itself unless Progress or Live is set.
 package boomer
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
 	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"os"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/rakyll/pb"
 )
 
-// client is the http.Client that will be used to make all requests
-// to the destination.
-var client *http.Client
-
 type result struct {
+	start         time.Time
 	err           error
 	statusCode    int
 	duration      time.Duration
 	contentLength int64
+
+	// proto is the HTTP version of the response, e.g. "HTTP/2.0".
+	proto string
+
+	// stream holds the messages of a streaming gRPC call.
+	stream *streamResult
+
+	// endpoint identifies the URL the request was sent to, and shard
+	// its host if requests are spread over Hosts.
+	endpoint string
+	shard    string
+	method   string
+
+	// target, targetName and targetIndex are the URL, name and index
+	// of the endpoint the request was sent to if requests are spread
+	// over Endpoints or steps.
+	target      string
+	targetName  string
+	targetIndex int
+
+	// keepBody is set if the response body is to be kept in body, for
+	// the values of a step to be extracted from it.
+	keepBody bool
+	body     []byte
+
+	// trace holds the W3C trace context sent with the request, if
+	// TraceContext is set.
+	trace *traceContext
+
+	// validatorMismatch is set if the response carried a strong ETag
+	// already seen with a different body.
+	validatorMismatch bool
+
+	// wireBytes and bodyBytes are the sizes of the response body as
+	// transferred and after decoding, and encoding is its
+	// Content-Encoding. Only set if the body was read.
+	// compressionMeasured is set if the response counts towards the
+	// compression stats.
+	wireBytes, bodyBytes int64
+	encoding             string
+	compressionMeasured  bool
+
+	// phase is the phase the request is in, accessed atomically.
+	phase int32
+
+	// marks are the times of the events of the request, in Unix
+	// nanoseconds, accessed atomically.
+	marks [numMarks]int64
+
+	// timeout is the phase at which the request timed out, if it did,
+	// and timeoutLimit the timeout that expired.
+	timeout, timeoutLimit string
+
+	// dnsLookups and dnsFailures count the DNS lookups made to dial
+	// connections for the request and those that failed, accessed
+	// atomically.
+	dnsLookups, dnsFailures int32
+
+	// handshakeFailed is set if the TLS handshake of the connection
+	// dialed for the request failed, accessed atomically.
+	handshakeFailed int32
+
+	// family is the address family of the connection the request was
+	// sent over, "IPv4" or "IPv6".
+	family string
+
+	// newConn is set if a new connection had to be dialed for the
+	// request rather than reusing an idle one.
+	newConn bool
+
+	// redirectCodes are the status codes of the redirects followed,
+	// and lastHop the time the last of them was.
+	redirectCodes []int
+	lastHop       time.Time
+
+	// retries is the number of times the request was retried, and
+	// retryFailed is set if it still failed after them.
+	retries     int
+	retryFailed bool
+
+	// rampPhase is the phase of the Ramp the request started in, if the
+	// run is ramped.
+	rampPhase int32
+
+	// stage is the number, from 1, of the stage the request started in,
+	// if the run is in Stages.
+	stage int32
+
+	// failedChecks are the indexes of the header checks the response
+	// did not pass.
+	failedChecks []int
+
+	// schemaChecked is set if the body was validated against the
+	// schema, and schemaErr holds the violation found, if any.
+	schemaChecked bool
+	schemaErr     error
+
+	// decodeErr is set if the body could not be decoded for field
+	// checks, and failedFieldChecks are the indexes of the field checks
+	// the body did not pass.
+	decodeErr         bool
+	failedFieldChecks []int
+
+	// bodyChecked is set if the body checks were evaluated against the
+	// body, and failedBodyChecks are the indexes of the ones it did not
+	// pass.
+	bodyChecked      bool
+	failedBodyChecks []int
+
+	// truncated is set if reading the body stopped at MaxBody.
+	truncated bool
+
+	// aborted is the phase at which the request was deliberately
+	// cancelled, or empty if it ran to completion.
+	aborted string
+
+	// bodySum is the SHA-256 digest of the decoded response body.
+	// Only set if hashed is true.
+	bodySum [sha256.Size]byte
+	hashed  bool
 }
 
 type Boomer struct {
@@ -45,148 +181,1339 @@ type Boomer struct {
 
 	RequestBody string
 
+	// BodyFunc, if not nil, produces the body of every request instead
+	// of RequestBody. It is called with the index of the worker making
+	// the request and the number of requests the worker made before,
+	// and must be safe to call from several goroutines.
+	BodyFunc func(worker, iteration int) io.Reader
+
+	// BodyFile, if set, is the path of a file sent as the body of every
+	// request instead of RequestBody. It is streamed from disk for each
+	// request rather than held in memory, so it can be large.
+	BodyFile string
+
 	// N is the total number of requests to make.
 	N int
 
 	// C is the concurrency level, the number of concurrent workers to run.
 	C int
 
-	// Timeout in seconds.
+	// Timeout in ms. It bounds the whole request, from dialing to
+	// reading the response body. Requests that time out are counted
+	// separately from other errors, by the phase they timed out in and
+	// the timeout that expired.
 	Timeout int
 
+	// DialTimeout, TLSTimeout and HeaderTimeout, if set, bound dialing
+	// a connection, resolving its host included, its TLS handshake, and
+	// waiting for the response headers once the request is written,
+	// within Timeout. Dialing and handshaking are otherwise bounded by
+	// Timeout alone.
+	DialTimeout, TLSTimeout, HeaderTimeout time.Duration
+
 	// Qps is the rate limit.
 	Qps int
 
+	// Burst, if set with Qps, paces the requests by a token bucket
+	// shared by the workers instead of handing them out on schedule:
+	// every request takes a token before it is sent. The bucket fills
+	// at the rate of the run, following its ramps and Stages, and holds
+	// Burst tokens, so a backlog of requests, e.g. once every worker was
+	// busy, is sent at most Burst at once rather than all back to back.
+	// The wait for a token is not part of the latency of a request.
+	// Burst has no effect with OpenModel, whose requests never wait for
+	// a worker.
+	Burst int
+
+	// Warmup, if positive, is how long requests are made for before
+	// the run, to warm up connection pools and the caches of the
+	// target. Their results are left out of the report, which only
+	// counts them. They are made at the Qps rate, or that of the first
+	// of the Stages, without ramping. Their seq and counter values come
+	// before those of the run, and they draw rows from the Feeds, so
+	// the rows of a Unique feed they use are not used by the run.
+	Warmup time.Duration
+
+	// Duration, if positive, is how long the run makes requests for,
+	// with N left 0, or at most, with N set. Requests in flight once it
+	// elapses are completed and no more are started.
+	Duration time.Duration
+
+	// Poisson, if set with Qps, makes the requests arrive as a Poisson
+	// process, the intervals between them drawn from an exponential
+	// distribution of the same mean, in bursts and lulls rather than
+	// evenly. Their rate still follows Ramp and Stages. The Schedule of
+	// the report compares the intervals drawn to those delivered.
+	Poisson bool
+
+	// OpenModel, if set with Qps, starts requests at Qps per second
+	// however long earlier ones take, instead of limiting the run to
+	// the requests C workers can make. An arrival finding every worker
+	// busy starts another, up to MaxWorkers. AutoScale is ignored.
+	OpenModel bool
+
+	// Ramp, if set, ramps the load up at the start of the run and down
+	// at its end. Ramping workers rather than the rate disables
+	// AutoScale.
+	Ramp *Ramp
+
+	// Stages, if set, makes the run a series of steps at the rate of
+	// each stage for its duration, replacing N and Qps. Ramp is ignored.
+	// Report.Stages holds a report of each stage.
+	Stages []Stage
+
+	// MaxWorkers bounds the workers of an OpenModel run. Arrivals
+	// finding them all busy wait and are reported late. Defaults to the
+	// larger of C and Qps, enough for requests taking up to a second.
+	MaxWorkers int
+
 	// AllowInsecure is an option to allow insecure TLS/SSL certificates.
 	AllowInsecure bool
 
-	// DisableCompression is an option to disable compression in response
+	// ClientCert, if set, is the certificate presented to servers asking
+	// for one, for mutual TLS. RootCAs, if set, are the authorities
+	// server certificates are verified against instead of those of the
+	// system.
+	ClientCert *tls.Certificate
+	RootCAs    *x509.CertPool
+
+	// ServerName, if set, is the name sent in the TLS handshake (SNI)
+	// and that server certificates are verified for, instead of the
+	// host of the URL. Set the Host of Request to send another Host
+	// header, e.g. to reach a virtual host through the address of a
+	// shared ingress.
+	ServerName string
+
+	// DisableCompression stops requests from asking for gzip encoded
+	// responses. Leave it unset to measure decompressed application
+	// throughput; set it to observe the raw wire behavior of the target.
 	DisableCompression bool
 
+	// AcceptEncoding, if set, is the Accept-Encoding requests are sent
+	// with, e.g. "gzip" or "br", unless Request has one. Requests that
+	// do not set it ask for gzip as the transport would.
+	AcceptEncoding string
+
+	// NoDecompress leaves the bodies of compressed responses encoded:
+	// they are read, checked and hashed as received, saving the cost of
+	// decoding them. Bodies are otherwise decoded if gzip or deflate
+	// encoded; others are always left as received.
+	NoDecompress bool
+
 	// DisableKeepAlives is an option to prevents re-use of TCP connections between different HTTP requests
 	DisableKeepAlives bool
 
-	// Output represents the output type. If "csv" is provided, the
-	// output will be dumped as a csv stream.
-	Output string
+	// HTTP2 forces HTTP/2: negotiated with ALPN for https URLs, failing
+	// with servers that do not support it, and spoken with prior
+	// knowledge (h2c) for http URLs. Requests use HTTP/1.1 otherwise.
+	HTTP2 bool
+
+	// GRPC makes the requests unary gRPC calls of the method at the path
+	// of Request's URL, e.g. /pkg.Service/Method, sending RequestBody as
+	// the serialized request message and the headers of Request as
+	// metadata. Calls use HTTP/2, as with HTTP2. StatusCodes counts
+	// their gRPC status codes, or the HTTP status of responses other
+	// than 200.
+	GRPC bool
 
-	// ProxyAddr is the address of HTTP proxy server in the format on "host:port".
+	// GRPCStream, with GRPC, makes streaming calls: "server" reads all
+	// the messages of server-streaming calls, and "bidi" sends
+	// RequestBody StreamMessages times, once by default, on
+	// bidirectional-streaming calls, each after reading the response to
+	// the previous one. Report.Stream describes their messages.
+	GRPCStream     string
+	StreamMessages int
+
+	// GraphQL checks the responses as those of GraphQL operations, e.g.
+	// sent with a RequestBody from GraphQL.Body: those carrying errors
+	// are counted as errors, by their first message, even with a 200
+	// status. Implies ReadAll.
+	GraphQL bool
+
+	// WebSocket makes each request a WebSocket session with Request's
+	// URL, e.g. ws://localhost/chat: the connection is upgraded and the
+	// WSMessages, or else RequestBody, are sent in turn, each after the
+	// reply to the previous one, before the session is closed. Failed
+	// upgrades are errors. Report.Stream describes the replies.
+	WebSocket  bool
+	WSMessages []string
+
+	// Transport, if set, makes the requests instead of a transport
+	// configured by the options of Boomer, e.g. to use a version of
+	// HTTP the standard library does not implement, such as HTTP/3 with
+	// the RoundTripper of github.com/quic-go/quic-go/http3. Timeout
+	// still applies; Validate rejects the options of the transport it
+	// replaces, such as Resolve, DialTimeout or ClientCert.
+	Transport http.RoundTripper
+
+	// GracePeriod is how long the requests in flight when the context
+	// of Run is done are given to complete before they are cancelled,
+	// counted as aborted in the "interrupted" phase. Zero waits for
+	// them to complete.
+	GracePeriod time.Duration
+
+	// Progress enables showing a progress bar on stdout while the run
+	// is in progress.
+	Progress bool
+
+	// ProxyAddr is the URL of the proxy to send requests through, an
+	// HTTP proxy for the http and https schemes, tunneling https
+	// requests with CONNECT, or a SOCKS5 proxy for socks5 and socks5h.
+	// Credentials in its user info authenticate with the proxy.
 	// Optional.
 	ProxyAddr *url.URL
 
+	// ProxyFromEnv sends requests through the proxies named by
+	// the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables
+	// unless ProxyAddr is set.
+	ProxyFromEnv bool
+
 	// ReadAll determines whether the body of the response needs
 	// to be fully consumed.
 	ReadAll bool
 
-	bar     *pb.ProgressBar
-	results chan *result
+	// CheckValidators enables verification that responses sharing a
+	// strong ETag for the same URL have identical bodies. Weak ETags and
+	// Last-Modified values only promise equivalent bodies, and are not
+	// checked. Implies ReadAll.
+	CheckValidators bool
+
+	// MeasureCompression enables reporting of the compression ratio of
+	// response bodies. Requests ask for gzip or deflate encoding and
+	// responses that arrive uncompressed are flagged. Implies ReadAll.
+	MeasureCompression bool
+
+	// HashBodies enables counting the distinct response bodies returned
+	// by each endpoint, to detect backends that answer inconsistently.
+	// Implies ReadAll.
+	HashBodies bool
+
+	// AbortRate is the probability, between 0 and 1, that a request is
+	// deliberately cancelled after its response headers arrive or while
+	// its body is being read, to exercise the server's handling of
+	// client aborts.
+	AbortRate float64
+
+	// SlowRate, if positive, limits the rate in bytes per second at
+	// which each request body is written and each response body is
+	// read, to simulate slow clients. Implies ReadAll.
+	SlowRate int
+
+	// ChurnRate, if positive, is the number of connections per second
+	// opened and immediately closed alongside the requests, to stress
+	// the target's listener queue and TLS termination.
+	ChurnRate int
+
+	// IdleConnTimeout is how long an idle keep-alive connection stays
+	// in the pool before being closed. Zero means no limit.
+	IdleConnTimeout time.Duration
+
+	// MaxIdleConnsPerHost is the number of idle connections kept per
+	// host. If zero, the net/http default of 2 is used, which makes
+	// workers beyond the second dial again after most requests.
+	MaxIdleConnsPerHost int
+
+	// MaxConnsPerHost limits the connections open to each host,
+	// including those in use. Requests beyond it wait for a connection
+	// to be freed, and the time they wait is reported in PoolWaits, as
+	// it is spent in the client rather than the server. Zero means no
+	// limit.
+	MaxConnsPerHost int
+
+	// Resolve pins the addresses, as host:port, of the target to the
+	// IP addresses to connect to instead of those their host resolves
+	// to, e.g. to load a single backend behind a load balancer. The
+	// addresses of a host are tried in order.
+	Resolve map[string][]string
+
+	// DNSCacheTTL, if set, caches the addresses hosts resolve to for
+	// that long, for connections to be dialed without a lookup each,
+	// and resolves them again once expired, to follow a failover.
+	DNSCacheTTL time.Duration
+
+	// IPVersion, if 4 or 6, restricts the connections dialed to IPv4
+	// or IPv6 addresses.
+	IPVersion int
+
+	// Retry, if set, retries the requests that fail. The latency of a
+	// retried request spans all its attempts. Bodies are held in
+	// memory to be sent again.
+	Retry *Retry
+
+	// NoRedirects stops redirects from being followed: the redirect
+	// responses are reported as they are. Otherwise up to MaxRedirects
+	// are followed, DefaultMaxRedirects if zero, and requests needing
+	// more fail.
+	NoRedirects  bool
+	MaxRedirects int
+
+	// LastHopLatency measures the latency of requests that were
+	// redirected from the request of their final hop, leaving out the
+	// redirects followed.
+	LastHopLatency bool
+
+	// CookieJar gives every worker a cookie jar, so that the cookies
+	// set by responses, e.g. session IDs, are sent with the following
+	// requests of the worker as a browser would.
+	CookieJar bool
+
+	// Cookies are set in the cookie jar of every worker before its
+	// first request to each host. They imply CookieJar.
+	Cookies []*http.Cookie
+
+	// HeaderChecks are assertions evaluated against the headers of
+	// every response. Their outcomes are counted in the report.
+	HeaderChecks []HeaderCheck
+
+	// Schema, if not nil, is the JSON Schema response bodies are
+	// validated against. Implies ReadAll.
+	Schema *Schema
+
+	// SchemaSample is the fraction, between 0 and 1, of responses
+	// validated against Schema. Zero validates every response.
+	SchemaSample float64
+
+	// Proto, if not nil, decodes protocol buffer response bodies so that
+	// FieldChecks can be evaluated against them. Implies ReadAll.
+	Proto *ProtoMessage
+
+	// FieldChecks are assertions evaluated against every decoded
+	// response body. Their outcomes are counted in the report.
+	FieldChecks []FieldCheck
+
+	// BodyChecks are assertions evaluated against the body of every
+	// response. Their outcomes are counted in the report, apart from
+	// errors. Implies ReadAll.
+	BodyChecks []BodyCheck
+
+	// Template enables rendering the URL path and query and the header
+	// values of Request and RequestBody as text/template templates
+	// before every request. The function seq yields a number that
+	// strictly increases with each request of a worker and is never
+	// repeated by another worker. The function counter yields the next
+	// value of the named counter shared by all workers, e.g. {{counter
+	// "user"}}. The functions uuid, randInt, now and env yield a random
+	// UUID, a random integer in a range, the time and an environment
+	// variable.
+	Template bool
+
+	// Scenario, if set, is the sequence of requests each worker makes
+	// in turn, as a virtual user, starting over after the last, in
+	// place of Request and Endpoints. The steps are always rendered as
+	// templates. Report.Endpoints breaks the latency down per step.
+	Scenario []Step
+
+	// MaxBody, if positive, is the number of bytes of a response body
+	// read at most. Reading stops at the cap and the response is counted
+	// as truncated, protecting the generator from unexpectedly large
+	// bodies.
+	MaxBody int64
+
+	// KeepLatencies enables retaining the latency of every successful
+	// request in Report.Lats, e.g. to write them with WriteCSV, and
+	// computing the percentiles from them exactly. Otherwise they are
+	// estimated within 1% from Report.Sketch, in bounded memory.
+	KeepLatencies bool
+
+	// TraceContext enables starting a trace per request, sent as a W3C
+	// traceparent header, whose IDs are in the request's Record, e.g. for
+	// NewOTLPSink to export client spans.
+	TraceContext bool
+
+	// KeepRecords enables retaining a Record of every request made, to
+	// be iterated over with Report.Records once the run is done.
+	KeepRecords bool
+
+	// Tags are attached to the Record of every request.
+	Tags map[string]string
+
+	// Tokens set their tokens as headers of every request, before it is
+	// signed.
+	Tokens []*TokenSource
+
+	// SigV4, if set, signs every request with AWS Signature Version 4
+	// after its body and headers are final.
+	SigV4 *SigV4
+
+	// Redact, if set, masks secrets in the endpoints and errors of the
+	// report and its records.
+	Redact *Redactor
+
+	// LatencyBudget enables attributing the latency of requests to the
+	// DNS, connect, TLS, server wait and transfer phases, per endpoint.
+	LatencyBudget bool
+
+	// MaxMem, if positive, is the number of bytes of heap the process
+	// should stay under. Past 80% of it, the report retains less: first
+	// dropping records and latency budget samples, then keeping fewer
+	// of the latencies retained by KeepLatencies. The report notes what
+	// was dropped in Degraded.
+	MaxMem int64
+
+	// Feeds fill in templates with rows of data, using {{feed "name"
+	// "column"}}. The run stops early once a Unique feed is exhausted.
+	Feeds []*Feed
+
+	// Endpoints, if set, are the URLs requests are spread over in
+	// proportion to their weights, in place of the URL and method of
+	// Request. Report.Endpoints breaks the latency down per endpoint.
+	Endpoints []Endpoint
+
+	// InOrder sends requests to the Endpoints in turn, in the order
+	// they are listed, rather than in proportion to their weights.
+	InOrder bool
+
+	// Hosts, if set, are the hosts requests are spread over evenly, in
+	// turn, replacing the host of Request's URL. Report.Shards breaks
+	// the latency down per host.
+	Hosts []string
+
+	// Baseline, if set, is the report of a run to compare this run's
+	// with in Report.Baseline, flagging the metrics that regressed by
+	// more than MaxRegression, e.g. 0.1.
+	Baseline      *Report
+	MaxRegression float64
+
+	// Thresholds are the limits the metrics of the run are checked
+	// against in Report.Thresholds.
+	Thresholds []Threshold
+
+	// Sinks receive the Record of every request as the run progresses,
+	// and the report once it is done.
+	Sinks []Sink
+
+	// Checkpoint, if set, is the file the state of the run is saved to
+	// every CheckpointEvery, DefaultCheckpointEvery if zero, so that the
+	// run can be resumed if interrupted. It is removed once the run
+	// completes. Records, churn, stalls and scaling are not saved.
+	Checkpoint      string
+	CheckpointEvery time.Duration
+
+	// Resume, if set, is the state of an interrupted run, as read by
+	// ReadCheckpoint, to continue: only the requests of N it did not
+	// make are made, and the report covers both runs. Templates carry
+	// on with the seq and counter values, and the rows of feeds not
+	// Random, where the interrupted run left off, and staged runs with
+	// the rest of the stage it was in.
+	Resume *Report
+
+	// AutoScale, if set, grows and shrinks the number of workers during
+	// the run depending on the CPU left to the process, starting from C.
+	AutoScale *AutoScale
+
+	// StallAfter is how long a worker may take to make a request before
+	// the watchdog counts it as stalled, e.g. on a leaked connection or
+	// hung dial. Defaults to ten times Timeout, but at least a second;
+	// the watchdog is off if neither is set, or StallAfter is negative.
+	StallAfter time.Duration
+
+	// StallLog, if set, receives the stack trace of every stalled
+	// worker.
+	StallLog io.Writer
+
+	// SLO, if set, is the objective the burn rate of the run is
+	// reported against.
+	SLO *SLO
+
+	// IDOffset is added to the values yielded by the seq and counter
+	// template functions. Load generators sharing a target are given
+	// disjoint ranges by using multiples of IDRange as their offsets.
+	IDOffset int64
+
+	// Name identifies the run in reports when several are made at once.
+	Name string
+
+	// Interval is the length of the intervals the report summarizes
+	// the run in, DefaultInterval if zero.
+	Interval time.Duration
+
+	// Live, if not nil, shows the progress of the run on it every
+	// second in place of the progress bar, with the rate, the p50, p95
+	// and p99 latencies and the error rate of its last 5 seconds. The
+	// view is redrawn in place if Live is a terminal and written as a
+	// line per second otherwise. It is not shown for RunAll of several
+	// runs.
+	Live io.Writer
+
+	// client is the http.Client that will be used to make all requests
+	// to the destination.
+	client *http.Client
+
+	bar        *pb.ProgressBar
+	view       *liveView
+	noProgress bool
+	results    chan *result
+	validators *validatorCache
+	churnStats *ChurnStats
+	dns        *dnsCache
+	bucket     *tokenBucket
+	counters   *counterSet
+	picker     *endpointPicker
+	stalls     int
+	completed  int64
+	scaling    []ScaleInterval
+	schedule   *ScheduleStats
+	arrivals   poissonArrivals
+
+	// reqCtx is the context of the requests of the run, cancelled
+	// once its grace period elapses.
+	reqCtx context.Context
+
+	// inFlight is the number of requests sent and not yet done,
+	// accessed atomically.
+	inFlight int64
+
+	// live holds the *Report collecting the results of the run.
+	live atomic.Value
+
+	// rampPhase is the phase of the Ramp requests are started in,
+	// accessed atomically.
+	rampPhase int32
+
+	// stage is the number, from 1, of the stage requests are started
+	// in, accessed atomically.
+	stage int32
+
+	// stagesDone is the number of Stages the run resumed had made.
+	stagesDone int
+
+	// seqBase is added to the values of seq, for those of the run to
+	// follow those of its warm-up and of the run it resumes. warmingUp
+	// is set during the warm-up, whose requests count the values they
+	// take in warmupSeq, accessed atomically.
+	seqBase   int64
+	warmingUp bool
+	warmupSeq int64
+
+	// stopping is set once the run is to stop before making all its
+	// requests, for the reason held by stopReason. Accessed atomically.
+	stopping   int32
+	stopReason atomic.Value
+
+	peakWorkers int
+
+	// ticking is closed to stop the progress of a run for a Duration.
+	ticking chan struct{}
 }
 
 func (b *Boomer) startProgress() {
-	if b.Output != "" {
+	if b.view != nil {
+		b.view.run()
+		return
+	}
+	if !b.Progress || b.noProgress {
 		return
 	}
-	b.bar = pb.New(b.N)
+	if b.N == 0 {
+		// The progress of a run for a Duration is the time elapsed.
+		b.bar = pb.New(int(b.Duration / time.Second))
+		b.ticking = make(chan struct{})
+		go tickProgress(b.bar, b.ticking)
+	} else {
+		b.bar = pb.New(b.N)
+	}
 	b.bar.Format("Bom !")
 	b.bar.Start()
 }
 
+// tickProgress advances bar every second until stop is closed.
+func tickProgress(bar *pb.ProgressBar, stop chan struct{}) {
+	t := time.NewTicker(time.Second)
+	defer t.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+			bar.Increment()
+		}
+	}
+}
+
 func (b *Boomer) finalizeProgress() {
-	if b.Output != "" {
+	if b.view != nil {
+		b.view.finish()
+		b.view = nil
+		return
+	}
+	if !b.Progress || b.noProgress {
 		return
 	}
+	if b.ticking != nil {
+		close(b.ticking)
+		b.ticking = nil
+	}
 	b.bar.Finish()
 }
 
 func (b *Boomer) incProgress() {
-	if b.Output != "" {
+	atomic.AddInt64(&b.completed, 1)
+	if !b.Progress || b.noProgress || b.view != nil || b.N == 0 {
 		return
 	}
 	b.bar.Increment()
 }
 
-// Run makes all the requests, prints the summary. It blocks until
-// all work is done.
-func (b *Boomer) Run() *Report {
-	b.results = make(chan *result, b.N)
-	b.startProgress()
+// Run makes all the requests of the run and returns its report. It
+// blocks until all work is done, or until ctx is done, which stops the
+// run early: no more requests are sent, those in flight are given
+// GracePeriod to complete and the report of the requests made, marked
+// Partial, is returned with the error of ctx.
+// An error is returned without a report if b is not a valid run.
+func (b *Boomer) Run(ctx context.Context) (*Report, error) {
+	if err := b.Validate(); err != nil {
+		return nil, err
+	}
+	r := b.run(ctx)
+	return r, ctx.Err()
+}
 
-	start := time.Now()
+// Validate returns an error if b is not a valid run, as Run does
+// before making any request.
+func (b *Boomer) Validate() error {
+	switch {
+	case b.Request == nil:
+		return errors.New("boomer: no Request")
+	case b.N <= 0 && b.Duration <= 0 && len(b.Stages) == 0:
+		return errors.New("boomer: one of N, Duration or Stages must be set")
+	case b.C <= 0 && b.Ramp == nil:
+		return errors.New("boomer: C must be positive")
+	case b.IPVersion != 0 && b.IPVersion != 4 && b.IPVersion != 6:
+		return errors.New("boomer: IPVersion must be 4 or 6")
+	}
+	if b.Transport != nil {
+		if opts := b.transportOptions(); len(opts) > 0 {
+			return errors.New("boomer: " + strings.Join(opts, ", ") + " cannot be set with Transport")
+		}
+	}
+	if b.Template || len(b.Scenario) > 0 {
+		return b.ParseTemplates()
+	}
+	return nil
+}
+
+// transportOptions returns the names of the options set that configure
+// the transport of the run, which a Transport replaces.
+func (b *Boomer) transportOptions() []string {
+	var opts []string
+	for _, o := range []struct {
+		name string
+		set  bool
+	}{
+		{"Resolve", len(b.Resolve) > 0},
+		{"DNSCacheTTL", b.DNSCacheTTL != 0},
+		{"IPVersion", b.IPVersion != 0},
+		{"DialTimeout", b.DialTimeout != 0},
+		{"TLSTimeout", b.TLSTimeout != 0},
+		{"HeaderTimeout", b.HeaderTimeout != 0},
+		{"AllowInsecure", b.AllowInsecure},
+		{"ClientCert", b.ClientCert != nil},
+		{"RootCAs", b.RootCAs != nil},
+		{"ServerName", b.ServerName != ""},
+		{"ProxyAddr", b.ProxyAddr != nil},
+		{"DisableKeepAlives", b.DisableKeepAlives},
+		{"IdleConnTimeout", b.IdleConnTimeout != 0},
+		{"MaxIdleConnsPerHost", b.MaxIdleConnsPerHost != 0},
+		{"MaxConnsPerHost", b.MaxConnsPerHost != 0},
+		{"HTTP2", b.HTTP2},
+	} {
+		if o.set {
+			opts = append(opts, o.name)
+		}
+	}
+	return opts
+}
+
+// stopOnDone stops the run once ctx is done, and cancels the requests
+// still in flight after GracePeriod, until the returned function is
+// called.
+func (b *Boomer) stopOnDone(ctx context.Context) func() {
+	reqCtx, cancel := context.WithCancel(b.Request.Context())
+	b.reqCtx = reqCtx
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			b.stop(ctx.Err().Error())
+		case <-done:
+			return
+		}
+		if b.GracePeriod <= 0 {
+			return
+		}
+		t := time.NewTimer(b.GracePeriod)
+		defer t.Stop()
+		select {
+		case <-t.C:
+			cancel()
+		case <-done:
+		}
+	}()
+	return func() {
+		close(done)
+		cancel()
+	}
+}
+
+// interrupted reports whether the requests in flight were cancelled
+// once the grace period of a stopped run elapsed.
+func (b *Boomer) interrupted() bool {
+	return b.reqCtx != nil && b.reqCtx.Err() != nil
+}
+
+func (b *Boomer) run(ctx context.Context) *Report {
+	staged := len(b.Stages) > 0
+	if staged {
+		n, qps, ramp, stages := b.N, b.Qps, b.Ramp, b.Stages
+		defer func() { b.N, b.Qps, b.Ramp, b.Stages, b.stagesDone = n, qps, ramp, stages, 0 }()
+		if b.Resume != nil {
+			// Carry on from where the interrupted run was in the stage
+			// it was in.
+			b.Stages, b.stagesDone = remainingStages(b.Stages, b.Resume.requests())
+		}
+		b.N, b.Qps = stagesRun(b.Stages)
+		b.Ramp = nil
+	}
+	if b.Resume != nil {
+		n, d := b.N, b.Duration
+		defer func() { b.N, b.Duration = n, d }()
+		if n > 0 && !staged {
+			b.N -= b.Resume.requests()
+		}
+		if d > 0 {
+			b.Duration -= time.Duration(b.Resume.TotalDuration) * time.Millisecond
+		}
+		if staged && len(b.Stages) == 0 || n > 0 && b.N <= 0 || d > 0 && b.Duration <= 0 {
+			live := &Report{n: n, finished: make(chan struct{})}
+			b.live.Store(live)
+			r := Merge(b.Resume)
+			r.Name, r.Resumed = b.Name, b.Resume.requests()
+			return b.done(live, r, newSinks(b.Sinks))
+		}
+	}
+	atomic.StoreInt32(&b.stopping, 0)
+	defer b.stopOnDone(ctx)()
+	atomic.StoreInt32(&b.rampPhase, 0)
+	atomic.StoreInt32(&b.stage, 0)
+	atomic.StoreInt64(&b.completed, 0)
+	b.results = make(chan *result, b.maxWorkers())
+	if b.CheckValidators {
+		b.validators = newValidatorCache()
+	}
+	b.counters = newCounterSet()
+	b.picker = newEndpointPicker(b.endpoints())
+	b.client = nil
+	var warmups int
+	b.seqBase, b.warmupSeq = 0, 0
+	if b.Resume != nil && b.Resume.Position != nil {
+		b.restore(b.Resume.Position)
+	}
+	if b.Warmup > 0 {
+		warmups = b.warmup()
+		b.seqBase += b.warmupSeq
+	}
+	seqEnd := b.seqBase + b.IDRange()
+
+	report := newReport(b.N, b.results, 0)
+	report.Name = b.Name
+	report.Warmup = warmups
+	report.GRPC = b.GRPC
+	if b.GRPC && b.GRPCStream != "" || b.WebSocket {
+		report.Stream = &StreamStats{}
+	}
+	report.headerChecks = b.HeaderChecks
+	report.fieldChecks = b.FieldChecks
+	report.bodyChecks = b.BodyChecks
+	report.keepRecords = b.KeepRecords
+	report.keepLats = b.KeepLatencies
+	report.interval = b.Interval
+	if report.interval <= 0 {
+		report.interval = DefaultInterval
+	}
+	if b.Ramp != nil {
+		report.ramp = make([]*rampSamples, len(rampPhaseNames))
+	}
+	report.tags = b.Tags
+	if b.LatencyBudget {
+		report.phases = make(map[string][]phaseSample)
+	}
+	if b.SLO != nil {
+		report.SLO = newSLOStats(*b.SLO)
+	}
+	if b.MaxMem > 0 {
+		report.guard = newMemoryGuard(b.MaxMem)
+	}
+	if b.Checkpoint != "" {
+		report.checkpoint = newCheckpointer(b)
+		report.position = func() *Position { return b.position(seqEnd) }
+	}
+	sinks := newSinks(b.Sinks)
+	if len(b.Sinks) > 0 {
+		report.sinks = sinks
+	}
+	report.n = b.N
+	if b.Resume != nil {
+		report.n += b.Resume.requests()
+	}
+	report.prev = b.Resume
+	report.workers = b.C
+	report.snapshots = make(chan chan *Report)
+	report.collected = make(chan struct{})
+	report.finished = make(chan struct{})
+	report.start = time.Now()
+	if len(b.Stages) > 0 {
+		report.stages = b.newStageReports(report)
+	}
+	if b.Live != nil && !b.noProgress {
+		b.view = newLiveView(b.Live, b.N, b.Duration)
+		b.view.grpc = b.GRPC
+		report.view = b.view
+	}
+	b.live.Store(report)
+	go report.collect()
+
+	b.startProgress()
 	b.runWorkers()
+	total := time.Since(report.start)
+	close(b.results)
+	<-report.collected
 	b.finalizeProgress()
 
-	report := newReport(b.N, b.results, b.Output, time.Now().Sub(start))
+	report.total = total
+	if b.stopped() {
+		report.Stopped, _ = b.stopReason.Load().(string)
+		report.Partial = ctx.Err() != nil
+	}
+	report.Churn = b.churnStats
+	report.Stalls = b.stalls
+	report.Scaling = b.scaling
+	report.Schedule = b.schedule
+	report.workers = b.peakWorkers
 	report.finalize()
+	live := report
+	if b.Resume != nil {
+		report = resume(b.Resume, report)
+	}
+	return b.done(live, report, sinks)
+}
+
+// warmup makes requests for Warmup, discarding their results, and
+// returns how many it made. The client it made them with is kept for
+// the run.
+func (b *Boomer) warmup() int {
+	n, d, qps, stages, ramp := b.N, b.Duration, b.Qps, b.Stages, b.Ramp
+	scale, churn, noProgress, results := b.AutoScale, b.ChurnRate, b.noProgress, b.results
+	defer func() {
+		b.N, b.Duration, b.Qps, b.Stages, b.Ramp = n, d, qps, stages, ramp
+		b.AutoScale, b.ChurnRate, b.noProgress, b.results = scale, churn, noProgress, results
+	}()
+	if len(b.Stages) > 0 {
+		b.Qps = b.Stages[0].Rate
+	}
+	b.N, b.Duration, b.Stages, b.Ramp = 0, b.Warmup, nil, nil
+	b.AutoScale, b.ChurnRate, b.noProgress = nil, 0, true
+	b.warmingUp = true
+	defer func() { b.warmingUp = false }()
+	b.results = make(chan *result, b.maxWorkers())
+	done := make(chan int)
+	go func() {
+		n := 0
+		for range b.results {
+			n++
+		}
+		done <- n
+	}()
+	b.runWorkers()
 	close(b.results)
+	return <-done
+}
 
-	return report
+// stop stops the run early for reason, unless it already was.
+func (b *Boomer) stop(reason string) {
+	if atomic.CompareAndSwapInt32(&b.stopping, 0, 1) {
+		b.stopReason.Store(reason)
+	}
 }
 
-func (b *Boomer) runWorker(wg *sync.WaitGroup, ch chan *http.Request) {
-	for req := range ch {
-		s := time.Now()
+// stopped reports whether the run stopped early.
+func (b *Boomer) stopped() bool {
+	return atomic.LoadInt32(&b.stopping) != 0
+}
 
-		var code int
-		var size int64
+// done completes the run reported by r: it is compared to the baseline,
+// the sinks are closed, the checkpoint, no longer needed, is removed
+// and r is made the final snapshot of live, the report that collected
+// the results.
+func (b *Boomer) done(live, r *Report, sinks *sinks) *Report {
+	if b.Baseline != nil {
+		r.Baseline = Compare(b.Baseline, r, b.MaxRegression)
+	}
+	r.Thresholds = Evaluate(r, b.Thresholds)
+	sinks.close(r)
+	if b.Checkpoint != "" {
+		os.Remove(b.Checkpoint)
+	}
+	live.final = r
+	close(live.finished)
+	return r
+}
 
-		resp, err := client.Do(req)
+func (b *Boomer) runWorker(w *worker, wg *sync.WaitGroup, ch chan *http.Request, retire chan struct{}) {
+	w.gid = goroutineID()
+	for {
+		atomic.StoreInt64(&w.since, 0)
+		var req *http.Request
+		var ok bool
+		select {
+		case req, ok = <-ch:
+		case <-retire:
+			return
+		}
+		if !ok {
+			return
+		}
+		if b.stopped() {
+			// Drop the requests queued before the run stopped.
+			wg.Done()
+			continue
+		}
+		if b.bucket != nil {
+			if err := b.bucket.take(req.Context()); err != nil || b.stopped() {
+				wg.Done()
+				continue
+			}
+		}
+		atomic.StoreInt64(&w.since, time.Now().UnixNano())
+		w.iter++
+		if len(b.Scenario) > 0 {
+			req = b.setEndpoint(req, w.scenarioStep(b))
+		}
+		if err := b.endpointErr(req); err != nil {
+			b.fail(wg, req, err)
+			continue
+		}
+		if b.templated() {
+			if err := w.render(req); err != nil {
+				if b.stopped() {
+					wg.Done()
+					continue
+				}
+				b.fail(wg, req, err)
+				continue
+			}
+		}
+		if b.BodyFunc != nil {
+			setBody(req, b.BodyFunc(w.id, int(w.iter-1)))
+		} else if b.BodyFile != "" {
+			if err := openBody(req, b.BodyFile); err != nil {
+				b.fail(wg, req, err)
+				continue
+			}
+		}
+		var wsKey string
+		if b.WebSocket {
+			wsKey = websocketRequest(req)
+		}
+		var bidi *bidiStream
+		if b.GRPC {
+			err := grpcRequest(req)
+			if err == nil && b.GRPCStream == "bidi" {
+				bidi, err = newBidiStream(req, b.streamMessages())
+			}
+			if err != nil {
+				b.fail(wg, req, err)
+				continue
+			}
+		}
+		if ae := b.acceptEncoding(req); ae != "" {
+			req.Header.Set("Accept-Encoding", ae)
+		}
+		var trace *traceContext
+		if b.TraceContext {
+			trace = newTraceContext()
+			req.Header.Set("traceparent", trace.String())
+		}
+		if err := b.authenticate(req); err != nil {
+			b.fail(wg, req, err)
+			continue
+		}
+		if b.Retry != nil {
+			if err := bufferBody(req); err != nil {
+				b.fail(wg, req, err)
+				continue
+			}
+		}
+		if b.SlowRate > 0 && req.Body != nil {
+			req.Body = slowReadCloser{newSlowReader(req.Body, b.SlowRate), req.Body}
+		}
+		req, cancel := b.chaos(req)
+		atomic.AddInt64(&b.inFlight, 1)
+		s := time.Now()
+		res := &result{endpoint: b.endpoint(req), shard: b.shard(req), method: req.Method, trace: trace}
+		b.target(req, res)
+		res.keepBody = b.needsBody(req)
+		res.rampPhase = atomic.LoadInt32(&b.rampPhase)
+		res.stage = atomic.LoadInt32(&b.stage)
+		req = withResult(withTrace(req, res), res)
+
+		resp, err := w.send(b, req, res)
 		if err == nil {
-			size = resp.ContentLength
-			code = resp.StatusCode
-			if b.ReadAll {
-				_, err = io.Copy(ioutil.Discard, resp.Body)
+			res.contentLength = resp.ContentLength
+			res.statusCode = resp.StatusCode
+			res.proto = resp.Proto
+			res.failedChecks = b.checkHeaders(resp.Header)
+			switch {
+			case cancel != nil:
+				res.aborted = abort(resp, cancel)
+			case b.GRPC && b.GRPCStream != "":
+				res.stream, err = readStream(resp, s, bidi)
+			case b.WebSocket:
+				res.stream, err = b.session(resp, wsKey, s)
+			default:
+				err = b.consume(req, resp, res)
+			}
+			if err == nil && cancel == nil && b.GRPC {
+				res.statusCode = grpcStatus(resp)
+			}
+			if err == nil && cancel == nil && len(b.Scenario) > 0 {
+				err = w.extract(b, req, resp, res.body)
+				res.body = nil
 			}
 			resp.Body.Close()
 		}
+		if bidi != nil {
+			bidi.close()
+		}
+		if cancel != nil {
+			cancel()
+		}
+		if err != nil && res.aborted == "" && b.interrupted() {
+			res.aborted = "interrupted"
+		}
+		if phase := timeoutPhase(err, res); phase != "" {
+			res.timeout, res.timeoutLimit = phase, timeoutLimit(err)
+		}
+		res.err = b.redactError(err)
+		res.start = s
+		res.duration = time.Now().Sub(s)
+		if b.LastHopLatency && !res.lastHop.IsZero() {
+			res.duration = time.Now().Sub(res.lastHop)
+		}
+		atomic.AddInt64(&b.inFlight, -1)
 
-		wg.Done()
+		// The result is sent before the request is marked done, as the
+		// results channel is closed once all are.
+		b.results <- res
 		b.incProgress()
-		b.results <- &result{
-			statusCode:    code,
-			duration:      time.Now().Sub(s),
-			err:           err,
-			contentLength: size,
+		wg.Done()
+	}
+}
+
+// dialTimeout returns the timeout of dialing a connection.
+func (b *Boomer) dialTimeout() time.Duration {
+	if b.DialTimeout > 0 {
+		return b.DialTimeout
+	}
+	return time.Duration(b.Timeout) * time.Millisecond
+}
+
+// tlsConfig returns the configuration of TLS connections to the
+// target.
+func (b *Boomer) tlsConfig() *tls.Config {
+	c := &tls.Config{
+		InsecureSkipVerify: b.AllowInsecure,
+		RootCAs:            b.RootCAs,
+		ServerName:         b.ServerName,
+	}
+	if b.ClientCert != nil {
+		c.Certificates = []tls.Certificate{*b.ClientCert}
+	}
+	return c
+}
+
+// streamMessages returns the number of messages sent on each
+// bidirectional-streaming call.
+func (b *Boomer) streamMessages() int {
+	if b.StreamMessages > 0 {
+		return b.StreamMessages
+	}
+	return 1
+}
+
+// authenticate sets the tokens of req and signs it.
+func (b *Boomer) authenticate(req *http.Request) error {
+	now := time.Now()
+	for _, t := range b.Tokens {
+		if err := t.apply(req, now); err != nil {
+			return err
 		}
 	}
+	if b.SigV4 != nil {
+		return b.SigV4.Sign(req, now)
+	}
+	return nil
+}
+
+// fail records req as failed with err before it could be sent, as
+// started now and taking no time.
+func (b *Boomer) fail(wg *sync.WaitGroup, req *http.Request, err error) {
+	res := &result{endpoint: b.endpoint(req), shard: b.shard(req), method: req.Method, err: b.redactError(err), start: time.Now()}
+	b.target(req, res)
+	res.rampPhase = atomic.LoadInt32(&b.rampPhase)
+	res.stage = atomic.LoadInt32(&b.stage)
+	b.results <- res
+	b.incProgress()
+	wg.Done()
+}
+
+// endpoint returns the URL of req as reported.
+func (b *Boomer) endpoint(req *http.Request) string {
+	if b.Redact != nil {
+		return b.Redact.URL(req.URL.String())
+	}
+	return req.URL.String()
+}
+
+func (b *Boomer) redactError(err error) error {
+	if b.Redact != nil {
+		return b.Redact.Error(err)
+	}
+	return err
+}
+
+// proxy returns the Proxy function of the transport.
+func (b *Boomer) proxy() func(*http.Request) (*url.URL, error) {
+	if b.ProxyAddr == nil && b.ProxyFromEnv {
+		return http.ProxyFromEnvironment
+	}
+	return http.ProxyURL(b.ProxyAddr)
 }
 
 func (b *Boomer) runWorkers() {
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: b.AllowInsecure,
-		},
-		DisableCompression: b.DisableCompression,
-		DisableKeepAlives:  b.DisableKeepAlives,
-		// TODO(jbd): Add dial timeout.
-		TLSHandshakeTimeout: time.Duration(b.Timeout) * time.Millisecond,
-		Proxy:               http.ProxyURL(b.ProxyAddr),
-	}
-	client = &http.Client{Transport: tr}
+	timeout := time.Duration(b.Timeout) * time.Millisecond
+	b.dns = nil
+	if b.DNSCacheTTL > 0 {
+		b.dns = newDNSCache(b.DNSCacheTTL)
+	}
+	b.arrivals = poissonArrivals{}
+	b.bucket = nil
+	if b.Burst > 0 && b.Qps > 0 && !b.openModel() {
+		b.bucket = newTokenBucket(func(n int) time.Duration {
+			at, _ := b.due(n)
+			return at
+		}, b.Burst)
+	}
+	tlsTimeout := timeout
+	if b.TLSTimeout > 0 {
+		tlsTimeout = b.TLSTimeout
+	}
+	var tr http.RoundTripper = &http.Transport{
+		TLSClientConfig:       b.tlsConfig(),
+		DisableCompression:    b.DisableCompression,
+		DisableKeepAlives:     b.DisableKeepAlives,
+		IdleConnTimeout:       b.IdleConnTimeout,
+		MaxIdleConnsPerHost:   b.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       b.MaxConnsPerHost,
+		DialContext:           b.dialer(b.dialTimeout()),
+		TLSHandshakeTimeout:   tlsTimeout,
+		ResponseHeaderTimeout: b.HeaderTimeout,
+		Proxy:                 b.proxy(),
+		Protocols:             b.protocols(),
+	}
+	if b.Transport != nil {
+		tr = b.Transport
+	}
+	if b.client == nil {
+		b.client = &http.Client{Transport: tr, Timeout: timeout, CheckRedirect: b.checkRedirect}
+	}
 
 	var wg sync.WaitGroup
-	wg.Add(b.N)
+	var expired <-chan time.Time
+	if b.Duration > 0 {
+		t := time.NewTimer(b.Duration)
+		defer t.Stop()
+		expired = t.C
+	}
 
-	var throttle <-chan time.Time
-	if b.Qps > 0 {
-		throttle = time.Tick(time.Duration(1e6/(b.Qps)) * time.Microsecond)
+	if b.ChurnRate > 0 {
+		stop := make(chan struct{})
+		done := make(chan *ChurnStats)
+		go func() { done <- b.churn(stop) }()
+		defer func() {
+			close(stop)
+			b.churnStats = <-done
+		}()
 	}
 
-	jobsch := make(chan *http.Request, b.N)
-	for i := 0; i < b.C; i++ {
-		go b.runWorker(&wg, jobsch)
+	workers := make([]*worker, b.maxWorkers())
+	for i := range workers {
+		workers[i] = b.newWorker(i)
+	}
+	if after := b.stallAfter(); after > 0 {
+		stop := make(chan struct{})
+		done := make(chan int)
+		go func() { done <- b.watch(workers, after, stop) }()
+		defer func() {
+			close(stop)
+			b.stalls = <-done
+		}()
 	}
 
-	for i := 0; i < b.N; i++ {
-		if b.Qps > 0 {
-			<-throttle
+	queue := b.N
+	if b.openModel() || b.Duration > 0 {
+		// Requests are only handed to idle workers, for none to be
+		// queued when the run is to end.
+		queue = 0
+	}
+	jobsch := make(chan *http.Request, queue)
+	sc := newScaler(b, workers, &wg, jobsch, b.AutoScale != nil || b.Ramp != nil)
+	defer func() { b.peakWorkers = sc.peak }()
+	if b.Ramp != nil && !b.rampsRate() {
+		stop := make(chan struct{})
+		done := make(chan struct{})
+		go func() { sc.ramp(stop); close(done) }()
+		defer func() {
+			close(stop)
+			<-done
+		}()
+	} else {
+		sc.start(b.C)
+	}
+	if b.openModel() {
+		b.schedule = b.dispatchOpen(sc, jobsch, &wg, expired)
+		close(jobsch)
+		wg.Wait()
+		return
+	}
+	if b.AutoScale != nil && (b.Ramp == nil || b.rampsRate()) {
+		stop := make(chan struct{})
+		done := make(chan []ScaleInterval)
+		go func() { done <- sc.autoScale(stop) }()
+		defer func() {
+			close(stop)
+			b.scaling = <-done
+		}()
+	}
+
+	start := time.Now()
+	var rec *scheduleRecorder
+	if b.Poisson && b.Qps > 0 && b.bucket == nil {
+		rec = newScheduleRecorder(b, start)
+	}
+loop:
+	for i := 0; b.more(i); i++ {
+		var due time.Time
+		// The workers wait for the tokens of the bucket, if any, rather
+		// than for the requests to be handed out on schedule.
+		if b.Qps > 0 && b.bucket == nil {
+			at, ok := b.due(i)
+			if !ok {
+				break
+			}
+			due = start.Add(at)
+			time.Sleep(time.Until(due))
+		}
+		if b.stopped() || fired(expired) {
+			break
+		}
+		b.setRampPhase(i)
+		b.setStage(i)
+		wg.Add(1)
+		select {
+		case jobsch <- b.job(i):
+		case <-expired:
+			wg.Done()
+			break loop
+		}
+		if rec != nil {
+			rec.handed(due)
 		}
-		jobsch <- cloneRequest(b.Request, b.RequestBody)
 	}
 	close(jobsch)
+	if rec != nil {
+		b.schedule = rec.done(sc.peak)
+	}
 
 	wg.Wait()
 }
 
+// more reports whether the run is to make an i-th request, having made
+// fewer than N or running for a Duration.
+func (b *Boomer) more(i int) bool {
+	return i < b.N || b.N == 0 && b.Duration > 0
+}
+
+// due returns the time since the start of a run at a rate the i-th
+// request is due at, and whether that is within its Duration.
+func (b *Boomer) due(i int) (time.Duration, bool) {
+	at := b.arrival(i)
+	if b.Poisson {
+		at = b.arrivals.next(at)
+	}
+	return at, b.Duration <= 0 || at < b.Duration
+}
+
+// fired reports whether the timer channel c fired.
+func fired(c <-chan time.Time) bool {
+	select {
+	case <-c:
+		return true
+	default:
+		return false
+	}
+}
+
+// job returns the i-th request of the run.
+func (b *Boomer) job(i int) *http.Request {
+	req := cloneRequest(b.Request, b.RequestBody)
+	if b.reqCtx != nil {
+		req = req.WithContext(b.reqCtx)
+	}
+	if b.picker != nil && len(b.Scenario) == 0 {
+		req = b.setEndpoint(req, b.picker.pick(i, b.InOrder))
+	}
+	if len(b.Hosts) > 0 {
+		setHost(req, b.Hosts[i%len(b.Hosts)])
+	}
+	return req
+}
+
+// setBody makes body the body of req. Like http.NewRequest, it sets the
+// content length for bodies of known size.
+func setBody(req *http.Request, body io.Reader) {
+	switch v := body.(type) {
+	case *bytes.Buffer:
+		req.ContentLength = int64(v.Len())
+	case *bytes.Reader:
+		req.ContentLength = int64(v.Len())
+	case *strings.Reader:
+		req.ContentLength = int64(v.Len())
+	default:
+		req.ContentLength = -1
+	}
+	rc, ok := body.(io.ReadCloser)
+	if !ok {
+		rc = ioutil.NopCloser(body)
+	}
+	req.Body = rc
+}
+
+// openBody makes the file at path the body of req, to be read as it is
+// sent.
+func openBody(req *http.Request, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	req.Body, req.ContentLength = f, fi.Size()
+	return nil
+}
+
 // cloneRequest returns a clone of the provided *http.Request.
 // The clone is a shallow copy of the struct and its Header map.
 func cloneRequest(r *http.Request, body string) *http.Request {
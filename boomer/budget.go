@@ -0,0 +1,95 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"math"
+	"sort"
+)
+
+// PhaseTimes splits a latency, in ms, by the phase it was spent in.
+type PhaseTimes struct {
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	TLS     float64 `json:"tls"`
+
+	// Wait is the time from getting a connection to the first byte of
+	// the response, mostly spent by the server.
+	Wait float64 `json:"wait"`
+
+	// Transfer is the time spent reading the response.
+	Transfer float64 `json:"transfer"`
+
+	// Other is the remainder, such as waiting for a pooled connection.
+	Other float64 `json:"other"`
+}
+
+func (p *PhaseTimes) add(q PhaseTimes, scale float64) {
+	p.DNS += q.DNS * scale
+	p.Connect += q.Connect * scale
+	p.TLS += q.TLS * scale
+	p.Wait += q.Wait * scale
+	p.Transfer += q.Transfer * scale
+	p.Other += q.Other * scale
+}
+
+// Total returns the latency the phases add up to.
+func (p PhaseTimes) Total() float64 {
+	return p.DNS + p.Connect + p.TLS + p.Wait + p.Transfer + p.Other
+}
+
+// LatencyBudget attributes the latency of the successful requests to an
+// endpoint to the phases it was spent in.
+type LatencyBudget struct {
+	Endpoint string `json:"endpoint"`
+	Requests int    `json:"requests"`
+
+	// Average splits the average latency. P99 splits the average latency
+	// of the requests at or above the 99th percentile.
+	Average PhaseTimes `json:"average"`
+	P99     PhaseTimes `json:"p99"`
+}
+
+// phaseSample holds the latency of a request, in ms, and its split.
+type phaseSample struct {
+	total  float64
+	phases PhaseTimes
+}
+
+func (r *Report) addPhases(res *result) {
+	r.phases[res.endpoint] = append(r.phases[res.endpoint], phaseSample{
+		total:  res.duration.Seconds() * 1000,
+		phases: phaseTimes(res),
+	})
+}
+
+func (r *Report) printBudget() {
+	r.Budget = nil
+	for endpoint, samples := range r.phases {
+		b := LatencyBudget{Endpoint: endpoint, Requests: len(samples)}
+		for _, s := range samples {
+			b.Average.add(s.phases, 1/float64(len(samples)))
+		}
+		sort.Slice(samples, func(i, j int) bool { return samples[i].total < samples[j].total })
+		tail := samples[int(math.Ceil(0.99*float64(len(samples))))-1:]
+		for _, s := range tail {
+			b.P99.add(s.phases, 1/float64(len(tail)))
+		}
+		r.Budget = append(r.Budget, b)
+	}
+	sort.Slice(r.Budget, func(i, j int) bool {
+		return r.Budget[i].Endpoint < r.Budget[j].Endpoint
+	})
+}
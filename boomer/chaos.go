@@ -0,0 +1,60 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+)
+
+// Phases at which a request may be aborted.
+const (
+	abortAfterHeaders = "after-headers"
+	abortMidBody      = "mid-body"
+)
+
+// abortChunk is how much of a body of unknown length is read before
+// aborting mid-body.
+const abortChunk = 512
+
+// chaos decides whether req should be aborted. If so, it returns a
+// cancellable copy of req and the function that cancels it.
+func (b *Boomer) chaos(req *http.Request) (*http.Request, context.CancelFunc) {
+	if b.AbortRate <= 0 || rand.Float64() >= b.AbortRate {
+		return req, nil
+	}
+	ctx, cancel := context.WithCancel(req.Context())
+	return req.WithContext(ctx), cancel
+}
+
+// abort cancels an in-flight request either right after its headers
+// arrived or after part of its body has been read, and returns the
+// phase at which it was cancelled.
+func abort(resp *http.Response, cancel context.CancelFunc) string {
+	if rand.Intn(2) == 0 {
+		cancel()
+		return abortAfterHeaders
+	}
+	n := resp.ContentLength / 2
+	if n <= 0 {
+		n = abortChunk
+	}
+	io.CopyN(ioutil.Discard, resp.Body, n)
+	cancel()
+	return abortMidBody
+}
@@ -0,0 +1,209 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// HeaderCheck is an assertion on a response header. With neither Value
+// nor Pattern set, the header only has to be present.
+type HeaderCheck struct {
+	Name string
+
+	// Value, if not empty, is the value the header must equal.
+	Value string
+
+	// Pattern, if not nil, must match the value of the header.
+	Pattern *regexp.Regexp
+}
+
+// String returns the check in the form accepted by the -hc flag:
+// "Name", "Name=value" or "Name~pattern".
+func (c HeaderCheck) String() string {
+	return checkString(c.Name, c.Value, c.Pattern)
+}
+
+func (c HeaderCheck) ok(h http.Header) bool {
+	values, found := h[http.CanonicalHeaderKey(c.Name)]
+	if !found {
+		return false
+	}
+	v := ""
+	if len(values) > 0 {
+		v = values[0]
+	}
+	return matchValue(v, c.Value, c.Pattern)
+}
+
+// FieldCheck is an assertion on a field of a decoded response body. The
+// field is addressed by a dot separated path of names and slice indexes,
+// such as "user.roles.0". With neither Value nor Pattern set, the field
+// only has to be present.
+type FieldCheck struct {
+	Path string
+
+	// Value, if not empty, is the value the field must equal when
+	// formatted as text.
+	Value string
+
+	// Pattern, if not nil, must match the field formatted as text.
+	Pattern *regexp.Regexp
+}
+
+// String returns the check in the form "path", "path=value" or
+// "path~pattern".
+func (c FieldCheck) String() string {
+	return checkString(c.Path, c.Value, c.Pattern)
+}
+
+func (c FieldCheck) ok(doc interface{}) bool {
+	v, ok := lookupPath(doc, c.Path)
+	return ok && matchValue(fmt.Sprint(v), c.Value, c.Pattern)
+}
+
+// BodyCheck is an assertion on a response body. Only one of its forms
+// applies: if Pattern is not nil, it must match the body; otherwise if
+// JSONPath is not empty, the field of the body decoded as JSON at the
+// path, such as "$.items[0].id", must be present and equal Value, if
+// not empty; otherwise the body must be Length bytes long.
+type BodyCheck struct {
+	Pattern  *regexp.Regexp
+	JSONPath string
+	Value    string
+	Length   int64
+}
+
+// String returns the check in the form accepted by the -bc flag:
+// "~pattern", "$.path", "$.path=value" or "length=n".
+func (c BodyCheck) String() string {
+	switch {
+	case c.Pattern != nil:
+		return "~" + c.Pattern.String()
+	case c.JSONPath != "":
+		return checkString(c.JSONPath, c.Value, nil)
+	}
+	return "length=" + strconv.FormatInt(c.Length, 10)
+}
+
+// ok evaluates the check against body. doc holds body decoded as JSON
+// once a JSONPath check needed it.
+func (c BodyCheck) ok(body []byte, doc *interface{}) bool {
+	switch {
+	case c.Pattern != nil:
+		return c.Pattern.Match(body)
+	case c.JSONPath != "":
+		if *doc == nil {
+			d := json.NewDecoder(bytes.NewReader(body))
+			d.UseNumber()
+			if d.Decode(doc) != nil {
+				return false
+			}
+		}
+		v, ok := lookupPath(*doc, jsonPathToDots(c.JSONPath))
+		return ok && matchValue(fmt.Sprint(v), c.Value, nil)
+	}
+	return int64(len(body)) == c.Length
+}
+
+// checkBody evaluates the body checks against a response body and
+// returns the indexes of the ones that failed.
+func (b *Boomer) checkBody(body []byte) []int {
+	var failed []int
+	var doc interface{}
+	for i, c := range b.BodyChecks {
+		if !c.ok(body, &doc) {
+			failed = append(failed, i)
+		}
+	}
+	return failed
+}
+
+// lookupPath returns the field of doc at the dot separated path of
+// names and slice indexes, and whether it is present.
+func lookupPath(doc interface{}, path string) (interface{}, bool) {
+	v := doc
+	if path == "" {
+		return v, true
+	}
+	for _, elem := range strings.Split(path, ".") {
+		switch t := v.(type) {
+		case map[string]interface{}:
+			var found bool
+			if v, found = t[elem]; !found {
+				return nil, false
+			}
+		case []interface{}:
+			i, err := strconv.Atoi(elem)
+			if err != nil || i < 0 || i >= len(t) {
+				return nil, false
+			}
+			v = t[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// checkFields evaluates the field checks against a decoded body and
+// returns the indexes of the ones that failed.
+func (b *Boomer) checkFields(doc interface{}) []int {
+	var failed []int
+	for i, c := range b.FieldChecks {
+		if !c.ok(doc) {
+			failed = append(failed, i)
+		}
+	}
+	return failed
+}
+
+func matchValue(v, value string, pattern *regexp.Regexp) bool {
+	switch {
+	case pattern != nil:
+		return pattern.MatchString(v)
+	case value != "":
+		return v == value
+	}
+	return true
+}
+
+func checkString(name, value string, pattern *regexp.Regexp) string {
+	switch {
+	case pattern != nil:
+		return name + "~" + pattern.String()
+	case value != "":
+		return name + "=" + value
+	}
+	return name
+}
+
+// checkHeaders evaluates the header checks against h and returns the
+// indexes of the ones that failed.
+func (b *Boomer) checkHeaders(h http.Header) []int {
+	var failed []int
+	for i, c := range b.HeaderChecks {
+		if !c.ok(h) {
+			failed = append(failed, i)
+		}
+	}
+	return failed
+}
@@ -0,0 +1,172 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// DefaultCheckpointEvery is how often the state of a run is saved if
+// CheckpointEvery is not set.
+const DefaultCheckpointEvery = time.Minute
+
+// ReadCheckpoint reads the state of an interrupted run saved to path,
+// to be given as Resume to the run continuing it.
+func ReadCheckpoint(path string) (*Report, error) {
+	return ReadReport(path)
+}
+
+// Position is how far a run got in generating its requests, for the
+// run resuming it to carry on with new seq and counter values and the
+// next rows of its Feeds. The stage it was in follows from the number
+// of requests it made.
+type Position struct {
+	// Seq is past every value of seq yielded, less the IDOffset.
+	Seq int64 `json:"seq"`
+
+	// Counters are the values the counters yield next, by name.
+	Counters map[string]int64 `json:"counters,omitempty"`
+
+	// Feeds are the numbers of rows drawn from the sequential and
+	// unique Feeds, by name.
+	Feeds map[string]int `json:"feeds,omitempty"`
+}
+
+// position returns the position of the run so far, whose seq values
+// end at seqEnd.
+func (b *Boomer) position(seqEnd int64) *Position {
+	p := &Position{Seq: seqEnd, Counters: b.counters.values()}
+	for _, f := range b.Feeds {
+		if f.Mode == Random {
+			continue
+		}
+		if p.Feeds == nil {
+			p.Feeds = make(map[string]int)
+		}
+		p.Feeds[f.Name] = f.drawn()
+	}
+	return p
+}
+
+// restore carries on from the position of the run resumed.
+func (b *Boomer) restore(p *Position) {
+	b.seqBase = p.Seq
+	for name, v := range p.Counters {
+		next := v
+		b.counters.m[name] = &next
+	}
+	for _, f := range b.Feeds {
+		if n, ok := p.Feeds[f.Name]; ok && f.Mode != Random {
+			f.seek(n)
+		}
+	}
+}
+
+// checkpointer periodically saves the report of a run in progress.
+type checkpointer struct {
+	path  string
+	every time.Duration
+}
+
+func newCheckpointer(b *Boomer) *checkpointer {
+	c := &checkpointer{path: b.Checkpoint, every: b.CheckpointEvery}
+	if c.every <= 0 {
+		c.every = DefaultCheckpointEvery
+	}
+	return c
+}
+
+// save saves a snapshot of r, noting a failure in CheckpointError.
+func (c *checkpointer) save(r *Report) {
+	r.CheckpointError = ""
+	if err := c.write(r.snapshot()); err != nil {
+		r.CheckpointError = err.Error()
+	}
+}
+
+// write writes r to a temporary file renamed over the checkpoint, so a
+// crash while writing leaves the previous checkpoint intact.
+func (c *checkpointer) write(r *Report) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	tmp := c.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
+
+// snapshot returns a finalized copy of r, a report still collecting
+// results, as if the run had stopped now. It covers the interrupted
+// run r resumes, if any.
+func (r *Report) snapshot() *Report {
+	s := *r
+	s.Errors, s.StatusCodes, s.Aborts, s.Timeouts = nil, nil, nil, nil
+	s.Compression, s.Variants, s.Schema, s.Budget, s.Shards = nil, nil, nil, nil, nil
+	s.HeaderChecks, s.FieldChecks, s.Worst, s.Protocols, s.Ramp = nil, nil, nil, nil, nil
+	s.Endpoints, s.BodyChecks, s.Redirects, s.RedirectCodes, s.Retries = nil, nil, nil, nil, nil
+	s.Families = nil
+	s.Lats = append([]float64(nil), r.Lats...)
+	s.Sketch = r.Sketch.copy()
+	s.Stream = r.Stream.copy()
+	if r.SLO != nil {
+		slo := *r.SLO
+		s.SLO = &slo
+	}
+	s.total = time.Since(r.start)
+	if !r.end.IsZero() {
+		s.total = r.end.Sub(r.start)
+	}
+	if r.position != nil {
+		s.Position = r.position()
+	}
+	s.finalize()
+	snap := &s
+	if r.prev != nil {
+		snap = resume(r.prev, snap)
+	}
+	return snap
+}
+
+// resume combines the report of an interrupted run with that of the
+// run resuming it, which ran after it.
+func resume(prev, cur *Report) *Report {
+	shifted := *cur
+	shifted.Worst = shiftWorst(cur.Worst, prev.TotalDuration/1000)
+	shifted.Intervals = shiftIntervals(cur.Intervals, float64(prev.TotalDuration)/1000)
+	shifted.intervals = nil
+	m := Merge(prev, &shifted)
+	m.Name = cur.Name
+	m.Resumed = prev.requests()
+	m.CheckpointError = cur.CheckpointError
+	m.Position = cur.Position
+	m.total = prev.total + cur.total
+	m.summarize()
+	return m
+}
+
+// requests returns the number of requests the report counts.
+func (r *Report) requests() int {
+	n := r.Responses() + r.ErrorCount()
+	for _, a := range r.Aborts {
+		n += a.Count
+	}
+	return n
+}
@@ -0,0 +1,178 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCheckpoint(t *testing.T) {
+	var count int64
+	unblock := make(chan struct{})
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt64(&count, 1) > 10 {
+			<-unblock
+		}
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	boomer := &Boomer{
+		Request:         req,
+		N:               20,
+		C:               1,
+		Checkpoint:      path,
+		CheckpointEvery: time.Millisecond,
+	}
+	done := make(chan *Report)
+	go func() { done <- runBoomer(t, boomer) }()
+
+	// The 11th request blocks until the checkpoint of the first ten is
+	// saved.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		r, err := ReadCheckpoint(path)
+		if err == nil && r.Responses() == 10 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected a checkpoint of 10 responses, found %+v, %v", r, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	close(unblock)
+	if r := <-done; r.Responses() != 20 {
+		t.Errorf("expected 20 responses, found %v", r.Responses())
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected the checkpoint to be removed, found %v", err)
+	}
+}
+
+func TestResume(t *testing.T) {
+	var count int64
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&count, 1)
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	first := runBoomer(t, &Boomer{Request: req, N: 10, C: 2, KeepLatencies: true})
+	// Resume from the report as saved to disk.
+	var buf bytes.Buffer
+	if err := first.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var prev Report
+	if err := json.Unmarshal(buf.Bytes(), &prev); err != nil {
+		t.Fatal(err)
+	}
+
+	boomer := &Boomer{Request: req, N: 30, C: 2, Resume: &prev, KeepLatencies: true}
+	r := runBoomer(t, boomer)
+	if count != 30 || boomer.N != 30 {
+		t.Errorf("expected 30 requests of N 30, found %v of N %v", count, boomer.N)
+	}
+	if r.Responses() != 30 || len(r.Lats) != 30 || r.Resumed != 10 {
+		t.Errorf("expected 30 responses resumed after 10, found %v with %v latencies resumed after %v",
+			r.Responses(), len(r.Lats), r.Resumed)
+	}
+	if r.TotalDuration < first.TotalDuration {
+		t.Errorf("expected the duration to include that of the first run, found %v", r.TotalDuration)
+	}
+}
+
+func TestResumePosition(t *testing.T) {
+	var mu sync.Mutex
+	seen := make(map[string]int)
+	// The requests of the first run past its checkpoint of 10 are not
+	// recorded, nor counted by the checkpoint.
+	var count, ignore int64
+	unblock := make(chan struct{})
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt64(&ignore) == 1 {
+			return
+		}
+		if atomic.AddInt64(&count, 1) > 10 {
+			<-unblock
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		for _, k := range []string{"seq", "counter", "row"} {
+			seen[k+"="+r.URL.Query().Get(k)]++
+		}
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	var rows []map[string]string
+	for i := 0; i < 40; i++ {
+		rows = append(rows, map[string]string{"id": fmt.Sprint(i)})
+	}
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	req, _ := http.NewRequest("GET", server.URL+`?seq={{seq}}&counter={{counter "n"}}&row={{feed "rows" "id"}}`, nil)
+	first := &Boomer{Request: req, N: 30, C: 1, Template: true, Checkpoint: path, CheckpointEvery: time.Millisecond,
+		Feeds: []*Feed{{Name: "rows", Mode: Unique, Rows: rows}}}
+	done := make(chan *Report)
+	go func() { done <- runBoomer(t, first) }()
+	var prev *Report
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		r, err := ReadCheckpoint(path)
+		if err == nil && r.Responses() == 10 && r.Position != nil {
+			prev = r
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected a checkpoint of 10 responses, found %+v, %v", r, err)
+		}
+	}
+	atomic.StoreInt64(&ignore, 1)
+	close(unblock)
+	<-done
+	atomic.StoreInt64(&count, -1<<32)
+	atomic.StoreInt64(&ignore, 0)
+
+	b := &Boomer{Request: req, N: 30, C: 2, Template: true, Resume: prev,
+		Feeds: []*Feed{{Name: "rows", Mode: Unique, Rows: rows}}}
+	if r := runBoomer(t, b); r.Responses() != 30 {
+		t.Fatalf("expected 30 responses, found %v", r.Responses())
+	}
+	for v, n := range seen {
+		if n > 1 {
+			t.Errorf("expected %s to be sent once, found %d times", v, n)
+		}
+	}
+
+	stages := []Stage{{Rate: 100, Duration: time.Second}, {Rate: 10, Duration: time.Second}}
+	left, skipped := remainingStages(stages, 105)
+	if want := []Stage{{Rate: 10, Duration: 500 * time.Millisecond}}; skipped != 1 || !reflect.DeepEqual(left, want) {
+		t.Errorf("expected %v left after 1 stage, found %v after %d", want, left, skipped)
+	}
+}
@@ -0,0 +1,170 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// defaultDialTimeout bounds connection attempts when no timeout is set.
+const defaultDialTimeout = 10 * time.Second
+
+// ChurnStats summarizes the connections opened and closed by the
+// connection churn mode. Latencies are in ms.
+type ChurnStats struct {
+	Attempts     int             `json:"attempts"`
+	Failures     int             `json:"failures"`
+	AvgConnect   float64         `json:"avg_connect"`
+	AvgHandshake float64         `json:"avg_handshake"`
+	Series       []ChurnInterval `json:"series"`
+}
+
+// ChurnInterval summarizes the connections attempted within one second
+// of the run.
+type ChurnInterval struct {
+	Second       int     `json:"second"`
+	Attempts     int     `json:"attempts"`
+	Failures     int     `json:"failures"`
+	AvgConnect   float64 `json:"avg_connect"`
+	AvgHandshake float64 `json:"avg_handshake"`
+
+	connects, handshakes int
+}
+
+type churner struct {
+	addr    string
+	dial    dialFunc
+	tls     *tls.Config
+	timeout time.Duration
+	start   time.Time
+	mu      sync.Mutex
+	wg      sync.WaitGroup
+	seconds map[int]*ChurnInterval
+}
+
+// churn opens and immediately closes connections to the target at
+// ChurnRate per second until stop is closed, independently of the
+// requests being made, and returns the accept and handshake latencies
+// observed.
+func (b *Boomer) churn(stop <-chan struct{}) *ChurnStats {
+	u := b.Request.URL
+	c := &churner{
+		addr:    u.Host,
+		timeout: b.dialTimeout(),
+		start:   time.Now(),
+		seconds: make(map[int]*ChurnInterval),
+	}
+	if c.timeout <= 0 {
+		c.timeout = defaultDialTimeout
+	}
+	c.dial = b.dialer(c.timeout)
+	port := "80"
+	if u.Scheme == "https" {
+		port = "443"
+		c.tls = b.tlsConfig()
+		if c.tls.ServerName == "" {
+			c.tls.ServerName = u.Hostname()
+		}
+	}
+	if u.Port() == "" {
+		c.addr = net.JoinHostPort(u.Hostname(), port)
+	}
+
+	tick := time.NewTicker(time.Second / time.Duration(b.ChurnRate))
+	defer tick.Stop()
+	for {
+		select {
+		case <-stop:
+			c.wg.Wait()
+			return c.stats()
+		case <-tick.C:
+			c.wg.Add(1)
+			go c.connect()
+		}
+	}
+}
+
+func (c *churner) connect() {
+	defer c.wg.Done()
+	s := time.Now()
+	conn, err := c.dial(context.Background(), "tcp", c.addr)
+	connected := time.Now()
+	var handshake time.Duration
+	if err == nil && c.tls != nil {
+		tc := tls.Client(conn, c.tls)
+		tc.SetDeadline(s.Add(c.timeout))
+		err = tc.Handshake()
+		handshake = time.Since(connected)
+		conn = tc
+	}
+	if conn != nil {
+		conn.Close()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	sec := int(s.Sub(c.start) / time.Second)
+	iv, ok := c.seconds[sec]
+	if !ok {
+		iv = &ChurnInterval{Second: sec}
+		c.seconds[sec] = iv
+	}
+	iv.Attempts++
+	if err != nil {
+		iv.Failures++
+		return
+	}
+	iv.connects++
+	iv.AvgConnect += connected.Sub(s).Seconds() * 1000
+	if c.tls != nil {
+		iv.handshakes++
+		iv.AvgHandshake += handshake.Seconds() * 1000
+	}
+}
+
+func (c *churner) stats() *ChurnStats {
+	st := &ChurnStats{}
+	var connects, handshakes int
+	for _, iv := range c.seconds {
+		st.Attempts += iv.Attempts
+		st.Failures += iv.Failures
+		st.AvgConnect += iv.AvgConnect
+		st.AvgHandshake += iv.AvgHandshake
+		connects += iv.connects
+		handshakes += iv.handshakes
+		if iv.connects > 0 {
+			iv.AvgConnect /= float64(iv.connects)
+		}
+		if iv.handshakes > 0 {
+			iv.AvgHandshake /= float64(iv.handshakes)
+		}
+		st.Series = append(st.Series, *iv)
+	}
+	if connects > 0 {
+		st.AvgConnect /= float64(connects)
+	}
+	if handshakes > 0 {
+		st.AvgHandshake /= float64(handshakes)
+	}
+	sort.Slice(st.Series, func(i, j int) bool {
+		return st.Series[i].Second < st.Series[j].Second
+	})
+	return st
+}
@@ -0,0 +1,42 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+)
+
+// jarClient returns a client of the run with a cookie jar of its own.
+func (b *Boomer) jarClient() *http.Client {
+	jar, _ := cookiejar.New(nil)
+	c := *b.client
+	c.Jar = jar
+	return &c
+}
+
+// do sends req with the client of the worker, setting the Cookies of
+// the run in its jar first if req is its first request to the host.
+func (w *worker) do(b *Boomer, req *http.Request) (*http.Response, error) {
+	if w.client == nil {
+		return b.client.Do(req)
+	}
+	if len(b.Cookies) > 0 && !w.seeded[req.URL.Host] {
+		w.client.Jar.SetCookies(&url.URL{Scheme: req.URL.Scheme, Host: req.URL.Host, Path: "/"}, b.Cookies)
+		w.seeded[req.URL.Host] = true
+	}
+	return w.client.Do(req)
+}
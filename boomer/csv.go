@@ -0,0 +1,101 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// csvSink writes records as rows of comma-separated values.
+type csvSink struct {
+	w     *csv.Writer
+	flush time.Time
+}
+
+// NewCSVSink returns a sink writing a row of comma-separated values per
+// request to w as it completes, after a header naming the columns:
+// start, endpoint, latency_ms, status, error, bytes and aborted. The
+// status of a request that failed is empty, and so is the latency of a
+// request aborted on purpose. Rows are flushed at least every second.
+func NewCSVSink(w io.Writer) Sink {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"start", "endpoint", "latency_ms", "status", "error", "bytes", "aborted"})
+	return &csvSink{w: cw, flush: time.Now()}
+}
+
+func (s *csvSink) Record(rec Record) error {
+	var latency, status, errMsg string
+	if rec.Aborted == "" {
+		latency = strconv.FormatFloat(rec.Duration.Seconds()*1000, 'f', 3, 64)
+	}
+	if rec.StatusCode > 0 {
+		status = strconv.Itoa(rec.StatusCode)
+	}
+	if rec.Err != nil {
+		errMsg = rec.Err.Error()
+	}
+	s.w.Write([]string{
+		rec.Start.Format(time.RFC3339Nano), rec.Endpoint, latency, status,
+		errMsg, strconv.FormatInt(rec.Size, 10), rec.Aborted,
+	})
+	if now := time.Now(); now.Sub(s.flush) >= sinkFlushInterval {
+		s.flush = now
+		s.w.Flush()
+	}
+	return s.w.Error()
+}
+
+func (s *csvSink) Close(*Report) error {
+	s.w.Flush()
+	return s.w.Error()
+}
+
+// WriteSummaryCSV writes the summary of the report to w as rows of
+// comma-separated metric names and values: durations and latencies in
+// seconds, the latency percentiles as p10 to p99, and the number of
+// responses with each status code as status_200 and so on.
+func (r *Report) WriteSummaryCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	row := func(metric string, v interface{}) {
+		cw.Write([]string{metric, fmt.Sprint(v)})
+	}
+	row("metric", "value")
+	if r.Name != "" {
+		row("name", r.Name)
+	}
+	row("total", float64(r.TotalDuration)/1000)
+	row("slowest", r.Slowest/1000)
+	row("fastest", r.Fastest/1000)
+	row("average", r.Average)
+	row("rps", r.RPS)
+	row("responses", r.Responses())
+	row("errors", r.ErrorCount())
+	row("timeouts", r.TimeoutCount())
+	row("bytes", r.SizeTotal)
+	row("wire_bytes", r.WireBytes)
+	row("body_bytes", r.BodyBytes)
+	for _, p := range r.Percentiales {
+		row(fmt.Sprintf("p%d", p.Percent), p.Count/1000)
+	}
+	for _, s := range r.StatusCodes {
+		row(fmt.Sprintf("status_%d", s.Code), s.Count)
+	}
+	cw.Flush()
+	return cw.Error()
+}
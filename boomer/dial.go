@@ -0,0 +1,146 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"time"
+)
+
+// dialFunc dials a connection to addr, as http.Transport.DialContext.
+type dialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// dialer returns the function the connections to the target are dialed
+// with. Addresses overridden in Resolve are dialed at the addresses
+// given for them, and hosts are resolved through the DNS cache of the
+// run if it has one, the addresses being tried in turn until one
+// connects. Only the addresses of the IPVersion of the run are dialed
+// if it is set.
+func (b *Boomer) dialer(timeout time.Duration) dialFunc {
+	d := &net.Dialer{Timeout: timeout}
+	if len(b.Resolve) == 0 && b.dns == nil && b.IPVersion == 0 {
+		return d.DialContext
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if timeout > 0 {
+			// The lookup and dials share the timeout, as they do with
+			// the dialer alone.
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		if b.IPVersion != 0 && network == "tcp" {
+			network = fmt.Sprintf("tcp%d", b.IPVersion)
+		}
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		ips, ok := b.Resolve[addr]
+		if !ok && b.dns != nil && net.ParseIP(host) == nil {
+			if ips, err = b.dns.lookup(ctx, host); err != nil {
+				return nil, &net.OpError{Op: "dial", Net: network, Err: err}
+			}
+			ok = true
+		}
+		if !ok {
+			return d.DialContext(ctx, network, addr)
+		}
+		if ips = b.ofIPVersion(ips); len(ips) == 0 {
+			return nil, fmt.Errorf("dial %s: no IPv%d address for %s", network, b.IPVersion, host)
+		}
+		for _, ip := range ips {
+			var conn net.Conn
+			if conn, err = d.DialContext(ctx, network, net.JoinHostPort(ip, port)); err == nil {
+				return conn, nil
+			}
+		}
+		return nil, err
+	}
+}
+
+// ofIPVersion returns the addresses of ips of the IPVersion of the run,
+// or all of them if it is not set.
+func (b *Boomer) ofIPVersion(ips []string) []string {
+	if b.IPVersion == 0 {
+		return ips
+	}
+	var of []string
+	for _, ip := range ips {
+		if p := net.ParseIP(ip); p != nil && (p.To4() != nil) == (b.IPVersion == 4) {
+			of = append(of, ip)
+		}
+	}
+	return of
+}
+
+// addrFamily returns the address family of addr, "IPv4" or "IPv6", or
+// the empty string if it is not an IP address.
+func addrFamily(addr net.Addr) string {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return ""
+	}
+	ip := net.ParseIP(host)
+	switch {
+	case ip == nil:
+		return ""
+	case ip.To4() != nil:
+		return "IPv4"
+	}
+	return "IPv6"
+}
+
+// FamilyStats describes the responses received over the connections of
+// an address family.
+type FamilyStats struct {
+	// Family is "IPv4" or "IPv6".
+	Family    string `json:"family"`
+	Responses int    `json:"responses"`
+
+	// Conns is the number of connections of the family dialed during
+	// the run.
+	Conns int `json:"conns"`
+}
+
+func (r *Report) addFamily(res *result) {
+	if res.family == "" {
+		return
+	}
+	if r.families == nil {
+		r.families = make(map[string]*FamilyStats)
+	}
+	f, ok := r.families[res.family]
+	if !ok {
+		f = &FamilyStats{Family: res.family}
+		r.families[res.family] = f
+	}
+	f.Responses++
+	if res.newConn {
+		f.Conns++
+	}
+}
+
+func (r *Report) printFamilies() {
+	for _, f := range r.families {
+		r.Families = append(r.Families, *f)
+	}
+	sort.Slice(r.Families, func(i, j int) bool {
+		return r.Families[i].Family < r.Families[j].Family
+	})
+}
@@ -0,0 +1,134 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"context"
+	"net"
+	"net/http/httptrace"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// dnsCache resolves hosts for the dialer of a run, keeping the
+// addresses of each host for a TTL. Concurrent lookups of a host share
+// one query, and failed lookups are not kept.
+type dnsCache struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]*dnsEntry
+}
+
+type dnsEntry struct {
+	done    chan struct{} // closed once the lookup completed
+	ips     []string
+	err     error
+	expires time.Time
+}
+
+func newDNSCache(ttl time.Duration) *dnsCache {
+	return &dnsCache{ttl: ttl, entries: make(map[string]*dnsEntry)}
+}
+
+// lookup returns the addresses of host, resolving it again if they
+// were resolved over a TTL ago. The lookup is reported to the client
+// trace of ctx if it is made for it.
+func (c *dnsCache) lookup(ctx context.Context, host string) ([]string, error) {
+	c.mu.Lock()
+	e, ok := c.entries[host]
+	if ok {
+		select {
+		case <-e.done:
+			ok = time.Now().Before(e.expires)
+		default:
+		}
+	}
+	trace := httptrace.ContextClientTrace(ctx)
+	if !ok {
+		e = &dnsEntry{done: make(chan struct{})}
+		c.entries[host] = e
+		if trace != nil && trace.DNSStart != nil {
+			trace.DNSStart(httptrace.DNSStartInfo{Host: host})
+		}
+		// The query outlives ctx, as other requests may be waiting for
+		// it.
+		go c.resolve(context.WithoutCancel(ctx), host, e)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-e.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if !ok && trace != nil && trace.DNSDone != nil {
+		addrs := make([]net.IPAddr, 0, len(e.ips))
+		for _, ip := range e.ips {
+			addrs = append(addrs, net.IPAddr{IP: net.ParseIP(ip)})
+		}
+		trace.DNSDone(httptrace.DNSDoneInfo{Addrs: addrs, Err: e.err})
+	}
+	return e.ips, e.err
+}
+
+func (c *dnsCache) resolve(ctx context.Context, host string, e *dnsEntry) {
+	e.ips, e.err = net.DefaultResolver.LookupHost(ctx, host)
+	e.expires = time.Now().Add(c.ttl)
+	close(e.done)
+	if e.err != nil {
+		c.mu.Lock()
+		if c.entries[host] == e {
+			delete(c.entries, host)
+		}
+		c.mu.Unlock()
+	}
+}
+
+// DNSStats summarizes the DNS lookups made to dial the connections of
+// a run.
+type DNSStats struct {
+	Lookups  int `json:"lookups"`
+	Failures int `json:"failures"`
+
+	// Latency is the latency of the lookups that completed. Merge only
+	// combines the latencies of reports made in the same process.
+	Latency LatencyStats `json:"latency"`
+}
+
+func (r *Report) addDNS(res *result) {
+	n := atomic.LoadInt32(&res.dnsLookups)
+	if n == 0 {
+		return
+	}
+	r.dnsLookups += int(n)
+	r.dnsFailures += int(atomic.LoadInt32(&res.dnsFailures))
+	start, done := atomic.LoadInt64(&res.marks[markDNSStart]), atomic.LoadInt64(&res.marks[markDNSDone])
+	if done >= start {
+		r.dnsLats.add(float64(done-start) / float64(time.Millisecond))
+	}
+}
+
+func (r *Report) printDNS() {
+	r.DNS = nil
+	if r.dnsLookups == 0 {
+		return
+	}
+	r.DNS = &DNSStats{
+		Lookups:  r.dnsLookups,
+		Failures: r.dnsFailures,
+		Latency:  r.dnsLats.stats(),
+	}
+}
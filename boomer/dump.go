@@ -0,0 +1,237 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// dumpMagic starts every dump.
+const dumpMagic = "BOOMDUMP\x01"
+
+// The kinds of entries of a dump.
+const (
+	dumpRequest = 1
+	dumpEnd     = 2
+)
+
+// dumpSink writes records in the binary format read by ReadDump.
+type dumpSink struct {
+	w       *bufio.Writer
+	strings map[string]uint64
+	start   int64
+	flush   time.Time
+	buf     []byte
+}
+
+// NewDumpSink returns a sink writing every request to w as it
+// completes, in a compact binary format of about a dozen bytes per
+// request, so that all the raw measurements of a long run are kept
+// without holding them in memory. ReadDump derives a report from them
+// afterwards. Entries are flushed at least every second.
+//
+// A dump starts with "BOOMDUMP\x01", followed by an entry per request:
+// the byte 1, the start of the request in ns since the start of the
+// previous one as a zigzag varint, its latency in ns, its status code,
+// its size as a zigzag varint, then its endpoint, method, error and the
+// phase it was aborted at as strings. A string is a uvarint: 0 for the
+// empty string, i for the i-th distinct string of the dump, or one more
+// than the number of strings so far for a new one, followed by its
+// length and bytes. The dump ends with the byte 2 and the duration of
+// the run in ns once it is done.
+func NewDumpSink(w io.Writer) Sink {
+	bw := bufio.NewWriter(w)
+	bw.WriteString(dumpMagic)
+	return &dumpSink{w: bw, strings: make(map[string]uint64), flush: time.Now()}
+}
+
+func (s *dumpSink) Record(rec Record) error {
+	var errMsg string
+	if rec.Err != nil {
+		errMsg = rec.Err.Error()
+	}
+	start := rec.Start.UnixNano()
+	b := append(s.buf[:0], dumpRequest)
+	b = binary.AppendVarint(b, start-s.start)
+	b = binary.AppendUvarint(b, uint64(rec.Duration))
+	b = binary.AppendUvarint(b, uint64(rec.StatusCode))
+	b = binary.AppendVarint(b, rec.Size)
+	for _, v := range []string{rec.Endpoint, rec.Method, errMsg, rec.Aborted} {
+		b = s.appendString(b, v)
+	}
+	s.start, s.buf = start, b
+	if _, err := s.w.Write(b); err != nil {
+		return err
+	}
+	if now := time.Now(); now.Sub(s.flush) >= sinkFlushInterval {
+		s.flush = now
+		return s.w.Flush()
+	}
+	return nil
+}
+
+func (s *dumpSink) appendString(b []byte, v string) []byte {
+	if v == "" {
+		return binary.AppendUvarint(b, 0)
+	}
+	if i, ok := s.strings[v]; ok {
+		return binary.AppendUvarint(b, i)
+	}
+	i := uint64(len(s.strings)) + 1
+	s.strings[v] = i
+	b = binary.AppendUvarint(b, i)
+	b = binary.AppendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+func (s *dumpSink) Close(r *Report) error {
+	b := binary.AppendUvarint([]byte{dumpEnd}, uint64(r.total))
+	if _, err := s.w.Write(b); err != nil {
+		return err
+	}
+	return s.w.Flush()
+}
+
+// ReadDump reads the requests written by NewDumpSink and derives the
+// report of the run from them, with its percentiles computed exactly.
+// The report of a run that did not end, e.g. because it crashed, covers
+// the requests dumped until then, a request cut short excepted.
+func ReadDump(r io.Reader) (*Report, error) {
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(dumpMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || string(magic) != dumpMagic {
+		return nil, errors.New("not a dump")
+	}
+	d := &dumpReader{r: br}
+	report := newReport(0, nil, 0)
+	report.keepLats = true
+	report.interval = DefaultInterval
+	var end time.Time
+	for {
+		kind, err := br.ReadByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if kind == dumpEnd {
+			total, err := binary.ReadUvarint(br)
+			if err != nil {
+				break
+			}
+			report.total = time.Duration(total)
+			break
+		}
+		if kind != dumpRequest {
+			return nil, fmt.Errorf("invalid dump entry %d", kind)
+		}
+		res, err := d.request()
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if report.start.IsZero() || res.start.Before(report.start) {
+			report.start = res.start
+		}
+		if e := res.start.Add(res.duration); e.After(end) {
+			end = e
+		}
+		report.add(res)
+	}
+	if report.total == 0 && !end.IsZero() {
+		report.total = end.Sub(report.start)
+	}
+	report.finalize()
+	return report, nil
+}
+
+// dumpReader decodes the requests of a dump.
+type dumpReader struct {
+	r       *bufio.Reader
+	strings []string
+	start   int64
+}
+
+func (d *dumpReader) request() (*result, error) {
+	delta, err := binary.ReadVarint(d.r)
+	if err != nil {
+		return nil, err
+	}
+	var fields [2]uint64
+	for i := range fields {
+		if fields[i], err = binary.ReadUvarint(d.r); err != nil {
+			return nil, err
+		}
+	}
+	size, err := binary.ReadVarint(d.r)
+	if err != nil {
+		return nil, err
+	}
+	var strs [4]string
+	for i := range strs {
+		if strs[i], err = d.string(); err != nil {
+			return nil, err
+		}
+	}
+	d.start += delta
+	res := &result{
+		start:         time.Unix(0, d.start),
+		duration:      time.Duration(fields[0]),
+		statusCode:    int(fields[1]),
+		contentLength: size,
+		endpoint:      strs[0],
+		method:        strs[1],
+		aborted:       strs[3],
+	}
+	if strs[2] != "" {
+		res.err = errors.New(strs[2])
+	}
+	return res, nil
+}
+
+func (d *dumpReader) string() (string, error) {
+	i, err := binary.ReadUvarint(d.r)
+	switch {
+	case err != nil:
+		return "", err
+	case i == 0:
+		return "", nil
+	case i <= uint64(len(d.strings)):
+		return d.strings[i-1], nil
+	case i > uint64(len(d.strings))+1:
+		return "", fmt.Errorf("invalid dump string %d", i)
+	}
+	n, err := binary.ReadUvarint(d.r)
+	if err != nil {
+		return "", err
+	}
+	if n > 1<<20 {
+		return "", fmt.Errorf("invalid dump string length %d", n)
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(d.r, b); err != nil {
+		return "", err
+	}
+	d.strings = append(d.strings, string(b))
+	return string(b), nil
+}
@@ -0,0 +1,67 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestDump(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+		}
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	var buf bytes.Buffer
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	report := runBoomer(t, &Boomer{Request: req, N: 30, C: 3, KeepLatencies: true, Sinks: []Sink{NewDumpSink(&buf)}, Endpoints: []Endpoint{
+		{URL: server.URL + "/ok"},
+		{URL: server.URL + "/missing"},
+		{URL: "http://127.0.0.1:1/refused"},
+	}})
+
+	dumped, err := ReadDump(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(dumped.StatusCodes, report.StatusCodes) || !reflect.DeepEqual(dumped.Errors, report.Errors) {
+		t.Errorf("Expected status codes %+v and errors %+v, found %+v and %+v", report.StatusCodes, report.Errors, dumped.StatusCodes, dumped.Errors)
+	}
+	if dumped.TotalDuration != report.TotalDuration || dumped.Percentile(50) != report.Percentile(50) || dumped.Slowest != report.Slowest {
+		t.Errorf("Expected the latencies of the run, found %+v", dumped)
+	}
+	if len(buf.Bytes()) > 30*40 {
+		t.Errorf("Expected a compact dump, found %d bytes for 30 requests", buf.Len())
+	}
+
+	// A dump cut short covers the requests written out in full.
+	cut, err := ReadDump(bytes.NewReader(buf.Bytes()[:buf.Len()-8]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := cut.requests(); n != 29 {
+		t.Errorf("Expected 29 requests in a dump cut short, found %d", n)
+	}
+	if _, err := ReadDump(bytes.NewReader([]byte("start,endpoint\n"))); err == nil {
+		t.Errorf("Expected an error reading a file that is not a dump")
+	}
+}
@@ -0,0 +1,314 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Endpoint is one of the URLs a run spreads its requests over.
+type Endpoint struct {
+	// Name, if set, names the endpoint in Report.Endpoints. The
+	// requests to endpoints of the same name are reported together.
+	Name string
+
+	// Method is the method of the requests to the URL, or that of
+	// Request if empty.
+	Method string
+	URL    string
+
+	// Header is added to the header of Request, replacing the values of
+	// the names it holds.
+	Header http.Header
+
+	// Body, if not empty, is sent instead of RequestBody.
+	Body string
+
+	// Weight is the share of the requests sent to the endpoint,
+	// relative to the weights of the others. Zero counts as one.
+	Weight int
+}
+
+func (e Endpoint) weight() int {
+	if e.Weight <= 0 {
+		return 1
+	}
+	return e.Weight
+}
+
+// EndpointStats describes the requests sent to one of the Endpoints of
+// a run, or made by one of the steps of its Scenario.
+type EndpointStats struct {
+	// Name is the name of the endpoint or step, if it has one. Method
+	// and URL are then those of the first endpoint of the name.
+	Name string `json:"name,omitempty"`
+
+	Method   string `json:"method"`
+	URL      string `json:"url"`
+	Requests int    `json:"requests"`
+
+	// Errors is the number of requests that failed without a response,
+	// including those that timed out, and ErrorResponses the number of
+	// responses of error status codes. ErrorRate is the fraction of the
+	// requests that were either.
+	Errors         int     `json:"errors"`
+	ErrorRate      float64 `json:"error_rate"`
+	ErrorResponses int     `json:"error_responses"`
+
+	// Bytes is the total size of the response bodies.
+	Bytes int64 `json:"bytes"`
+
+	// Average, P50, P90 and P99 are latencies of the successful
+	// requests, in ms.
+	Average float64 `json:"average"`
+	P50     float64 `json:"p50"`
+	P90     float64 `json:"p90"`
+	P99     float64 `json:"p99"`
+}
+
+// endpointSamples holds the latencies of the responses of an endpoint,
+// the number of failed requests and of error responses and the size of
+// the responses.
+type endpointSamples struct {
+	// name is the name of the endpoint, if it has one, and index its
+	// position among the endpoints or steps of the run.
+	name        string
+	index       int
+	method, url string
+
+	lats           latencySamples
+	errors         int
+	errorResponses int
+	bytes          int64
+}
+
+// endpointKey is the context key of the index of the endpoint of a
+// request.
+type endpointKey struct{}
+
+// endpointPicker spreads requests over endpoints in proportion to their
+// weights. The i-th request goes to the endpoint owning slot i*stride
+// mod total of the cumulative weights: stride is coprime with total, so
+// every slot is visited once every total requests, and far from total
+// and from one, so the endpoints' turns are interleaved rather than
+// bunched.
+type endpointPicker struct {
+	endpoints []Endpoint
+	cum       []int
+	stride    int
+
+	// urls are the parsed URLs of the endpoints, and errs the errors
+	// parsing those that are invalid.
+	urls []*url.URL
+	errs []error
+}
+
+func newEndpointPicker(endpoints []Endpoint) *endpointPicker {
+	if len(endpoints) == 0 {
+		return nil
+	}
+	p := &endpointPicker{
+		endpoints: endpoints,
+		cum:       make([]int, len(endpoints)),
+		urls:      make([]*url.URL, len(endpoints)),
+		errs:      make([]error, len(endpoints)),
+	}
+	total := 0
+	for i, e := range endpoints {
+		total += e.weight()
+		p.cum[i] = total
+		p.urls[i], p.errs[i] = url.Parse(e.URL)
+	}
+	p.stride = total*618/1000 + 1
+	for gcd(p.stride, total) != 1 {
+		p.stride++
+	}
+	return p
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// pick returns the index of the endpoint of the i-th request. If
+// inOrder is set, the endpoints are taken in turn regardless of their
+// weights.
+func (p *endpointPicker) pick(i int, inOrder bool) int {
+	if inOrder {
+		return i % len(p.cum)
+	}
+	total := p.cum[len(p.cum)-1]
+	slot := int(uint64(i) * uint64(p.stride) % uint64(total))
+	return sort.SearchInts(p.cum, slot+1)
+}
+
+// endpoints returns the endpoints requests are sent to: those of the
+// steps of the Scenario, if any, or Endpoints.
+func (b *Boomer) endpoints() []Endpoint {
+	if len(b.Scenario) == 0 {
+		return b.Endpoints
+	}
+	endpoints := make([]Endpoint, len(b.Scenario))
+	for i, s := range b.Scenario {
+		endpoints[i] = s.Endpoint
+	}
+	return endpoints
+}
+
+// setEndpoint sends req to the endpoint of index idx. Requests to an
+// endpoint whose URL is invalid keep the URL of Request and fail with
+// endpointErr before being sent.
+func (b *Boomer) setEndpoint(req *http.Request, idx int) *http.Request {
+	if u := b.picker.urls[idx]; u != nil {
+		if req.Host == "" || req.Host == req.URL.Host {
+			req.Host = u.Host
+		}
+		u := *u
+		req.URL = &u
+	}
+	e := b.picker.endpoints[idx]
+	if e.Method != "" {
+		req.Method = e.Method
+	}
+	for k, v := range e.Header {
+		req.Header[k] = append([]string(nil), v...)
+	}
+	if e.Body != "" {
+		setBody(req, strings.NewReader(e.Body))
+	}
+	return req.WithContext(context.WithValue(req.Context(), endpointKey{}, idx))
+}
+
+// endpointErr returns the error parsing the URL of the endpoint of req,
+// if any.
+func (b *Boomer) endpointErr(req *http.Request) error {
+	if idx := endpointOf(req); idx >= 0 {
+		return b.picker.errs[idx]
+	}
+	return nil
+}
+
+// endpointOf returns the index of the endpoint of req, or -1 if
+// requests are not spread over Endpoints or steps.
+func endpointOf(req *http.Request) int {
+	if idx, ok := req.Context().Value(endpointKey{}).(int); ok {
+		return idx
+	}
+	return -1
+}
+
+// target sets the URL, name and index of the endpoint req was sent to
+// as reported in res.
+func (b *Boomer) target(req *http.Request, res *result) {
+	idx := endpointOf(req)
+	if idx < 0 {
+		return
+	}
+	e := b.picker.endpoints[idx]
+	res.target, res.targetName, res.targetIndex = e.URL, e.Name, idx
+	if b.Redact != nil {
+		res.target = b.Redact.URL(res.target)
+	}
+}
+
+func (r *Report) addEndpoint(res *result) {
+	// Endpoints are told apart by their names, if they have any.
+	key := "\x00" + res.method + " " + res.target
+	if res.targetName != "" {
+		key = res.targetName
+	}
+	s, ok := r.endpoints[key]
+	if !ok {
+		s = &endpointSamples{name: res.targetName, index: res.targetIndex, method: res.method, url: res.target}
+		r.endpoints[key] = s
+	}
+	if res.targetIndex < s.index {
+		s.index, s.method, s.url = res.targetIndex, res.method, res.target
+	}
+	if res.err != nil || res.timeout != "" {
+		s.errors++
+		return
+	}
+	if !r.succeeded(res) {
+		s.errorResponses++
+	}
+	if res.contentLength > 0 {
+		s.bytes += res.contentLength
+	}
+	s.lats.add(res.duration.Seconds() * 1000)
+}
+
+// mergeEndpoints adds the endpoint samples of o to those of r.
+func (r *Report) mergeEndpoints(o *Report) {
+	for key, s := range o.endpoints {
+		m, ok := r.endpoints[key]
+		if !ok {
+			m = &endpointSamples{name: s.name, index: s.index, method: s.method, url: s.url}
+			r.endpoints[key] = m
+		}
+		if s.index < m.index {
+			m.index, m.method, m.url = s.index, s.method, s.url
+		}
+		m.lats.merge(&s.lats)
+		m.errors += s.errors
+		m.errorResponses += s.errorResponses
+		m.bytes += s.bytes
+	}
+}
+
+func (r *Report) printEndpoints() {
+	samples := make([]*endpointSamples, 0, len(r.endpoints))
+	for _, s := range r.endpoints {
+		samples = append(samples, s)
+	}
+	sort.Slice(samples, func(i, j int) bool {
+		a, b := samples[i], samples[j]
+		switch {
+		case a.index != b.index:
+			return a.index < b.index
+		case a.url != b.url:
+			return a.url < b.url
+		}
+		return a.method < b.method
+	})
+	r.Endpoints = nil
+	for _, s := range samples {
+		lats := s.lats.stats()
+		st := EndpointStats{
+			Name:           s.name,
+			Method:         s.method,
+			URL:            s.url,
+			Requests:       lats.Count + s.errors,
+			Errors:         s.errors,
+			ErrorResponses: s.errorResponses,
+			Bytes:          s.bytes,
+			Average:        lats.Average,
+			P50:            lats.P50,
+			P90:            lats.P90,
+			P99:            lats.P99,
+		}
+		if st.Requests > 0 {
+			st.ErrorRate = float64(st.Errors+st.ErrorResponses) / float64(st.Requests)
+		}
+		r.Endpoints = append(r.Endpoints, st)
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestEndpointPicker(t *testing.T) {
+	p := newEndpointPicker([]Endpoint{{Weight: 3}, {}, {Weight: 6}})
+	counts := make([]int, 3)
+	for i := 0; i < 10; i++ {
+		counts[p.pick(i, false)]++
+	}
+	if counts[0] != 3 || counts[1] != 1 || counts[2] != 6 {
+		t.Errorf("Expected 3, 1 and 6 of 10 picks, found %v", counts)
+	}
+	// Picks of the heaviest endpoint should be spread out, not bunched.
+	run := 0
+	for i := 0; i < 10; i++ {
+		if p.pick(i, false) != 2 {
+			run = 0
+		} else if run++; run > 3 {
+			t.Errorf("Expected interleaved picks, found %v in a row", run)
+		}
+	}
+}
+
+func TestEndpoints(t *testing.T) {
+	var mu sync.Mutex
+	seen := make(map[string]int)
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen[r.Method+" "+r.URL.Path]++
+		mu.Unlock()
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(500)
+		}
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	boomer := &Boomer{
+		Request: req,
+		N:       40,
+		C:       4,
+		Endpoints: []Endpoint{
+			{URL: server.URL + "/read", Weight: 3},
+			{Method: "POST", URL: server.URL + "/write"},
+			{URL: "://invalid"},
+		},
+	}
+	report := runBoomer(t, boomer)
+	if seen["GET /read"] != 24 || seen["POST /write"] != 8 || len(seen) != 2 {
+		t.Errorf("Expected 24 reads and 8 writes, found %v", seen)
+	}
+	if len(report.Endpoints) != 3 {
+		t.Fatalf("Expected 3 endpoints, found %+v", report.Endpoints)
+	}
+	for _, e := range report.Endpoints {
+		switch e.URL {
+		case server.URL + "/read":
+			if e.Method != "GET" || e.Requests != 24 || e.Errors != 0 || e.P99 <= 0 {
+				t.Errorf("Unexpected read endpoint %+v", e)
+			}
+		case server.URL + "/write":
+			if e.Method != "POST" || e.Requests != 8 {
+				t.Errorf("Unexpected write endpoint %+v", e)
+			}
+		default:
+			if e.Requests != 8 || e.Errors != 8 || e.ErrorRate != 1 {
+				t.Errorf("Expected the invalid endpoint to fail, found %+v", e)
+			}
+		}
+	}
+}
+
+func TestNamedEndpoints(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/items/2" {
+			w.WriteHeader(404)
+		}
+		w.Write([]byte("0123456789"))
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	report := runBoomer(t, &Boomer{Request: req, N: 30, C: 3, Endpoints: []Endpoint{
+		{Name: "item", URL: server.URL + "/items/1"},
+		{Name: "item", URL: server.URL + "/items/2"},
+		{URL: server.URL + "/health"},
+	}})
+	if len(report.Endpoints) != 2 {
+		t.Fatalf("Expected the items to be reported together, found %+v", report.Endpoints)
+	}
+	item, health := report.Endpoints[0], report.Endpoints[1]
+	if item.Name != "item" || item.URL != server.URL+"/items/1" || item.Requests != 20 ||
+		item.ErrorResponses != 10 || item.Bytes != 200 || item.ErrorRate != 0.5 {
+		t.Errorf("Unexpected item endpoint %+v", item)
+	}
+	if health.Name != "" || health.Requests != 10 || health.ErrorResponses != 0 || health.Bytes != 100 {
+		t.Errorf("Unexpected health endpoint %+v", health)
+	}
+}
@@ -0,0 +1,253 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FeedMode is the order in which the rows of a feed are handed out.
+type FeedMode int
+
+const (
+	// Sequential hands rows out in order, starting over after the last.
+	Sequential FeedMode = iota
+	// Random hands out rows picked at random, with replacement.
+	Random
+	// Unique hands out every row once, in order. The run stops once
+	// all are, e.g. so each account of a create test is used once.
+	Unique
+)
+
+// ParseFeedMode parses "sequential", "random" or "unique".
+func ParseFeedMode(s string) (FeedMode, error) {
+	for m := Sequential; m <= Unique; m++ {
+		if m.String() == s {
+			return m, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid feed mode %q", s)
+}
+
+func (m FeedMode) String() string {
+	switch m {
+	case Sequential:
+		return "sequential"
+	case Random:
+		return "random"
+	}
+	return "unique"
+}
+
+// Feed is a table of data, such as user IDs or tokens, filling in the
+// templates of requests. Each request is given a row of every feed it
+// uses, so values of the same row go together.
+type Feed struct {
+	Name string
+	Mode FeedMode
+
+	// Rows map the columns of the feed to their values.
+	Rows []map[string]string
+
+	mu   sync.Mutex
+	next int
+	rand *rand.Rand
+}
+
+// ReadFeed reads the rows of a feed from path: a CSV file whose first
+// record names the columns, or, for files ending in .jsonl, .ndjson or
+// .json, a JSON object per line whose fields are the columns. A .json
+// file may also hold an array of such objects.
+func ReadFeed(name, path string, mode FeedMode) (*Feed, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var rows []map[string]string
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jsonl", ".ndjson":
+		rows, err = readJSONLines(f)
+	case ".json":
+		rows, err = readJSON(f)
+	default:
+		rows, err = readCSV(f)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("feed %s: %v", name, err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("feed %s: %s has no rows", name, path)
+	}
+	return &Feed{Name: name, Mode: mode, Rows: rows}, nil
+}
+
+func readCSV(r io.Reader) ([]map[string]string, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil || len(records) == 0 {
+		return nil, err
+	}
+	columns := records[0]
+	rows := make([]map[string]string, 0, len(records)-1)
+	for _, rec := range records[1:] {
+		row := make(map[string]string, len(columns))
+		for i, c := range columns {
+			row[c] = rec[i]
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// readJSON reads a JSON array of objects, or JSON lines.
+func readJSON(r io.Reader) ([]map[string]string, error) {
+	br := bufio.NewReader(r)
+	for {
+		c, _, err := br.ReadRune()
+		if err == io.EOF {
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if strings.ContainsRune(" \t\r\n", c) {
+			continue
+		}
+		br.UnreadRune()
+		if c != '[' {
+			return readJSONLines(br)
+		}
+		break
+	}
+	var objects []map[string]json.RawMessage
+	if err := json.NewDecoder(br).Decode(&objects); err != nil {
+		return nil, err
+	}
+	rows := make([]map[string]string, len(objects))
+	for i, fields := range objects {
+		rows[i] = jsonRow(fields)
+	}
+	return rows, nil
+}
+
+func readJSONLines(r io.Reader) ([]map[string]string, error) {
+	var rows []map[string]string
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1<<20)
+	for line := 1; s.Scan(); line++ {
+		data := bytes.TrimSpace(s.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		rows = append(rows, jsonRow(fields))
+	}
+	return rows, s.Err()
+}
+
+// jsonRow returns the row of the fields of a JSON object.
+func jsonRow(fields map[string]json.RawMessage) map[string]string {
+	row := make(map[string]string, len(fields))
+	for k, v := range fields {
+		// Strings are used unquoted, other values as JSON.
+		var str string
+		if json.Unmarshal(v, &str) == nil {
+			row[k] = str
+		} else {
+			row[k] = string(v)
+		}
+	}
+	return row
+}
+
+// row returns the next row of the feed, or false if a Unique feed is
+// exhausted.
+func (f *Feed) row() (map[string]string, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch f.Mode {
+	case Random:
+		if f.rand == nil {
+			f.rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+		}
+		return f.Rows[f.rand.Intn(len(f.Rows))], true
+	case Unique:
+		if f.next >= len(f.Rows) {
+			return nil, false
+		}
+	}
+	row := f.Rows[f.next%len(f.Rows)]
+	f.next++
+	return row, true
+}
+
+// drawn returns the number of rows drawn in order from the feed.
+func (f *Feed) drawn() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.next
+}
+
+// seek makes n the number of rows drawn in order from the feed.
+func (f *Feed) seek(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.next = n
+}
+
+// feedValue returns column of the row of the named feed drawn for the
+// worker's current request, drawing one if none was.
+func (w *worker) feedValue(b *Boomer, name, column string) (string, error) {
+	row, ok := w.rows[name]
+	if !ok {
+		var f *Feed
+		for _, feed := range b.Feeds {
+			if feed.Name == name {
+				f = feed
+				break
+			}
+		}
+		if f == nil {
+			return "", fmt.Errorf("no feed %q", name)
+		}
+		if row, ok = f.row(); !ok {
+			err := fmt.Errorf("feed %s exhausted", name)
+			b.stop(err.Error())
+			return "", err
+		}
+		if w.rows == nil {
+			w.rows = make(map[string]map[string]string)
+		}
+		w.rows[name] = row
+	}
+	v, ok := row[column]
+	if !ok {
+		return "", fmt.Errorf("feed %s has no column %q", name, column)
+	}
+	return v, nil
+}
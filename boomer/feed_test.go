@@ -0,0 +1,115 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestReadFeed(t *testing.T) {
+	dir := t.TempDir()
+	csvPath := filepath.Join(dir, "users.csv")
+	ioutil.WriteFile(csvPath, []byte("id,token\n1,a\n2,b\n"), 0644)
+	jsonPath := filepath.Join(dir, "users.jsonl")
+	ioutil.WriteFile(jsonPath, []byte(`{"id": 1, "token": "a"}`+"\n\n"+`{"id": 2, "token": "b"}`+"\n"), 0644)
+
+	arrayPath := filepath.Join(dir, "users.json")
+	ioutil.WriteFile(arrayPath, []byte("\n[\n  {\"id\": 1, \"token\": \"a\"},\n  {\"id\": 2, \"token\": \"b\"}\n]\n"), 0644)
+
+	for _, path := range []string{csvPath, jsonPath, arrayPath} {
+		f, err := ReadFeed("users", path, Random)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(f.Rows) != 2 || f.Rows[1]["id"] != "2" || f.Rows[1]["token"] != "b" || f.Mode != Random {
+			t.Errorf("%v: unexpected feed %+v", path, f)
+		}
+	}
+}
+
+// feedServer records the paths requested.
+func feedServer() (*httptest.Server, func() []string) {
+	var mu sync.Mutex
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+	}))
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		sort.Strings(paths)
+		return paths
+	}
+}
+
+func TestFeedModes(t *testing.T) {
+	rows := []map[string]string{{"id": "1", "name": "a"}, {"id": "2", "name": "b"}, {"id": "3", "name": "c"}}
+	for _, tt := range []struct {
+		mode    FeedMode
+		n       int
+		want    string
+		stopped string
+	}{
+		{Sequential, 6, "/1/a /1/a /2/b /2/b /3/c /3/c", ""},
+		{Unique, 6, "/1/a /2/b /3/c", "feed users exhausted"},
+	} {
+		server, paths := feedServer()
+		req, _ := http.NewRequest("GET", server.URL+`/{{feed "users" "id"}}/{{feed "users" "name"}}`, nil)
+		boomer := &Boomer{
+			Request:  req,
+			N:        tt.n,
+			C:        2,
+			Template: true,
+			Feeds:    []*Feed{{Name: "users", Mode: tt.mode, Rows: rows}},
+		}
+		r := runBoomer(t, boomer)
+		server.Close()
+		if got := strings.Join(paths(), " "); got != tt.want {
+			t.Errorf("%v: expected requests %v, found %v", tt.mode, tt.want, got)
+		}
+		if r.Stopped != tt.stopped || r.ErrorCount() != 0 {
+			t.Errorf("%v: expected stopped %q without errors, found %q and %v", tt.mode, tt.stopped, r.Stopped, r.Errors)
+		}
+	}
+}
+
+func TestRandomFeed(t *testing.T) {
+	server, paths := feedServer()
+	defer server.Close()
+	rows := []map[string]string{{"id": "1", "name": "a"}, {"id": "2", "name": "b"}}
+	req, _ := http.NewRequest("GET", server.URL+`/{{feed "users" "id"}}{{feed "users" "name"}}`, nil)
+	boomer := &Boomer{
+		Request:  req,
+		N:        20,
+		C:        2,
+		Template: true,
+		Feeds:    []*Feed{{Name: "users", Mode: Random, Rows: rows}},
+	}
+	runBoomer(t, boomer)
+	for _, p := range paths() {
+		if p != "/1a" && p != "/2b" {
+			t.Errorf("expected the columns of a row, found %v", p)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// GraphQL is a GraphQL operation.
+type GraphQL struct {
+	// Query is the document holding the query or mutation, and
+	// OperationName the operation of it to execute, if it holds several.
+	Query         string
+	OperationName string
+	Variables     map[string]interface{}
+}
+
+// Body returns the JSON body of a POST request executing the operation.
+func (q *GraphQL) Body() (string, error) {
+	data, err := json.Marshal(struct {
+		Query         string                 `json:"query"`
+		OperationName string                 `json:"operationName,omitempty"`
+		Variables     map[string]interface{} `json:"variables,omitempty"`
+	}{q.Query, q.OperationName, q.Variables})
+	return string(data), err
+}
+
+// graphqlError returns the first of the errors in the GraphQL response
+// body, if any. Bodies that are not GraphQL responses carry none.
+func graphqlError(body []byte) error {
+	var resp struct {
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if json.Unmarshal(body, &resp) != nil || len(resp.Errors) == 0 {
+		return nil
+	}
+	return errors.New("graphql: " + resp.Errors[0].Message)
+}
@@ -0,0 +1,56 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestGraphQL(t *testing.T) {
+	var n int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var op struct {
+			Query         string
+			OperationName string
+			Variables     map[string]interface{}
+		}
+		if err := json.NewDecoder(r.Body).Decode(&op); err != nil || op.OperationName != "Item" || op.Variables["id"] != "1" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if atomic.AddInt32(&n, 1)%2 == 0 {
+			w.Write([]byte(`{"data": null, "errors": [{"message": "item not found"}]}`))
+			return
+		}
+		w.Write([]byte(`{"data": {"item": {"name": "a"}}}`))
+	}))
+	defer server.Close()
+
+	q := &GraphQL{Query: "query Item($id: ID!) { item(id: $id) { name } }", OperationName: "Item", Variables: map[string]interface{}{"id": "1"}}
+	body, err := q.Body()
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest("POST", server.URL, nil)
+	report := runBoomer(t, &Boomer{Request: req, RequestBody: body, N: 10, C: 1, GraphQL: true})
+	if report.StatusCount(200) != 5 || len(report.Errors) != 1 ||
+		report.Errors[0].Error != "graphql: item not found" || report.Errors[0].Count != 5 {
+		t.Errorf("expected 5 responses and 5 GraphQL errors, found %+v and %+v", report.StatusCodes, report.Errors)
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+)
+
+// grpcCodes are the names of the gRPC status codes.
+var grpcCodes = []string{
+	"OK", "CANCELLED", "UNKNOWN", "INVALID_ARGUMENT", "DEADLINE_EXCEEDED",
+	"NOT_FOUND", "ALREADY_EXISTS", "PERMISSION_DENIED", "RESOURCE_EXHAUSTED",
+	"FAILED_PRECONDITION", "ABORTED", "OUT_OF_RANGE", "UNIMPLEMENTED",
+	"INTERNAL", "UNAVAILABLE", "DATA_LOSS", "UNAUTHENTICATED",
+}
+
+// grpcUnknown is the code of calls whose response carries no status.
+const grpcUnknown = 2
+
+// GRPCCodeName returns the name of a gRPC status code, e.g.
+// "UNAVAILABLE" for 14, or the empty string if there is none.
+func GRPCCodeName(code int) string {
+	if code < 0 || code >= len(grpcCodes) {
+		return ""
+	}
+	return grpcCodes[code]
+}
+
+// grpcRequest makes req a unary gRPC call sending its body as the
+// request message.
+func grpcRequest(req *http.Request) error {
+	var msg []byte
+	if req.Body != nil {
+		var err error
+		if msg, err = ioutil.ReadAll(req.Body); err != nil {
+			return err
+		}
+		req.Body.Close()
+	}
+	setBody(req, bytes.NewReader(grpcFrame(msg)))
+	req.Method = "POST"
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("TE", "trailers")
+	return nil
+}
+
+// grpcFrame returns msg prefixed as an uncompressed message of a gRPC
+// stream.
+func grpcFrame(msg []byte) []byte {
+	frame := make([]byte, 5+len(msg))
+	binary.BigEndian.PutUint32(frame[1:5], uint32(len(msg)))
+	copy(frame[5:], msg)
+	return frame
+}
+
+// grpcMessage returns the first message of a gRPC stream, or as much
+// of it as data holds.
+func grpcMessage(data []byte) []byte {
+	if len(data) < 5 {
+		return nil
+	}
+	n := binary.BigEndian.Uint32(data[1:5])
+	if data = data[5:]; uint64(n) < uint64(len(data)) {
+		data = data[:n]
+	}
+	return data
+}
+
+// grpcStatus returns the gRPC status code of resp, whose body must have
+// been read for its trailers to be, or its HTTP status code if it is
+// not a successful HTTP response.
+func grpcStatus(resp *http.Response) int {
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode
+	}
+	v := resp.Trailer.Get("Grpc-Status")
+	if v == "" {
+		// A response without a message may carry it in its headers.
+		v = resp.Header.Get("Grpc-Status")
+	}
+	code, err := strconv.Atoi(v)
+	if err != nil {
+		return grpcUnknown
+	}
+	return code
+}
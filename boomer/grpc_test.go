@@ -0,0 +1,126 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// grpcServer returns a cleartext HTTP/2 server answering unary calls
+// with handler's response message and status code.
+func grpcServer(handler func(r *http.Request, msg []byte) ([]byte, string)) *httptest.Server {
+	s := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		if r.Header.Get("Content-Type") != "application/grpc" || len(data) < 5 {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		resp, code := handler(r, grpcMessage(data))
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Trailer", "Grpc-Status")
+		w.Write(grpcFrame(resp))
+		w.Header().Set("Grpc-Status", code)
+	}))
+	s.Config.Protocols = new(http.Protocols)
+	s.Config.Protocols.SetUnencryptedHTTP2(true)
+	s.Start()
+	return s
+}
+
+func TestGRPC(t *testing.T) {
+	var calls int32
+	server := grpcServer(func(r *http.Request, msg []byte) ([]byte, string) {
+		if r.URL.Path != "/pkg.Items/Get" || r.Header.Get("X-Tenant") != "a" || !bytes.Equal(msg, []byte{0x08, 0x01}) {
+			return nil, "3"
+		}
+		if atomic.AddInt32(&calls, 1)%2 == 0 {
+			return nil, "14"
+		}
+		return []byte{0x08, 0x02}, "0"
+	})
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL+"/pkg.Items/Get", nil)
+	req.Header.Set("X-Tenant", "a")
+	report := runBoomer(t, &Boomer{Request: req, RequestBody: "\x08\x01", N: 10, C: 2, GRPC: true})
+	if report.StatusCount(0) != 5 || report.StatusCount(14) != 5 || len(report.Errors) != 0 {
+		t.Errorf("expected 5 OK and 5 UNAVAILABLE calls, found %+v and errors %+v", report.StatusCodes, report.Errors)
+	}
+	if len(report.Protocols) != 1 || report.Protocols[0].Proto != "HTTP/2.0" {
+		t.Errorf("expected the calls to use HTTP/2, found %+v", report.Protocols)
+	}
+	var buf bytes.Buffer
+	report.WriteText(&buf)
+	if !strings.Contains(buf.String(), "[14 UNAVAILABLE]\t5 responses") {
+		t.Errorf("expected the status codes to be named, found:\n%s", buf.String())
+	}
+}
+
+func TestGRPCStreams(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Trailer", "Grpc-Status")
+		br := bufio.NewReader(r.Body)
+		switch r.URL.Path {
+		case "/pkg.Items/List":
+			// Server streaming: three messages for the request.
+			skipGRPCMessage(br)
+			for i := 0; i < 3; i++ {
+				w.Write(grpcFrame([]byte{0x08, byte(i)}))
+				w.(http.Flusher).Flush()
+			}
+		case "/pkg.Items/Chat":
+			// Bidirectional streaming: a message for every one received.
+			for skipGRPCMessage(br) == nil {
+				w.Write(grpcFrame([]byte{0x08, 0x01}))
+				w.(http.Flusher).Flush()
+			}
+		}
+		w.Header().Set("Grpc-Status", "0")
+	}))
+	server.Config.Protocols = new(http.Protocols)
+	server.Config.Protocols.SetUnencryptedHTTP2(true)
+	server.Start()
+	defer server.Close()
+
+	for _, tt := range []struct {
+		method, stream      string
+		messages, latencies int
+	}{
+		{"List", "server", 12, 8},
+		{"Chat", "bidi", 16, 16},
+	} {
+		req, _ := http.NewRequest("POST", server.URL+"/pkg.Items/"+tt.method, nil)
+		report := runBoomer(t, &Boomer{Request: req, N: 4, C: 2, GRPC: true, GRPCStream: tt.stream, StreamMessages: 4})
+		st := report.Stream
+		if st == nil || report.StatusCount(0) != 4 || len(report.Errors) != 0 {
+			t.Fatalf("%s: expected 4 OK streams, found %+v, %+v and errors %+v", tt.stream, st, report.StatusCodes, report.Errors)
+		}
+		if st.Streams != 4 || st.Messages != tt.messages || st.FirstMessage.Sketch.count() != 4 ||
+			st.Message.Sketch.count() != int64(tt.latencies) {
+			t.Errorf("%s: unexpected stream stats %+v", tt.stream, st)
+		}
+		if st.FirstMessage.P50 <= 0 || st.FirstMessage.Average > report.Slowest {
+			t.Errorf("%s: unexpected first message latency %+v", tt.stream, st.FirstMessage)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// harFile is the part of an HTTP Archive (HAR) needed to replay its
+// requests.
+type harFile struct {
+	Log struct {
+		Entries []struct {
+			StartedDateTime time.Time `json:"startedDateTime"`
+			Request         struct {
+				Method  string `json:"method"`
+				URL     string `json:"url"`
+				Headers []struct {
+					Name  string `json:"name"`
+					Value string `json:"value"`
+				} `json:"headers"`
+				PostData *struct {
+					MimeType string `json:"mimeType"`
+					Text     string `json:"text"`
+				} `json:"postData"`
+			} `json:"request"`
+		} `json:"entries"`
+	} `json:"log"`
+}
+
+// harSkipHeaders are the headers of recorded requests not replayed, as
+// the transport sets them itself.
+var harSkipHeaders = map[string]bool{
+	"Host":              true,
+	"Content-Length":    true,
+	"Connection":        true,
+	"Keep-Alive":        true,
+	"Transfer-Encoding": true,
+	"Upgrade":           true,
+}
+
+// ReadHAR reads the requests of an HTTP Archive (HAR) file, as exported
+// by browsers, as endpoints carrying their methods, headers and bodies,
+// in the order they were started. Replaying them with InOrder set
+// reproduces the recorded traffic.
+func ReadHAR(path string) ([]Endpoint, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var har harFile
+	if err := json.Unmarshal(data, &har); err != nil {
+		return nil, fmt.Errorf("invalid HAR file %s: %v", path, err)
+	}
+	entries := har.Log.Entries
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].StartedDateTime.Before(entries[j].StartedDateTime)
+	})
+	var endpoints []Endpoint
+	for _, en := range entries {
+		r := en.Request
+		if !strings.HasPrefix(r.URL, "http://") && !strings.HasPrefix(r.URL, "https://") {
+			// data: and blob: URLs never reach the network.
+			continue
+		}
+		e := Endpoint{Method: strings.ToUpper(r.Method), URL: r.URL, Header: make(http.Header)}
+		for _, h := range r.Headers {
+			// HTTP/2 pseudo-headers, e.g. :authority, are not headers.
+			if strings.HasPrefix(h.Name, ":") || harSkipHeaders[http.CanonicalHeaderKey(h.Name)] {
+				continue
+			}
+			e.Header.Add(h.Name, h.Value)
+		}
+		if r.PostData != nil {
+			e.Body = r.PostData.Text
+			if r.PostData.MimeType != "" && e.Header.Get("Content-Type") == "" {
+				e.Header.Set("Content-Type", r.PostData.MimeType)
+			}
+		}
+		endpoints = append(endpoints, e)
+	}
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("no requests in HAR file %s", path)
+	}
+	return endpoints, nil
+}
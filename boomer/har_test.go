@@ -0,0 +1,80 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+const testHAR = `{"log": {"entries": [
+  {"startedDateTime": "2024-05-01T10:00:01.000Z", "request": {
+    "method": "POST", "url": "%[1]s/api/cart",
+    "headers": [{"name": ":authority", "value": "shop"}, {"name": "X-Token", "value": "t1"}, {"name": "Content-Length", "value": "9"}],
+    "postData": {"mimeType": "application/json", "text": "{\"id\": 1}"}}},
+  {"startedDateTime": "2024-05-01T10:00:00.000Z", "request": {
+    "method": "GET", "url": "%[1]s/index.html", "headers": []}},
+  {"startedDateTime": "2024-05-01T10:00:00.500Z", "request": {
+    "method": "GET", "url": "data:image/png;base64,AAAA", "headers": []}},
+  {"startedDateTime": "2024-05-01T10:00:02.000Z", "request": {
+    "method": "GET", "url": "%[1]s/api/cart", "headers": []}}
+]}}`
+
+func TestHAR(t *testing.T) {
+	var mu sync.Mutex
+	var seen []string
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		mu.Lock()
+		seen = append(seen, fmt.Sprintf("%s %s %s %s %s", r.Method, r.URL.Path, r.Header.Get("X-Token"), r.Header.Get("Content-Type"), body))
+		mu.Unlock()
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "shop.har")
+	ioutil.WriteFile(path, []byte(fmt.Sprintf(testHAR, server.URL)), 0644)
+	endpoints, err := ReadHAR(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(endpoints) != 3 || endpoints[0].URL != server.URL+"/index.html" || endpoints[1].Method != "POST" {
+		t.Fatalf("Expected 3 requests in the order started, found %+v", endpoints)
+	}
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	report := runBoomer(t, &Boomer{Request: req, N: 6, C: 1, Endpoints: endpoints, InOrder: true})
+	want := []string{
+		"GET /index.html   ",
+		`POST /api/cart t1 application/json {"id": 1}`,
+		"GET /api/cart   ",
+	}
+	if len(seen) != 6 {
+		t.Fatalf("Expected 6 requests, found %q", seen)
+	}
+	for i, s := range seen {
+		if s != want[i%3] {
+			t.Errorf("Expected request %d to be %q, found %q", i, want[i%3], s)
+		}
+	}
+	if len(report.Endpoints) != 3 {
+		t.Errorf("Expected 3 endpoints, found %+v", report.Endpoints)
+	}
+}
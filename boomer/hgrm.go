@@ -0,0 +1,91 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"io"
+	"math"
+)
+
+// hgrmTicksPerHalfDistance is how many percentiles are written for
+// every halving of the distance to 100%, as by wrk2.
+const hgrmTicksPerHalfDistance = 5
+
+// WriteHgrm writes the latency distribution of the successful requests
+// to w in the percentile format of HdrHistogram, in ms, to be plotted
+// at hdrhistogram.org alongside wrk2 or Gatling runs. Percentiles come
+// from the sketch, within 1%, unless all latencies were kept.
+func (r *Report) WriteHgrm(w io.Writer) error {
+	ew := &errWriter{w: w}
+	ew.printf("%12s %14s %10s %14s\n\n", "Value", "Percentile", "TotalCount", "1/(1-Percentile)")
+	n := r.latencyCount()
+	if n == 0 {
+		return ew.err
+	}
+	for p := 0.0; ; {
+		count := int64(math.Ceil(p / 100 * float64(n)))
+		if count >= n {
+			break
+		}
+		if count < 1 {
+			count = 1
+		}
+		ew.printf("%12.3f %2.12f %10d %14.2f\n", r.Percentile(math.Max(p, 1e-9)), p/100, count, 100/(100-p))
+		ticks := hgrmTicksPerHalfDistance * math.Pow(2, math.Floor(math.Log2(100/(100-p)))+1)
+		p += 100 / ticks
+	}
+	ew.printf("%12.3f %2.12f %10d\n", r.Slowest, 1.0, n)
+
+	mean, stddev := r.latencyMoments()
+	ew.printf("#[Mean    = %12.3f, StdDeviation   = %12.3f]\n", mean, stddev)
+	ew.printf("#[Max     = %12.3f, Total count    = %12d]\n", r.Slowest, n)
+	return ew.err
+}
+
+// latencyCount returns the number of latencies the statistics of the
+// report are computed from.
+func (r *Report) latencyCount() int64 {
+	if r.fromLats() {
+		return int64(len(r.Lats))
+	}
+	return r.Sketch.count()
+}
+
+// latencyMoments returns the mean and standard deviation of the
+// latencies, in ms, the standard deviation estimated from the sketch
+// unless all latencies were kept.
+func (r *Report) latencyMoments() (mean, stddev float64) {
+	var n, sum, squares float64
+	add := func(v float64, c int64) {
+		n += float64(c)
+		sum += v * float64(c)
+		squares += v * v * float64(c)
+	}
+	if r.fromLats() {
+		for _, lat := range r.Lats {
+			add(lat, 1)
+		}
+	} else {
+		for i, c := range r.Sketch.Counts {
+			add(r.Sketch.value(r.Sketch.Offset+i), c)
+		}
+		n += float64(r.Sketch.Zero)
+	}
+	if n == 0 {
+		return 0, 0
+	}
+	mean = r.Average * 1000
+	return mean, math.Sqrt(math.Max(squares/n-(sum/n)*(sum/n), 0))
+}
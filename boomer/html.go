@@ -0,0 +1,179 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+)
+
+// chartBar is a bar of a chart, in the coordinates of its SVG view box,
+// with its label centered under it at Middle if Tick is set.
+type chartBar struct {
+	X, Y, Width, Height, Middle float64
+	Label, Value                string
+	Tick                        bool
+}
+
+// chart is a bar chart drawn as inline SVG.
+type chart struct {
+	Width, Height float64
+	Bars          []chartBar
+}
+
+const (
+	chartWidth  = 640
+	chartHeight = 200
+)
+
+// barChart returns a chart of a bar per value, labelled with labels and
+// the values as formatted by format.
+func barChart(labels []string, values []float64, format string) *chart {
+	if len(values) == 0 {
+		return nil
+	}
+	var max float64
+	for _, v := range values {
+		if v > max {
+			max = v
+		}
+	}
+	c := &chart{Width: chartWidth, Height: chartHeight}
+	slot := float64(chartWidth) / float64(len(values))
+	// Label a dozen bars at most.
+	step := (len(values) + 11) / 12
+	for i, v := range values {
+		h := 0.0
+		if max > 0 {
+			h = v / max * (chartHeight - 20)
+		}
+		c.Bars = append(c.Bars, chartBar{
+			X:      float64(i)*slot + slot*0.1,
+			Y:      chartHeight - 20 - h,
+			Width:  slot * 0.8,
+			Height: h,
+			Middle: float64(i)*slot + slot/2,
+			Label:  labels[i],
+			Value:  fmt.Sprintf(format, v),
+			Tick:   i%step == 0,
+		})
+	}
+	return c
+}
+
+// WriteHTML writes the report to w as a single self-contained HTML
+// page, with the summary, charts of the latency distribution and
+// histogram, and tables of the status codes, errors and checks. It
+// loads no scripts, styles or fonts, so it can be shared as a file.
+func (r *Report) WriteHTML(w io.Writer) error {
+	var labels []string
+	var values []float64
+	for _, p := range r.Percentiales {
+		labels = append(labels, fmt.Sprintf("p%d", p.Percent))
+		values = append(values, p.Count/1000)
+	}
+	percentiles := barChart(labels, values, "%4.4f s")
+
+	labels, values = nil, nil
+	for _, b := range r.Histogram {
+		labels = append(labels, fmt.Sprintf("%4.3f", b.Bucket/1000))
+		values = append(values, float64(b.Count))
+	}
+	histogram := barChart(labels, values, "%.0f")
+
+	labels, values = nil, nil
+	for _, wl := range r.Worst {
+		labels = append(labels, fmt.Sprintf("%ds", wl.Second))
+		values = append(values, wl.Latency/1000)
+	}
+	worst := barChart(labels, values, "%4.4f s")
+
+	title := "boom report"
+	if r.Name != "" {
+		title += ": " + r.Name
+	}
+	return htmlTemplate.Execute(w, map[string]interface{}{
+		"Title":       title,
+		"Report":      r,
+		"Total":       float64(r.TotalDuration),
+		"Percentiles": percentiles,
+		"Histogram":   histogram,
+		"Worst":       worst,
+		"Outcomes":    r.Outcomes(),
+	})
+}
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"secs": func(ms float64) string { return fmt.Sprintf("%4.4f secs.", ms/1000) },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 1.5em; }
+th, td { text-align: left; padding: 0.2em 1em 0.2em 0; }
+svg { display: block; margin-bottom: 1.5em; max-width: 100%; }
+rect { fill: #4a78b5; }
+text { font-size: 10px; fill: #222; text-anchor: middle; }
+.fail { color: #b00; } .warn { color: #b70; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{with .Report}}
+<h2>Summary</h2>
+<table>
+<tr><th>Total</th><td>{{secs $.Total}}</td></tr>
+<tr><th>Slowest</th><td>{{secs .Slowest}}</td></tr>
+<tr><th>Fastest</th><td>{{secs .Fastest}}</td></tr>
+<tr><th>Average</th><td>{{printf "%4.4f" .Average}} secs.</td></tr>
+<tr><th>Requests/sec</th><td>{{printf "%4.4f" .RPS}}</td></tr>
+<tr><th>Responses</th><td>{{.Responses}}</td></tr>
+<tr><th>Errors</th><td>{{.ErrorCount}}</td></tr>
+{{if .Stopped}}<tr><th>Stopped</th><td>{{.Stopped}}</td></tr>{{end}}
+{{if .Partial}}<tr><th>Partial</th><td>interrupted, only the requests made until then are reported</td></tr>{{end}}
+</table>
+{{end}}
+{{define "chart"}}<svg viewBox="0 0 {{.Width}} {{.Height}}" width="{{.Width}}" height="{{.Height}}">
+{{range .Bars}}<rect x="{{.X}}" y="{{.Y}}" width="{{.Width}}" height="{{.Height}}"><title>{{.Label}}: {{.Value}}</title></rect>
+{{if .Tick}}<text x="{{.Middle}}" y="{{$.Height}}" dy="-6">{{.Label}}</text>{{end}}
+{{end}}</svg>{{end}}
+{{with .Percentiles}}<h2>Latency distribution</h2>{{template "chart" .}}{{end}}
+{{with .Histogram}}<h2>Response time histogram</h2>{{template "chart" .}}{{end}}
+{{with .Worst}}<h2>Worst latency per second</h2>{{template "chart" .}}{{end}}
+{{with .Report.StatusCodes}}
+<h2>Status code distribution</h2>
+<table>
+{{range .}}<tr><th>{{.Code}}</th><td>{{.Count}} responses</td></tr>
+{{end}}</table>
+{{end}}
+{{with .Report.Errors}}
+<h2>Error distribution</h2>
+<table>
+{{range .}}<tr><td>{{.Count}}</td><td>{{.Error}}</td></tr>
+{{end}}</table>
+{{end}}
+{{with .Outcomes}}
+<h2>Checks</h2>
+<table>
+{{range .}}<tr class="{{.Verdict}}"><th>{{.Check}}</th><td>{{.Verdict}}</td><td>{{.Message}}</td></tr>
+{{end}}</table>
+{{end}}
+</body>
+</html>
+`))
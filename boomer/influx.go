@@ -0,0 +1,204 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// influxSink writes records as InfluxDB line protocol.
+type influxSink struct {
+	buf   bytes.Buffer
+	send  func([]byte) error
+	flush time.Time
+
+	// interval aggregates the records started within the second
+	// starting at its start.
+	interval influxInterval
+	tags     string
+}
+
+type influxInterval struct {
+	start           time.Time
+	requests, fails int
+	sum, max        float64
+	responses       int
+}
+
+// NewInfluxSink returns a sink writing InfluxDB line protocol to w: a
+// "boom_request" point per request, as it completes, a "boom_interval"
+// point per second of the run summarizing the requests started within
+// it, and a "boom_summary" point once the run is done. The tags of the
+// run are tags of every point. Lines are flushed at least every second.
+func NewInfluxSink(w io.Writer) Sink {
+	return &influxSink{
+		send:  func(b []byte) error { _, err := w.Write(b); return err },
+		flush: time.Now(),
+	}
+}
+
+// NewInfluxHTTPSink returns a sink like NewInfluxSink posting the lines
+// to the write endpoint of an InfluxDB server, e.g.
+// http://localhost:8086/write?db=boom or, with InfluxDB 2,
+// http://localhost:8086/api/v2/write?org=o&bucket=boom. The token, if
+// set, authenticates the writes.
+func NewInfluxHTTPSink(url, token string) Sink {
+	client := &http.Client{Timeout: 10 * time.Second}
+	return &influxSink{
+		send: func(b []byte) error {
+			req, err := http.NewRequest("POST", url, bytes.NewReader(b))
+			if err != nil {
+				return err
+			}
+			req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+			if token != "" {
+				req.Header.Set("Authorization", "Token "+token)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode/100 != 2 {
+				msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+				return fmt.Errorf("influxdb: %s: %s", resp.Status, bytes.TrimSpace(msg))
+			}
+			return nil
+		},
+		flush: time.Now(),
+	}
+}
+
+func (s *influxSink) Record(rec Record) error {
+	if s.tags == "" && len(rec.Tags) > 0 {
+		s.tags = influxTags(rec.Tags)
+	}
+	// Requests complete roughly in the order they start, so an interval
+	// is written once a request of a later one completes. The few that
+	// complete later still are counted in the interval then current.
+	if sec := rec.Start.Truncate(time.Second); sec.After(s.interval.start) {
+		s.writeInterval()
+		s.interval = influxInterval{start: sec}
+	}
+	iv := &s.interval
+	iv.requests++
+
+	fmt.Fprintf(&s.buf, "boom_request,endpoint=%s", influxEscape(rec.Endpoint))
+	if rec.StatusCode > 0 {
+		fmt.Fprintf(&s.buf, ",status=%d", rec.StatusCode)
+	}
+	fmt.Fprintf(&s.buf, "%s size=%di", s.tags, rec.Size)
+	if rec.Aborted != "" {
+		fmt.Fprintf(&s.buf, ",aborted=%s", influxString(rec.Aborted))
+	} else {
+		lat := rec.Duration.Seconds() * 1000
+		fmt.Fprintf(&s.buf, ",latency_ms=%s", promFloat(lat))
+		if rec.Err != nil {
+			iv.fails++
+		} else {
+			iv.responses++
+			iv.sum += lat
+			if lat > iv.max {
+				iv.max = lat
+			}
+		}
+	}
+	if rec.Err != nil {
+		fmt.Fprintf(&s.buf, ",error=%s", influxString(rec.Err.Error()))
+	}
+	fmt.Fprintf(&s.buf, " %d\n", rec.Start.UnixNano())
+
+	if now := time.Now(); now.Sub(s.flush) >= sinkFlushInterval {
+		s.flush = now
+		return s.write()
+	}
+	return nil
+}
+
+func (s *influxSink) writeInterval() {
+	iv := s.interval
+	if iv.requests == 0 {
+		return
+	}
+	fmt.Fprintf(&s.buf, "boom_interval%s requests=%di,errors=%di", s.tags, iv.requests, iv.fails)
+	if iv.responses > 0 {
+		fmt.Fprintf(&s.buf, ",latency_avg_ms=%s,latency_max_ms=%s", promFloat(iv.sum/float64(iv.responses)), promFloat(iv.max))
+	}
+	fmt.Fprintf(&s.buf, " %d\n", iv.start.UnixNano())
+}
+
+func (s *influxSink) write() error {
+	if s.buf.Len() == 0 {
+		return nil
+	}
+	err := s.send(s.buf.Bytes())
+	s.buf.Reset()
+	return err
+}
+
+func (s *influxSink) Close(r *Report) error {
+	s.writeInterval()
+	tags := s.tags
+	if r.Name != "" {
+		tags = ",name=" + influxEscape(r.Name) + tags
+	}
+	fmt.Fprintf(&s.buf, "boom_summary%s responses=%di,errors=%di,rps=%s", tags, r.Responses(), r.ErrorCount(), promFloat(r.RPS))
+	if r.hasLatencies() {
+		for _, p := range []float64{50, 90, 99} {
+			fmt.Fprintf(&s.buf, ",p%v_ms=%s", p, promFloat(r.Percentile(p)))
+		}
+	}
+	fmt.Fprintf(&s.buf, " %d\n", time.Now().UnixNano())
+	return s.write()
+}
+
+// influxTags returns the tags as a line protocol tag set, sorted by
+// key as InfluxDB prefers.
+func influxTags(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		if tags[k] == "" {
+			continue
+		}
+		fmt.Fprintf(&b, ",%s=%s", influxEscape(k), influxEscape(tags[k]))
+	}
+	return b.String()
+}
+
+var influxEscaper = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `, "\n", `\n`)
+
+// influxEscape escapes a tag key or value.
+func influxEscape(s string) string {
+	return influxEscaper.Replace(s)
+}
+
+var influxStringEscaper = strings.NewReplacer(`"`, `\"`, `\`, `\\`)
+
+// influxString quotes a string field value.
+func influxString(s string) string {
+	return `"` + influxStringEscaper.Replace(s) + `"`
+}
@@ -0,0 +1,88 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInfluxSink(t *testing.T) {
+	var buf bytes.Buffer
+	s := NewInfluxSink(&buf)
+	start := time.Unix(100, 0)
+	tags := map[string]string{"run": "nightly build"}
+	for _, rec := range []Record{
+		{Start: start, Duration: 2 * time.Millisecond, Endpoint: "http://a/x", StatusCode: 200, Size: 10, Tags: tags},
+		{Start: start.Add(500 * time.Millisecond), Duration: 4 * time.Millisecond, Endpoint: "http://a/x", StatusCode: 200, Size: 10, Tags: tags},
+		{Start: start.Add(1500 * time.Millisecond), Duration: time.Millisecond, Endpoint: "http://a/x", Err: errors.New(`dial "a": refused`), Size: -1, Tags: tags},
+	} {
+		if err := s.Record(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := testReport()
+	r.Name = "api"
+	if err := s.Close(r); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	want := []string{
+		`boom_request,endpoint=http://a/x,status=200,run=nightly\ build size=10i,latency_ms=2 100000000000`,
+		`boom_request,endpoint=http://a/x,status=200,run=nightly\ build size=10i,latency_ms=4 100500000000`,
+		`boom_interval,run=nightly\ build requests=2i,errors=0i,latency_avg_ms=3,latency_max_ms=4 100000000000`,
+		`boom_request,endpoint=http://a/x,run=nightly\ build size=-1i,latency_ms=1,error="dial \"a\": refused" 101500000000`,
+		`boom_interval,run=nightly\ build requests=1i,errors=1i 101000000000`,
+	}
+	if len(lines) != 6 {
+		t.Fatalf("expected 6 lines, found:\n%s", buf.String())
+	}
+	for i, w := range want {
+		if lines[i] != w {
+			t.Errorf("line %d: expected\n%s\nfound\n%s", i, w, lines[i])
+		}
+	}
+	if !strings.HasPrefix(lines[5], `boom_summary,name=api,run=nightly\ build responses=4i,errors=1i,rps=2,p50_ms=`) {
+		t.Errorf("unexpected summary %s", lines[5])
+	}
+}
+
+func TestInfluxHTTPSink(t *testing.T) {
+	var auth, body string
+	influx := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		auth, body = r.Header.Get("Authorization"), body+string(data)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer influx.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	r := runBoomer(t, &Boomer{Request: req, N: 3, C: 1, Sinks: []Sink{NewInfluxHTTPSink(influx.URL+"/write?db=boom", "secret")}})
+	if len(r.SinkErrors) != 0 {
+		t.Fatal(r.SinkErrors)
+	}
+	if auth != "Token secret" || strings.Count(body, "boom_request,") != 3 || !strings.Contains(body, "boom_summary ") {
+		t.Errorf("unexpected write with %q:\n%s", auth, body)
+	}
+}
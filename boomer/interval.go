@@ -0,0 +1,137 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"sort"
+	"time"
+)
+
+// DefaultInterval is the length of the intervals a report summarizes a
+// run in if Interval is not set.
+const DefaultInterval = 5 * time.Second
+
+// IntervalStats summarizes the requests started within an interval of
+// a run, so that trends over the run are preserved.
+type IntervalStats struct {
+	// Start and End are the bounds of the interval, in seconds from the
+	// start of the run. The last interval ends with the run.
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+
+	// Requests counts the requests made, Errors those that failed: error
+	// responses, errors and timeouts. RPS is the requests made per
+	// second.
+	Requests int     `json:"requests"`
+	Errors   int     `json:"errors"`
+	RPS      float64 `json:"rps"`
+
+	// P50, P95 and P99 are percentiles of the latencies of the
+	// responses, in ms.
+	P50 float64 `json:"p50"`
+	P95 float64 `json:"p95"`
+	P99 float64 `json:"p99"`
+}
+
+// intervalSamples counts the requests started within an interval.
+type intervalSamples struct {
+	requests int
+	errors   int
+	lats     Sketch
+}
+
+// addInterval counts res in the interval it started in.
+func (r *Report) addInterval(res *result) {
+	if r.interval <= 0 {
+		return
+	}
+	if r.intervals == nil {
+		r.intervals = make(map[int]*intervalSamples)
+	}
+	i := int(res.start.Sub(r.start) / r.interval)
+	s, ok := r.intervals[i]
+	if !ok {
+		s = &intervalSamples{}
+		r.intervals[i] = s
+	}
+	s.requests++
+	if !r.succeeded(res) {
+		s.errors++
+	}
+	if res.err != nil || res.timeout != "" {
+		return
+	}
+	s.lats.add(res.duration.Seconds() * 1000)
+}
+
+// mergeIntervals adds the intervals of o to those of r. The intervals
+// of a report decoded from JSON are kept as they are.
+func (r *Report) mergeIntervals(o *Report) {
+	if o.intervals == nil {
+		r.fixedIntervals = append(r.fixedIntervals, o.Intervals...)
+		return
+	}
+	if r.intervals == nil {
+		r.intervals = make(map[int]*intervalSamples)
+	}
+	r.interval = o.interval
+	for i, s := range o.intervals {
+		m, ok := r.intervals[i]
+		if !ok {
+			m = &intervalSamples{}
+			r.intervals[i] = m
+		}
+		m.requests += s.requests
+		m.errors += s.errors
+		m.lats.merge(&s.lats)
+	}
+}
+
+func (r *Report) printIntervals() {
+	r.Intervals = append([]IntervalStats(nil), r.fixedIntervals...)
+	for i, s := range r.intervals {
+		st := IntervalStats{
+			Start:    (time.Duration(i) * r.interval).Seconds(),
+			End:      (time.Duration(i+1) * r.interval).Seconds(),
+			Requests: s.requests,
+			Errors:   s.errors,
+		}
+		if end := r.total.Round(time.Millisecond).Seconds(); st.End > end && end > st.Start {
+			st.End = end
+		}
+		st.RPS = float64(st.Requests) / (st.End - st.Start)
+		if s.lats.count() > 0 {
+			st.P50 = s.lats.quantile(50)
+			st.P95 = s.lats.quantile(95)
+			st.P99 = s.lats.quantile(99)
+		}
+		r.Intervals = append(r.Intervals, st)
+	}
+	sort.Slice(r.Intervals, func(i, j int) bool {
+		return r.Intervals[i].Start < r.Intervals[j].Start
+	})
+}
+
+// shiftIntervals returns the series moved later by the given number of
+// seconds.
+func shiftIntervals(series []IntervalStats, seconds float64) []IntervalStats {
+	shifted := make([]IntervalStats, len(series))
+	for i, s := range series {
+		s.Start += seconds
+		s.End += seconds
+		shifted[i] = s
+	}
+	return shifted
+}
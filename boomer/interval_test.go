@@ -0,0 +1,86 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestIntervals(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(5 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	report := runBoomer(t, &Boomer{Request: req, C: 2, Duration: 350 * time.Millisecond, Interval: 100 * time.Millisecond})
+	if len(report.Intervals) != 4 {
+		t.Fatalf("Expected 4 intervals, found %+v", report.Intervals)
+	}
+	var requests int
+	for i, iv := range report.Intervals {
+		if want := float64(i) / 10; iv.Start < want-1e-9 || iv.Start > want+1e-9 {
+			t.Errorf("Expected interval %d to start at %vs, found %+v", i, want, iv)
+		}
+		if iv.Requests == 0 || iv.Errors != 0 || iv.P50 < 5 || iv.P99 < iv.P50 || iv.RPS <= 0 {
+			t.Errorf("Unexpected interval %+v", iv)
+		}
+		requests += iv.Requests
+	}
+	if last := report.Intervals[3]; last.End < 0.35 || last.End >= 0.4 {
+		t.Errorf("Expected the last interval to end with the run, found %+v", last)
+	}
+	if requests != report.Responses() {
+		t.Errorf("Expected the intervals to count %d requests, found %d", report.Responses(), requests)
+	}
+
+	m := Merge(report, report)
+	if len(m.Intervals) != 4 || m.Intervals[0].Requests != 2*report.Intervals[0].Requests {
+		t.Errorf("Expected merged intervals to add up, found %+v", m.Intervals)
+	}
+	shifted := shiftIntervals(report.Intervals, 10)
+	if shifted[1].Start != report.Intervals[1].Start+10 || shifted[1].Requests != report.Intervals[1].Requests {
+		t.Errorf("Expected intervals shifted by 10s, found %+v", shifted)
+	}
+}
+
+func TestFailedIntervals(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	// Requests failing before they are sent are counted in the interval
+	// they failed in.
+	fetch := func() (Token, error) { return Token{}, errors.New("no token") }
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	report := runBoomer(t, &Boomer{Request: req, N: 10, C: 2, Interval: time.Second, Tokens: []*TokenSource{{Fetch: fetch}}, KeepRecords: true})
+	if len(report.Intervals) != 1 || report.Intervals[0].Start != 0 || report.Intervals[0].Errors != 10 {
+		t.Errorf("Expected an interval of 10 errors, found %+v", report.Intervals)
+	}
+	var records int
+	for rec := range report.Records {
+		if rec.Start.IsZero() {
+			t.Errorf("Expected failed requests to have a start, found %+v", rec)
+			break
+		}
+		records++
+	}
+	if records != 10 {
+		t.Errorf("Expected 10 records, found %d", records)
+	}
+}
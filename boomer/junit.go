@@ -0,0 +1,79 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Suites   []junitSuite `xml:"testsuite"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Time     string      `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+}
+
+// WriteJUnit writes the checks of the run to w as a JUnit XML report,
+// read by CI servers such as Jenkins and GitLab: a test suite named
+// after the run with a test case per check, failing if the check did.
+// Checks close to failing pass, with a "warn" verdict in their output.
+func (r *Report) WriteJUnit(w io.Writer) error {
+	name := r.Name
+	if name == "" {
+		name = "boom"
+	}
+	suite := junitSuite{Name: name, Time: fmt.Sprintf("%.3f", float64(r.TotalDuration)/1000)}
+	for _, o := range r.Outcomes() {
+		c := junitCase{Name: o.Check, ClassName: name, SystemOut: o.Verdict.String() + ": " + o.Message}
+		if o.Verdict == Fail {
+			c.Failure = &junitFailure{Message: o.Message, Type: "fail"}
+			suite.Failures++
+		}
+		suite.Cases = append(suite.Cases, c)
+	}
+	suite.Tests = len(suite.Cases)
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(junitSuites{Suites: []junitSuite{suite}, Tests: suite.Tests, Failures: suite.Failures}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,148 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+// LatencyStats summarizes latencies, in ms.
+type LatencyStats struct {
+	Count   int     `json:"count"`
+	Average float64 `json:"average"`
+	P50     float64 `json:"p50"`
+	P90     float64 `json:"p90"`
+	P99     float64 `json:"p99"`
+}
+
+// latencySamples counts latencies, in ms, in bounded memory.
+type latencySamples struct {
+	sketch Sketch
+	sum    float64
+}
+
+func (s *latencySamples) add(lat float64) {
+	s.sketch.add(lat)
+	s.sum += lat
+}
+
+func (s *latencySamples) merge(o *latencySamples) {
+	s.sketch.merge(&o.sketch)
+	s.sum += o.sum
+}
+
+func (s *latencySamples) stats() LatencyStats {
+	st := LatencyStats{Count: int(s.sketch.count())}
+	if st.Count > 0 {
+		st.Average = s.sum / float64(st.Count)
+		st.P50 = s.sketch.quantile(50)
+		st.P90 = s.sketch.quantile(90)
+		st.P99 = s.sketch.quantile(99)
+	}
+	return st
+}
+
+// succeeded reports whether res is a response of a status code other
+// than an error, as opposed to an error response, a failed request or
+// one that timed out.
+func (r *Report) succeeded(res *result) bool {
+	return !failed(res, r.GRPC)
+}
+
+// failed reports whether res is an error response, a failed request or
+// one that timed out. grpc is set if res is of a gRPC call.
+func failed(res *result, grpc bool) bool {
+	return res.err != nil || res.timeout != "" || errorStatus(res.statusCode, grpc)
+}
+
+// errorStatus reports whether code is the status code of an error
+// response: a non-OK code of a gRPC call, or an HTTP code of 400 or
+// above.
+func errorStatus(code int, grpc bool) bool {
+	if grpc {
+		return code != 0
+	}
+	return code >= 400
+}
+
+// addOutcome records the latency of res by its status code, by
+// whether it succeeded and by whether its connection was reused, and
+// how long it waited for a connection if it was blocked on the pool.
+func (r *Report) addOutcome(res *result) {
+	lat := res.duration.Seconds() * 1000
+	if res.err == nil && res.timeout == "" {
+		s, ok := r.statusLats[res.statusCode]
+		if !ok {
+			s = &latencySamples{}
+			r.statusLats[res.statusCode] = s
+		}
+		s.add(lat)
+	}
+	if r.succeeded(res) {
+		r.successLats.add(lat)
+	} else {
+		r.failureLats.add(lat)
+	}
+	if res.err == nil && res.timeout == "" && res.gotConn() {
+		if res.newConn {
+			r.newConnLats.add(lat)
+		} else {
+			r.reusedConnLats.add(lat)
+		}
+	}
+	if wait := poolWait(res); wait >= poolWaitThreshold {
+		r.poolWaitLats.add(wait.Seconds() * 1000)
+	}
+}
+
+// mergeOutcomes adds the latencies by status code, outcome and
+// connection, and the waits for a connection, of o to those of r.
+func (r *Report) mergeOutcomes(o *Report) {
+	for code, s := range o.statusLats {
+		m, ok := r.statusLats[code]
+		if !ok {
+			m = &latencySamples{}
+			r.statusLats[code] = m
+		}
+		m.merge(s)
+	}
+	r.successLats.merge(&o.successLats)
+	r.failureLats.merge(&o.failureLats)
+	r.newConnLats.merge(&o.newConnLats)
+	r.reusedConnLats.merge(&o.reusedConnLats)
+	r.poolWaitLats.merge(&o.poolWaitLats)
+}
+
+func (r *Report) printOutcomes() {
+	r.Successes, r.Failures = nil, nil
+	if r.successLats.sketch.count() > 0 {
+		st := r.successLats.stats()
+		r.Successes = &st
+	}
+	if r.failureLats.sketch.count() > 0 {
+		st := r.failureLats.stats()
+		r.Failures = &st
+	}
+	r.NewConns, r.ReusedConns = nil, nil
+	if r.newConnLats.sketch.count() > 0 {
+		st := r.newConnLats.stats()
+		r.NewConns = &st
+	}
+	if r.reusedConnLats.sketch.count() > 0 {
+		st := r.reusedConnLats.stats()
+		r.ReusedConns = &st
+	}
+	r.PoolWaits = nil
+	if r.poolWaitLats.sketch.count() > 0 {
+		st := r.poolWaitLats.stats()
+		r.PoolWaits = &st
+	}
+}
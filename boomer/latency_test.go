@@ -0,0 +1,59 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLatencyByStatus(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(500)
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	report := runBoomer(t, &Boomer{Request: req, N: 40, C: 4, Endpoints: []Endpoint{
+		{URL: server.URL + "/ok"},
+		{URL: server.URL + "/fail", Weight: 3},
+		{URL: "http://127.0.0.1:1/refused"},
+	}})
+	var ok, failed StatusCode
+	for _, s := range report.StatusCodes {
+		switch s.Code {
+		case 200:
+			ok = s
+		case 500:
+			failed = s
+		}
+	}
+	if ok.Count != 8 || failed.Count != 24 {
+		t.Fatalf("Expected 8 200s and 24 500s, found %+v", report.StatusCodes)
+	}
+	if ok.P50 < 20 || failed.P50 >= 20 || ok.Average < failed.Average {
+		t.Errorf("Expected slow 200s and fast 500s, found %+v and %+v", ok, failed)
+	}
+	if s, f := report.Successes, report.Failures; s == nil || f == nil || s.Count != 8 || f.Count != 32 || s.P50 < f.P50 {
+		t.Errorf("Expected 8 slow successes and 32 fast failures, found %+v and %+v", s, f)
+	}
+}
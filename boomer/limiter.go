@@ -0,0 +1,72 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// tokenBucket limits the requests of all the workers of a run to the
+// rate of its schedule, letting at most burst of them through at once
+// after a lull. It keeps the time the next token would be due were the
+// bucket always drained, as in the generic cell rate algorithm.
+type tokenBucket struct {
+	// due returns when the n-th token is due on the schedule of the
+	// run, since its start; the interval between tokens follows the
+	// rate of the run as it ramps or goes through stages.
+	due   func(n int) time.Duration
+	burst int
+
+	mu   sync.Mutex
+	n    int
+	last time.Duration // when the last token was due
+	tat  time.Time     // theoretical arrival time of the next request
+}
+
+func newTokenBucket(due func(n int) time.Duration, burst int) *tokenBucket {
+	return &tokenBucket{due: due, burst: burst}
+}
+
+// take waits for a token, or for ctx to be done.
+func (t *tokenBucket) take(ctx context.Context) error {
+	t.mu.Lock()
+	now := time.Now()
+	if t.tat.Before(now) {
+		t.tat = now
+	}
+	due := t.due(t.n)
+	interval := due - t.last
+	t.n, t.last = t.n+1, due
+	// The request may be sent as long as it is no more than burst-1
+	// intervals ahead of the rate.
+	at := t.tat.Add(-time.Duration(t.burst-1) * interval)
+	t.tat = t.tat.Add(interval)
+	t.mu.Unlock()
+
+	d := at.Sub(now)
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
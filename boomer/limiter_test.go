@@ -0,0 +1,89 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	b := newTokenBucket(func(n int) time.Duration { return time.Duration(n+1) * 50 * time.Millisecond }, 3)
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		b.take(context.Background())
+	}
+	if d := time.Since(start); d > 20*time.Millisecond {
+		t.Errorf("expected a burst of 3 without waiting, took %v", d)
+	}
+	b.take(context.Background())
+	if d := time.Since(start); d < 40*time.Millisecond {
+		t.Errorf("expected the 4th token to be 50ms away, took %v", d)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := b.take(ctx); err != context.Canceled {
+		t.Errorf("expected the wait to be cancelled, found %v", err)
+	}
+}
+
+func TestBurst(t *testing.T) {
+	var mu sync.Mutex
+	var arrivals []time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		arrivals = append(arrivals, time.Now())
+		n := len(arrivals)
+		mu.Unlock()
+		// The first requests hold both workers while more are due.
+		if n <= 2 {
+			time.Sleep(300 * time.Millisecond)
+		}
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	report := runBoomer(t, &Boomer{Request: req, N: 10, C: 2, Qps: 20, Burst: 1})
+	if report.StatusCount(200) != 10 {
+		t.Fatalf("expected 10 responses, found %+v, %+v", report.StatusCodes, report.Errors)
+	}
+	for i := 3; i < len(arrivals); i++ {
+		if gap := arrivals[i].Sub(arrivals[i-1]); gap < 30*time.Millisecond {
+			t.Errorf("expected requests 50ms apart once the workers were free, found %v before request %d", gap, i+1)
+		}
+	}
+}
+
+func TestBurstPacing(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	// The bucket alone paces the requests: a full bucket lets Burst of
+	// them through at once rather than one every 100ms.
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	start := time.Now()
+	report := runBoomer(t, &Boomer{Request: req, N: 5, C: 5, Qps: 10, Burst: 5})
+	if report.StatusCount(200) != 5 {
+		t.Fatalf("expected 5 responses, found %+v, %+v", report.StatusCodes, report.Errors)
+	}
+	if d := time.Since(start); d > 300*time.Millisecond {
+		t.Errorf("expected a burst of 5 requests, took %v", d)
+	}
+}
@@ -0,0 +1,189 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// liveWindow is the number of seconds the rolling statistics of the
+// live view cover.
+const liveWindow = 5
+
+// liveSecond holds the results completed within a second of the run.
+type liveSecond struct {
+	sec      int64
+	requests int
+	errors   int
+	sketch   Sketch
+}
+
+// liveView shows the progress of a run and rolling statistics of its
+// last seconds on w every second, redrawn in place on a terminal and as
+// a line per second otherwise.
+type liveView struct {
+	w        io.Writer
+	tty      bool
+	n        int
+	duration time.Duration
+
+	// grpc is set if the requests are gRPC calls, whose non-OK status
+	// codes count as errors.
+	grpc bool
+
+	mu      sync.Mutex
+	start   time.Time
+	total   int
+	seconds [liveWindow]liveSecond
+	drawn   bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newLiveView(w io.Writer, n int, d time.Duration) *liveView {
+	return &liveView{w: w, tty: isTerminal(w), n: n, duration: d, start: time.Now()}
+}
+
+// isTerminal reports whether w is a terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// add counts the result in the second it completed in.
+func (v *liveView) add(res *result) {
+	if res.aborted != "" {
+		return
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.total++
+	sec := int64(res.start.Add(res.duration).Sub(v.start) / time.Second)
+	s := &v.seconds[sec%liveWindow]
+	if s.sec != sec || s.requests == 0 {
+		*s = liveSecond{sec: sec}
+	}
+	s.requests++
+	if failed(res, v.grpc) {
+		s.errors++
+	}
+	if res.err != nil || res.timeout != "" {
+		return
+	}
+	s.sketch.add(res.duration.Seconds() * 1000)
+}
+
+// run draws the view every second until stop is closed, and once more
+// then.
+func (v *liveView) run() {
+	v.stop, v.done = make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(v.done)
+		t := time.NewTicker(time.Second)
+		defer t.Stop()
+		for {
+			select {
+			case <-v.stop:
+				v.draw()
+				return
+			case <-t.C:
+				v.draw()
+			}
+		}
+	}()
+}
+
+// finish draws the view a last time and waits for it.
+func (v *liveView) finish() {
+	close(v.stop)
+	<-v.done
+}
+
+// draw writes the current state of the view.
+func (v *liveView) draw() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	elapsed := time.Since(v.start)
+	now := int64(elapsed / time.Second)
+
+	// The window spans the whole seconds before the current one and
+	// the current one so far.
+	var window Sketch
+	var requests, errs int
+	for _, s := range v.seconds {
+		if s.requests > 0 && s.sec > now-liveWindow && s.sec <= now {
+			requests += s.requests
+			errs += s.errors
+			window.merge(&s.sketch)
+		}
+	}
+	span := elapsed
+	if from := time.Duration(now-liveWindow+1) * time.Second; from > 0 {
+		span -= from
+	}
+	var rps, errRate float64
+	if span > 0 {
+		rps = float64(requests) / span.Seconds()
+	}
+	if requests > 0 {
+		errRate = float64(errs) / float64(requests)
+	}
+	lats := "-"
+	if window.count() > 0 {
+		lats = fmt.Sprintf("p50 %.1fms  p95 %.1fms  p99 %.1fms", window.quantile(50), window.quantile(95), window.quantile(99))
+	}
+
+	progress := fmt.Sprintf("%d requests in %v", v.total, elapsed.Truncate(time.Second))
+	switch {
+	case v.n > 0:
+		progress = fmt.Sprintf("%d of %d requests (%.0f%%) in %v", v.total, v.n, float64(v.total)*100/float64(v.n), elapsed.Truncate(time.Second))
+	case v.duration > 0:
+		done := elapsed
+		if done > v.duration {
+			done = v.duration
+		}
+		progress = fmt.Sprintf("%d requests in %v of %v (%.0f%%)", v.total, done.Truncate(time.Second), v.duration, float64(done)*100/float64(v.duration))
+	}
+
+	if !v.tty {
+		fmt.Fprintf(v.w, "%s\t%.1f req/s\t%s\terrors %.2f%%\n", progress, rps, lats, errRate*100)
+		return
+	}
+	lines := []string{
+		"Progress:\t" + progress,
+		fmt.Sprintf("Rate:\t\t%.1f req/s", rps),
+		fmt.Sprintf("Latency:\t%s", lats),
+		fmt.Sprintf("Errors:\t\t%.2f%%", errRate*100),
+	}
+	var buf strings.Builder
+	if v.drawn {
+		fmt.Fprintf(&buf, "\033[%dA", len(lines))
+	}
+	for _, l := range lines {
+		buf.WriteString("\r\033[K" + l + "\n")
+	}
+	io.WriteString(v.w, buf.String())
+	v.drawn = true
+}
@@ -0,0 +1,64 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLiveView(t *testing.T) {
+	var buf bytes.Buffer
+	v := newLiveView(&buf, 100, 0)
+	v.start = time.Now().Add(-10*time.Second - 500*time.Millisecond)
+	// Only the results of the last 5 seconds are in the window.
+	v.add(&result{start: v.start, duration: time.Second})
+	for i := 0; i < 9; i++ {
+		v.add(&result{start: v.start.Add(8 * time.Second), duration: 10 * time.Millisecond})
+	}
+	v.add(&result{start: v.start.Add(9 * time.Second), err: errors.New("refused")})
+	v.add(&result{start: v.start.Add(9 * time.Second), statusCode: 500})
+	v.add(&result{start: v.start.Add(9 * time.Second), aborted: "body"})
+	v.draw()
+
+	line := buf.String()
+	for _, want := range []string{"12 of 100 requests (12%) in 10s", "2.4 req/s", "p50 10.0ms", "errors 18.18%"} {
+		if !strings.Contains(line, want) {
+			t.Errorf("expected %q in the live line %q", want, line)
+		}
+	}
+	if strings.Count(line, "\n") != 1 || strings.Contains(line, "\033") {
+		t.Errorf("expected a plain line when not a terminal, found %q", line)
+	}
+}
+
+func TestLive(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	var buf bytes.Buffer
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	b := &Boomer{Request: req, N: 10, C: 2, Live: &buf}
+	runBoomer(t, b)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if last := lines[len(lines)-1]; !strings.HasPrefix(last, "10 of 10 requests (100%)") {
+		t.Errorf("expected the last live line to show the run done, found %q", last)
+	}
+}
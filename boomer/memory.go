@@ -0,0 +1,115 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/metrics"
+	"time"
+)
+
+const (
+	// memoryCheckInterval is how often the heap size is checked.
+	memoryCheckInterval = 100 * time.Millisecond
+
+	heapMetric = "/memory/classes/heap/objects:bytes"
+)
+
+// memoryGuard degrades what a report retains as the heap approaches
+// a limit.
+type memoryGuard struct {
+	limit   int64
+	last    time.Time
+	samples []metrics.Sample
+
+	// heap returns the size of the heap. It is a variable for tests.
+	heap func() int64
+}
+
+func newMemoryGuard(limit int64) *memoryGuard {
+	g := &memoryGuard{
+		limit:   limit,
+		samples: []metrics.Sample{{Name: heapMetric}},
+	}
+	g.heap = g.readHeap
+	return g
+}
+
+func (g *memoryGuard) readHeap() int64 {
+	metrics.Read(g.samples)
+	if g.samples[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return int64(g.samples[0].Value.Uint64())
+}
+
+// check degrades the retention of r by a step if the heap exceeds 80%
+// of the limit. It checks at most every memoryCheckInterval.
+func (g *memoryGuard) check(r *Report) {
+	now := time.Now()
+	if now.Sub(g.last) < memoryCheckInterval {
+		return
+	}
+	g.last = now
+	if g.heap() < g.limit/10*8 {
+		return
+	}
+	n := r.responses + r.errorCount()
+	switch {
+	case r.keepRecords:
+		r.keepRecords, r.records = false, nil
+		r.degrade("records dropped after %d requests", n)
+	case r.phases != nil:
+		r.phases = nil
+		r.degrade("latency budget dropped after %d requests", n)
+	case r.keepLats:
+		// Keep every other latency, and only one in twice as many from
+		// now on.
+		if r.LatencySampling < 1 {
+			r.LatencySampling = 1
+		}
+		kept := r.Lats[:0]
+		for i := 0; i < len(r.Lats); i += 2 {
+			kept = append(kept, r.Lats[i])
+		}
+		r.Lats = append([]float64(nil), kept...)
+		r.LatencySampling *= 2
+		r.degrade("latencies sampled 1 in %d after %d requests", r.LatencySampling, n)
+	default:
+		// Nothing left to drop: the sketch is bounded.
+		return
+	}
+	// Give the memory back before checking again.
+	runtime.GC()
+}
+
+// errorCount returns the number of failed requests collected so far.
+func (r *Report) errorCount() int {
+	var n int
+	for _, m := range []map[string]int{r.errorDist, r.abortDist} {
+		for _, c := range m {
+			n += c
+		}
+	}
+	for _, c := range r.timeoutDist {
+		n += c
+	}
+	return n
+}
+
+func (r *Report) degrade(format string, args ...interface{}) {
+	r.Degraded = append(r.Degraded, fmt.Sprintf(format, args...))
+}
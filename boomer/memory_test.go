@@ -0,0 +1,82 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMaxMem(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	boomer := &Boomer{
+		Request: req,
+		N:       200,
+		C:       4,
+		// Always over the limit.
+		MaxMem:        1,
+		KeepLatencies: true,
+	}
+	r := runBoomer(t, boomer)
+	if len(r.Degraded) == 0 || r.LatencySampling < 2 {
+		t.Fatalf("expected sampled latencies, found %v sampling 1 in %v", r.Degraded, r.LatencySampling)
+	}
+	if r.Responses() != 200 || len(r.Lats) >= 200 {
+		t.Errorf("expected fewer than 200 of 200 latencies, found %v of %v", len(r.Lats), r.Responses())
+	}
+	if r.Fastest <= 0 || r.Slowest < r.Fastest {
+		t.Errorf("unexpected fastest %v and slowest %v", r.Fastest, r.Slowest)
+	}
+	var n int
+	for _, b := range r.Histogram {
+		n += b.Count
+	}
+	if n < 190 || n > 210 {
+		t.Errorf("expected the histogram to count about 200 responses, found %v", n)
+	}
+}
+
+func TestMemoryGuardSteps(t *testing.T) {
+	r := newReport(0, nil, 0)
+	r.keepRecords = true
+	r.keepLats = true
+	r.phases = make(map[string][]phaseSample)
+	for i := 0; i < 8; i++ {
+		r.addLatency(float64(i + 1))
+	}
+	g := newMemoryGuard(100)
+	g.heap = func() int64 { return 80 }
+	for i := 0; i < 3; i++ {
+		g.check(r)
+		g.last = g.last.Add(-memoryCheckInterval)
+	}
+	if r.keepRecords || r.phases != nil || len(r.Degraded) != 3 {
+		t.Fatalf("expected records and phases dropped, found %v", r.Degraded)
+	}
+	if r.LatencySampling != 2 || len(r.Lats) != 4 {
+		t.Errorf("expected 4 latencies sampled 1 in 2, found %v sampled 1 in %v", r.Lats, r.LatencySampling)
+	}
+
+	g.heap = func() int64 { return 79 }
+	g.last = g.last.Add(-memoryCheckInterval)
+	g.check(r)
+	if len(r.Degraded) != 3 {
+		t.Errorf("expected no degradation under 80%% of the limit, found %v", r.Degraded)
+	}
+}
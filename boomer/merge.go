@@ -0,0 +1,350 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MultiReport holds the reports of several runs made concurrently and
+// their combination.
+type MultiReport struct {
+	Targets  []*Report `json:"targets"`
+	Combined *Report   `json:"combined"`
+}
+
+// RunAll makes the runs of all the boomers concurrently, e.g. to load a
+// read path and a write path of a service at different rates at once.
+// It blocks until all runs are done, or stops them early once ctx is
+// done, as Run does. An error is returned without a report if any of
+// the boomers is not a valid run.
+func RunAll(ctx context.Context, boomers ...*Boomer) (*MultiReport, error) {
+	for _, b := range boomers {
+		if err := b.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	m := &MultiReport{Targets: make([]*Report, len(boomers))}
+	var wg sync.WaitGroup
+	for i, b := range boomers {
+		wg.Add(1)
+		// Several progress bars would garble each other.
+		b.noProgress = len(boomers) > 1
+		go func(i int, b *Boomer) {
+			defer wg.Done()
+			m.Targets[i] = b.run(ctx)
+		}(i, b)
+	}
+	wg.Wait()
+	m.Combined = Merge(m.Targets...)
+	return m, ctx.Err()
+}
+
+// Merge combines the reports of runs made concurrently into one, as if
+// a single run had made all their requests. Reports decoded from JSON
+// can be merged as well. As bodies are not retained, the distinct body
+// variants of an endpoint present in several reports are estimated by
+// the largest count among them.
+func Merge(reports ...*Report) *Report {
+	m := newReport(0, nil, 0)
+	variants := make(map[string]*BodyVariants)
+	schema := make(map[string]*SchemaStats)
+	var churn []*ChurnStats
+	var scaling [][]ScaleInterval
+	for _, r := range reports {
+		if r.total == 0 {
+			r.total = time.Duration(r.TotalDuration) * time.Millisecond
+		}
+		if r.total > m.total {
+			m.total = r.total
+		}
+		m.Lats = append(m.Lats, r.Lats...)
+		if r.LatencySampling > m.LatencySampling {
+			m.LatencySampling = r.LatencySampling
+		}
+		if r.Sketch != nil {
+			if m.Sketch == nil {
+				m.Sketch = &Sketch{}
+			}
+			m.Sketch.merge(r.Sketch)
+		}
+		if r.hasLatencies() {
+			if m.fastest == 0 || r.Fastest < m.fastest {
+				m.fastest = r.Fastest
+			}
+			if r.Slowest > m.slowest {
+				m.slowest = r.Slowest
+			}
+		}
+		m.GRPC = m.GRPC || r.GRPC
+		if r.Stream != nil {
+			if m.Stream == nil {
+				m.Stream = &StreamStats{}
+			}
+			m.Stream.merge(r.Stream)
+		}
+		m.Degraded = append(m.Degraded, r.Degraded...)
+		m.SinkErrors = append(m.SinkErrors, r.SinkErrors...)
+		m.records = append(m.records, r.records...)
+		for host, s := range r.shards {
+			ms, ok := m.shards[host]
+			if !ok {
+				ms = &shardSamples{}
+				m.shards[host] = ms
+			}
+			ms.lats = append(ms.lats, s.lats...)
+			ms.errors += s.errors
+		}
+		m.mergeEndpoints(r)
+		m.mergeOutcomes(r)
+		if r.DNS != nil {
+			m.dnsLookups += r.DNS.Lookups
+			m.dnsFailures += r.DNS.Failures
+		}
+		m.dnsLats.merge(&r.dnsLats)
+		m.mergeIntervals(r)
+		for _, w := range r.Worst {
+			m.addWorst(w.Second, w.Latency)
+		}
+		if r.ramp != nil {
+			m.mergeRamp(r)
+		}
+		for endpoint, samples := range r.phases {
+			if m.phases == nil {
+				m.phases = make(map[string][]phaseSample)
+			}
+			m.phases[endpoint] = append(m.phases[endpoint], samples...)
+		}
+		m.AvgTotal += r.AvgTotal
+		m.SizeTotal += r.SizeTotal
+		m.WireBytes += r.WireBytes
+		m.BodyBytes += r.BodyBytes
+		m.ValidatorMismatches += r.ValidatorMismatches
+		m.ConnsDialed += r.ConnsDialed
+		m.Truncated += r.Truncated
+		m.Stalls += r.Stalls
+		m.Resumed += r.Resumed
+		if m.Stopped == "" {
+			m.Stopped = r.Stopped
+		}
+		m.Partial = m.Partial || r.Partial
+		m.ExtraDials += r.ExtraDials
+		m.HandshakeFailures += r.HandshakeFailures
+		m.Warmup += r.Warmup
+		m.DecodeErrors += r.DecodeErrors
+		m.HeaderChecks = mergeChecks(m.HeaderChecks, r.HeaderChecks)
+		m.FieldChecks = mergeChecks(m.FieldChecks, r.FieldChecks)
+		m.BodyChecks = mergeChecks(m.BodyChecks, r.BodyChecks)
+		m.AssertionFailures += r.AssertionFailures
+		for _, s := range r.StatusCodes {
+			m.statusCodeDist[s.Code] += s.Count
+		}
+		for _, rc := range r.Redirects {
+			if m.redirectDist == nil {
+				m.redirectDist = make(map[int]int)
+				m.redirectCodeDist = make(map[int]int)
+			}
+			m.redirectDist[rc.Hops] += rc.Count
+		}
+		for _, s := range r.RedirectCodes {
+			m.redirectCodeDist[s.Code] += s.Count
+		}
+		for _, rc := range r.Retries {
+			if m.retryDist == nil {
+				m.retryDist = make(map[int]*retrySamples)
+			}
+			s, ok := m.retryDist[rc.Retries]
+			if !ok {
+				s = &retrySamples{}
+				m.retryDist[rc.Retries] = s
+			}
+			s.requests += rc.Requests
+			s.succeeded += rc.Succeeded
+			s.total += rc.Average * float64(rc.Requests)
+			m.retried = m.retried || rc.Retries > 0
+		}
+		for _, p := range r.Protocols {
+			if m.protocols == nil {
+				m.protocols = make(map[string]*ProtocolStats)
+			}
+			mp, ok := m.protocols[p.Proto]
+			if !ok {
+				mp = &ProtocolStats{Proto: p.Proto}
+				m.protocols[p.Proto] = mp
+			}
+			mp.Responses += p.Responses
+			mp.Conns += p.Conns
+		}
+		for _, f := range r.Families {
+			if m.families == nil {
+				m.families = make(map[string]*FamilyStats)
+			}
+			mf, ok := m.families[f.Family]
+			if !ok {
+				mf = &FamilyStats{Family: f.Family}
+				m.families[f.Family] = mf
+			}
+			mf.Responses += f.Responses
+			mf.Conns += f.Conns
+		}
+		for _, e := range r.Errors {
+			m.errorDist[e.Error] += e.Count
+		}
+		for _, a := range r.Aborts {
+			m.abortDist[a.Phase] += a.Count
+		}
+		for _, t := range r.Timeouts {
+			m.timeoutDist[timeoutKey{t.Phase, t.Limit}] += t.Count
+		}
+		for _, c := range r.Compression {
+			mc, ok := m.compression[c.Endpoint]
+			if !ok {
+				mc = &CompressionStats{Endpoint: c.Endpoint}
+				m.compression[c.Endpoint] = mc
+			}
+			mc.Responses += c.Responses
+			mc.WireBytes += c.WireBytes
+			mc.BodyBytes += c.BodyBytes
+			mc.Uncompressed += c.Uncompressed
+		}
+		for _, v := range r.Variants {
+			mv, ok := variants[v.Endpoint]
+			if !ok {
+				mv = &BodyVariants{Endpoint: v.Endpoint}
+				variants[v.Endpoint] = mv
+			}
+			mv.Responses += v.Responses
+			if v.Distinct > mv.Distinct {
+				mv.Distinct = v.Distinct
+			}
+		}
+		for _, s := range r.Schema {
+			ms, ok := schema[s.Endpoint]
+			if !ok {
+				ms = &SchemaStats{Endpoint: s.Endpoint, FirstError: s.FirstError}
+				schema[s.Endpoint] = ms
+			}
+			ms.Validated += s.Validated
+			ms.Failed += s.Failed
+			if ms.FirstError == "" {
+				ms.FirstError = s.FirstError
+			}
+		}
+		if r.Churn != nil {
+			churn = append(churn, r.Churn)
+		}
+		if r.Schedule != nil {
+			m.Schedule = mergeSchedule(m.Schedule, r.Schedule)
+		}
+		if r.Scaling != nil {
+			scaling = append(scaling, r.Scaling)
+		}
+		if r.SLO != nil {
+			if m.SLO == nil {
+				m.SLO = &SLOStats{Target: r.SLO.Target, Latency: r.SLO.Latency, Period: r.SLO.Period}
+			}
+			m.SLO.Total += r.SLO.Total
+			m.SLO.Bad += r.SLO.Bad
+		}
+	}
+
+	for _, v := range variants {
+		m.Variants = append(m.Variants, *v)
+	}
+	sort.Slice(m.Variants, func(i, j int) bool {
+		return m.Variants[i].Endpoint < m.Variants[j].Endpoint
+	})
+	for _, s := range schema {
+		m.schema[s.Endpoint] = s
+	}
+	m.Churn = mergeChurn(churn)
+	if len(scaling) > 0 {
+		m.Scaling = mergeScaling(scaling...)
+	}
+	m.printStatusCodes()
+	m.printProtocols()
+	m.printRedirects()
+	m.printRetries()
+	m.responses = m.Responses()
+	m.printErrors()
+	m.printAborts()
+	m.printTimeouts()
+	m.printCompression()
+	m.printSchema()
+	m.printBudget()
+	m.printShards()
+	m.printEndpoints()
+	m.printOutcomes()
+	m.printDNS()
+	m.printFamilies()
+	m.printRamp()
+	m.printWorst()
+	m.printIntervals()
+	if m.SLO != nil {
+		m.SLO.compute()
+	}
+	if m.Stream != nil {
+		m.Stream.compute()
+	}
+	m.summarize()
+	return m
+}
+
+func mergeChecks(dst, src []CheckResult) []CheckResult {
+	for _, c := range src {
+		found := false
+		for i := range dst {
+			if dst[i].Check == c.Check {
+				dst[i].Passed += c.Passed
+				dst[i].Failed += c.Failed
+				found = true
+				break
+			}
+		}
+		if !found {
+			dst = append(dst, c)
+		}
+	}
+	return dst
+}
+
+func mergeChurn(stats []*ChurnStats) *ChurnStats {
+	if len(stats) == 0 {
+		return nil
+	}
+	c := &churner{seconds: make(map[int]*ChurnInterval)}
+	for _, st := range stats {
+		for _, iv := range st.Series {
+			m, ok := c.seconds[iv.Second]
+			if !ok {
+				m = &ChurnInterval{Second: iv.Second}
+				c.seconds[iv.Second] = m
+			}
+			connects := iv.Attempts - iv.Failures
+			m.Attempts += iv.Attempts
+			m.Failures += iv.Failures
+			m.connects += connects
+			m.AvgConnect += iv.AvgConnect * float64(connects)
+			if iv.AvgHandshake > 0 {
+				m.handshakes += connects
+				m.AvgHandshake += iv.AvgHandshake * float64(connects)
+			}
+		}
+	}
+	return c.stats()
+}
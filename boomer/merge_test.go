@@ -0,0 +1,93 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRunAll(t *testing.T) {
+	var reads, writes int64
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			atomic.AddInt64(&writes, 1)
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		atomic.AddInt64(&reads, 1)
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	read, _ := http.NewRequest("GET", server.URL, nil)
+	write, _ := http.NewRequest("POST", server.URL, nil)
+	m, err := RunAll(context.Background(),
+		&Boomer{Name: "read", Request: read, N: 20, C: 4, KeepLatencies: true},
+		&Boomer{Name: "write", Request: write, N: 5, C: 1, KeepLatencies: true},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reads != 20 || writes != 5 {
+		t.Errorf("Expected 20 reads and 5 writes, found %v and %v", reads, writes)
+	}
+	if len(m.Targets) != 2 || m.Targets[0].Name != "read" || len(m.Targets[1].Lats) != 5 {
+		t.Fatalf("Expected a report per target, found %+v", m.Targets)
+	}
+	want := []StatusCode{{Code: 200, Count: 20}, {Code: 201, Count: 5}}
+	codes := m.Combined.StatusCodes
+	if len(m.Combined.Lats) != 25 || len(codes) != 2 ||
+		codes[0].Code != 200 || codes[0].Count != 20 || codes[1].Code != 201 || codes[1].Count != 5 {
+		t.Errorf("Expected combined status codes %v, found %v", want, codes)
+	}
+	for _, s := range codes {
+		if s.P99 <= 0 || s.P99 < s.P50 {
+			t.Errorf("Expected the latencies of the merged %d responses, found %+v", s.Code, s)
+		}
+	}
+}
+
+func TestMergeDecoded(t *testing.T) {
+	var reports []*Report
+	for _, doc := range []string{
+		`{"total_duration": 1000, "lats": [1, 2, 3], "avg_total": 0.006,
+		  "status_codes": [{"code": 200, "count": 3}],
+		  "errors": [{"error": "timeout", "count": 1}]}`,
+		`{"total_duration": 2000, "lats": [4], "avg_total": 0.004,
+		  "status_codes": [{"code": 200, "count": 1}],
+		  "errors": [{"error": "timeout", "count": 2}]}`,
+	} {
+		var r Report
+		if err := json.Unmarshal([]byte(doc), &r); err != nil {
+			t.Fatal(err)
+		}
+		reports = append(reports, &r)
+	}
+	m := Merge(reports...)
+	if m.TotalDuration != 2000 || m.RPS != 2 || m.Fastest != 1 || m.Slowest != 4 {
+		t.Errorf("Unexpected merged latency statistics: %+v", m)
+	}
+	if len(m.Errors) != 1 || m.Errors[0].Count != 3 {
+		t.Errorf("Expected 3 merged timeout errors, found %v", m.Errors)
+	}
+	if len(m.StatusCodes) != 1 || m.StatusCodes[0].Count != 4 {
+		t.Errorf("Expected 4 merged 200 responses, found %v", m.StatusCodes)
+	}
+}
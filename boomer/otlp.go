@@ -0,0 +1,313 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// traceContext is the W3C trace context a request is sent with.
+type traceContext struct {
+	traceID [16]byte
+	spanID  [8]byte
+}
+
+func newTraceContext() *traceContext {
+	t := &traceContext{}
+	rand.Read(t.traceID[:])
+	rand.Read(t.spanID[:])
+	return t
+}
+
+// String returns the context as the value of a traceparent header, of
+// a sampled trace.
+func (t *traceContext) String() string {
+	return fmt.Sprintf("00-%x-%x-01", t.traceID, t.spanID)
+}
+
+const (
+	// otlpBatchSize is the most spans exported at once.
+	otlpBatchSize = 512
+
+	// otlpMetricsInterval is how often the metrics of the run in
+	// progress are exported.
+	otlpMetricsInterval = 10 * time.Second
+)
+
+// otlpSink exports spans and metrics to an OpenTelemetry collector.
+type otlpSink struct {
+	endpoint string
+	headers  map[string]string
+	client   *http.Client
+
+	spans    []otlpSpan
+	flush    time.Time
+	exported time.Time
+
+	// The metrics of the run so far.
+	start     time.Time
+	responses map[int]int64
+	errors    int64
+	sketch    Sketch
+	sum       float64
+	tags      map[string]string
+}
+
+// NewOTLPSink returns a sink exporting to the OpenTelemetry collector
+// at endpoint, e.g. http://localhost:4318, with OTLP over HTTP in its
+// JSON encoding. The headers, if any, are sent with every export.
+//
+// A client span is exported per request sent with a trace context, if
+// TraceContext is set, so that the spans of the server join the trace.
+// The metrics of the run are exported every 10 seconds and once it is
+// done: the requests by status code, the errors, the request rate and
+// a histogram of the latency of successful requests.
+func NewOTLPSink(endpoint string, headers map[string]string) Sink {
+	return &otlpSink{
+		endpoint:  strings.TrimSuffix(endpoint, "/"),
+		headers:   headers,
+		client:    &http.Client{Timeout: 10 * time.Second},
+		flush:     time.Now(),
+		exported:  time.Now(),
+		responses: make(map[int]int64),
+	}
+}
+
+type otlpValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    string  `json:"intValue,omitempty"`
+}
+
+type otlpAttr struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+func stringAttr(k, v string) otlpAttr { return otlpAttr{Key: k, Value: otlpValue{StringValue: &v}} }
+func intAttr(k string, v int) otlpAttr {
+	return otlpAttr{Key: k, Value: otlpValue{IntValue: strconv.Itoa(v)}}
+}
+
+type otlpSpan struct {
+	TraceID    string     `json:"traceId"`
+	SpanID     string     `json:"spanId"`
+	Name       string     `json:"name"`
+	Kind       int        `json:"kind"`
+	Start      string     `json:"startTimeUnixNano"`
+	End        string     `json:"endTimeUnixNano"`
+	Attributes []otlpAttr `json:"attributes"`
+	Status     struct {
+		Code    int    `json:"code,omitempty"`
+		Message string `json:"message,omitempty"`
+	} `json:"status"`
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+func (s *otlpSink) Record(rec Record) error {
+	if s.start.IsZero() {
+		s.start, s.tags = rec.Start, rec.Tags
+	}
+	if rec.Aborted == "" {
+		if rec.Err != nil {
+			s.errors++
+		} else {
+			s.responses[rec.StatusCode]++
+			lat := rec.Duration.Seconds() * 1000
+			s.sketch.add(lat)
+			s.sum += lat
+		}
+	}
+	if rec.TraceID != "" {
+		span := otlpSpan{
+			TraceID: rec.TraceID,
+			SpanID:  rec.SpanID,
+			Name:    rec.Method,
+			Kind:    3, // client
+			Start:   unixNano(rec.Start),
+			End:     unixNano(rec.Start.Add(rec.Duration)),
+			Attributes: []otlpAttr{
+				stringAttr("http.request.method", rec.Method),
+				stringAttr("url.full", rec.Endpoint),
+			},
+		}
+		if rec.StatusCode > 0 {
+			span.Attributes = append(span.Attributes, intAttr("http.response.status_code", rec.StatusCode))
+		}
+		switch {
+		case rec.Err != nil:
+			span.Status.Code, span.Status.Message = 2, rec.Err.Error()
+		case rec.StatusCode >= 500:
+			span.Status.Code = 2
+		}
+		s.spans = append(s.spans, span)
+	}
+
+	now := time.Now()
+	if len(s.spans) >= otlpBatchSize || now.Sub(s.flush) >= sinkFlushInterval {
+		s.flush = now
+		if err := s.exportSpans(); err != nil {
+			return err
+		}
+	}
+	if now.Sub(s.exported) >= otlpMetricsInterval {
+		s.exported = now
+		return s.exportMetrics(nil, now)
+	}
+	return nil
+}
+
+func (s *otlpSink) Close(r *Report) error {
+	if err := s.exportSpans(); err != nil {
+		return err
+	}
+	return s.exportMetrics(r, time.Now())
+}
+
+// resource returns the resource describing the run.
+func (s *otlpSink) resource(name string) map[string]interface{} {
+	attrs := []otlpAttr{stringAttr("service.name", "boom")}
+	if name != "" {
+		attrs = append(attrs, stringAttr("boom.run", name))
+	}
+	keys := make([]string, 0, len(s.tags))
+	for k := range s.tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		attrs = append(attrs, stringAttr(k, s.tags[k]))
+	}
+	return map[string]interface{}{"attributes": attrs}
+}
+
+var otlpScope = map[string]string{"name": "github.com/rakyll/boom"}
+
+func (s *otlpSink) exportSpans() error {
+	if len(s.spans) == 0 {
+		return nil
+	}
+	spans := s.spans
+	s.spans = nil
+	return s.post("/v1/traces", map[string]interface{}{
+		"resourceSpans": []interface{}{map[string]interface{}{
+			"resource":   s.resource(""),
+			"scopeSpans": []interface{}{map[string]interface{}{"scope": otlpScope, "spans": spans}},
+		}},
+	})
+}
+
+// exportMetrics exports the cumulative metrics of the run so far, or
+// those of the final report r if it is not nil.
+func (s *otlpSink) exportMetrics(r *Report, now time.Time) error {
+	if s.start.IsZero() {
+		s.start = now
+	}
+	start, end := unixNano(s.start), unixNano(now)
+	point := func(attrs []otlpAttr, fields map[string]interface{}) map[string]interface{} {
+		fields["startTimeUnixNano"], fields["timeUnixNano"] = start, end
+		if attrs != nil {
+			fields["attributes"] = attrs
+		}
+		return fields
+	}
+
+	var requests []interface{}
+	codes := make([]int, 0, len(s.responses))
+	for code := range s.responses {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	for _, code := range codes {
+		requests = append(requests, point([]otlpAttr{intAttr("http.response.status_code", code)},
+			map[string]interface{}{"asInt": strconv.FormatInt(s.responses[code], 10)}))
+	}
+	rps := float64(s.sketch.count()) / now.Sub(s.start).Seconds()
+	name := ""
+	if r != nil {
+		rps, name = r.RPS, r.Name
+	}
+	sum := func(points ...interface{}) map[string]interface{} {
+		return map[string]interface{}{"dataPoints": points, "aggregationTemporality": 2, "isMonotonic": true}
+	}
+	metrics := []interface{}{
+		map[string]interface{}{"name": "boom.responses", "unit": "{response}", "sum": sum(requests...)},
+		map[string]interface{}{"name": "boom.errors", "unit": "{request}",
+			"sum": sum(point(nil, map[string]interface{}{"asInt": strconv.FormatInt(s.errors, 10)}))},
+		map[string]interface{}{"name": "boom.rps", "unit": "{response}/s",
+			"gauge": map[string]interface{}{"dataPoints": []interface{}{point(nil, map[string]interface{}{"asDouble": rps})}}},
+	}
+	if n := s.sketch.count(); n > 0 {
+		bounds := make([]float64, len(prometheusBuckets))
+		counts := make([]string, len(prometheusBuckets)+1)
+		var below int64
+		for i, le := range prometheusBuckets {
+			bounds[i] = le * 1000
+			c := s.sketch.countUnder(bounds[i])
+			counts[i] = strconv.FormatInt(c-below, 10)
+			below = c
+		}
+		counts[len(prometheusBuckets)] = strconv.FormatInt(n-below, 10)
+		metrics = append(metrics, map[string]interface{}{"name": "boom.latency", "unit": "ms",
+			"histogram": map[string]interface{}{"aggregationTemporality": 2, "dataPoints": []interface{}{
+				point(nil, map[string]interface{}{
+					"count": strconv.FormatInt(n, 10), "sum": s.sum, "bucketCounts": counts, "explicitBounds": bounds,
+				}),
+			}}})
+	}
+	return s.post("/v1/metrics", map[string]interface{}{
+		"resourceMetrics": []interface{}{map[string]interface{}{
+			"resource":     s.resource(name),
+			"scopeMetrics": []interface{}{map[string]interface{}{"scope": otlpScope, "metrics": metrics}},
+		}},
+	})
+}
+
+func (s *otlpSink) post(path string, body interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", s.endpoint+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("otlp: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestOTLPSink(t *testing.T) {
+	var mu sync.Mutex
+	var traceparents []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		traceparents = append(traceparents, r.Header.Get("traceparent"))
+		mu.Unlock()
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+
+	var spans []map[string]interface{}
+	var metrics []string
+	var apiKey string
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		apiKey = r.Header.Get("api-key")
+		switch r.URL.Path {
+		case "/v1/traces":
+			var body struct {
+				ResourceSpans []struct {
+					ScopeSpans []struct {
+						Spans []map[string]interface{}
+					}
+				}
+			}
+			if err := json.Unmarshal(data, &body); err != nil {
+				t.Error(err)
+			}
+			spans = append(spans, body.ResourceSpans[0].ScopeSpans[0].Spans...)
+		case "/v1/metrics":
+			metrics = append(metrics, string(data))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer collector.Close()
+
+	req, _ := http.NewRequest("GET", server.URL+"/fail", nil)
+	r := runBoomer(t, &Boomer{
+		Request:      req,
+		N:            3,
+		C:            1,
+		TraceContext: true,
+		Sinks:        []Sink{NewOTLPSink(collector.URL+"/", map[string]string{"api-key": "secret"})},
+	})
+	if len(r.SinkErrors) != 0 {
+		t.Fatal(r.SinkErrors)
+	}
+	if apiKey != "secret" {
+		t.Errorf("expected the headers to be sent, found api-key %q", apiKey)
+	}
+	if len(spans) != 3 || len(traceparents) != 3 {
+		t.Fatalf("expected 3 spans and traceparents, found %v and %v", len(spans), traceparents)
+	}
+	for i, s := range spans {
+		want := "00-" + s["traceId"].(string) + "-" + s["spanId"].(string) + "-01"
+		if traceparents[i] != want || s["name"] != "GET" || s["kind"] != 3.0 {
+			t.Errorf("unexpected span %v for traceparent %v", s, traceparents[i])
+		}
+		if s["status"].(map[string]interface{})["code"] != 2.0 {
+			t.Errorf("expected the span of a 502 to fail, found %v", s["status"])
+		}
+	}
+	if len(metrics) != 1 {
+		t.Fatalf("expected the metrics to be exported once, found %v", metrics)
+	}
+	for _, want := range []string{
+		`"name":"boom.responses"`, `"intValue":"502"`, `"asInt":"3"`,
+		`"name":"boom.latency"`, `"count":"3"`, `"stringValue":"boom"`,
+	} {
+		if !strings.Contains(metrics[0], want) {
+			t.Errorf("expected %s in the metrics:\n%s", want, metrics[0])
+		}
+	}
+}
+
+func TestTraceContext(t *testing.T) {
+	a, b := newTraceContext(), newTraceContext()
+	if a.String() == b.String() {
+		t.Errorf("expected distinct trace contexts, found %v", a)
+	}
+	if s := a.String(); len(s) != 55 || !strings.HasPrefix(s, "00-") || !strings.HasSuffix(s, "-01") {
+		t.Errorf("unexpected traceparent %q", s)
+	}
+}
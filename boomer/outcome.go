@@ -0,0 +1,119 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import "fmt"
+
+// Verdict is the result of a check.
+type Verdict int
+
+const (
+	Pass Verdict = iota
+	// Warn is the verdict of checks that passed close to their limit.
+	Warn
+	Fail
+)
+
+func (v Verdict) String() string {
+	switch v {
+	case Pass:
+		return "pass"
+	case Warn:
+		return "warn"
+	}
+	return "fail"
+}
+
+// nearLimit is the fraction of a limit past which passing checks warn.
+const nearLimit = 0.8
+
+// Outcome is the verdict of one of the checks of a run.
+type Outcome struct {
+	// Check names the check, e.g. "baseline p99".
+	Check   string
+	Verdict Verdict
+	Message string
+}
+
+// Outcomes evaluates the checks of the run: the header, field, body and
+// schema checks, the SLO, the comparison to the baseline and the
+// thresholds.
+func (r *Report) Outcomes() []Outcome {
+	var out []Outcome
+	for _, c := range append(append(append([]CheckResult(nil), r.HeaderChecks...), r.FieldChecks...), r.BodyChecks...) {
+		o := Outcome{
+			Check:   "check " + c.Check,
+			Message: fmt.Sprintf("%d of %d responses failed", c.Failed, c.Passed+c.Failed),
+		}
+		if c.Failed > 0 {
+			o.Verdict = Fail
+		}
+		out = append(out, o)
+	}
+	for _, s := range r.Schema {
+		o := Outcome{
+			Check:   "schema " + s.Endpoint,
+			Message: fmt.Sprintf("%d of %d responses failed", s.Failed, s.Validated),
+		}
+		if s.Failed > 0 {
+			o.Verdict = Fail
+			o.Message += ": " + s.FirstError
+		}
+		out = append(out, o)
+	}
+	if s := r.SLO; s != nil {
+		o := Outcome{
+			Check:   "slo " + s.String(),
+			Message: fmt.Sprintf("burn rate %.2f, %d of %d requests bad", s.BurnRate, s.Bad, s.Total),
+		}
+		for _, w := range s.Windows {
+			if w.Alert {
+				o.Verdict = Fail
+				o.Message += fmt.Sprintf(", alerting over %vh", w.Window)
+				break
+			}
+		}
+		if o.Verdict == Pass && s.BurnRate > 1 {
+			// Spending the error budget faster than it is earned.
+			o.Verdict = Warn
+		}
+		out = append(out, o)
+	}
+	for _, c := range r.Baseline {
+		o := Outcome{
+			Check:   "baseline " + c.Metric,
+			Message: fmt.Sprintf("%.4f vs %.4f, %+.1f%% for at most %.1f%%", c.Value, c.Baseline, c.Change*100, c.Max*100),
+		}
+		if c.Regressed {
+			o.Verdict = Fail
+		} else if c.Max > 0 && c.Change >= nearLimit*c.Max {
+			o.Verdict = Warn
+		}
+		out = append(out, o)
+	}
+	for _, t := range r.Thresholds {
+		o := Outcome{
+			Check:   "threshold " + t.Metric,
+			Message: formatMetric(t.Metric, t.Value) + " " + t.limit(),
+		}
+		if t.Failed {
+			o.Verdict = Fail
+		} else if t.Min > 0 && nearLimit*t.Value <= t.Min || t.Min <= 0 && t.Value >= nearLimit*t.Max {
+			o.Verdict = Warn
+		}
+		out = append(out, o)
+	}
+	return out
+}
@@ -0,0 +1,204 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func outcomeReport() *Report {
+	return &Report{
+		Name:         "api",
+		HeaderChecks: []CheckResult{{Check: "Content-Type=application/json", Passed: 9, Failed: 1}},
+		FieldChecks:  []CheckResult{{Check: "ok=true", Passed: 10}},
+		Baseline: []Comparison{
+			{Metric: "p99", Value: 109, Baseline: 100, Change: 0.09, Max: 0.1},
+			{Metric: "rps", Value: 100, Baseline: 100, Max: 0.1},
+		},
+	}
+}
+
+func TestOutcomes(t *testing.T) {
+	want := map[string]Verdict{
+		"check Content-Type=application/json": Fail,
+		"check ok=true":                       Pass,
+		"baseline p99":                        Warn,
+		"baseline rps":                        Pass,
+	}
+	outcomes := outcomeReport().Outcomes()
+	if len(outcomes) != len(want) {
+		t.Fatalf("expected %v outcomes, found %+v", len(want), outcomes)
+	}
+	for _, o := range outcomes {
+		if v, ok := want[o.Check]; !ok || o.Verdict != v {
+			t.Errorf("%v: expected %v, found %v", o.Check, v, o.Verdict)
+		}
+	}
+}
+
+func TestWriteGitHubAnnotations(t *testing.T) {
+	var buf bytes.Buffer
+	if err := outcomeReport().WriteGitHubAnnotations(&buf); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 annotations, found %q", buf.String())
+	}
+	if want := "::error title=boom api%3A check Content-Type=application/json::1 of 10 responses failed"; lines[0] != want {
+		t.Errorf("expected %q, found %q", want, lines[0])
+	}
+	if !strings.HasPrefix(lines[1], "::warning title=boom api%3A baseline p99::") {
+		t.Errorf("expected a warning for p99, found %q", lines[1])
+	}
+}
+
+func TestWriteGitLabCodeQuality(t *testing.T) {
+	var buf bytes.Buffer
+	if err := outcomeReport().WriteGitLabCodeQuality(&buf, "load/api.json"); err != nil {
+		t.Fatal(err)
+	}
+	var issues []codeQualityIssue
+	if err := json.Unmarshal(buf.Bytes(), &issues); err != nil {
+		t.Fatal(err)
+	}
+	if len(issues) != 2 || issues[0].Severity != "major" || issues[1].Severity != "minor" {
+		t.Fatalf("expected a major and a minor issue, found %+v", issues)
+	}
+	if issues[0].Location.Path != "load/api.json" || issues[0].Fingerprint == issues[1].Fingerprint {
+		t.Errorf("unexpected issues %+v", issues)
+	}
+}
+
+func TestWriteTAP(t *testing.T) {
+	var buf bytes.Buffer
+	if err := outcomeReport().WriteTAP(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `TAP version 13
+1..4
+not ok 1 - api: check Content-Type=application/json
+  ---
+  message: "1 of 10 responses failed"
+  verdict: fail
+  ...
+ok 2 - api: check ok=true
+  ---
+  message: "0 of 10 responses failed"
+  verdict: pass
+  ...
+ok 3 - api: baseline p99
+  ---
+  message: "109.0000 vs 100.0000, +9.0% for at most 10.0%"
+  verdict: warn
+  ...
+ok 4 - api: baseline rps
+  ---
+  message: "100.0000 vs 100.0000, +0.0% for at most 10.0%"
+  verdict: pass
+  ...
+`
+	if buf.String() != want {
+		t.Errorf("expected\n%s\nfound\n%s", want, buf.String())
+	}
+
+	buf.Reset()
+	if err := (&Report{}).WriteTAP(&buf); err != nil || buf.String() != "TAP version 13\n1..0 # SKIP no checks\n" {
+		t.Errorf("unexpected TAP for a run without checks %q, %v", buf.String(), err)
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	r := &Report{
+		Lats:        []float64{10, 20, 30, 40},
+		keepLats:    true,
+		StatusCodes: []StatusCode{{Code: 200, Count: 4}},
+		Errors:      []Error{{Error: "refused", Count: 1}},
+		HeaderChecks: []CheckResult{
+			{Check: "Cache-Control", Passed: 3, Failed: 1},
+			{Check: "X-Frame-Options=DENY", Passed: 4},
+		},
+	}
+	r.RPS = 800
+	got := Evaluate(r, []Threshold{
+		{Metric: "p99", Max: 50},
+		{Metric: "p50", Max: 20},
+		{Metric: "error_rate", Max: 0.1},
+		{Metric: "rps", Min: 500},
+		{Metric: "rps", Min: 1000},
+		{Metric: "header_failure_rate", Max: 0.1},
+	})
+	want := []ThresholdResult{
+		{Metric: "p99", Max: 50, Value: 40},
+		{Metric: "p50", Max: 20, Value: 20, Failed: true},
+		{Metric: "error_rate", Max: 0.1, Value: 0.2, Failed: true},
+		{Metric: "rps", Min: 500, Value: 800},
+		{Metric: "rps", Min: 1000, Value: 800, Failed: true},
+		{Metric: "header_failure_rate", Max: 0.1, Value: 0.125, Failed: true},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %+v, found %+v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("expected %+v, found %+v", want[i], got[i])
+		}
+	}
+	if r.ThresholdsFailed() || !(&Report{Thresholds: got}).ThresholdsFailed() {
+		t.Errorf("expected the failed thresholds to be reported")
+	}
+	if rs := Evaluate(&Report{}, []Threshold{{Metric: "average", Max: 100}}); !rs[0].Failed {
+		t.Errorf("expected a latency threshold to fail without responses, found %+v", rs[0])
+	}
+	errorRate := []Threshold{{Metric: "error_rate", Max: 0.01}}
+	if rs := Evaluate(&Report{StatusCodes: []StatusCode{{Code: 500, Count: 4}}}, errorRate); !rs[0].Failed || rs[0].Value != 1 {
+		t.Errorf("expected error responses to count as errors, found %+v", rs[0])
+	}
+	if rs := Evaluate(&Report{GRPC: true, StatusCodes: []StatusCode{{Code: 0, Count: 3}, {Code: 14, Count: 1}}}, errorRate); rs[0].Value != 0.25 {
+		t.Errorf("expected non-OK gRPC codes to count as errors, found %+v", rs[0])
+	}
+}
+
+func TestWriteJUnit(t *testing.T) {
+	r := outcomeReport()
+	r.TotalDuration = 1500
+	r.Thresholds = []ThresholdResult{{Metric: "error_rate", Max: 0.005, Value: 0.01, Failed: true}}
+	var buf bytes.Buffer
+	if err := r.WriteJUnit(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var got junitSuites
+	if err := xml.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Tests != 5 || got.Failures != 2 || len(got.Suites) != 1 {
+		t.Fatalf("unexpected test suites:\n%s", buf.String())
+	}
+	s := got.Suites[0]
+	if s.Name != "api" || s.Time != "1.500" || len(s.Cases) != 5 {
+		t.Fatalf("unexpected test suite:\n%s", buf.String())
+	}
+	c := s.Cases[4]
+	if c.Name != "threshold error_rate" || c.Failure == nil || c.Failure.Message != "1% for at most 0.5%" {
+		t.Errorf("unexpected threshold test case %+v", c)
+	}
+	if s.Cases[1].Failure != nil || s.Cases[2].SystemOut != "warn: 109.0000 vs 100.0000, +9.0% for at most 10.0%" {
+		t.Errorf("unexpected test cases %+v", s.Cases)
+	}
+}
@@ -15,6 +15,7 @@
 package boomer
 
 import (
+	"crypto/sha256"
 	"sort"
 	"time"
 )
@@ -24,6 +25,9 @@ const (
 )
 
 type Report struct {
+	// Name is the name of the run the report describes, if any.
+	Name string `json:"name,omitempty"`
+
 	AvgTotal float64 `json:"avg_total"`
 	Fastest  float64 `json:"fastest"`
 	Slowest  float64 `json:"slowest"`
@@ -37,14 +41,292 @@ type Report struct {
 	Percentiales  []Percential `json:"percentiales"`
 	Histogram     []Bucket     `json:"histogram"`
 
-	Lats      []float64 `json:"lats"`
-	SizeTotal int64     `json:"size_total"`
+	// Lats are the latencies of successful requests, in ms, if they
+	// were retained. If LatencySampling is more than 1, only one in that
+	// many is kept. Sketch counts them all in bounded memory, and the
+	// latency statistics are estimated from it unless Lats holds every
+	// latency.
+	Lats            []float64 `json:"lats"`
+	LatencySampling int       `json:"latency_sampling,omitempty"`
+	Sketch          *Sketch   `json:"sketch,omitempty"`
+	SizeTotal       int64     `json:"size_total"`
+
+	// WireBytes and BodyBytes are the sizes of the response bodies as
+	// transferred and once decoded, for bandwidth and payload size to be
+	// told apart. Only counted if bodies are read, as with ReadAll,
+	// unlike SizeTotal, the total of their Content-Length.
+	WireBytes int64 `json:"wire_bytes,omitempty"`
+	BodyBytes int64 `json:"body_bytes,omitempty"`
+
+	// ValidatorMismatches is the number of responses whose body differed
+	// from an earlier response carrying the same strong ETag.
+	ValidatorMismatches int `json:"validator_mismatches"`
+
+	// CompressionRatio is the ratio of decoded to transferred response
+	// bytes across all endpoints. Only reported if compression is measured.
+	CompressionRatio float64            `json:"compression_ratio,omitempty"`
+	Compression      []CompressionStats `json:"compression,omitempty"`
+
+	// Variants lists the number of distinct response bodies seen per
+	// endpoint. Only reported if bodies are hashed.
+	Variants []BodyVariants `json:"variants,omitempty"`
+
+	// Timeouts counts the requests that timed out, by the phase they
+	// were in: waiting for a pooled connection ("connect"), resolving
+	// ("dns"), dialing ("dial"), handshaking ("tls"), waiting for the
+	// response headers ("headers") or reading the body ("body"). They
+	// are not included in Errors.
+	Timeouts []Timeout `json:"timeouts,omitempty"`
+
+	// Scaling lists the workers running over time if they were
+	// auto-scaled.
+	Scaling []ScaleInterval `json:"scaling,omitempty"`
+
+	// Degraded notes what the report stopped retaining to keep the
+	// memory used under the limit.
+	Degraded []string `json:"degraded,omitempty"`
+
+	// Stopped is why the run stopped before making all its requests,
+	// if it did.
+	Stopped string `json:"stopped,omitempty"`
+
+	// Partial is set if the run was interrupted, its context done,
+	// before making all its requests. The report covers the requests
+	// made until then.
+	Partial bool `json:"partial,omitempty"`
+
+	// Resumed is the number of requests made by the interrupted run
+	// the report's run resumed, if any. CheckpointError holds the error
+	// the last time the state of the run failed to be saved.
+	Resumed         int    `json:"resumed,omitempty"`
+	CheckpointError string `json:"checkpoint_error,omitempty"`
+
+	// Position is how far the run got in generating requests, saved
+	// in checkpoints for the run resuming it to carry on from.
+	Position *Position `json:"position,omitempty"`
+
+	// SinkErrors holds the errors of the sinks that failed, which were
+	// no longer given records.
+	SinkErrors []string `json:"sink_errors,omitempty"`
+
+	// Stalls is the number of requests a worker was stuck on for much
+	// longer than the timeout allows, as counted by the watchdog.
+	Stalls int `json:"stalls,omitempty"`
+
+	// Truncated is the number of response bodies that were not read
+	// completely as they exceeded the maximum body size.
+	Truncated int `json:"truncated,omitempty"`
+
+	// Aborts counts the requests deliberately cancelled, by phase. They
+	// are excluded from the latency and error statistics.
+	Aborts []Abort `json:"aborts,omitempty"`
+
+	// ConnsDialed is the number of requests that had to dial a new
+	// connection. ExtraDials counts the new connections after the first
+	// of each worker, whatever closed the previous one: the idle timeout,
+	// an idle pool too small to keep it, or the server.
+	ConnsDialed int `json:"conns_dialed"`
+	ExtraDials  int `json:"extra_dials"`
+
+	// HandshakeFailures counts the errors of requests whose connection
+	// failed its TLS handshake, e.g. as the server rejected the client
+	// certificate. They are also in Errors.
+	HandshakeFailures int `json:"handshake_failures,omitempty"`
+
+	// GRPC is set if the requests were gRPC calls, whose status codes
+	// StatusCodes counts.
+	GRPC bool `json:"grpc,omitempty"`
+
+	// Stream describes the messages of streaming gRPC calls or
+	// WebSocket sessions, if they were made.
+	Stream *StreamStats `json:"stream,omitempty"`
+
+	// Protocols counts the successful requests, and the connections,
+	// by the HTTP version they used.
+	Protocols []ProtocolStats `json:"protocols,omitempty"`
+
+	// Redirects counts the successful requests by the number of
+	// redirects followed to get their response, and RedirectCodes
+	// counts the status codes of the redirects, if any were followed.
+	Redirects     []RedirectCount `json:"redirects,omitempty"`
+	RedirectCodes []StatusCode    `json:"redirect_codes,omitempty"`
+
+	// Retries describes the requests by the number of times they were
+	// retried, if any were.
+	Retries []RetryCount `json:"retries,omitempty"`
+
+	// HeaderChecks holds the outcome of each header check.
+	HeaderChecks []CheckResult `json:"header_checks,omitempty"`
+
+	// FieldChecks holds the outcome of each field check. DecodeErrors
+	// counts the bodies that could not be decoded to evaluate them.
+	FieldChecks  []CheckResult `json:"field_checks,omitempty"`
+	DecodeErrors int           `json:"decode_errors,omitempty"`
+
+	// BodyChecks holds the outcome of each body check.
+	// AssertionFailures counts the responses that failed any of them,
+	// which are still counted as responses rather than errors.
+	BodyChecks        []CheckResult `json:"body_checks,omitempty"`
+	AssertionFailures int           `json:"assertion_failures,omitempty"`
+
+	// Schema holds the outcome of validating response bodies against
+	// the JSON Schema, per endpoint.
+	Schema []SchemaStats `json:"schema,omitempty"`
+
+	// Warmup is the number of requests made to warm up before the run,
+	// which are left out of the report.
+	Warmup int `json:"warmup,omitempty"`
+
+	// Schedule compares the intended and achieved arrival rates of an
+	// open model run, or of one with Poisson arrivals.
+	Schedule *ScheduleStats `json:"schedule,omitempty"`
+
+	// Ramp describes the requests started in each phase of a ramped
+	// run, to tell the steady state apart. Merge only combines the
+	// phases of reports made in the same process.
+	Ramp []RampPhase `json:"ramp,omitempty"`
+
+	// Stages holds a report of each stage of a run in stages started.
+	// Merge leaves them out.
+	Stages []*Report `json:"stages,omitempty"`
+
+	// Churn describes the connections opened by the churn mode.
+	Churn *ChurnStats `json:"churn,omitempty"`
+
+	// Budget attributes the latency of each endpoint to the phases of
+	// its requests. Only reported if enabled. Merge only combines the
+	// budgets of reports made in the same process.
+	Budget []LatencyBudget `json:"budget,omitempty"`
+
+	// Shards describes the requests sent to each host, if they were
+	// spread over several. Merge only combines the shards of reports
+	// made in the same process.
+	Shards []ShardStats `json:"shards,omitempty"`
+
+	// Successes and Failures are the latencies of the requests that
+	// succeeded and of those that failed: error responses, errors and
+	// timeouts. Only reported if there were any. Merge only combines
+	// the latencies of reports made in the same process.
+	Successes *LatencyStats `json:"successes,omitempty"`
+	Failures  *LatencyStats `json:"failures,omitempty"`
+
+	// NewConns and ReusedConns are the latencies of the responses
+	// received over a connection dialed for the request and over a
+	// kept-alive one. Merge only combines the latencies of reports made
+	// in the same process.
+	NewConns    *LatencyStats `json:"new_conns,omitempty"`
+	ReusedConns *LatencyStats `json:"reused_conns,omitempty"`
+
+	// PoolWaits is how long the requests that blocked waiting for a
+	// connection from the pool, as MaxConnsPerHost were in use, waited.
+	// Its count is the number of such requests; waits under a
+	// millisecond are not counted. Merge only combines the waits of
+	// reports made in the same process.
+	PoolWaits *LatencyStats `json:"pool_waits,omitempty"`
+
+	// DNS summarizes the DNS lookups made to dial connections, if any
+	// were made.
+	DNS *DNSStats `json:"dns,omitempty"`
+
+	// Families describes the responses received over IPv4 and IPv6
+	// connections.
+	Families []FamilyStats `json:"families,omitempty"`
+
+	// Endpoints describes the requests sent to each endpoint, if they
+	// were spread over several. Merge only combines the endpoints of
+	// reports made in the same process.
+	Endpoints []EndpointStats `json:"endpoints,omitempty"`
+
+	// Worst is the latency of the slowest successful request started in
+	// each second of the run.
+	Worst []WorstLatency `json:"worst,omitempty"`
+
+	// Intervals summarizes the requests started in each interval of
+	// the run, every 5 seconds by default, in order.
+	Intervals []IntervalStats `json:"intervals,omitempty"`
 
-	errorDist      map[string]int
-	statusCodeDist map[int]int
-	results        chan *result
-	total          time.Duration
-	output         string
+	// Baseline compares the run to a baseline run, if one was given.
+	Baseline []Comparison `json:"baseline,omitempty"`
+
+	// Thresholds holds the metrics of the thresholds of the run, if
+	// any were given.
+	Thresholds []ThresholdResult `json:"thresholds,omitempty"`
+
+	// SLO holds the burn rate of the run against the SLO, if one was
+	// given.
+	SLO *SLOStats `json:"slo,omitempty"`
+
+	errorDist        map[string]int
+	abortDist        map[string]int
+	timeoutDist      map[timeoutKey]int
+	compression      map[string]*CompressionStats
+	protocols        map[string]*ProtocolStats
+	variants         map[string]map[[sha256.Size]byte]int
+	statusCodeDist   map[int]int
+	redirectDist     map[int]int
+	redirectCodeDist map[int]int
+	retryDist        map[int]*retrySamples
+	retried          bool
+	results          chan *result
+	total            time.Duration
+	workers          int
+	headerChecks     []HeaderCheck
+	checkFailures    []int
+	fieldChecks      []FieldCheck
+	fieldFailures    []int
+	decoded          int
+	bodyChecks       []BodyCheck
+	bodyFailures     []int
+	bodyChecked      int
+	schema           map[string]*SchemaStats
+	keepRecords      bool
+	keepLats         bool
+	tags             map[string]string
+	records          []Record
+	phases           map[string][]phaseSample
+	shards           map[string]*shardSamples
+	endpoints        map[string]*endpointSamples
+	statusLats       map[int]*latencySamples
+	successLats      latencySamples
+	failureLats      latencySamples
+	newConnLats      latencySamples
+	reusedConnLats   latencySamples
+	poolWaitLats     latencySamples
+	dnsLookups       int
+	dnsFailures      int
+	dnsLats          latencySamples
+	families         map[string]*FamilyStats
+	ramp             []*rampSamples
+	stages           []*Report
+	worst            map[int]float64
+	interval         time.Duration
+	intervals        map[int]*intervalSamples
+	fixedIntervals   []IntervalStats
+	guard            *memoryGuard
+	checkpoint       *checkpointer
+	position         func() *Position
+	sinks            *sinks
+	view             *liveView
+
+	// end is when the last request of the report of a stage completed.
+	end time.Time
+
+	// start is when the run of n requests started, and prev the report
+	// of the interrupted run it resumes, if any. Snapshots of the report
+	// in progress are requested on snapshots until collected is closed,
+	// and final holds the final report once finished is.
+	start     time.Time
+	n         int
+	prev      *Report
+	snapshots chan chan *Report
+	collected chan struct{}
+	finished  chan struct{}
+	final     *Report
+
+	// responses counts the successful requests, and fastest and slowest
+	// are their extreme latencies, whether sampled or not.
+	responses        int
+	fastest, slowest float64
 }
 
 type Percential struct {
@@ -60,6 +342,72 @@ type Bucket struct {
 type StatusCode struct {
 	Code  int `json:"code"`
 	Count int `json:"count"`
+
+	// Average, P50, P90 and P99 are latencies of the responses of the
+	// code, in ms. Merge only combines the latencies of reports made in
+	// the same process.
+	Average float64 `json:"average,omitempty"`
+	P50     float64 `json:"p50,omitempty"`
+	P90     float64 `json:"p90,omitempty"`
+	P99     float64 `json:"p99,omitempty"`
+}
+
+// CompressionStats describes how well the responses of an endpoint
+// were compressed.
+type CompressionStats struct {
+	Endpoint  string  `json:"endpoint"`
+	Responses int     `json:"responses"`
+	WireBytes int64   `json:"wire_bytes"`
+	BodyBytes int64   `json:"body_bytes"`
+	Ratio     float64 `json:"ratio"`
+
+	// Uncompressed is the number of non-empty responses returned
+	// without a Content-Encoding even though one was accepted.
+	Uncompressed int `json:"uncompressed"`
+}
+
+// BodyVariants describes how consistent the response bodies of an
+// endpoint were. A deterministic endpoint has a single variant.
+type BodyVariants struct {
+	Endpoint  string `json:"endpoint"`
+	Responses int    `json:"responses"`
+	Distinct  int    `json:"distinct"`
+}
+
+type Timeout struct {
+	Phase string `json:"phase"`
+	Count int    `json:"count"`
+
+	// Limit is the timeout that expired: "dial", "tls" or "header" for
+	// DialTimeout, TLSTimeout and HeaderTimeout, or "total" for Timeout.
+	Limit string `json:"limit,omitempty"`
+}
+
+// timeoutKey counts the timeouts of a phase and limit.
+type timeoutKey struct {
+	phase, limit string
+}
+
+type Abort struct {
+	Phase string `json:"phase"`
+	Count int    `json:"count"`
+}
+
+// CheckResult counts the responses that passed and failed a check.
+type CheckResult struct {
+	Check  string `json:"check"`
+	Passed int    `json:"passed"`
+	Failed int    `json:"failed"`
+}
+
+// SchemaStats counts the response bodies of an endpoint that were
+// validated against the JSON Schema and those that failed. FirstError
+// describes the first violation found.
+type SchemaStats struct {
+	Endpoint   string `json:"endpoint"`
+	Validated  int    `json:"validated"`
+	Failed     int    `json:"failed"`
+	FirstError string `json:"first_error,omitempty"`
 }
 
 type Error struct {
@@ -67,47 +415,216 @@ type Error struct {
 	Count int    `json:"count"`
 }
 
-func newReport(size int, results chan *result, output string, total time.Duration) *Report {
+func newReport(size int, results chan *result, total time.Duration) *Report {
 	return &Report{
-		output:         output,
 		results:        results,
 		total:          total,
 		statusCodeDist: make(map[int]int),
 		errorDist:      make(map[string]int),
+		abortDist:      make(map[string]int),
+		timeoutDist:    make(map[timeoutKey]int),
+		compression:    make(map[string]*CompressionStats),
+		variants:       make(map[string]map[[sha256.Size]byte]int),
+		schema:         make(map[string]*SchemaStats),
+		shards:         make(map[string]*shardSamples),
+		endpoints:      make(map[string]*endpointSamples),
+		statusLats:     make(map[int]*latencySamples),
 	}
 }
 
-func (r *Report) finalize() {
+// collect consumes the results of requests as they are made, until the
+// results channel is closed.
+func (r *Report) collect() {
+	defer close(r.collected)
+	var checkpoints <-chan time.Time
+	if r.checkpoint != nil {
+		t := time.NewTicker(r.checkpoint.every)
+		defer t.Stop()
+		checkpoints = t.C
+	}
 	for {
 		select {
-		case res := <-r.results:
-			if res.err != nil {
-				r.errorDist[res.err.Error()]++
-			} else {
-				r.Lats = append(r.Lats, res.duration.Seconds()*1000)
-				r.AvgTotal += res.duration.Seconds()
-				r.statusCodeDist[res.statusCode]++
-				if res.contentLength > 0 {
-					r.SizeTotal += res.contentLength
-				}
-			}
-		default:
-			r.RPS = float64(len(r.Lats)) / r.total.Seconds()
-			r.Average = r.AvgTotal / float64(len(r.Lats))
-			sort.Float64s(r.Lats)
-			if len(r.Lats) == 0 {
+		case res, ok := <-r.results:
+			if !ok {
 				return
 			}
+			r.add(res)
+			if r.guard != nil {
+				r.guard.check(r)
+			}
+		case <-checkpoints:
+			r.checkpoint.save(r)
+		case reply := <-r.snapshots:
+			reply <- r.snapshot()
+		}
+	}
+}
+
+func (r *Report) add(res *result) {
+	if r.keepRecords {
+		r.records = append(r.records, r.record(res))
+	}
+	if r.view != nil {
+		r.view.add(res)
+	}
+	if r.sinks != nil {
+		r.sinks.record(r.record(res))
+	}
+	if res.validatorMismatch {
+		r.ValidatorMismatches++
+	}
+	if res.newConn {
+		r.ConnsDialed++
+	}
+	r.addDNS(res)
+	if res.truncated {
+		r.Truncated++
+	}
+	if r.SLO != nil && res.aborted == "" {
+		r.SLO.add(res)
+	}
+	if res.aborted == "" {
+		r.addRetries(res)
+	}
+	if res.aborted != "" {
+		r.abortDist[res.aborted]++
+	} else if res.timeout != "" {
+		r.timeoutDist[timeoutKey{res.timeout, res.timeoutLimit}]++
+	} else if res.err != nil {
+		r.errorDist[res.err.Error()]++
+		if handshakeFailed(res) {
+			r.HandshakeFailures++
+		}
+	} else {
+		r.countChecks(res)
+		r.addLatency(res.duration.Seconds() * 1000)
+		r.addWorst(int(res.start.Sub(r.start)/time.Second), res.duration.Seconds()*1000)
+		r.AvgTotal += res.duration.Seconds()
+		r.statusCodeDist[res.statusCode]++
+		r.addProtocol(res)
+		r.addFamily(res)
+		r.addRedirects(res)
+		if r.Stream != nil && res.stream != nil {
+			r.Stream.add(res.stream)
+		}
+		if res.contentLength > 0 {
+			r.SizeTotal += res.contentLength
+		}
+		r.WireBytes += res.wireBytes
+		r.BodyBytes += res.bodyBytes
+		if res.compressionMeasured && res.wireBytes > 0 {
+			r.addCompression(res)
+		}
+		if res.hashed {
+			r.addVariant(res)
+		}
+		if res.schemaChecked {
+			r.addSchema(res)
+		}
+		if r.phases != nil {
+			r.addPhases(res)
+		}
+	}
+	if res.shard != "" && res.aborted == "" {
+		r.addShard(res)
+	}
+	if res.target != "" && res.aborted == "" {
+		r.addEndpoint(res)
+	}
+	if res.aborted == "" {
+		r.addOutcome(res)
+		r.addInterval(res)
+	}
+	if r.ramp != nil && res.rampPhase > 0 && res.aborted == "" {
+		r.addRamp(res)
+	}
+	if r.stages != nil && res.stage > 0 {
+		r.addStage(res)
+	}
+}
+
+// addLatency records the latency, in ms, of a successful request. Only
+// one in LatencySampling is kept in Lats, if they are retained and
+// sampled.
+func (r *Report) addLatency(lat float64) {
+	if r.responses == 0 || lat < r.fastest {
+		r.fastest = lat
+	}
+	if lat > r.slowest {
+		r.slowest = lat
+	}
+	if r.keepLats && (r.LatencySampling <= 1 || r.responses%r.LatencySampling == 0) {
+		r.Lats = append(r.Lats, lat)
+	}
+	if r.Sketch == nil {
+		r.Sketch = &Sketch{}
+	}
+	r.Sketch.add(lat)
+	r.responses++
+}
+
+// finalize computes the statistics of the report once all results are
+// collected.
+func (r *Report) finalize() {
+	if r.ExtraDials = r.ConnsDialed - r.workers; r.ExtraDials < 0 {
+		r.ExtraDials = 0
+	}
+	r.printStatusCodes()
+	r.printProtocols()
+	r.printRedirects()
+	r.printRetries()
+	r.printErrors()
+	r.printAborts()
+	r.printTimeouts()
+	r.printHeaderChecks()
+	r.printCompression()
+	r.printVariants()
+	r.printSchema()
+	r.printBudget()
+	r.printShards()
+	r.printEndpoints()
+	r.printOutcomes()
+	r.printDNS()
+	r.printFamilies()
+	r.printRamp()
+	r.printStages()
+	r.printWorst()
+	r.printIntervals()
+	if r.SLO != nil {
+		r.SLO.compute()
+	}
+	if r.Stream != nil {
+		r.Stream.compute()
+	}
+	r.summarize()
+}
 
-			r.Fastest = r.Lats[0]
-			r.Slowest = r.Lats[len(r.Lats)-1]
-			r.printStatusCodes()
-			r.printStatusCodes()
-			r.printLatencies()
-			r.printHistogram()
-			return
+// summarize computes the latency statistics from Lats or the sketch.
+func (r *Report) summarize() {
+	r.TotalDuration = int(r.total / time.Millisecond)
+	r.RPS = float64(r.Responses()) / r.total.Seconds()
+	r.Average = 0
+	if n := r.Responses(); n > 0 {
+		r.Average = r.AvgTotal / float64(n)
+	}
+	sort.Float64s(r.Lats)
+	r.Percentiales, r.Histogram = nil, nil
+	if !r.fromLats() {
+		if r.Sketch.count() > 0 {
+			r.Fastest, r.Slowest = r.fastest, r.slowest
+			r.printSketch()
 		}
+		return
 	}
+
+	r.Fastest = r.Lats[0]
+	r.Slowest = r.Lats[len(r.Lats)-1]
+	if r.LatencySampling > 1 && r.responses > 0 {
+		// The extremes may not have been sampled.
+		r.Fastest, r.Slowest = r.fastest, r.slowest
+	}
+	r.printLatencies()
+	r.printHistogram()
 }
 
 func (r *Report) printLatencies() {
@@ -155,10 +672,15 @@ func (r *Report) printHistogram() {
 		}
 	}
 
+	// Scale the counts of sampled latencies up to all responses.
+	scale := 1.0
+	if n := r.Responses(); n > len(r.Lats) {
+		scale = float64(n) / float64(len(r.Lats))
+	}
 	for i := 0; i < len(buckets); i++ {
 		r.Histogram = append(r.Histogram, Bucket{
 			Bucket: buckets[i],
-			Count:  counts[i],
+			Count:  int(float64(counts[i])*scale + 0.5),
 		})
 	}
 }
@@ -166,11 +688,188 @@ func (r *Report) printHistogram() {
 // Prints status code distribution.
 func (r *Report) printStatusCodes() {
 	for code, num := range r.statusCodeDist {
-		r.StatusCodes = append(r.StatusCodes, StatusCode{
+		s := StatusCode{
 			Code:  code,
 			Count: num,
+		}
+		if lats, ok := r.statusLats[code]; ok {
+			st := lats.stats()
+			s.Average, s.P50, s.P90, s.P99 = st.Average, st.P50, st.P90, st.P99
+		}
+		r.StatusCodes = append(r.StatusCodes, s)
+	}
+	sort.Slice(r.StatusCodes, func(i, j int) bool {
+		return r.StatusCodes[i].Code < r.StatusCodes[j].Code
+	})
+}
+
+func (r *Report) addCompression(res *result) {
+	c, ok := r.compression[res.endpoint]
+	if !ok {
+		c = &CompressionStats{Endpoint: res.endpoint}
+		r.compression[res.endpoint] = c
+	}
+	c.Responses++
+	c.WireBytes += res.wireBytes
+	c.BodyBytes += res.bodyBytes
+	if res.encoding == "" {
+		c.Uncompressed++
+	}
+}
+
+func (r *Report) printCompression() {
+	var wire, body int64
+	for _, c := range r.compression {
+		c.Ratio = float64(c.BodyBytes) / float64(c.WireBytes)
+		wire += c.WireBytes
+		body += c.BodyBytes
+		r.Compression = append(r.Compression, *c)
+	}
+	sort.Slice(r.Compression, func(i, j int) bool {
+		return r.Compression[i].Endpoint < r.Compression[j].Endpoint
+	})
+	if wire > 0 {
+		r.CompressionRatio = float64(body) / float64(wire)
+	}
+}
+
+func (r *Report) addVariant(res *result) {
+	sums, ok := r.variants[res.endpoint]
+	if !ok {
+		sums = make(map[[sha256.Size]byte]int)
+		r.variants[res.endpoint] = sums
+	}
+	sums[res.bodySum]++
+}
+
+func (r *Report) printVariants() {
+	for endpoint, sums := range r.variants {
+		v := BodyVariants{Endpoint: endpoint, Distinct: len(sums)}
+		for _, n := range sums {
+			v.Responses += n
+		}
+		r.Variants = append(r.Variants, v)
+	}
+	sort.Slice(r.Variants, func(i, j int) bool {
+		return r.Variants[i].Endpoint < r.Variants[j].Endpoint
+	})
+}
+
+func (r *Report) printAborts() {
+	for phase, num := range r.abortDist {
+		r.Aborts = append(r.Aborts, Abort{
+			Phase: phase,
+			Count: num,
+		})
+	}
+	sort.Slice(r.Aborts, func(i, j int) bool {
+		return r.Aborts[i].Phase < r.Aborts[j].Phase
+	})
+}
+
+func (r *Report) countChecks(res *result) {
+	if r.checkFailures == nil {
+		r.checkFailures = make([]int, len(r.headerChecks))
+		r.fieldFailures = make([]int, len(r.fieldChecks))
+		r.bodyFailures = make([]int, len(r.bodyChecks))
+	}
+	for _, i := range res.failedChecks {
+		r.checkFailures[i]++
+	}
+	if res.bodyChecked {
+		r.bodyChecked++
+		for _, i := range res.failedBodyChecks {
+			r.bodyFailures[i]++
+		}
+		if len(res.failedBodyChecks) > 0 {
+			r.AssertionFailures++
+		}
+	}
+	if len(r.fieldChecks) == 0 {
+		return
+	}
+	if res.decodeErr {
+		r.DecodeErrors++
+		return
+	}
+	r.decoded++
+	for _, i := range res.failedFieldChecks {
+		r.fieldFailures[i]++
+	}
+}
+
+func (r *Report) printHeaderChecks() {
+	for i, c := range r.headerChecks {
+		var failed int
+		if r.checkFailures != nil {
+			failed = r.checkFailures[i]
+		}
+		r.HeaderChecks = append(r.HeaderChecks, CheckResult{
+			Check:  c.String(),
+			Passed: r.Responses() - failed,
+			Failed: failed,
+		})
+	}
+	for i, c := range r.fieldChecks {
+		var failed int
+		if r.fieldFailures != nil {
+			failed = r.fieldFailures[i]
+		}
+		r.FieldChecks = append(r.FieldChecks, CheckResult{
+			Check:  c.String(),
+			Passed: r.decoded - failed,
+			Failed: failed,
+		})
+	}
+	for i, c := range r.bodyChecks {
+		var failed int
+		if r.bodyFailures != nil {
+			failed = r.bodyFailures[i]
+		}
+		r.BodyChecks = append(r.BodyChecks, CheckResult{
+			Check:  "body " + c.String(),
+			Passed: r.bodyChecked - failed,
+			Failed: failed,
+		})
+	}
+}
+
+func (r *Report) addSchema(res *result) {
+	s, ok := r.schema[res.endpoint]
+	if !ok {
+		s = &SchemaStats{Endpoint: res.endpoint}
+		r.schema[res.endpoint] = s
+	}
+	s.Validated++
+	if res.schemaErr != nil {
+		if s.Failed == 0 {
+			s.FirstError = res.schemaErr.Error()
+		}
+		s.Failed++
+	}
+}
+
+func (r *Report) printSchema() {
+	for _, s := range r.schema {
+		r.Schema = append(r.Schema, *s)
+	}
+	sort.Slice(r.Schema, func(i, j int) bool {
+		return r.Schema[i].Endpoint < r.Schema[j].Endpoint
+	})
+}
+
+func (r *Report) printTimeouts() {
+	for k, num := range r.timeoutDist {
+		r.Timeouts = append(r.Timeouts, Timeout{
+			Phase: k.phase,
+			Count: num,
+			Limit: k.limit,
 		})
 	}
+	sort.Slice(r.Timeouts, func(i, j int) bool {
+		a, b := r.Timeouts[i], r.Timeouts[j]
+		return a.Phase < b.Phase || a.Phase == b.Phase && a.Limit < b.Limit
+	})
 }
 
 func (r *Report) printErrors() {
@@ -180,4 +879,7 @@ func (r *Report) printErrors() {
 			Count: num,
 		})
 	}
+	sort.Slice(r.Errors, func(i, j int) bool {
+		return r.Errors[i].Error < r.Errors[j].Error
+	})
 }
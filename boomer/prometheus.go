@@ -0,0 +1,164 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// prometheusBuckets are the upper bounds, in seconds, of the buckets
+// of the latency histogram exposed to Prometheus.
+var prometheusBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// WritePrometheus writes the metrics of the report to w in the
+// Prometheus text exposition format: the responses by status code, the
+// errors, timeouts and aborts, the request rate and a histogram of the
+// latency of successful requests. The histogram is only exposed if the
+// report has a sketch.
+func (r *Report) WritePrometheus(w io.Writer) error {
+	ew := &errWriter{w: w}
+	labels := ""
+	if r.Name != "" {
+		labels = fmt.Sprintf("name=%q", r.Name)
+	}
+	with := func(extra string) string {
+		switch {
+		case labels == "" && extra == "":
+			return ""
+		case labels == "":
+			return "{" + extra + "}"
+		case extra == "":
+			return "{" + labels + "}"
+		}
+		return "{" + labels + "," + extra + "}"
+	}
+
+	ew.printf("# HELP boom_responses_total Responses received, by status code.\n")
+	ew.printf("# TYPE boom_responses_total counter\n")
+	for _, s := range r.StatusCodes {
+		ew.printf("boom_responses_total%s %d\n", with(fmt.Sprintf("code=\"%d\"", s.Code)), s.Count)
+	}
+	ew.printf("# HELP boom_errors_total Requests failed without a response, timeouts excluded.\n")
+	ew.printf("# TYPE boom_errors_total counter\n")
+	ew.printf("boom_errors_total%s %d\n", with(""), r.ErrorCount()-r.TimeoutCount())
+	ew.printf("# HELP boom_timeouts_total Requests timed out, by phase and timeout.\n")
+	ew.printf("# TYPE boom_timeouts_total counter\n")
+	for _, t := range r.Timeouts {
+		ew.printf("boom_timeouts_total%s %d\n", with(fmt.Sprintf("phase=%q,limit=%q", t.Phase, t.Limit)), t.Count)
+	}
+	ew.printf("# HELP boom_aborts_total Requests aborted on purpose, by phase.\n")
+	ew.printf("# TYPE boom_aborts_total counter\n")
+	for _, a := range r.Aborts {
+		ew.printf("boom_aborts_total%s %d\n", with(fmt.Sprintf("phase=%q", a.Phase)), a.Count)
+	}
+	ew.printf("# HELP boom_requests_per_second Responses per second since the run started.\n")
+	ew.printf("# TYPE boom_requests_per_second gauge\n")
+	ew.printf("boom_requests_per_second%s %s\n", with(""), promFloat(r.RPS))
+
+	if r.Sketch != nil {
+		ew.printf("# HELP boom_request_duration_seconds Latency of successful requests.\n")
+		ew.printf("# TYPE boom_request_duration_seconds histogram\n")
+		for _, le := range prometheusBuckets {
+			ew.printf("boom_request_duration_seconds_bucket%s %d\n",
+				with(fmt.Sprintf("le=%q", promFloat(le))), r.Sketch.countUnder(le*1000))
+		}
+		n := r.Sketch.count()
+		ew.printf("boom_request_duration_seconds_bucket%s %d\n", with(`le="+Inf"`), n)
+		ew.printf("boom_request_duration_seconds_sum%s %s\n", with(""), promFloat(r.AvgTotal))
+		ew.printf("boom_request_duration_seconds_count%s %d\n", with(""), n)
+	}
+	return ew.err
+}
+
+func promFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// countUnder returns the number of latencies counted in the buckets
+// standing for at most lat ms.
+func (s *Sketch) countUnder(lat float64) int64 {
+	n := s.Zero
+	for i, c := range s.Counts {
+		if s.value(s.Offset+i) > lat {
+			break
+		}
+		n += c
+	}
+	return n
+}
+
+// writeMetrics writes the metrics of the run in progress to w, the
+// requests in flight along with those of the report so far.
+func (b *Boomer) writeMetrics(w io.Writer) error {
+	report, _, _ := b.snapshot()
+	fmt.Fprintf(w, "# HELP boom_in_flight_requests Requests sent and not done yet.\n")
+	fmt.Fprintf(w, "# TYPE boom_in_flight_requests gauge\n")
+	if _, err := fmt.Fprintf(w, "boom_in_flight_requests %d\n", atomic.LoadInt64(&b.inFlight)); err != nil {
+		return err
+	}
+	if report == nil {
+		return nil
+	}
+	return report.WritePrometheus(w)
+}
+
+// pushgatewaySink pushes the metrics of the report to a Prometheus
+// Pushgateway once the run is done.
+type pushgatewaySink struct {
+	url    string
+	client *http.Client
+}
+
+// NewPushgatewaySink returns a sink pushing the metrics of the report,
+// as written by WritePrometheus, to the Pushgateway at addr, e.g.
+// http://localhost:9091, under the given job once the run is done.
+// Pushing replaces the metrics previously pushed for the job.
+func NewPushgatewaySink(addr, job string) Sink {
+	return &pushgatewaySink{
+		url:    strings.TrimSuffix(addr, "/") + "/metrics/job/" + url.PathEscape(job),
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (s *pushgatewaySink) Record(Record) error { return nil }
+
+func (s *pushgatewaySink) Close(r *Report) error {
+	var buf bytes.Buffer
+	if err := r.WritePrometheus(&buf); err != nil {
+		return err
+	}
+	req, err := http.NewRequest("PUT", s.url, &buf)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("pushgateway: %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	var count int64
+	release := make(chan struct{})
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt64(&count, 1) > 5 {
+			<-release
+		}
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	boomer := &Boomer{Request: req, N: 10, C: 1}
+	h := boomer.StatusHandler()
+	done := make(chan *Report)
+	go func() { done <- runBoomer(t, boomer) }()
+	defer func() {
+		close(release)
+		<-done
+	}()
+
+	want := []string{
+		"boom_in_flight_requests 1\n",
+		`boom_responses_total{code="200"} 5` + "\n",
+		"boom_errors_total 0\n",
+		`boom_request_duration_seconds_bucket{le="+Inf"} 5` + "\n",
+		"boom_request_duration_seconds_count 5\n",
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+		missing := ""
+		for _, line := range want {
+			if !strings.Contains(w.Body.String(), line) {
+				missing = line
+			}
+		}
+		if missing == "" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected metrics to contain %q, found:\n%s", missing, w.Body.String())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestPushgatewaySink(t *testing.T) {
+	var method, path, body string
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		method, path, body = r.Method, r.URL.Path, string(data)
+	}))
+	defer gateway.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	r := runBoomer(t, &Boomer{
+		Name:    "soak",
+		Request: req,
+		N:       4,
+		C:       2,
+		Sinks:   []Sink{NewPushgatewaySink(gateway.URL+"/", "boom test")},
+	})
+	if len(r.SinkErrors) != 0 {
+		t.Fatal(r.SinkErrors)
+	}
+	if method != "PUT" || path != "/metrics/job/boom test" {
+		t.Errorf("expected metrics to be put to the job, found %v %v", method, path)
+	}
+	if !strings.Contains(body, `boom_responses_total{name="soak",code="200"} 4`) {
+		t.Errorf("unexpected metrics pushed:\n%s", body)
+	}
+}
@@ -0,0 +1,370 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Protocol buffer wire types.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// Field types and labels of google.protobuf.FieldDescriptorProto.
+const (
+	typeDouble   = 1
+	typeFloat    = 2
+	typeInt64    = 3
+	typeUint64   = 4
+	typeInt32    = 5
+	typeFixed64  = 6
+	typeFixed32  = 7
+	typeBool     = 8
+	typeString   = 9
+	typeMessage  = 11
+	typeBytes    = 12
+	typeUint32   = 13
+	typeEnum     = 14
+	typeSfixed32 = 15
+	typeSfixed64 = 16
+	typeSint32   = 17
+	typeSint64   = 18
+
+	labelRepeated = 3
+)
+
+var errTruncated = errors.New("proto: truncated message")
+
+// ProtoMessage decodes protocol buffer messages of a single type into
+// generic values so that FieldChecks can be evaluated against them.
+type ProtoMessage struct {
+	msg   *protoType
+	types map[string]*protoType
+	enums map[string]map[int64]string
+}
+
+type protoType struct {
+	name   string
+	fields map[uint64]*protoField
+}
+
+type protoField struct {
+	name     string
+	typ      uint64
+	repeated bool
+	typeName string
+}
+
+// ParseProtoMessage looks up the message called name (fully qualified,
+// e.g. "pkg.Response") in a serialized google.protobuf.FileDescriptorSet,
+// as produced by protoc --descriptor_set_out.
+func ParseProtoMessage(descriptorSet []byte, name string) (*ProtoMessage, error) {
+	p := &ProtoMessage{
+		types: make(map[string]*protoType),
+		enums: make(map[string]map[int64]string),
+	}
+	err := walkProto(descriptorSet, func(num uint64, file []byte) error {
+		if num != 1 {
+			return nil
+		}
+		var pkg string
+		walkProto(file, func(num uint64, v []byte) error {
+			if num == 2 {
+				pkg = string(v)
+			}
+			return nil
+		})
+		return walkProto(file, func(num uint64, v []byte) error {
+			switch num {
+			case 4:
+				return p.addType(pkg, v)
+			case 5:
+				return p.addEnum(pkg, v)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid descriptor set: %v", err)
+	}
+	p.msg = p.types[strings.TrimPrefix(name, ".")]
+	if p.msg == nil {
+		return nil, fmt.Errorf("message %q not found in descriptor set", name)
+	}
+	return p, nil
+}
+
+// addType registers a DescriptorProto and its nested types and enums.
+func (p *ProtoMessage) addType(scope string, data []byte) error {
+	t := &protoType{fields: make(map[uint64]*protoField)}
+	walkProto(data, func(num uint64, v []byte) error {
+		if num == 1 {
+			t.name = qualify(scope, string(v))
+		}
+		return nil
+	})
+	p.types[t.name] = t
+	return walkProto(data, func(num uint64, v []byte) error {
+		switch num {
+		case 2:
+			f := &protoField{}
+			var number uint64
+			err := walkProtoScalars(v, func(num, x uint64, b []byte) {
+				switch num {
+				case 1:
+					f.name = string(b)
+				case 3:
+					number = x
+				case 4:
+					f.repeated = x == labelRepeated
+				case 5:
+					f.typ = x
+				case 6:
+					f.typeName = strings.TrimPrefix(string(b), ".")
+				}
+			})
+			t.fields[number] = f
+			return err
+		case 3:
+			return p.addType(t.name, v)
+		case 4:
+			return p.addEnum(t.name, v)
+		}
+		return nil
+	})
+}
+
+// addEnum registers an EnumDescriptorProto.
+func (p *ProtoMessage) addEnum(scope string, data []byte) error {
+	var name string
+	values := make(map[int64]string)
+	err := walkProto(data, func(num uint64, v []byte) error {
+		switch num {
+		case 1:
+			name = string(v)
+		case 2:
+			var vname string
+			var number uint64
+			err := walkProtoScalars(v, func(num, x uint64, b []byte) {
+				switch num {
+				case 1:
+					vname = string(b)
+				case 2:
+					number = x
+				}
+			})
+			values[int64(int32(number))] = vname
+			return err
+		}
+		return nil
+	})
+	p.enums[qualify(scope, name)] = values
+	return err
+}
+
+func qualify(scope, name string) string {
+	if scope == "" {
+		return name
+	}
+	return scope + "." + name
+}
+
+// Decode decodes a serialized message into a map from field names to
+// values. Nested messages decode to maps, repeated fields to slices and
+// enums to the names of their values.
+func (p *ProtoMessage) Decode(data []byte) (map[string]interface{}, error) {
+	return p.decode(p.msg, data)
+}
+
+func (p *ProtoMessage) decode(t *protoType, data []byte) (map[string]interface{}, error) {
+	m := make(map[string]interface{})
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return nil, errTruncated
+		}
+		data = data[n:]
+		num, wire := key>>3, key&7
+		var x uint64
+		var b []byte
+		switch wire {
+		case wireVarint:
+			if x, n = binary.Uvarint(data); n <= 0 {
+				return nil, errTruncated
+			}
+		case wireFixed64:
+			if n = 8; len(data) < n {
+				return nil, errTruncated
+			}
+			x = binary.LittleEndian.Uint64(data)
+		case wireFixed32:
+			if n = 4; len(data) < n {
+				return nil, errTruncated
+			}
+			x = uint64(binary.LittleEndian.Uint32(data))
+		case wireBytes:
+			l, ln := binary.Uvarint(data)
+			if ln <= 0 || uint64(len(data)-ln) < l {
+				return nil, errTruncated
+			}
+			b, n = data[ln:ln+int(l)], ln+int(l)
+		default:
+			return nil, fmt.Errorf("proto: unsupported wire type %d", wire)
+		}
+		data = data[n:]
+
+		f, ok := t.fields[num]
+		if !ok {
+			continue
+		}
+		var vals []interface{}
+		switch {
+		case f.typ == typeMessage:
+			nested := p.types[f.typeName]
+			if nested == nil {
+				return nil, fmt.Errorf("proto: unknown message type %q", f.typeName)
+			}
+			v, err := p.decode(nested, b)
+			if err != nil {
+				return nil, err
+			}
+			vals = append(vals, v)
+		case f.typ == typeString || f.typ == typeBytes:
+			vals = append(vals, string(b))
+		case wire == wireBytes:
+			// Packed repeated scalars.
+			for len(b) > 0 {
+				var n int
+				switch f.typ {
+				case typeDouble, typeFixed64, typeSfixed64:
+					if n = 8; len(b) < n {
+						return nil, errTruncated
+					}
+					x = binary.LittleEndian.Uint64(b)
+				case typeFloat, typeFixed32, typeSfixed32:
+					if n = 4; len(b) < n {
+						return nil, errTruncated
+					}
+					x = uint64(binary.LittleEndian.Uint32(b))
+				default:
+					if x, n = binary.Uvarint(b); n <= 0 {
+						return nil, errTruncated
+					}
+				}
+				b = b[n:]
+				vals = append(vals, p.scalar(f, x))
+			}
+		default:
+			vals = append(vals, p.scalar(f, x))
+		}
+
+		if !f.repeated {
+			m[f.name] = vals[len(vals)-1]
+			continue
+		}
+		prev, _ := m[f.name].([]interface{})
+		m[f.name] = append(prev, vals...)
+	}
+	return m, nil
+}
+
+func (p *ProtoMessage) scalar(f *protoField, x uint64) interface{} {
+	switch f.typ {
+	case typeDouble:
+		return math.Float64frombits(x)
+	case typeFloat:
+		return float64(math.Float32frombits(uint32(x)))
+	case typeInt64, typeSfixed64:
+		return int64(x)
+	case typeInt32, typeSfixed32:
+		return int64(int32(x))
+	case typeSint32, typeSint64:
+		return int64(x>>1) ^ -int64(x&1)
+	case typeBool:
+		return x != 0
+	case typeEnum:
+		if name, ok := p.enums[f.typeName][int64(int32(x))]; ok {
+			return name
+		}
+		return int64(int32(x))
+	}
+	return x
+}
+
+// walkProto calls fn for every length-delimited field of a message,
+// skipping fields of other wire types.
+func walkProto(data []byte, fn func(num uint64, v []byte) error) error {
+	return walkProtoFields(data, func(num, wire, _ uint64, v []byte) error {
+		if wire != wireBytes {
+			return nil
+		}
+		return fn(num, v)
+	})
+}
+
+// walkProtoScalars calls fn for every varint and length-delimited field
+// of a message.
+func walkProtoScalars(data []byte, fn func(num, x uint64, v []byte)) error {
+	return walkProtoFields(data, func(num, _, x uint64, v []byte) error {
+		fn(num, x, v)
+		return nil
+	})
+}
+
+func walkProtoFields(data []byte, fn func(num, wire, x uint64, v []byte) error) error {
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errTruncated
+		}
+		data = data[n:]
+		var x uint64
+		var v []byte
+		switch key & 7 {
+		case wireVarint:
+			if x, n = binary.Uvarint(data); n <= 0 {
+				return errTruncated
+			}
+		case wireFixed64:
+			n = 8
+		case wireFixed32:
+			n = 4
+		case wireBytes:
+			l, ln := binary.Uvarint(data)
+			if ln <= 0 || uint64(len(data)-ln) < l {
+				return errTruncated
+			}
+			v, n = data[ln:ln+int(l)], ln+int(l)
+		default:
+			return fmt.Errorf("proto: unsupported wire type %d", key&7)
+		}
+		if len(data) < n {
+			return errTruncated
+		}
+		data = data[n:]
+		if err := fn(key>>3, key&7, x, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
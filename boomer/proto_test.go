@@ -0,0 +1,141 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func pbVarint(b []byte, num, x uint64) []byte {
+	b = binary.AppendUvarint(b, num<<3|wireVarint)
+	return binary.AppendUvarint(b, x)
+}
+
+func pbBytes(b []byte, num uint64, v []byte) []byte {
+	b = binary.AppendUvarint(b, num<<3|wireBytes)
+	b = binary.AppendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+func pbField(name string, number, label, typ uint64, typeName string) []byte {
+	f := pbBytes(nil, 1, []byte(name))
+	f = pbVarint(f, 3, number)
+	f = pbVarint(f, 4, label)
+	f = pbVarint(f, 5, typ)
+	if typeName != "" {
+		f = pbBytes(f, 6, []byte(typeName))
+	}
+	return f
+}
+
+// testDescriptorSet describes:
+//
+//	package pkg;
+//	enum Role { USER = 0; ADMIN = 1; }
+//	message User {
+//	  message Address { string city = 1; }
+//	  int64 id = 1;
+//	  string name = 2;
+//	  repeated Role roles = 3;
+//	  Address address = 4;
+//	}
+func testDescriptorSet() []byte {
+	address := pbBytes(nil, 1, []byte("Address"))
+	address = pbBytes(address, 2, pbField("city", 1, 1, typeString, ""))
+
+	user := pbBytes(nil, 1, []byte("User"))
+	user = pbBytes(user, 2, pbField("id", 1, 1, typeInt64, ""))
+	user = pbBytes(user, 2, pbField("name", 2, 1, typeString, ""))
+	user = pbBytes(user, 2, pbField("roles", 3, labelRepeated, typeEnum, ".pkg.Role"))
+	user = pbBytes(user, 2, pbField("address", 4, 1, typeMessage, ".pkg.User.Address"))
+	user = pbBytes(user, 3, address)
+
+	role := pbBytes(nil, 1, []byte("Role"))
+	role = pbBytes(role, 2, pbVarint(pbBytes(nil, 1, []byte("USER")), 2, 0))
+	role = pbBytes(role, 2, pbVarint(pbBytes(nil, 1, []byte("ADMIN")), 2, 1))
+
+	file := pbBytes(nil, 1, []byte("user.proto"))
+	file = pbBytes(file, 2, []byte("pkg"))
+	file = pbBytes(file, 4, user)
+	file = pbBytes(file, 5, role)
+	return pbBytes(nil, 1, file)
+}
+
+func testUser() []byte {
+	u := pbVarint(nil, 1, 42)
+	u = pbBytes(u, 2, []byte("jbd"))
+	u = pbBytes(u, 3, []byte{1, 0})
+	return pbBytes(u, 4, pbBytes(nil, 1, []byte("Istanbul")))
+}
+
+func TestProtoDecode(t *testing.T) {
+	msg, err := ParseProtoMessage(testDescriptorSet(), "pkg.User")
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := msg.Decode(testUser())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"id":      int64(42),
+		"name":    "jbd",
+		"roles":   []interface{}{"ADMIN", "USER"},
+		"address": map[string]interface{}{"city": "Istanbul"},
+	}
+	if !reflect.DeepEqual(doc, want) {
+		t.Errorf("Expected decoded message %v, found %v", want, doc)
+	}
+	if _, err := ParseProtoMessage(testDescriptorSet(), "pkg.Missing"); err == nil {
+		t.Errorf("Expected an unknown message name to fail")
+	}
+}
+
+func TestFieldChecks(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Write(testUser())
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	msg, _ := ParseProtoMessage(testDescriptorSet(), "pkg.User")
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	boomer := &Boomer{
+		Request: req,
+		N:       3,
+		C:       1,
+		Proto:   msg,
+		FieldChecks: []FieldCheck{
+			{Path: "id", Value: "42"},
+			{Path: "roles.0", Value: "ADMIN"},
+			{Path: "address.city", Value: "Ankara"},
+			{Path: "email"},
+		},
+	}
+	report := runBoomer(t, boomer)
+	want := []CheckResult{
+		{Check: "id=42", Passed: 3},
+		{Check: "roles.0=ADMIN", Passed: 3},
+		{Check: "address.city=Ankara", Failed: 3},
+		{Check: "email", Failed: 3},
+	}
+	if !reflect.DeepEqual(report.FieldChecks, want) {
+		t.Errorf("Expected field check results %v, found %v", want, report.FieldChecks)
+	}
+}
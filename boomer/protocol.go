@@ -0,0 +1,69 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"net/http"
+	"sort"
+)
+
+// ProtocolStats counts the responses served over a version of HTTP.
+type ProtocolStats struct {
+	// Proto is the version, e.g. "HTTP/1.1" or "HTTP/2.0".
+	Proto     string `json:"proto"`
+	Responses int    `json:"responses"`
+
+	// Conns is the number of connections dialed during the run that
+	// negotiated the version.
+	Conns int `json:"conns"`
+}
+
+// protocols returns the HTTP versions the transport may use: HTTP/2
+// only if it is forced or for gRPC, over TLS or in cleartext, or else
+// HTTP/1.1.
+func (b *Boomer) protocols() *http.Protocols {
+	p := new(http.Protocols)
+	if b.HTTP2 || b.GRPC {
+		p.SetHTTP2(true)
+		p.SetUnencryptedHTTP2(true)
+	} else {
+		p.SetHTTP1(true)
+	}
+	return p
+}
+
+func (r *Report) addProtocol(res *result) {
+	if r.protocols == nil {
+		r.protocols = make(map[string]*ProtocolStats)
+	}
+	p, ok := r.protocols[res.proto]
+	if !ok {
+		p = &ProtocolStats{Proto: res.proto}
+		r.protocols[res.proto] = p
+	}
+	p.Responses++
+	if res.newConn {
+		p.Conns++
+	}
+}
+
+func (r *Report) printProtocols() {
+	for _, p := range r.protocols {
+		r.Protocols = append(r.Protocols, *p)
+	}
+	sort.Slice(r.Protocols, func(i, j int) bool {
+		return r.Protocols[i].Proto < r.Protocols[j].Proto
+	})
+}
@@ -0,0 +1,142 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+// socks5Server serves SOCKS5 CONNECT requests authenticated with the
+// given username and password, counting the connections made.
+func socks5Server(t *testing.T, user, pass string, conns *int32) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				if target, ok := socks5Handshake(c, user, pass); ok {
+					atomic.AddInt32(conns, 1)
+					d, err := net.Dial("tcp", target)
+					if err != nil {
+						return
+					}
+					defer d.Close()
+					go io.Copy(d, c)
+					io.Copy(c, d)
+				}
+			}()
+		}
+	}()
+	return l
+}
+
+func socks5Handshake(c net.Conn, user, pass string) (string, bool) {
+	buf := make([]byte, 256)
+	// Version and methods, requiring username/password authentication.
+	if _, err := io.ReadFull(c, buf[:2]); err != nil {
+		return "", false
+	}
+	if _, err := io.ReadFull(c, buf[:buf[1]]); err != nil {
+		return "", false
+	}
+	c.Write([]byte{5, 2})
+	if _, err := io.ReadFull(c, buf[:2]); err != nil {
+		return "", false
+	}
+	u := make([]byte, buf[1])
+	io.ReadFull(c, u)
+	io.ReadFull(c, buf[:1])
+	p := make([]byte, buf[0])
+	io.ReadFull(c, p)
+	if string(u) != user || string(p) != pass {
+		c.Write([]byte{1, 1})
+		return "", false
+	}
+	c.Write([]byte{1, 0})
+	// CONNECT to an IPv4 address or a domain name.
+	if _, err := io.ReadFull(c, buf[:4]); err != nil {
+		return "", false
+	}
+	var host string
+	switch buf[3] {
+	case 1:
+		io.ReadFull(c, buf[:4])
+		host = net.IP(buf[:4]).String()
+	case 3:
+		io.ReadFull(c, buf[:1])
+		n := int(buf[0])
+		io.ReadFull(c, buf[:n])
+		host = string(buf[:n])
+	default:
+		return "", false
+	}
+	io.ReadFull(c, buf[:2])
+	port := binary.BigEndian.Uint16(buf[:2])
+	c.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+	return net.JoinHostPort(host, strconv.Itoa(int(port))), true
+}
+
+func TestProxy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	req, _ := http.NewRequest("GET", server.URL, nil)
+
+	var proxied int32
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := "Basic " + base64.StdEncoding.EncodeToString([]byte("user:secret"))
+		if !r.URL.IsAbs() || r.Header.Get("Proxy-Authorization") != auth {
+			w.WriteHeader(http.StatusProxyAuthRequired)
+			return
+		}
+		atomic.AddInt32(&proxied, 1)
+	}))
+	defer proxy.Close()
+	u, _ := url.Parse(proxy.URL)
+	u.User = url.UserPassword("user", "secret")
+	report := runBoomer(t, &Boomer{Request: req, N: 4, C: 2, ProxyAddr: u})
+	if report.StatusCount(200) != 4 || atomic.LoadInt32(&proxied) != 4 {
+		t.Errorf("expected 4 requests through the HTTP proxy, found %v and %+v", proxied, report.StatusCodes)
+	}
+
+	var conns int32
+	l := socks5Server(t, "user", "secret", &conns)
+	defer l.Close()
+	u = &url.URL{Scheme: "socks5", Host: l.Addr().String(), User: url.UserPassword("user", "secret")}
+	report = runBoomer(t, &Boomer{Request: req, N: 4, C: 1, ProxyAddr: u})
+	if report.StatusCount(200) != 4 || atomic.LoadInt32(&conns) != 1 {
+		t.Errorf("expected 4 requests over 1 SOCKS5 connection, found %v and %+v", conns, report.StatusCodes)
+	}
+	u.User = url.UserPassword("user", "wrong")
+	report = runBoomer(t, &Boomer{Request: req, N: 2, C: 1, ProxyAddr: u})
+	if report.ErrorCount() != 2 {
+		t.Errorf("expected the SOCKS5 proxy to reject the credentials, found %+v", report.Errors)
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"math"
+	"sort"
+)
+
+// Percentile returns the latency in ms below which p percent of the
+// successful requests completed, e.g. Percentile(99.9), estimated from
+// the sketch unless every latency was retained. It returns 0 if there
+// were no successful requests.
+func (r *Report) Percentile(p float64) float64 {
+	if !r.fromLats() {
+		if r.Sketch.count() == 0 {
+			return 0
+		}
+		return r.sketchPercentile(p)
+	}
+	if !sort.Float64sAreSorted(r.Lats) {
+		sort.Float64s(r.Lats)
+	}
+	return nearestRank(r.Lats, p)
+}
+
+// nearestRank returns the p percentile of the sorted, non-empty lats.
+func nearestRank(lats []float64, p float64) float64 {
+	// Tolerate rounding errors such as 99.9% of 1000 being computed as
+	// slightly more than 999.
+	i := int(math.Ceil(p/100*float64(len(lats))-1e-9)) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(lats) {
+		i = len(lats) - 1
+	}
+	return lats[i]
+}
+
+// Responses returns the number of requests that got a response.
+func (r *Report) Responses() int {
+	var n int
+	for _, s := range r.StatusCodes {
+		n += s.Count
+	}
+	return n
+}
+
+// ErrorCount returns the number of requests that failed without a
+// response, including those that timed out.
+func (r *Report) ErrorCount() int {
+	var n int
+	for _, e := range r.Errors {
+		n += e.Count
+	}
+	return n + r.TimeoutCount()
+}
+
+// TimeoutCount returns the number of requests that timed out.
+func (r *Report) TimeoutCount() int {
+	var n int
+	for _, t := range r.Timeouts {
+		n += t.Count
+	}
+	return n
+}
+
+// ErrorResponses returns the number of responses of error status
+// codes: 400 and above, or non-OK codes of gRPC calls.
+func (r *Report) ErrorResponses() int {
+	var n int
+	for _, s := range r.StatusCodes {
+		if errorStatus(s.Code, r.GRPC) {
+			n += s.Count
+		}
+	}
+	return n
+}
+
+// ErrorRate returns the fraction, between 0 and 1, of requests that
+// failed: error responses, requests that failed without a response and
+// those that timed out.
+func (r *Report) ErrorRate() float64 {
+	errs := r.ErrorCount()
+	if total := errs + r.Responses(); total > 0 {
+		return float64(errs+r.ErrorResponses()) / float64(total)
+	}
+	return 0
+}
+
+// HeaderFailureRate returns the fraction, between 0 and 1, of the header
+// checks of responses that failed.
+func (r *Report) HeaderFailureRate() float64 {
+	var failed, total int
+	for _, c := range r.HeaderChecks {
+		failed += c.Failed
+		total += c.Passed + c.Failed
+	}
+	if total > 0 {
+		return float64(failed) / float64(total)
+	}
+	return 0
+}
+
+// StatusCount returns the number of responses with the given status
+// code.
+func (r *Report) StatusCount(code int) int {
+	for _, s := range r.StatusCodes {
+		if s.Code == code {
+			return s.Count
+		}
+	}
+	return 0
+}
+
+// Regressed reports whether any metric regressed against the baseline
+// the run was compared to.
+func (r *Report) Regressed() bool {
+	for _, c := range r.Baseline {
+		if c.Regressed {
+			return true
+		}
+	}
+	return false
+}
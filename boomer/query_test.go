@@ -0,0 +1,52 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQuery(t *testing.T) {
+	r := newReport(0, nil, time.Second)
+	for i := 1000; i > 0; i-- {
+		r.Lats = append(r.Lats, float64(i))
+	}
+	r.statusCodeDist[200] = 990
+	r.statusCodeDist[500] = 10
+	r.errorDist["timeout"] = 250
+	r.printStatusCodes()
+	r.printErrors()
+
+	for _, tt := range []struct {
+		p, want float64
+	}{{50, 500}, {99, 990}, {99.9, 999}, {100, 1000}, {0, 1}} {
+		if got := r.Percentile(tt.p); got != tt.want {
+			t.Errorf("Percentile(%v) = %v, want %v", tt.p, got, tt.want)
+		}
+	}
+	if got := r.ErrorRate(); got != 0.208 {
+		t.Errorf("ErrorRate() = %v, want 0.208", got)
+	}
+	if got := r.ErrorResponses(); got != 10 {
+		t.Errorf("ErrorResponses() = %v, want 10", got)
+	}
+	if got := r.StatusCount(500); got != 10 {
+		t.Errorf("StatusCount(500) = %v, want 10", got)
+	}
+	if got := r.StatusCount(404); got != 0 {
+		t.Errorf("StatusCount(404) = %v, want 0", got)
+	}
+}
@@ -0,0 +1,301 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// rampTick is how often the workers of a ramp are adjusted.
+const rampTick = 50 * time.Millisecond
+
+// The phases of a ramped run.
+const (
+	rampUp = iota + 1
+	rampSteady
+	rampDown
+)
+
+var rampPhaseNames = [...]string{"", "ramp-up", "steady", "ramp-down"}
+
+// Ramp ramps the load of a run linearly from From up to its target,
+// the Qps rate if set or else C workers, over Up, and back down to
+// From over Down at its end, so that the steady state between can be
+// told apart. A run at a rate ramps down for its last requests; one of
+// workers starts to once the requests left would take Down at the rate
+// of the steady state.
+type Ramp struct {
+	From     int
+	Up, Down time.Duration
+}
+
+// RampPhase describes the requests started in a phase of a ramped run.
+type RampPhase struct {
+	Phase    string `json:"phase"`
+	Requests int    `json:"requests"`
+	Errors   int    `json:"errors"`
+
+	// Start and End are the times, in seconds since the run started,
+	// the first and last requests of the phase started at.
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	RPS   float64 `json:"rps"`
+
+	// Average, P50, P90 and P99 are latencies of the successful
+	// requests, in ms.
+	Average float64 `json:"average"`
+	P50     float64 `json:"p50"`
+	P90     float64 `json:"p90"`
+	P99     float64 `json:"p99"`
+}
+
+// rampSamples holds the latencies of the successful requests of a
+// phase, the number of failed ones and when the first and last
+// started.
+type rampSamples struct {
+	sketch      Sketch
+	sum         float64
+	errors      int
+	first, last time.Time
+}
+
+// rampsRate reports whether the run ramps its rate rather than its
+// workers.
+func (b *Boomer) rampsRate() bool {
+	return b.Ramp != nil && b.Qps > 0
+}
+
+// rampBounds returns the number of requests a run ramping its rate
+// makes ramping up, and the index of the first one ramping down.
+func (b *Boomer) rampBounds() (up, down float64) {
+	avg := float64(b.Ramp.From+b.Qps) / 2
+	up = avg * b.Ramp.Up.Seconds()
+	if b.N == 0 {
+		// A run for a Duration ramps down for its last Down.
+		steady := b.Duration - b.Ramp.Up - b.Ramp.Down
+		return up, math.Max(up, up+float64(b.Qps)*steady.Seconds())
+	}
+	down = math.Max(up, float64(b.N)-avg*b.Ramp.Down.Seconds())
+	return up, down
+}
+
+// rampTime returns the time, in seconds, n requests take while the rate
+// changes linearly from r0 to r1 over d seconds.
+func rampTime(n, r0, r1, d float64) float64 {
+	a := (r1 - r0) / (2 * d)
+	if a == 0 {
+		return n / r0
+	}
+	disc := r0*r0 + 4*a*n
+	if disc < 0 {
+		return d
+	}
+	return math.Min((-r0+math.Sqrt(disc))/(2*a), d)
+}
+
+// arrival returns the time since the start of the run the i-th request
+// is due at, for runs with a Qps rate.
+func (b *Boomer) arrival(i int) time.Duration {
+	if len(b.Stages) > 0 {
+		return b.stageArrival(i)
+	}
+	q, n := float64(b.Qps), float64(i)
+	if !b.rampsRate() {
+		// Each request is due at the end of its interval.
+		return time.Duration((n + 1) / q * float64(time.Second))
+	}
+	from, upTime := float64(b.Ramp.From), b.Ramp.Up.Seconds()
+	up, down := b.rampBounds()
+	var t float64
+	switch {
+	case n < up:
+		t = rampTime(n, from, q, upTime)
+	case n < down:
+		t = upTime + (n-up)/q
+	default:
+		t = upTime + (down-up)/q + rampTime(n-down, q, from, b.Ramp.Down.Seconds())
+	}
+	return time.Duration(t * float64(time.Second))
+}
+
+// setRampPhase marks the phase the i-th request of a run ramping its
+// rate is in.
+func (b *Boomer) setRampPhase(i int) {
+	if !b.rampsRate() {
+		return
+	}
+	up, down := b.rampBounds()
+	phase := int32(rampSteady)
+	switch n := float64(i); {
+	case n < up:
+		phase = rampUp
+	case n >= down:
+		phase = rampDown
+	}
+	atomic.StoreInt32(&b.rampPhase, phase)
+}
+
+// ramp starts and retires workers every rampTick, following the Ramp of
+// the run, until stop is closed.
+func (s *scaler) ramp(stop chan struct{}) {
+	b, r := s.b, s.b.Ramp
+	from := r.From
+	if from < 1 {
+		from = 1
+	}
+	phase := int32(rampSteady)
+	if r.Up > 0 {
+		phase = rampUp
+		s.start(from)
+	} else {
+		s.start(b.C)
+	}
+	atomic.StoreInt32(&b.rampPhase, phase)
+	t := time.NewTicker(rampTick)
+	defer t.Stop()
+
+	start := time.Now()
+	steadyAt, steadyDone := start, atomic.LoadInt64(&b.completed)
+	var downAt time.Time
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+		}
+		now := time.Now()
+		switch phase {
+		case rampUp:
+			elapsed := now.Sub(start)
+			if elapsed < r.Up {
+				s.start(from + int(float64(b.C-from)*elapsed.Seconds()/r.Up.Seconds()) - s.active)
+				continue
+			}
+			s.start(b.C - s.active)
+			phase, steadyAt, steadyDone = rampSteady, now, atomic.LoadInt64(&b.completed)
+		case rampSteady:
+			if r.Down <= 0 || !s.rampingDown(now.Sub(start), now.Sub(steadyAt), steadyDone) {
+				continue
+			}
+			phase, downAt = rampDown, now
+		case rampDown:
+			target := b.C - int(float64(b.C-from)*now.Sub(downAt).Seconds()/r.Down.Seconds())
+			if target < from {
+				target = from
+			}
+			s.retire(s.active - target)
+			continue
+		}
+		atomic.StoreInt32(&b.rampPhase, phase)
+	}
+}
+
+// rampingDown reports whether a ramp of workers, elapsed into the run
+// and steady into its steady state having completed steadyDone
+// requests before, is to ramp down: in time for the end of a run for a
+// Duration, or once the requests left would take Down at the rate of
+// the steady state.
+func (s *scaler) rampingDown(elapsed, steady time.Duration, steadyDone int64) bool {
+	b := s.b
+	if b.N == 0 {
+		return elapsed >= b.Duration-b.Ramp.Down
+	}
+	done := atomic.LoadInt64(&b.completed)
+	if done == steadyDone {
+		return false
+	}
+	rate := float64(done-steadyDone) / steady.Seconds()
+	return float64(int64(b.N)-done) <= rate*b.Ramp.Down.Seconds()
+}
+
+func (r *Report) addRamp(res *result) {
+	s := r.ramp[res.rampPhase]
+	if s == nil {
+		s = &rampSamples{first: res.start}
+		r.ramp[res.rampPhase] = s
+	}
+	if res.start.Before(s.first) {
+		s.first = res.start
+	}
+	if res.start.After(s.last) {
+		s.last = res.start
+	}
+	if res.err != nil || res.timeout != "" {
+		s.errors++
+		return
+	}
+	lat := res.duration.Seconds() * 1000
+	s.sketch.add(lat)
+	s.sum += lat
+}
+
+// mergeRamp adds the phases of o, the report of a run made at the same
+// time, to r.
+func (r *Report) mergeRamp(o *Report) {
+	if r.ramp == nil {
+		r.ramp = make([]*rampSamples, len(rampPhaseNames))
+	}
+	if r.start.IsZero() || o.start.Before(r.start) {
+		r.start = o.start
+	}
+	for phase, s := range o.ramp {
+		if s == nil {
+			continue
+		}
+		m := r.ramp[phase]
+		if m == nil {
+			m = &rampSamples{first: s.first, last: s.last}
+			r.ramp[phase] = m
+		}
+		m.sketch.merge(&s.sketch)
+		m.sum += s.sum
+		m.errors += s.errors
+		if s.first.Before(m.first) {
+			m.first = s.first
+		}
+		if s.last.After(m.last) {
+			m.last = s.last
+		}
+	}
+}
+
+func (r *Report) printRamp() {
+	r.Ramp = nil
+	for phase, s := range r.ramp {
+		if s == nil {
+			continue
+		}
+		n := s.sketch.count()
+		p := RampPhase{
+			Phase:    rampPhaseNames[phase],
+			Requests: int(n) + s.errors,
+			Errors:   s.errors,
+			Start:    s.first.Sub(r.start).Seconds(),
+			End:      s.last.Sub(r.start).Seconds(),
+		}
+		if p.End > p.Start {
+			p.RPS = float64(p.Requests) / (p.End - p.Start)
+		}
+		if n > 0 {
+			p.Average = s.sum / float64(n)
+			p.P50 = s.sketch.quantile(50)
+			p.P90 = s.sketch.quantile(90)
+			p.P99 = s.sketch.quantile(99)
+		}
+		r.Ramp = append(r.Ramp, p)
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestArrival(t *testing.T) {
+	b := &Boomer{N: 300, Qps: 100, Ramp: &Ramp{Up: time.Second, Down: time.Second}}
+	// 50 requests ramping up and down each, over a second, and 200 at
+	// 100 req/s between.
+	up, down := b.rampBounds()
+	if up != 50 || down != 250 {
+		t.Fatalf("expected the ramps to end at 50 and start at 250, found %v and %v", up, down)
+	}
+	for i, want := range map[int]float64{0: 0, 50: 1, 150: 2, 250: 3, 299: 3.86} {
+		if got := b.arrival(i).Seconds(); math.Abs(got-want) > 0.01 {
+			t.Errorf("expected request %d at %vs, found %vs", i, want, got)
+		}
+	}
+	for i := 1; i < b.N; i++ {
+		if b.arrival(i) < b.arrival(i-1) {
+			t.Fatalf("request %d arrives before request %d", i, i-1)
+		}
+	}
+	b.setRampPhase(10)
+	if b.rampPhase != rampUp {
+		t.Errorf("expected request 10 to ramp up, found phase %d", b.rampPhase)
+	}
+	b.setRampPhase(260)
+	if b.rampPhase != rampDown {
+		t.Errorf("expected request 260 to ramp down, found phase %d", b.rampPhase)
+	}
+}
+
+func TestRamp(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	report := runBoomer(t, &Boomer{Request: req, N: 150, C: 10, Qps: 200, Ramp: &Ramp{Up: 250 * time.Millisecond, Down: 250 * time.Millisecond}})
+	var phases []string
+	total := 0
+	for _, p := range report.Ramp {
+		phases = append(phases, p.Phase)
+		total += p.Requests
+	}
+	if len(phases) != 3 || phases[0] != "ramp-up" || phases[2] != "ramp-down" || total != 150 {
+		t.Fatalf("expected 150 requests over 3 phases, found %+v", report.Ramp)
+	}
+	if steady := report.Ramp[1]; steady.Requests != 100 || steady.RPS < 150 {
+		t.Errorf("expected 100 requests at 200 req/s in the steady state, found %+v", steady)
+	}
+
+	report = runBoomer(t, &Boomer{Request: req, N: 400, C: 8, Ramp: &Ramp{Up: 200 * time.Millisecond, Down: 100 * time.Millisecond}})
+	if len(report.Ramp) != 3 || report.Ramp[0].Phase != "ramp-up" {
+		t.Errorf("expected the workers to ramp up and down, found %+v", report.Ramp)
+	}
+	if report.StatusCount(200) != 400 {
+		t.Errorf("expected 400 responses, found %+v", report.StatusCodes)
+	}
+}
+
+func TestStages(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	b := &Boomer{Request: req, N: 1, C: 5, Qps: 10, Stages: []Stage{
+		{Rate: 100, Duration: 200 * time.Millisecond},
+		{Rate: 0, Duration: 100 * time.Millisecond},
+		{Rate: 400, Duration: 100 * time.Millisecond},
+	}}
+	if d := b.stageArrival(20); d != 300*time.Millisecond {
+		t.Errorf("expected the third stage to start at 300ms, found %v", d)
+	}
+	report := runBoomer(t, b)
+	if b.N != 1 || b.Qps != 10 {
+		t.Errorf("expected N and Qps to be restored, found %v and %v", b.N, b.Qps)
+	}
+	if report.StatusCount(200) != 60 || len(report.Stages) != 2 {
+		t.Fatalf("expected 60 responses over 2 stages, found %+v and %d stages", report.StatusCodes, len(report.Stages))
+	}
+	first, last := report.Stages[0], report.Stages[1]
+	if first.Name != "stage 1: 100 req/s for 200ms" || first.StatusCount(200) != 20 || first.RPS < 70 || first.RPS > 110 {
+		t.Errorf("unexpected first stage %v with %+v at %v req/s", first.Name, first.StatusCodes, first.RPS)
+	}
+	if last.Name != "stage 3: 400 req/s for 100ms" || last.StatusCount(200) != 40 || last.RPS < 250 {
+		t.Errorf("unexpected last stage %v with %+v at %v req/s", last.Name, last.StatusCodes, last.RPS)
+	}
+}
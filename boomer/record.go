@@ -0,0 +1,102 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"encoding/hex"
+	"sort"
+	"time"
+)
+
+// Record describes a single request made during a run.
+type Record struct {
+	Start    time.Time
+	Duration time.Duration
+
+	// Endpoint is the URL the request was sent to, with Method.
+	Endpoint string
+	Method   string
+
+	// StatusCode is the status of the response, or zero if the request
+	// failed without one, in which case Err is set.
+	StatusCode int
+	Err        error
+
+	// Size is the Content-Length of the response, -1 if unknown.
+	Size int64
+
+	// Aborted is the phase at which the request was deliberately
+	// cancelled, if it was.
+	Aborted string
+
+	// Reused is set if the request was sent over a kept-alive
+	// connection rather than one dialed for it.
+	Reused bool
+
+	// Family is the address family of the connection the request was
+	// sent over, "IPv4" or "IPv6", if it got one.
+	Family string
+
+	// TraceID and SpanID identify the trace context the request was sent
+	// with, in hex, if TraceContext was set.
+	TraceID, SpanID string
+
+	// Tags are the tags of the run, shared by all its records.
+	Tags map[string]string
+}
+
+func (r *Report) record(res *result) Record {
+	rec := Record{
+		Start:      res.start,
+		Duration:   res.duration,
+		Endpoint:   res.endpoint,
+		Method:     res.method,
+		StatusCode: res.statusCode,
+		Err:        res.err,
+		Size:       res.contentLength,
+		Aborted:    res.aborted,
+		Reused:     res.gotConn() && !res.newConn,
+		Family:     res.family,
+		Tags:       r.tags,
+	}
+	if res.trace != nil {
+		rec.TraceID = hex.EncodeToString(res.trace.traceID[:])
+		rec.SpanID = hex.EncodeToString(res.trace.spanID[:])
+	}
+	return rec
+}
+
+// Records calls yield with the Record of every request in the order
+// they were started, until yield returns false. Records are only
+// retained if the run had KeepRecords set. Records has the signature of
+// an iterator, so it can be ranged over:
+//
+//	for rec := range report.Records {
+//		...
+//	}
+func (r *Report) Records(yield func(Record) bool) {
+	if !sort.SliceIsSorted(r.records, r.recordBefore) {
+		sort.SliceStable(r.records, r.recordBefore)
+	}
+	for _, rec := range r.records {
+		if !yield(rec) {
+			return
+		}
+	}
+}
+
+func (r *Report) recordBefore(i, j int) bool {
+	return r.records[i].Start.Before(r.records[j].Start)
+}
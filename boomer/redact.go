@@ -0,0 +1,152 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Redacted replaces the values masked by a Redactor.
+const Redacted = "REDACTED"
+
+// DefaultRedacted are the names of the headers, query parameters and
+// fields always masked by a Redactor.
+var DefaultRedacted = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"Set-Cookie",
+	"X-Amz-Security-Token",
+	"X-Vault-Token",
+	"X-Api-Key",
+	"api_key",
+	"password",
+}
+
+var urlRegexp = regexp.MustCompile(`[a-zA-Z][a-zA-Z0-9+.-]*://[^\s"'<>]+`)
+
+// Redactor masks secrets in the endpoints and errors of a report, so
+// that it can be shared safely. It masks the passwords of URLs, and the
+// values of the query parameters named in DefaultRedacted and Names and
+// of such headers and fields quoted in errors, e.g. the messages of
+// GraphQL errors taken from response bodies.
+type Redactor struct {
+	// Names are the names, matched case-insensitively, of the headers,
+	// query parameters and fields to mask, e.g. "session".
+	Names []string
+
+	once  sync.Once
+	pairs *regexp.Regexp
+}
+
+func (r *Redactor) masks(name string) bool {
+	for _, n := range DefaultRedacted {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	for _, n := range r.Names {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// URL returns u with its password and masked query parameters
+// replaced. Strings that do not parse as URLs are returned unchanged.
+func (r *Redactor) URL(u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return u
+	}
+	changed := false
+	if _, ok := parsed.User.Password(); ok {
+		parsed.User = url.UserPassword(parsed.User.Username(), Redacted)
+		changed = true
+	}
+	if parsed.RawQuery != "" {
+		params := strings.Split(parsed.RawQuery, "&")
+		for i, p := range params {
+			kv := strings.SplitN(p, "=", 2)
+			name, err := url.QueryUnescape(kv[0])
+			if err == nil && len(kv) == 2 && r.masks(name) {
+				params[i] = kv[0] + "=" + Redacted
+				changed = true
+			}
+		}
+		parsed.RawQuery = strings.Join(params, "&")
+	}
+	if !changed {
+		return u
+	}
+	return parsed.String()
+}
+
+// String masks the URLs found in s, such as those of error messages,
+// and the values of masked names it quotes, as in "password=s",
+// "Authorization: Bearer t" or `"api_key": "k"`.
+func (r *Redactor) String(s string) string {
+	s = urlRegexp.ReplaceAllStringFunc(s, r.URL)
+	re := r.pairRegexp()
+	return re.ReplaceAllStringFunc(s, func(pair string) string {
+		m := re.FindStringSubmatch(pair)
+		v := Redacted
+		if q := m[3][0]; q == '"' || q == '\'' {
+			v = string(q) + Redacted + string(q)
+		}
+		return m[1] + m[2] + v
+	})
+}
+
+// pairRegexp returns the regexp matching a masked name, the separator
+// after it and its value.
+func (r *Redactor) pairRegexp() *regexp.Regexp {
+	r.once.Do(func() {
+		var names []string
+		for _, ns := range [][]string{DefaultRedacted, r.Names} {
+			for _, n := range ns {
+				names = append(names, regexp.QuoteMeta(n))
+			}
+		}
+		r.pairs = regexp.MustCompile(`(?i)\b(` + strings.Join(names, "|") + `)("?\s*[:=]\s*)((?:(?:Bearer|Basic|Token)\s+)?(?:"[^"]*"|'[^']*'|[^\s"',;&]+))`)
+	})
+	return r.pairs
+}
+
+// redactedError is an error whose message was redacted.
+type redactedError struct {
+	err error
+	msg string
+}
+
+func (e *redactedError) Error() string { return e.msg }
+func (e *redactedError) Unwrap() error { return e.err }
+
+// Error returns err with the URLs of its message masked. It returns
+// err itself if there is nothing to mask.
+func (r *Redactor) Error(err error) error {
+	if err == nil {
+		return nil
+	}
+	msg := err.Error()
+	if redacted := r.String(msg); redacted != msg {
+		return &redactedError{err: err, msg: redacted}
+	}
+	return err
+}
@@ -0,0 +1,94 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// DefaultMaxRedirects is the number of redirects followed if
+// MaxRedirects is zero, as by net/http.
+const DefaultMaxRedirects = 10
+
+// RedirectCount counts the responses got by following a number of
+// redirects.
+type RedirectCount struct {
+	Hops  int `json:"hops"`
+	Count int `json:"count"`
+}
+
+// resultKey is the context key of the result of a request.
+type resultKey struct{}
+
+// withResult returns req with res in its context, for the redirects
+// followed to be recorded in res.
+func withResult(req *http.Request, res *result) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), resultKey{}, res))
+}
+
+// checkRedirect is the redirect policy of the client: the redirect
+// response is returned if NoRedirects is set, the request fails once
+// more than MaxRedirects were followed, and the redirects followed are
+// recorded in the result of the request.
+func (b *Boomer) checkRedirect(req *http.Request, via []*http.Request) error {
+	if b.NoRedirects {
+		return http.ErrUseLastResponse
+	}
+	max := b.MaxRedirects
+	if max == 0 {
+		max = DefaultMaxRedirects
+	}
+	if len(via) > max {
+		return fmt.Errorf("stopped after %d redirects", max)
+	}
+	if res, ok := req.Context().Value(resultKey{}).(*result); ok {
+		res.redirectCodes = append(res.redirectCodes, req.Response.StatusCode)
+		res.lastHop = time.Now()
+	}
+	return nil
+}
+
+func (r *Report) addRedirects(res *result) {
+	if r.redirectDist == nil {
+		r.redirectDist = make(map[int]int)
+		r.redirectCodeDist = make(map[int]int)
+	}
+	r.redirectDist[len(res.redirectCodes)]++
+	for _, code := range res.redirectCodes {
+		r.redirectCodeDist[code]++
+	}
+}
+
+func (r *Report) printRedirects() {
+	if len(r.redirectCodeDist) == 0 {
+		return
+	}
+	for hops, num := range r.redirectDist {
+		r.Redirects = append(r.Redirects, RedirectCount{Hops: hops, Count: num})
+	}
+	sort.Slice(r.Redirects, func(i, j int) bool {
+		return r.Redirects[i].Hops < r.Redirects[j].Hops
+	})
+	for code, num := range r.redirectCodeDist {
+		r.RedirectCodes = append(r.RedirectCodes, StatusCode{Code: code, Count: num})
+	}
+	sort.Slice(r.RedirectCodes, func(i, j int) bool {
+		return r.RedirectCodes[i].Code < r.RedirectCodes[j].Code
+	})
+}
@@ -0,0 +1,184 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	mrand "math/rand"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// DefaultMaxBackoff is the longest wait between retries if
+// Retry.MaxBackoff is zero.
+const DefaultMaxBackoff = 10 * time.Second
+
+// Retry is a policy for retrying failed requests.
+type Retry struct {
+	// Max is the most times a request is retried.
+	Max int
+
+	// StatusCodes are the status codes of the responses retried, e.g.
+	// 502, 503 and 504. Requests failing without a response are always
+	// retried.
+	StatusCodes []int
+
+	// Backoff is the wait before the first retry, doubled for every
+	// following one up to MaxBackoff. Waits are jittered, drawn
+	// between half and all of it.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// retries reports whether a request that got resp and err is to be
+// retried.
+func (p *Retry) retries(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	for _, code := range p.StatusCodes {
+		if resp.StatusCode == code {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff returns the wait before the retry after n retries.
+func (p *Retry) backoff(n int) time.Duration {
+	max := p.MaxBackoff
+	if max <= 0 {
+		max = DefaultMaxBackoff
+	}
+	d := p.Backoff
+	for i := 0; i < n && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	if d <= 1 {
+		return d
+	}
+	return d/2 + time.Duration(mrand.Int63n(int64(d/2)))
+}
+
+// RetryCount describes the requests retried a number of times.
+type RetryCount struct {
+	Retries   int `json:"retries"`
+	Requests  int `json:"requests"`
+	Succeeded int `json:"succeeded"`
+
+	// Average is the latency of the requests, in ms, from their first
+	// attempt to the end of their last.
+	Average float64 `json:"average"`
+}
+
+// bufferBody reads the body of req in memory for it to be sent again
+// on retries.
+func bufferBody(req *http.Request) error {
+	if req.Body == nil || req.GetBody != nil {
+		return nil
+	}
+	data, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return err
+	}
+	req.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(data)), nil
+	}
+	req.Body, _ = req.GetBody()
+	return nil
+}
+
+// send sends req with the client of the worker, retrying it as the
+// Retry policy of the run allows, and returns the outcome of the last
+// attempt. No more retries are made once the run is stopped.
+func (w *worker) send(b *Boomer, req *http.Request, res *result) (*http.Response, error) {
+	for {
+		resp, err := w.do(b, req)
+		p := b.Retry
+		if p == nil || !p.retries(resp, err) {
+			return resp, err
+		}
+		if res.retries >= p.Max || b.stopped() || req.GetBody == nil {
+			res.retryFailed = res.retries > 0
+			return resp, err
+		}
+		if resp != nil {
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		select {
+		case <-time.After(p.backoff(res.retries)):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+		res.retries++
+		res.redirectCodes, res.lastHop = nil, time.Time{}
+		if req.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+		if b.SlowRate > 0 {
+			req.Body = slowReadCloser{newSlowReader(req.Body, b.SlowRate), req.Body}
+		}
+	}
+}
+
+// retrySamples accumulates the requests retried a number of times.
+type retrySamples struct {
+	requests, succeeded int
+	total               float64
+}
+
+func (r *Report) addRetries(res *result) {
+	if r.retryDist == nil {
+		r.retryDist = make(map[int]*retrySamples)
+	}
+	s, ok := r.retryDist[res.retries]
+	if !ok {
+		s = &retrySamples{}
+		r.retryDist[res.retries] = s
+	}
+	s.requests++
+	if !res.retryFailed && res.err == nil {
+		s.succeeded++
+	}
+	s.total += res.duration.Seconds() * 1000
+	if res.retries > 0 {
+		r.retried = true
+	}
+}
+
+func (r *Report) printRetries() {
+	if !r.retried {
+		return
+	}
+	for n, s := range r.retryDist {
+		r.Retries = append(r.Retries, RetryCount{
+			Retries:   n,
+			Requests:  s.requests,
+			Succeeded: s.succeeded,
+			Average:   s.total / float64(s.requests),
+		})
+	}
+	sort.Slice(r.Retries, func(i, j int) bool {
+		return r.Retries[i].Retries < r.Retries[j].Retries
+	})
+}
@@ -16,15 +16,38 @@ package boomer
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
 	"io/ioutil"
+	"math"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 )
 
+// runBoomer runs b, noting a failure if it is not a valid run.
+func runBoomer(t *testing.T, b *Boomer) *Report {
+	t.Helper()
+	r, err := b.Run(context.Background())
+	if err != nil {
+		t.Errorf("Run: %v", err)
+	}
+	return r
+}
+
 func TestN(t *testing.T) {
 	var count int64
 	handler := func(w http.ResponseWriter, r *http.Request) {
@@ -39,12 +62,33 @@ func TestN(t *testing.T) {
 		N:       20,
 		C:       2,
 	}
-	boomer.Run()
+	runBoomer(t, boomer)
 	if count != 20 {
 		t.Errorf("Expected to boom 20 times, found %v", count)
 	}
 }
 
+func TestAllFailed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	r := runBoomer(t, &Boomer{Request: req, N: 4, C: 2})
+	if r.ErrorCount() != 4 || r.Responses() != 0 {
+		t.Fatalf("%d errors and %d responses; want 4 and 0", r.ErrorCount(), r.Responses())
+	}
+	if r.Average != 0 {
+		t.Errorf("Average = %v; want 0", r.Average)
+	}
+	var out bytes.Buffer
+	if err := r.WriteJSON(&out); err != nil {
+		t.Errorf("WriteJSON: %v", err)
+	}
+	if err := r.WriteXML(&out); err != nil {
+		t.Errorf("WriteXML: %v", err)
+	}
+}
+
 func TestQps(t *testing.T) {
 	var wg sync.WaitGroup
 	var count int64
@@ -68,15 +112,14 @@ func TestQps(t *testing.T) {
 		}
 		wg.Done()
 	})
-	go boomer.Run()
+	go runBoomer(t, boomer)
 	wg.Wait()
 }
 
 func TestRequest(t *testing.T) {
-	var uri, contentType, some, method, auth string
+	var uri, contentType, some, auth string
 	handler := func(w http.ResponseWriter, r *http.Request) {
 		uri = r.RequestURI
-		method = r.Method
 		contentType = r.Header.Get("Content-type")
 		some = r.Header.Get("X-some")
 		auth = r.Header.Get("Authorization")
@@ -95,7 +138,7 @@ func TestRequest(t *testing.T) {
 		N:       1,
 		C:       1,
 	}
-	boomer.Run()
+	runBoomer(t, boomer)
 	if uri != "/" {
 		t.Errorf("Uri is expected to be /, %v is found", uri)
 	}
@@ -128,8 +171,1283 @@ func TestBody(t *testing.T) {
 		N:           10,
 		C:           1,
 	}
-	boomer.Run()
+	runBoomer(t, boomer)
 	if count != 10 {
 		t.Errorf("Expected to boom 10 times, found %v", count)
 	}
 }
+
+func TestCheckValidators(t *testing.T) {
+	var count int64
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt64(&count, 1)
+		if r.URL.Path == "/weak" {
+			// Weak validators allow bodies that differ.
+			w.Header().Set("ETag", `W/"v1"`)
+			w.Header().Set("Last-Modified", "Wed, 21 Oct 2015 07:28:00 GMT")
+		} else {
+			w.Header().Set("ETag", `"v1"`)
+		}
+		if n%2 == 0 {
+			w.Write([]byte("stale"))
+			return
+		}
+		w.Write([]byte("fresh"))
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	boomer := &Boomer{
+		Request:         req,
+		N:               10,
+		C:               1,
+		CheckValidators: true,
+	}
+	report := runBoomer(t, boomer)
+	if report.ValidatorMismatches != 5 {
+		t.Errorf("Expected 5 validator mismatches, found %v", report.ValidatorMismatches)
+	}
+
+	boomer.Request, _ = http.NewRequest("GET", server.URL+"/weak", nil)
+	if report := runBoomer(t, boomer); report.ValidatorMismatches != 0 {
+		t.Errorf("Expected no mismatches for weak validators, found %v", report.ValidatorMismatches)
+	}
+}
+
+func TestMeasureCompression(t *testing.T) {
+	body := strings.Repeat("boom", 1024)
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/plain" {
+			w.Write([]byte(body))
+			return
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		zw.Write([]byte(body))
+		zw.Close()
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	for _, path := range []string{"/gzip", "/plain"} {
+		req, _ := http.NewRequest("GET", server.URL+path, nil)
+		boomer := &Boomer{
+			Request:            req,
+			N:                  4,
+			C:                  2,
+			MeasureCompression: true,
+		}
+		report := runBoomer(t, boomer)
+		if len(report.Compression) != 1 {
+			t.Fatalf("Expected compression stats for 1 endpoint, found %v", len(report.Compression))
+		}
+		stats := report.Compression[0]
+		if stats.BodyBytes != int64(4*len(body)) {
+			t.Errorf("%v: expected %v body bytes, found %v", path, 4*len(body), stats.BodyBytes)
+		}
+		switch path {
+		case "/gzip":
+			if stats.Uncompressed != 0 || report.CompressionRatio <= 1 {
+				t.Errorf("%v: expected compressed responses, found %+v", path, stats)
+			}
+		case "/plain":
+			if stats.Uncompressed != 4 || report.CompressionRatio != 1 {
+				t.Errorf("%v: expected uncompressed responses, found %+v", path, stats)
+			}
+		}
+	}
+}
+
+func TestAcceptEncoding(t *testing.T) {
+	body := strings.Repeat("boom", 1024)
+	var encodings []string
+	var mu sync.Mutex
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		encodings = append(encodings, r.Header.Get("Accept-Encoding"))
+		mu.Unlock()
+		if r.Header.Get("Accept-Encoding") != "gzip" {
+			w.Write([]byte(body))
+			return
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		zw.Write([]byte(body))
+		zw.Close()
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	// Compressed responses are counted both as transferred and decoded,
+	// and checked decoded.
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	checks := []BodyCheck{{Pattern: regexp.MustCompile("^boomboom")}}
+	report := runBoomer(t, &Boomer{Request: req, N: 2, C: 1, BodyChecks: checks})
+	if report.BodyBytes != int64(2*len(body)) || report.WireBytes <= 0 || report.WireBytes >= report.BodyBytes || report.BodyChecks[0].Passed != 2 {
+		t.Errorf("expected compressed responses decoded, found %v wire bytes, %v body bytes and checks %+v", report.WireBytes, report.BodyBytes, report.BodyChecks)
+	}
+
+	report = runBoomer(t, &Boomer{Request: req, N: 2, C: 1, ReadAll: true, NoDecompress: true})
+	if report.BodyBytes != report.WireBytes || report.WireBytes >= int64(2*len(body)) {
+		t.Errorf("expected compressed responses left encoded, found %v wire bytes, %v body bytes", report.WireBytes, report.BodyBytes)
+	}
+
+	encodings = nil
+	report = runBoomer(t, &Boomer{Request: req, N: 2, C: 1, ReadAll: true, AcceptEncoding: "br"})
+	if report.BodyBytes != int64(2*len(body)) || report.WireBytes != report.BodyBytes || !reflect.DeepEqual(encodings, []string{"br", "br"}) {
+		t.Errorf("expected br to be asked for, found %v, %v wire bytes, %v body bytes", encodings, report.WireBytes, report.BodyBytes)
+	}
+}
+
+func TestHashBodies(t *testing.T) {
+	var count int64
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt64(&count, 1)%5 == 0 {
+			http.Error(w, "overloaded", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	boomer := &Boomer{
+		Request:    req,
+		N:          10,
+		C:          2,
+		HashBodies: true,
+	}
+	report := runBoomer(t, boomer)
+	if len(report.Variants) != 1 {
+		t.Fatalf("Expected variants for 1 endpoint, found %v", len(report.Variants))
+	}
+	if v := report.Variants[0]; v.Distinct != 2 || v.Responses != 10 {
+		t.Errorf("Expected 2 variants in 10 responses, found %+v", v)
+	}
+}
+
+func TestAbortRate(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("boom", 1024)))
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	boomer := &Boomer{
+		Request:   req,
+		N:         10,
+		C:         2,
+		AbortRate: 1,
+	}
+	report := runBoomer(t, boomer)
+	var aborted int
+	for _, a := range report.Aborts {
+		aborted += a.Count
+	}
+	if aborted != 10 {
+		t.Errorf("Expected 10 aborted requests, found %v", aborted)
+	}
+	if len(report.Lats) != 0 || len(report.errorDist) != 0 {
+		t.Errorf("Expected aborted requests to be excluded from statistics")
+	}
+}
+
+func TestSlowRate(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("b", 100)))
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	boomer := &Boomer{
+		Request:  req,
+		N:        2,
+		C:        2,
+		SlowRate: 200,
+	}
+	start := time.Now()
+	runBoomer(t, boomer)
+	if d := time.Since(start); d < 400*time.Millisecond {
+		t.Errorf("Expected reading 100 bytes at 200 bytes/sec to take at least 400ms, took %v", d)
+	}
+}
+
+func TestChurnRate(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}
+	server := httptest.NewTLSServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	boomer := &Boomer{
+		Request:       req,
+		N:             5,
+		C:             1,
+		ChurnRate:     50,
+		AllowInsecure: true,
+	}
+	report := runBoomer(t, boomer)
+	if report.Churn == nil || report.Churn.Attempts == 0 {
+		t.Fatalf("Expected churned connections to be reported")
+	}
+	if report.Churn.Failures != 0 {
+		t.Errorf("Expected no failed connections, found %v", report.Churn.Failures)
+	}
+	if report.Churn.AvgHandshake <= 0 {
+		t.Errorf("Expected TLS handshake latency to be reported")
+	}
+}
+
+func TestExtraDials(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	boomer := &Boomer{
+		Request:           req,
+		N:                 10,
+		C:                 1,
+		DisableKeepAlives: true,
+	}
+	report := runBoomer(t, boomer)
+	if report.ConnsDialed != 10 || report.ExtraDials != 9 {
+		t.Errorf("Expected 10 dials, 9 extra, found %v and %v", report.ConnsDialed, report.ExtraDials)
+	}
+
+	boomer.DisableKeepAlives = false
+	report = runBoomer(t, boomer)
+	if report.ConnsDialed != 1 || report.ExtraDials != 0 {
+		t.Errorf("Expected 1 dial and no extra dials, found %v and %v", report.ConnsDialed, report.ExtraDials)
+	}
+}
+
+func TestHTTP2(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {}
+	tlsServer := httptest.NewUnstartedServer(http.HandlerFunc(handler))
+	tlsServer.EnableHTTP2 = true
+	tlsServer.StartTLS()
+	defer tlsServer.Close()
+	h2cServer := httptest.NewUnstartedServer(http.HandlerFunc(handler))
+	h2cServer.Config.Protocols = new(http.Protocols)
+	h2cServer.Config.Protocols.SetUnencryptedHTTP2(true)
+	h2cServer.Start()
+	defer h2cServer.Close()
+
+	for _, url := range []string{tlsServer.URL, h2cServer.URL} {
+		req, _ := http.NewRequest("GET", url, nil)
+		report := runBoomer(t, &Boomer{Request: req, N: 20, C: 4, AllowInsecure: true, HTTP2: true})
+		want := []ProtocolStats{{Proto: "HTTP/2.0", Responses: 20, Conns: 1}}
+		if !reflect.DeepEqual(report.Protocols, want) || len(report.Errors) != 0 {
+			t.Errorf("%s: expected %+v, found %+v and errors %+v", url, want, report.Protocols, report.Errors)
+		}
+	}
+
+	req, _ := http.NewRequest("GET", tlsServer.URL, nil)
+	report := runBoomer(t, &Boomer{Request: req, N: 2, C: 1, AllowInsecure: true})
+	if want := []ProtocolStats{{Proto: "HTTP/1.1", Responses: 2, Conns: 1}}; !reflect.DeepEqual(report.Protocols, want) {
+		t.Errorf("expected %+v without HTTP2, found %+v", want, report.Protocols)
+	}
+}
+
+// protoTransport reports the responses of its transport as served
+// over proto.
+type protoTransport struct {
+	http.RoundTripper
+	proto string
+}
+
+func (t protoTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.RoundTripper.RoundTrip(req)
+	if err == nil {
+		resp.Proto = t.proto
+	}
+	return resp, err
+}
+
+func TestTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	tr := protoTransport{http.DefaultTransport, "HTTP/3.0"}
+	report := runBoomer(t, &Boomer{Request: req, N: 5, C: 1, Transport: tr})
+	if len(report.Protocols) != 1 || report.Protocols[0].Proto != "HTTP/3.0" || report.Protocols[0].Responses != 5 {
+		t.Errorf("expected 5 responses from the transport, found %+v", report.Protocols)
+	}
+
+	b := &Boomer{Request: req, N: 5, C: 1, Transport: tr, DialTimeout: time.Second, HTTP2: true}
+	if err := b.Validate(); err == nil || !strings.Contains(err.Error(), "DialTimeout, HTTP2") {
+		t.Errorf("expected the options of the replaced transport to be rejected, found %v", err)
+	}
+}
+
+func TestDisableCompression(t *testing.T) {
+	var encoding atomic.Value
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		encoding.Store(r.Header.Get("Accept-Encoding"))
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	for _, disable := range []bool{false, true} {
+		req, _ := http.NewRequest("GET", server.URL, nil)
+		boomer := &Boomer{
+			Request:            req,
+			N:                  1,
+			C:                  1,
+			DisableCompression: disable,
+		}
+		runBoomer(t, boomer)
+		got := encoding.Load().(string)
+		if disable && got != "" {
+			t.Errorf("Expected no Accept-Encoding with compression disabled, found %q", got)
+		}
+		if !disable && got != "gzip" {
+			t.Errorf("Expected Accept-Encoding gzip by default, found %q", got)
+		}
+	}
+}
+
+func TestHeaderChecks(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("X-Frame-Options", "SAMEORIGIN")
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	boomer := &Boomer{
+		Request: req,
+		N:       4,
+		C:       2,
+		HeaderChecks: []HeaderCheck{
+			{Name: "Cache-Control", Pattern: regexp.MustCompile(`max-age=\d+`)},
+			{Name: "X-Frame-Options", Value: "DENY"},
+			{Name: "Strict-Transport-Security"},
+		},
+	}
+	report := runBoomer(t, boomer)
+	want := []CheckResult{
+		{Check: `Cache-Control~max-age=\d+`, Passed: 4},
+		{Check: "X-Frame-Options=DENY", Failed: 4},
+		{Check: "Strict-Transport-Security", Failed: 4},
+	}
+	if !reflect.DeepEqual(report.HeaderChecks, want) {
+		t.Errorf("Expected header check results %v, found %v", want, report.HeaderChecks)
+	}
+}
+
+func TestRedirects(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if n, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/r/")); n > 0 {
+			time.Sleep(20 * time.Millisecond)
+			http.Redirect(w, r, fmt.Sprintf("/r/%d", n-1), http.StatusFound)
+		}
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL+"/r/2", nil)
+	report := runBoomer(t, &Boomer{Request: req, N: 4, C: 2})
+	if report.StatusCount(200) != 4 || !reflect.DeepEqual(report.Redirects, []RedirectCount{{Hops: 2, Count: 4}}) ||
+		len(report.RedirectCodes) != 1 || report.RedirectCodes[0] != (StatusCode{Code: 302, Count: 8}) {
+		t.Errorf("expected 4 responses after 2 redirects each, found %+v, %+v, %+v", report.StatusCodes, report.Redirects, report.RedirectCodes)
+	}
+	if report.Slowest < 40 {
+		t.Errorf("expected the latency to include the redirects, found %v", report.Slowest)
+	}
+
+	report = runBoomer(t, &Boomer{Request: req, N: 4, C: 2, LastHopLatency: true})
+	if report.Slowest >= 20 {
+		t.Errorf("expected the latency to leave out the redirects, found %v", report.Slowest)
+	}
+
+	report = runBoomer(t, &Boomer{Request: req, N: 4, C: 2, MaxRedirects: 1})
+	if report.ErrorCount() != 4 || report.Responses() != 0 {
+		t.Errorf("expected the requests to fail after 1 redirect, found %+v, %+v", report.StatusCodes, report.Errors)
+	}
+
+	report = runBoomer(t, &Boomer{Request: req, N: 4, C: 2, NoRedirects: true})
+	if report.StatusCount(302) != 4 || len(report.Redirects) != 0 {
+		t.Errorf("expected the redirect responses to be reported, found %+v, %+v", report.StatusCodes, report.Redirects)
+	}
+}
+
+func TestRetries(t *testing.T) {
+	var calls, badBodies int64
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if body, _ := ioutil.ReadAll(r.Body); string(body) != "payload" {
+			atomic.AddInt64(&badBodies, 1)
+		}
+		switch atomic.AddInt64(&calls, 1) {
+		case 1, 2, 5:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	req, _ := http.NewRequest("POST", server.URL, nil)
+	retry := &Retry{Max: 2, StatusCodes: []int{503}, Backoff: time.Millisecond}
+	report := runBoomer(t, &Boomer{Request: req, RequestBody: "payload", N: 4, C: 1, Retry: retry})
+	want := []RetryCount{{Retries: 0, Requests: 2, Succeeded: 2}, {Retries: 1, Requests: 1, Succeeded: 1}, {Retries: 2, Requests: 1, Succeeded: 1}}
+	for i := range report.Retries {
+		report.Retries[i].Average = 0
+	}
+	if report.StatusCount(200) != 4 || !reflect.DeepEqual(report.Retries, want) || badBodies != 0 {
+		t.Errorf("expected the failed attempts to be retried, found %+v, %+v, %d bad bodies", report.StatusCodes, report.Retries, badBodies)
+	}
+
+	// Requests still failing after the retries are reported as such.
+	server.Close()
+	report = runBoomer(t, &Boomer{Request: req, RequestBody: "payload", N: 2, C: 1, Retry: retry})
+	if report.ErrorCount() != 2 || len(report.Retries) != 1 || report.Retries[0] != (RetryCount{Retries: 2, Requests: 2, Average: report.Retries[0].Average}) {
+		t.Errorf("expected the failed requests to be retried twice, found %+v, %+v", report.Errors, report.Retries)
+	}
+}
+
+func TestConnLatency(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	report := runBoomer(t, &Boomer{Request: req, N: 5, C: 1, KeepRecords: true})
+	if report.NewConns == nil || report.NewConns.Count != 1 || report.ReusedConns == nil || report.ReusedConns.Count != 4 {
+		t.Errorf("expected 1 response over a new connection and 4 over a reused one, found %+v, %+v", report.NewConns, report.ReusedConns)
+	}
+	var reused int
+	for rec := range report.Records {
+		if rec.Reused {
+			reused++
+		}
+	}
+	if reused != 4 {
+		t.Errorf("expected 4 records of reused connections, found %d", reused)
+	}
+
+	report = runBoomer(t, &Boomer{Request: req, N: 5, C: 1, DisableKeepAlives: true})
+	if report.NewConns == nil || report.NewConns.Count != 5 || report.ReusedConns != nil {
+		t.Errorf("expected every response over a new connection, found %+v, %+v", report.NewConns, report.ReusedConns)
+	}
+}
+
+func TestPoolWaits(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	// With a single connection, all but one of the workers queue for it.
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	report := runBoomer(t, &Boomer{Request: req, N: 8, C: 4, MaxConnsPerHost: 1})
+	if report.NewConns == nil || report.NewConns.Count != 1 || report.PoolWaits == nil || report.PoolWaits.Count < 4 || report.PoolWaits.P90 < 20 {
+		t.Errorf("expected requests to wait for the single connection, found %+v, %+v", report.NewConns, report.PoolWaits)
+	}
+
+	report = runBoomer(t, &Boomer{Request: req, N: 8, C: 4})
+	if report.PoolWaits != nil {
+		t.Errorf("expected no waits for a connection without a limit, found %+v", report.PoolWaits)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	var hosts []string
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hosts = append(hosts, r.Host)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	// The host does not resolve, only the pinned address connects.
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	addr := net.JoinHostPort("backend.invalid", port)
+	req, _ := http.NewRequest("GET", "http://"+addr, nil)
+	report := runBoomer(t, &Boomer{Request: req, N: 4, C: 2, Resolve: map[string][]string{addr: {"127.0.0.2", "127.0.0.1"}}})
+	if report.StatusCount(200) != 4 || len(hosts) != 4 || hosts[0] != addr {
+		t.Errorf("expected the requests to be sent to %v at the pinned address, found %+v, %v", addr, report.Errors, hosts)
+	}
+}
+
+func TestDNSCache(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	req, _ := http.NewRequest("GET", "http://localhost:"+port, nil)
+	for _, tt := range []struct {
+		ttl     time.Duration
+		lookups int
+	}{
+		{0, 4},
+		{time.Hour, 1},
+		{time.Nanosecond, 4},
+	} {
+		report := runBoomer(t, &Boomer{Request: req, N: 4, C: 1, DisableKeepAlives: true, DNSCacheTTL: tt.ttl})
+		if report.StatusCount(200) != 4 || report.DNS == nil || report.DNS.Lookups != tt.lookups || report.DNS.Latency.Count != tt.lookups {
+			t.Errorf("expected %d lookups with a TTL of %v, found %+v, %+v", tt.lookups, tt.ttl, report.DNS, report.Errors)
+		}
+	}
+
+	// Concurrent dials share a lookup.
+	report := runBoomer(t, &Boomer{Request: req, N: 8, C: 4, DisableKeepAlives: true, DNSCacheTTL: time.Hour})
+	if report.DNS == nil || report.DNS.Lookups != 1 {
+		t.Errorf("expected a single lookup, found %+v", report.DNS)
+	}
+}
+
+func TestIPVersion(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	req, _ := http.NewRequest("GET", "http://localhost:"+port, nil)
+	report := runBoomer(t, &Boomer{Request: req, N: 4, C: 1, IPVersion: 4})
+	if want := []FamilyStats{{Family: "IPv4", Responses: 4, Conns: 1}}; !reflect.DeepEqual(report.Families, want) {
+		t.Errorf("expected IPv4 connections, found %+v, %+v", report.Families, report.Errors)
+	}
+
+	// The server only listens on IPv4.
+	report = runBoomer(t, &Boomer{Request: req, N: 2, C: 1, IPVersion: 6, DNSCacheTTL: time.Hour})
+	if report.ErrorCount() != 2 || report.Families != nil {
+		t.Errorf("expected IPv6 connections to fail, found %+v, %+v", report.Families, report.Errors)
+	}
+
+	l, err := net.Listen("tcp6", "[::1]:0")
+	if err != nil {
+		t.Skipf("IPv6 not available: %v", err)
+	}
+	server6 := &httptest.Server{Listener: l, Config: &http.Server{Handler: server.Config.Handler}}
+	server6.Start()
+	defer server6.Close()
+	req, _ = http.NewRequest("GET", server6.URL, nil)
+	report = runBoomer(t, &Boomer{Request: req, N: 2, C: 1, IPVersion: 6})
+	if want := []FamilyStats{{Family: "IPv6", Responses: 2, Conns: 1}}; !reflect.DeepEqual(report.Families, want) {
+		t.Errorf("expected IPv6 connections, found %+v, %+v", report.Families, report.Errors)
+	}
+}
+
+func TestCookieJar(t *testing.T) {
+	var sessions, seeded, carried int64
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if c, err := r.Cookie("team"); err == nil && c.Value == "perf" {
+			atomic.AddInt64(&seeded, 1)
+		}
+		if _, err := r.Cookie("session"); err == nil {
+			atomic.AddInt64(&carried, 1)
+			return
+		}
+		n := atomic.AddInt64(&sessions, 1)
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: strconv.FormatInt(n, 10)})
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	runBoomer(t, &Boomer{Request: req, N: 10, C: 2, Cookies: []*http.Cookie{{Name: "team", Value: "perf"}}})
+	if sessions != 2 || carried != 8 || seeded != 10 {
+		t.Errorf("expected a session per worker carried across its requests, found %d sessions, %d carried, %d seeded", sessions, carried, seeded)
+	}
+
+	sessions, carried = 0, 0
+	runBoomer(t, &Boomer{Request: req, N: 10, C: 2})
+	if sessions != 10 || carried != 0 {
+		t.Errorf("expected no cookies to be kept without a jar, found %d sessions", sessions)
+	}
+}
+
+func TestBodyChecks(t *testing.T) {
+	var n int32
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&n, 1)%2 == 0 {
+			w.Write([]byte(`{"error": "overloaded"}`))
+			return
+		}
+		w.Write([]byte(`{"items": [{"id": 1000000}]}`))
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	boomer := &Boomer{
+		Request: req,
+		N:       4,
+		C:       1,
+		BodyChecks: []BodyCheck{
+			{Pattern: regexp.MustCompile(`"items"`)},
+			{JSONPath: "$.items[0].id", Value: "1000000"},
+			{JSONPath: "$.error"},
+			{Length: 23},
+		},
+	}
+	report := runBoomer(t, boomer)
+	want := []CheckResult{
+		{Check: `body ~"items"`, Passed: 2, Failed: 2},
+		{Check: "body $.items[0].id=1000000", Passed: 2, Failed: 2},
+		{Check: "body $.error", Passed: 2, Failed: 2},
+		{Check: "body length=23", Passed: 2, Failed: 2},
+	}
+	if !reflect.DeepEqual(report.BodyChecks, want) {
+		t.Errorf("Expected body check results %v, found %v", want, report.BodyChecks)
+	}
+	if report.AssertionFailures != 4 || len(report.Errors) != 0 || report.Responses() != 4 {
+		t.Errorf("Expected 4 responses failing assertions without errors, found %d failing and errors %v", report.AssertionFailures, report.Errors)
+	}
+}
+
+func TestTemplateSeq(t *testing.T) {
+	var mu sync.Mutex
+	seen := make(map[string]bool)
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		if r.URL.Path != "/users/"+string(body) {
+			t.Errorf("Expected path and body to render the same seq, found %v and %s", r.URL.Path, body)
+		}
+		if seen[r.URL.Path] {
+			t.Errorf("Expected a unique seq per request, found %v twice", r.URL.Path)
+		}
+		seen[r.URL.Path] = true
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	req, _ := http.NewRequest("POST", server.URL+"/users/{{seq}}", nil)
+	boomer := &Boomer{
+		Request:     req,
+		RequestBody: "{{seq}}",
+		N:           20,
+		C:           4,
+		Template:    true,
+	}
+	runBoomer(t, boomer)
+	if len(seen) != 20 {
+		t.Errorf("Expected 20 distinct seq values, found %v", len(seen))
+	}
+
+	// A second generator offset by the ID range must not repeat any.
+	boomer.IDOffset = boomer.IDRange()
+	runBoomer(t, boomer)
+	if len(seen) != 40 {
+		t.Errorf("Expected 40 distinct seq values across offset runs, found %v", len(seen))
+	}
+}
+
+func TestTemplateCounter(t *testing.T) {
+	var mu sync.Mutex
+	var ids []int
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		id, _ := strconv.Atoi(r.URL.Query().Get("id"))
+		mu.Lock()
+		ids = append(ids, id)
+		mu.Unlock()
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL+`?id={{counter "user"}}`, nil)
+	boomer := &Boomer{
+		Request:  req,
+		N:        20,
+		C:        4,
+		Template: true,
+	}
+	runBoomer(t, boomer)
+	sort.Ints(ids)
+	for i, id := range ids {
+		if id != i {
+			t.Fatalf("Expected dense ids from 0 to 19, found %v", ids)
+		}
+	}
+}
+
+func TestWarmupSeq(t *testing.T) {
+	var mu sync.Mutex
+	seen := make(map[string]int)
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen[r.URL.Query().Get("id")]++
+		mu.Unlock()
+		time.Sleep(time.Millisecond)
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL+"?id={{seq}}", nil)
+	report := runBoomer(t, &Boomer{Request: req, N: 20, C: 2, Template: true, Warmup: 50 * time.Millisecond})
+	if len(seen) != report.Warmup+20 {
+		t.Errorf("expected %d distinct ids over the warm-up and the run, found %d: %v", report.Warmup+20, len(seen), seen)
+	}
+}
+
+func TestTemplateFuncs(t *testing.T) {
+	os.Setenv("BOOM_TEST_TENANT", "acme")
+	defer os.Unsetenv("BOOM_TEST_TENANT")
+	uuidRegexp := regexp.MustCompile("^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$")
+	start := time.Now().Unix()
+
+	var mu sync.Mutex
+	ids := make(map[string]bool)
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		id := r.Header.Get("X-Request-Id")
+		if !uuidRegexp.MatchString(id) {
+			t.Errorf("Expected a UUID header, found %q", id)
+		}
+		if got := r.URL.Query().Get("tenant"); got != "acme" {
+			t.Errorf("Expected the tenant from the environment, found %q", got)
+		}
+		var n, ts int64
+		if _, err := fmt.Sscanf(string(body), "%d %d", &n, &ts); err != nil || n < 5 || n >= 10 || ts < start {
+			t.Errorf("Unexpected body %q", body)
+		}
+		mu.Lock()
+		ids[id] = true
+		mu.Unlock()
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	req, _ := http.NewRequest("POST", server.URL+`/?tenant={{env "BOOM_TEST_TENANT"}}`, nil)
+	req.Header.Set("X-Request-Id", "{{uuid}}")
+	boomer := &Boomer{
+		Request:     req,
+		RequestBody: "{{randInt 5 10}} {{now.Unix}}",
+		N:           20,
+		C:           4,
+		Template:    true,
+	}
+	runBoomer(t, boomer)
+	if len(ids) != 20 {
+		t.Errorf("Expected 20 distinct UUIDs, found %v", len(ids))
+	}
+}
+
+func TestRecords(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	boomer := &Boomer{
+		Request:     req,
+		N:           10,
+		C:           2,
+		KeepRecords: true,
+		Tags:        map[string]string{"build": "42"},
+	}
+	report := runBoomer(t, boomer)
+	var n int
+	var last time.Time
+	report.Records(func(rec Record) bool {
+		n++
+		if rec.StatusCode != http.StatusAccepted || rec.Duration <= 0 || rec.Tags["build"] != "42" {
+			t.Errorf("Unexpected record: %+v", rec)
+		}
+		if rec.Start.Before(last) {
+			t.Errorf("Expected records in the order requests started")
+		}
+		last = rec.Start
+		return n < 5
+	})
+	if n != 5 {
+		t.Errorf("Expected iteration to stop after 5 records, found %v", n)
+	}
+}
+
+func TestMaxBody(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/small" {
+			w.Write([]byte("boom"))
+			return
+		}
+		w.Write([]byte(strings.Repeat("boom", 1024)))
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	for path, want := range map[string]int{"/small": 0, "/large": 5} {
+		req, _ := http.NewRequest("GET", server.URL+path, nil)
+		boomer := &Boomer{
+			Request: req,
+			N:       5,
+			C:       1,
+			ReadAll: true,
+			MaxBody: 4,
+		}
+		if got := runBoomer(t, boomer).Truncated; got != want {
+			t.Errorf("%v: expected %v truncated responses, found %v", path, want, got)
+		}
+	}
+}
+
+func TestMaxBodyGzip(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		zw.Write([]byte(strings.Repeat("boom", 1024)))
+		zw.Close()
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	// The first limit cuts off the gzip header, the second the data.
+	for _, max := range []int64{4, 24} {
+		req, _ := http.NewRequest("GET", server.URL, nil)
+		r := runBoomer(t, &Boomer{Request: req, N: 5, C: 1, ReadAll: true, MaxBody: max})
+		if r.Truncated != 5 || r.ErrorCount() != 0 {
+			t.Errorf("MaxBody %d: %d truncated and %d errors; want 5 and 0", max, r.Truncated, r.ErrorCount())
+		}
+	}
+}
+
+func TestBodyFunc(t *testing.T) {
+	var mu sync.Mutex
+	bodies := make(map[string]bool)
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.ContentLength != int64(len(body)) {
+			t.Errorf("Expected content length %v, found %v", len(body), r.ContentLength)
+		}
+		mu.Lock()
+		bodies[string(body)] = true
+		mu.Unlock()
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	req, _ := http.NewRequest("POST", server.URL, nil)
+	boomer := &Boomer{
+		Request: req,
+		N:       10,
+		C:       2,
+		BodyFunc: func(worker, iteration int) io.Reader {
+			return strings.NewReader(fmt.Sprintf("%d-%d", worker, iteration))
+		},
+	}
+	runBoomer(t, boomer)
+	if len(bodies) != 10 {
+		t.Errorf("Expected 10 distinct bodies, found %v", len(bodies))
+	}
+	for b := range bodies {
+		var w, i int
+		if _, err := fmt.Sscanf(b, "%d-%d", &w, &i); err != nil || w > 1 || i >= 10 {
+			t.Errorf("Unexpected body %q", b)
+		}
+	}
+}
+
+func TestBodyFile(t *testing.T) {
+	want := bytes.Repeat([]byte("0123456789"), 100000)
+	path := filepath.Join(t.TempDir(), "body")
+	if err := ioutil.WriteFile(path, want, 0644); err != nil {
+		t.Fatal(err)
+	}
+	var count int64
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.ContentLength != int64(len(want)) || !bytes.Equal(body, want) {
+			t.Errorf("Unexpected body of %v bytes, content length %v", len(body), r.ContentLength)
+		}
+		atomic.AddInt64(&count, 1)
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	req, _ := http.NewRequest("POST", server.URL, nil)
+	boomer := &Boomer{Request: req, N: 5, C: 2, BodyFile: path}
+	runBoomer(t, boomer)
+	if count != 5 {
+		t.Errorf("Expected 5 requests, found %v", count)
+	}
+
+	boomer = &Boomer{Request: req, N: 3, C: 1, BodyFile: path + ".missing"}
+	if errs := runBoomer(t, boomer).Errors; len(errs) != 1 || errs[0].Count != 3 {
+		t.Errorf("Expected an error for a missing body file, found %v", errs)
+	}
+}
+
+func TestTimeouts(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow-body" {
+			w.(http.Flusher).Flush()
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	for path, phase := range map[string]string{"/slow-headers": "headers", "/slow-body": "body"} {
+		req, _ := http.NewRequest("GET", server.URL+path, nil)
+		boomer := &Boomer{
+			Request: req,
+			N:       2,
+			C:       2,
+			Timeout: 50,
+			ReadAll: true,
+		}
+		report := runBoomer(t, boomer)
+		want := []Timeout{{Phase: phase, Count: 2, Limit: "total"}}
+		if !reflect.DeepEqual(report.Timeouts, want) {
+			t.Errorf("%v: expected timeouts %v, found %v", path, want, report.Timeouts)
+		}
+		if len(report.Errors) != 0 || report.ErrorRate() != 1 {
+			t.Errorf("%v: expected timeouts to be counted apart from errors, found %v", path, report.Errors)
+		}
+	}
+}
+
+func TestPhaseTimeouts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	report := runBoomer(t, &Boomer{Request: req, N: 2, C: 2, Timeout: 1000, HeaderTimeout: 50 * time.Millisecond})
+	if want := []Timeout{{Phase: "headers", Count: 2, Limit: "header"}}; !reflect.DeepEqual(report.Timeouts, want) {
+		t.Errorf("expected timeouts %v, found %v", want, report.Timeouts)
+	}
+
+	// A listener that never completes a TLS handshake.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	req, _ = http.NewRequest("GET", "https://"+l.Addr().String(), nil)
+	report = runBoomer(t, &Boomer{Request: req, N: 2, C: 2, Timeout: 1000, TLSTimeout: 50 * time.Millisecond})
+	if want := []Timeout{{Phase: "tls", Count: 2, Limit: "tls"}}; !reflect.DeepEqual(report.Timeouts, want) {
+		t.Errorf("expected timeouts %v, found %v and errors %v", want, report.Timeouts, report.Errors)
+	}
+}
+
+func TestLatencyBudget(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte("a"))
+		w.(http.Flusher).Flush()
+		time.Sleep(10 * time.Millisecond)
+		w.Write([]byte("b"))
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	boomer := &Boomer{
+		Request:           req,
+		N:                 4,
+		C:                 2,
+		ReadAll:           true,
+		DisableKeepAlives: true,
+		LatencyBudget:     true,
+	}
+	report := runBoomer(t, boomer)
+	if len(report.Budget) != 1 || report.Budget[0].Requests != 4 {
+		t.Fatalf("expected a budget for 4 requests, found %+v", report.Budget)
+	}
+	for _, p := range []PhaseTimes{report.Budget[0].Average, report.Budget[0].P99} {
+		if p.Wait < 20 || p.Transfer < 10 || p.Connect <= 0 {
+			t.Errorf("expected wait, transfer and connect times, found %+v", p)
+		}
+	}
+	if avg := report.Budget[0].Average.Total(); math.Abs(avg-report.Average*1000) > 1e-6 {
+		t.Errorf("expected the phases to add up to the average latency %v, found %v", report.Average*1000, avg)
+	}
+}
+
+func TestStallWatchdog(t *testing.T) {
+	release := make(chan struct{})
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("hang") != "" {
+			<-release
+		}
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+	time.AfterFunc(300*time.Millisecond, func() { close(release) })
+
+	var log bytes.Buffer
+	req, _ := http.NewRequest("GET", server.URL+"?hang=1", nil)
+	boomer := &Boomer{
+		Request:    req,
+		N:          2,
+		C:          2,
+		StallAfter: 100 * time.Millisecond,
+		StallLog:   &log,
+	}
+	report := runBoomer(t, boomer)
+	if report.Stalls != 2 {
+		t.Errorf("expected 2 stalls, found %d", report.Stalls)
+	}
+	if !strings.Contains(log.String(), "stalled") || !strings.Contains(log.String(), "runWorker") {
+		t.Errorf("expected the stacks of the stalled workers to be logged, found %q", log.String())
+	}
+}
+
+func TestAutoScale(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	boomer := &Boomer{
+		Request:       req,
+		N:             200,
+		C:             1,
+		AutoScale:     &AutoScale{MaxWorkers: 8, Interval: 50 * time.Millisecond},
+		KeepLatencies: true,
+	}
+	report := runBoomer(t, boomer)
+	if len(report.Scaling) == 0 || report.Scaling[len(report.Scaling)-1].Workers <= 1 {
+		t.Errorf("expected workers to be added, found %+v", report.Scaling)
+	}
+	if len(report.Lats) != 200 {
+		t.Errorf("expected 200 requests, found %d", len(report.Lats))
+	}
+
+	// A threshold no run stays under retires all workers but one.
+	boomer.C = 4
+	boomer.AutoScale.CPUThreshold = 1e-9
+	report = runBoomer(t, boomer)
+	if len(report.Scaling) < 2 || report.Scaling[len(report.Scaling)-1].Workers >= 4 {
+		t.Errorf("expected workers to be retired, found %+v", report.Scaling)
+	}
+	if len(report.Lats) != 200 {
+		t.Errorf("expected 200 requests, found %d", len(report.Lats))
+	}
+}
+
+func TestScalerRestartsRetired(t *testing.T) {
+	b := &Boomer{N: 10, C: 1, AutoScale: &AutoScale{MaxWorkers: 2}}
+	workers := []*worker{b.newWorker(0), b.newWorker(1)}
+	ch := make(chan *http.Request)
+	var wg sync.WaitGroup
+	sc := newScaler(b, workers, &wg, ch, true)
+	defer close(ch)
+
+	sc.start(2)
+	sc.retire(1)
+	if sc.active != 1 {
+		t.Fatalf("expected 1 active worker once shrunk, found %d", sc.active)
+	}
+	// The retired worker is started again once it returned.
+	for deadline := time.Now().Add(time.Second); sc.active < 2 && time.Now().Before(deadline); {
+		sc.start(1)
+		time.Sleep(time.Millisecond)
+	}
+	if sc.active != 2 || sc.started != 2 || sc.peak != 2 {
+		t.Errorf("expected the pool to grow back to 2 workers, found %d active of %d started", sc.active, sc.started)
+	}
+}
+
+func TestOpenModel(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	boomer := &Boomer{Request: req, N: 40, C: 1, Qps: 100, OpenModel: true}
+	start := time.Now()
+	report := runBoomer(t, boomer)
+	// Closed to a single worker the run would take 2 seconds.
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the arrivals to keep to the rate, the run took %v", elapsed)
+	}
+	s := report.Schedule
+	if s == nil || s.IntendedRPS != 100 || s.AchievedRPS < 80 || s.Workers < 3 {
+		t.Fatalf("expected workers to be added to keep to 100 req/s, found %+v", s)
+	}
+	if report.StatusCount(200) != 40 {
+		t.Errorf("expected 40 responses, found %+v", report.StatusCodes)
+	}
+
+	boomer.MaxWorkers = 2
+	report = runBoomer(t, boomer)
+	if s := report.Schedule; s.Workers != 2 || s.Late == 0 || s.MaxLag < 10*time.Millisecond {
+		t.Errorf("expected arrivals to wait for the 2 workers, found %+v", s)
+	}
+}
+
+func TestPoisson(t *testing.T) {
+	var p poissonArrivals
+	var at time.Duration
+	for i := 1; i <= 10000; i++ {
+		at = p.next(time.Duration(i) * time.Millisecond)
+	}
+	if at < 9*time.Second || at > 11*time.Second {
+		t.Errorf("expected 10000 arrivals 1ms apart on average to take about 10s, took %v", at)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	report := runBoomer(t, &Boomer{Request: req, N: 100, C: 4, Qps: 200, Poisson: true})
+	s := report.Schedule
+	if s == nil || !s.Poisson || s.IntendedGaps == nil || s.DeliveredGaps == nil {
+		t.Fatalf("expected the schedule of the Poisson arrivals, found %+v", s)
+	}
+	if g := s.IntendedGaps; g.Average < 3.5 || g.Average > 6.5 || g.P99 < 3*g.P50 {
+		t.Errorf("expected exponential gaps of 5ms on average, found %+v", g)
+	}
+	if report.StatusCount(200) != 100 || s.AchievedRPS < 120 || s.AchievedRPS > 300 {
+		t.Errorf("expected 100 responses at about 200 req/s, found %+v, %+v", report.StatusCodes, s)
+	}
+}
+
+func TestRunContext(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	report, err := (&Boomer{Request: req, N: 1000, C: 2}).Run(ctx)
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expected the deadline to stop the run, found %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 300*time.Millisecond {
+		t.Errorf("expected the run to stop after 100ms, it took %v", elapsed)
+	}
+	if n := report.StatusCount(200); n == 0 || n >= 1000 || report.ErrorCount() != 0 || report.Stopped != err.Error() {
+		t.Errorf("expected the requests made until the deadline, found %+v, %+v, stopped %q", report.StatusCodes, report.Errors, report.Stopped)
+	}
+
+	for _, b := range []*Boomer{
+		{N: 10, C: 1},
+		{Request: req, C: 1},
+		{Request: req, N: 10},
+		{Request: req, N: 10, C: 1, Template: true, RequestBody: "{{"},
+	} {
+		if r, err := b.Run(context.Background()); err == nil || r != nil {
+			t.Errorf("expected an error running %+v, found %v", b, err)
+		}
+	}
+}
+
+func TestGracePeriod(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	report, err := (&Boomer{Request: req, N: 10, C: 2, GracePeriod: 50 * time.Millisecond}).Run(ctx)
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expected the deadline to stop the run, found %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("expected the requests in flight to be cancelled after the grace period, the run took %v", elapsed)
+	}
+	if !report.Partial {
+		t.Errorf("expected the report to be partial")
+	}
+	if len(report.Aborts) != 1 || report.Aborts[0].Phase != "interrupted" || report.Aborts[0].Count != 2 {
+		t.Errorf("expected the 2 requests in flight to be interrupted, found %+v", report.Aborts)
+	}
+	if len(report.Lats) != 0 || report.ErrorCount() != 0 {
+		t.Errorf("expected interrupted requests to be excluded from statistics, found %v errors", report.ErrorCount())
+	}
+
+	// Without a grace period, the requests in flight complete.
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	report, _ = (&Boomer{Request: req, N: 10, C: 2}).Run(ctx)
+	if !report.Partial || report.StatusCount(200) != 2 || len(report.Aborts) != 0 {
+		t.Errorf("expected the 2 requests in flight to complete, found %+v, aborts %+v", report.StatusCodes, report.Aborts)
+	}
+}
+
+func TestDuration(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	start := time.Now()
+	report := runBoomer(t, &Boomer{Request: req, C: 2, Duration: 300 * time.Millisecond})
+	if elapsed := time.Since(start); elapsed < 300*time.Millisecond || elapsed > 400*time.Millisecond {
+		t.Errorf("expected the run to end after 300ms, it took %v", elapsed)
+	}
+	if n := report.StatusCount(200); n < 30 || n > 62 || report.ErrorCount() != 0 {
+		t.Errorf("expected about 60 responses and no errors, found %+v and %+v", report.StatusCodes, report.Errors)
+	}
+	if want := float64(report.StatusCount(200)) / report.total.Seconds(); report.RPS != want {
+		t.Errorf("expected %v req/s over the time elapsed, found %v", want, report.RPS)
+	}
+
+	report = runBoomer(t, &Boomer{Request: req, C: 2, Qps: 100, Duration: 200 * time.Millisecond})
+	if n := report.StatusCount(200); n != 19 {
+		t.Errorf("expected 19 responses at 100 req/s, the first 10ms in, found %v", n)
+	}
+	start = time.Now()
+	report = runBoomer(t, &Boomer{Request: req, N: 4, C: 2, Duration: time.Minute})
+	if time.Since(start) > time.Second || report.StatusCount(200) != 4 {
+		t.Errorf("expected N to end the run, found %+v", report.StatusCodes)
+	}
+}
+
+func TestWarmup(t *testing.T) {
+	var conns int64
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(5 * time.Millisecond)
+	}))
+	server.Config.ConnState = func(c net.Conn, s http.ConnState) {
+		if s == http.StateNew {
+			atomic.AddInt64(&conns, 1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	start := time.Now()
+	report := runBoomer(t, &Boomer{Request: req, N: 20, C: 2, Warmup: 100 * time.Millisecond, KeepLatencies: true})
+	if report.Warmup < 10 || report.StatusCount(200) != 20 || len(report.Lats) != 20 {
+		t.Errorf("expected 20 responses after the warm-up, found %v warm-up requests and %+v", report.Warmup, report.StatusCodes)
+	}
+	if total := time.Duration(report.TotalDuration) * time.Millisecond; total > time.Since(start)-100*time.Millisecond {
+		t.Errorf("expected the warm-up to be left out of the total, found %v", total)
+	}
+	if n := atomic.LoadInt64(&conns); n != 2 || report.ConnsDialed != 0 {
+		t.Errorf("expected the run to reuse the 2 connections of the warm-up, found %v, %v dialed by the run", n, report.ConnsDialed)
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// Step is one of the requests of a Scenario, named after its
+// endpoint. Its URL path and query, headers and body are templates,
+// which can use the values captured by earlier steps as {{var
+// "name"}}.
+type Step struct {
+	Endpoint
+
+	// Extract captures values of the response for the later steps.
+	Extract []Extract
+}
+
+// Extract captures a value of a response into a variable of the
+// virtual user. Exactly one of Header, Cookie and JSONPath is set.
+type Extract struct {
+	Var string
+
+	// Header is the name of the response header holding the value.
+	Header string
+
+	// Cookie is the name of the cookie set by the response holding the
+	// value.
+	Cookie string
+
+	// JSONPath is the path of the field of the JSON response body
+	// holding the value, e.g. "$.data.token" or "$.items[0].id".
+	JSONPath string
+}
+
+func (e Extract) String() string {
+	switch {
+	case e.Header != "":
+		return "header " + e.Header
+	case e.Cookie != "":
+		return "cookie " + e.Cookie
+	}
+	return e.JSONPath
+}
+
+// scenarioStep returns the index of the step of the worker's current
+// request: the workers are the virtual users of the scenario, each
+// making its steps in turn. The variables captured are cleared as the
+// worker starts the scenario over.
+func (w *worker) scenarioStep(b *Boomer) int {
+	step := int((w.iter - 1) % int64(len(b.Scenario)))
+	if step == 0 {
+		w.vars = nil
+	}
+	return step
+}
+
+// needsBody reports whether the step of req extracts values from the
+// response body.
+func (b *Boomer) needsBody(req *http.Request) bool {
+	if len(b.Scenario) == 0 {
+		return false
+	}
+	for _, e := range b.Scenario[endpointOf(req)].Extract {
+		if e.JSONPath != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// extract captures the values of the response of the step of req into
+// the variables of the worker.
+func (w *worker) extract(b *Boomer, req *http.Request, resp *http.Response, body []byte) error {
+	step := b.Scenario[endpointOf(req)]
+	var doc interface{}
+	for _, e := range step.Extract {
+		var v string
+		var ok bool
+		switch {
+		case e.Header != "":
+			v = resp.Header.Get(e.Header)
+			ok = v != ""
+		case e.Cookie != "":
+			for _, c := range resp.Cookies() {
+				if c.Name == e.Cookie {
+					v, ok = c.Value, true
+				}
+			}
+		default:
+			if doc == nil {
+				if err := json.Unmarshal(body, &doc); err != nil {
+					return fmt.Errorf("step %s: invalid JSON body: %v", step.Name, err)
+				}
+			}
+			var field interface{}
+			if field, ok = lookupPath(doc, jsonPathToDots(e.JSONPath)); ok {
+				if s, isString := field.(string); isString {
+					v = s
+				} else {
+					data, _ := json.Marshal(field)
+					v = string(data)
+				}
+			}
+		}
+		if !ok {
+			return fmt.Errorf("step %s: no %s in response", step.Name, e)
+		}
+		if w.vars == nil {
+			w.vars = make(map[string]string)
+		}
+		w.vars[e.Var] = v
+	}
+	return nil
+}
+
+var jsonPathIndexRegexp = regexp.MustCompile(`\[(\d+)\]`)
+
+// jsonPathToDots converts a JSONPath of names and indexes, such as
+// "$.items[0].id", to the dot separated path "items.0.id".
+func jsonPathToDots(path string) string {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	path = jsonPathIndexRegexp.ReplaceAllString(path, ".$1")
+	return strings.TrimPrefix(path, ".")
+}
+
+// varValue returns the value of the variable captured by an earlier
+// step.
+func (w *worker) varValue(name string) (string, error) {
+	v, ok := w.vars[name]
+	if !ok {
+		return "", fmt.Errorf("no variable %q captured", name)
+	}
+	return v, nil
+}
@@ -0,0 +1,96 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestScenario(t *testing.T) {
+	var logins int64
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			n := atomic.AddInt64(&logins, 1)
+			http.SetCookie(w, &http.Cookie{Name: "sid", Value: fmt.Sprintf("s%d", n)})
+			fmt.Fprintf(w, `{"data": {"tokens": ["t%d"]}}`, n)
+		case "/me":
+			c, err := r.Cookie("sid")
+			if err != nil || r.Header.Get("Authorization") != "Bearer t"+c.Value[1:] {
+				t.Errorf("Expected the token and session of the same login, found %v and %v", r.Header, err)
+			}
+		}
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	boomer := &Boomer{
+		Request: req,
+		N:       20,
+		C:       2,
+		Scenario: []Step{
+			{
+				Endpoint: Endpoint{Name: "login", Method: "POST", URL: server.URL + "/login"},
+				Extract: []Extract{
+					{Var: "token", JSONPath: "$.data.tokens[0]"},
+					{Var: "sid", Cookie: "sid"},
+				},
+			},
+			{
+				Endpoint: Endpoint{Name: "me", URL: server.URL + "/me", Header: http.Header{
+					"Authorization": {`Bearer {{var "token"}}`},
+					"Cookie":        {`sid={{var "sid"}}`},
+				}},
+			},
+		},
+	}
+	report := runBoomer(t, boomer)
+	if report.StatusCount(200) != 20 {
+		t.Errorf("Expected 20 responses, found %+v", report.StatusCodes)
+	}
+	// The requests are not spread evenly over the workers, so a
+	// worker may stop after logging in.
+	if e := report.Endpoints; len(e) != 2 || e[0].Name != "login" || e[1].Name != "me" || e[0].Method != "POST" ||
+		e[0].Requests != int(logins) || e[0].Requests+e[1].Requests != 20 || e[1].Requests < 8 || e[1].Errors != 0 {
+		t.Errorf("Expected %v logins and the rest of the 20 requests to me, found %+v", logins, e)
+	}
+
+	// A value missing from the response fails the step, and the steps
+	// using it.
+	boomer.Scenario[0].Extract[0].JSONPath = "$.data.missing"
+	report = runBoomer(t, boomer)
+	if len(report.Endpoints) != 2 || report.ErrorCount() != 20 {
+		t.Errorf("Expected all the steps to fail, found %+v", report.Endpoints)
+	}
+}
+
+func TestJSONPathToDots(t *testing.T) {
+	for path, want := range map[string]string{
+		"$.data.token":    "data.token",
+		"$.items[0].id":   "items.0.id",
+		"$[2]":            "2",
+		"user.roles.1":    "user.roles.1",
+		"$.a[1][2].b[10]": "a.1.2.b.10",
+	} {
+		if got := jsonPathToDots(path); got != want {
+			t.Errorf("%v: expected %v, found %v", path, want, got)
+		}
+	}
+}
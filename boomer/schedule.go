@@ -0,0 +1,175 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// lateAfter is how long after its scheduled time a request of an open
+// model run can be handed to a worker before it counts as late.
+const lateAfter = time.Millisecond
+
+// ScheduleStats describes how closely an open model run, or one with
+// Poisson arrivals, kept to its arrival rate.
+type ScheduleStats struct {
+	// IntendedRPS is the arrival rate asked for and AchievedRPS the
+	// rate requests were handed to workers at.
+	IntendedRPS float64 `json:"intended_rps"`
+	AchievedRPS float64 `json:"achieved_rps"`
+
+	// Poisson is set if the arrivals were a Poisson process.
+	Poisson bool `json:"poisson,omitempty"`
+
+	// IntendedGaps are the intervals, in ms, between the times requests
+	// were due at and DeliveredGaps those between the times they were
+	// handed to the workers, or queued for them. Merge drops them.
+	IntendedGaps  *LatencyStats `json:"intended_gaps,omitempty"`
+	DeliveredGaps *LatencyStats `json:"delivered_gaps,omitempty"`
+
+	// Late is the number of requests that waited for a worker, all of
+	// MaxWorkers being busy, and MaxLag the longest any waited.
+	Late   int           `json:"late"`
+	MaxLag time.Duration `json:"max_lag"`
+
+	// Workers is the number of workers the run needed.
+	Workers int `json:"workers"`
+}
+
+// openModel reports whether requests arrive at Qps independently of the
+// workers.
+func (b *Boomer) openModel() bool {
+	return b.OpenModel && b.Qps > 0
+}
+
+// dispatchOpen hands the requests to the workers at Qps arrivals per
+// second, starting another worker whenever an arrival finds all of
+// them busy, until the run's Duration expires. ch must be unbuffered,
+// for a send to only succeed when a worker is idle.
+func (b *Boomer) dispatchOpen(sc *scaler, ch chan *http.Request, wg *sync.WaitGroup, expired <-chan time.Time) *ScheduleStats {
+	start := time.Now()
+	rec := newScheduleRecorder(b, start)
+loop:
+	for i := 0; b.more(i); i++ {
+		due, ok := b.due(i)
+		if !ok {
+			break
+		}
+		at := start.Add(due)
+		time.Sleep(time.Until(at))
+		if b.stopped() || fired(expired) {
+			break
+		}
+		b.setRampPhase(i)
+		b.setStage(i)
+		req := b.job(i)
+		wg.Add(1)
+		select {
+		case ch <- req:
+		default:
+			sc.start(1)
+			select {
+			case ch <- req:
+			case <-expired:
+				wg.Done()
+				break loop
+			}
+		}
+		rec.handed(at)
+	}
+	return rec.done(sc.peak)
+}
+
+// scheduleRecorder records how closely the requests handed to the
+// workers kept to the times they were due at.
+type scheduleRecorder struct {
+	s                   ScheduleStats
+	start               time.Time
+	sent                int
+	lastDue, lastSent   time.Time
+	intended, delivered latencySamples
+}
+
+func newScheduleRecorder(b *Boomer, start time.Time) *scheduleRecorder {
+	return &scheduleRecorder{
+		s:     ScheduleStats{IntendedRPS: float64(b.Qps), Poisson: b.Poisson},
+		start: start,
+	}
+}
+
+// handed records a request due at at as handed to a worker now.
+func (r *scheduleRecorder) handed(at time.Time) {
+	now := time.Now()
+	if r.sent > 0 {
+		r.intended.add(at.Sub(r.lastDue).Seconds() * 1000)
+		r.delivered.add(now.Sub(r.lastSent).Seconds() * 1000)
+	}
+	r.sent++
+	r.lastDue, r.lastSent = at, now
+	if lag := now.Sub(at); lag > lateAfter {
+		r.s.Late++
+		if lag > r.s.MaxLag {
+			r.s.MaxLag = lag
+		}
+	}
+}
+
+// done returns the stats of the schedule of a run that needed workers.
+func (r *scheduleRecorder) done(workers int) *ScheduleStats {
+	s := r.s
+	if elapsed := time.Since(r.start); r.sent > 1 {
+		s.AchievedRPS = float64(r.sent-1) / elapsed.Seconds()
+		intended, delivered := r.intended.stats(), r.delivered.stats()
+		s.IntendedGaps, s.DeliveredGaps = &intended, &delivered
+	}
+	s.Workers = workers
+	return &s
+}
+
+// poissonArrivals spreads the requests of a run as a Poisson process:
+// each interval of the schedule at the rate of the run is scaled by a
+// draw of an exponential distribution of mean 1, so arrivals keep to
+// the rate on average, following its ramps and stages.
+type poissonArrivals struct {
+	fixed, at time.Duration
+}
+
+// next returns when the request due at at on the fixed schedule of the
+// run arrives. It must be called for each request in turn.
+func (p *poissonArrivals) next(at time.Duration) time.Duration {
+	gap := at - p.fixed
+	p.fixed = at
+	p.at += time.Duration(float64(gap) * rand.ExpFloat64())
+	return p.at
+}
+
+// mergeSchedule sums the rates and workers of concurrent runs.
+func mergeSchedule(dst, src *ScheduleStats) *ScheduleStats {
+	if dst == nil {
+		dst = &ScheduleStats{}
+	}
+	dst.IntendedRPS += src.IntendedRPS
+	dst.Poisson = dst.Poisson || src.Poisson
+	dst.AchievedRPS += src.AchievedRPS
+	dst.Late += src.Late
+	if src.MaxLag > dst.MaxLag {
+		dst.MaxLag = src.MaxLag
+	}
+	dst.Workers += src.Workers
+	return dst
+}
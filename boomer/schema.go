@@ -0,0 +1,320 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// Schema is a compiled JSON Schema. The validation keywords supported
+// are type, enum, const, properties, required, additionalProperties,
+// items, minItems, maxItems, minLength, maxLength, pattern, minimum,
+// maximum, allOf, anyOf and oneOf. Annotations such as title and
+// description are ignored; other keywords, e.g. $ref or format, are
+// rejected rather than silently not enforced.
+type Schema struct {
+	types      []string
+	enum       []interface{}
+	constant   *interface{}
+	properties map[string]*Schema
+	required   []string
+	additional *Schema
+	noExtra    bool
+	items      *Schema
+	minItems   *int
+	maxItems   *int
+	minLength  *int
+	maxLength  *int
+	pattern    *regexp.Regexp
+	minimum    *float64
+	maximum    *float64
+	allOf      []*Schema
+	anyOf      []*Schema
+	oneOf      []*Schema
+}
+
+type rawSchema struct {
+	Type                 json.RawMessage            `json:"type"`
+	Enum                 []interface{}              `json:"enum"`
+	Const                *interface{}               `json:"const"`
+	Properties           map[string]json.RawMessage `json:"properties"`
+	Required             []string                   `json:"required"`
+	AdditionalProperties json.RawMessage            `json:"additionalProperties"`
+	Items                json.RawMessage            `json:"items"`
+	MinItems             *int                       `json:"minItems"`
+	MaxItems             *int                       `json:"maxItems"`
+	MinLength            *int                       `json:"minLength"`
+	MaxLength            *int                       `json:"maxLength"`
+	Pattern              *string                    `json:"pattern"`
+	Minimum              *float64                   `json:"minimum"`
+	Maximum              *float64                   `json:"maximum"`
+	AllOf                []json.RawMessage          `json:"allOf"`
+	AnyOf                []json.RawMessage          `json:"anyOf"`
+	OneOf                []json.RawMessage          `json:"oneOf"`
+}
+
+// schemaKeywords are the keywords ParseSchema accepts: true for those
+// validated, false for annotations.
+var schemaKeywords = map[string]bool{
+	"type": true, "enum": true, "const": true, "properties": true,
+	"required": true, "additionalProperties": true, "items": true,
+	"minItems": true, "maxItems": true, "minLength": true,
+	"maxLength": true, "pattern": true, "minimum": true, "maximum": true,
+	"allOf": true, "anyOf": true, "oneOf": true,
+
+	"$schema": false, "$id": false, "id": false, "$comment": false,
+	"title": false, "description": false, "default": false,
+	"examples": false, "deprecated": false, "readOnly": false,
+	"writeOnly": false,
+}
+
+// ParseSchema compiles a JSON Schema document. It fails if the
+// document uses a keyword that is not supported.
+func ParseSchema(data []byte) (*Schema, error) {
+	var keywords map[string]json.RawMessage
+	if err := json.Unmarshal(data, &keywords); err != nil {
+		return nil, fmt.Errorf("invalid schema: %v", err)
+	}
+	for k := range keywords {
+		if _, ok := schemaKeywords[k]; !ok {
+			return nil, fmt.Errorf("unsupported schema keyword: %s", k)
+		}
+	}
+	var raw rawSchema
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid schema: %v", err)
+	}
+	s := &Schema{
+		enum:      raw.Enum,
+		constant:  raw.Const,
+		required:  raw.Required,
+		minItems:  raw.MinItems,
+		maxItems:  raw.MaxItems,
+		minLength: raw.MinLength,
+		maxLength: raw.MaxLength,
+		minimum:   raw.Minimum,
+		maximum:   raw.Maximum,
+	}
+	if len(raw.Type) > 0 {
+		if err := json.Unmarshal(raw.Type, &s.types); err != nil {
+			var t string
+			if err := json.Unmarshal(raw.Type, &t); err != nil {
+				return nil, fmt.Errorf("invalid schema type: %s", raw.Type)
+			}
+			s.types = []string{t}
+		}
+	}
+	if raw.Pattern != nil {
+		re, err := regexp.Compile(*raw.Pattern)
+		if err != nil {
+			return nil, err
+		}
+		s.pattern = re
+	}
+	var err error
+	if len(raw.Properties) > 0 {
+		s.properties = make(map[string]*Schema, len(raw.Properties))
+		for name, p := range raw.Properties {
+			if s.properties[name], err = ParseSchema(p); err != nil {
+				return nil, err
+			}
+		}
+	}
+	switch string(raw.AdditionalProperties) {
+	case "", "true":
+	case "false":
+		s.noExtra = true
+	default:
+		if s.additional, err = ParseSchema(raw.AdditionalProperties); err != nil {
+			return nil, err
+		}
+	}
+	if len(raw.Items) > 0 {
+		if s.items, err = ParseSchema(raw.Items); err != nil {
+			return nil, err
+		}
+	}
+	for _, l := range []struct {
+		raw []json.RawMessage
+		dst *[]*Schema
+	}{{raw.AllOf, &s.allOf}, {raw.AnyOf, &s.anyOf}, {raw.OneOf, &s.oneOf}} {
+		for _, r := range l.raw {
+			sub, err := ParseSchema(r)
+			if err != nil {
+				return nil, err
+			}
+			*l.dst = append(*l.dst, sub)
+		}
+	}
+	return s, nil
+}
+
+// ValidateJSON reports whether data is a JSON document valid against s.
+// The error describes the first violation found.
+func (s *Schema) ValidateJSON(data []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	return s.validate("", v)
+}
+
+func (s *Schema) validate(path string, v interface{}) error {
+	if len(s.types) > 0 && !s.hasType(v) {
+		return schemaError(path, "expected %s, found %s", strings.Join(s.types, " or "), jsonType(v))
+	}
+	if s.enum != nil {
+		found := false
+		for _, e := range s.enum {
+			if reflect.DeepEqual(e, v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return schemaError(path, "value not in enum")
+		}
+	}
+	if s.constant != nil && !reflect.DeepEqual(*s.constant, v) {
+		return schemaError(path, "value does not equal const")
+	}
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for _, name := range s.required {
+			if _, ok := v[name]; !ok {
+				return schemaError(path, "missing required property %q", name)
+			}
+		}
+		for name, pv := range v {
+			ps, ok := s.properties[name]
+			switch {
+			case ok:
+			case s.noExtra:
+				return schemaError(path, "unexpected property %q", name)
+			case s.additional != nil:
+				ps = s.additional
+			default:
+				continue
+			}
+			if err := ps.validate(path+"/"+name, pv); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		if s.minItems != nil && len(v) < *s.minItems {
+			return schemaError(path, "expected at least %d items, found %d", *s.minItems, len(v))
+		}
+		if s.maxItems != nil && len(v) > *s.maxItems {
+			return schemaError(path, "expected at most %d items, found %d", *s.maxItems, len(v))
+		}
+		if s.items != nil {
+			for i, iv := range v {
+				if err := s.items.validate(fmt.Sprintf("%s/%d", path, i), iv); err != nil {
+					return err
+				}
+			}
+		}
+	case string:
+		n := len([]rune(v))
+		if s.minLength != nil && n < *s.minLength {
+			return schemaError(path, "expected at least %d characters, found %d", *s.minLength, n)
+		}
+		if s.maxLength != nil && n > *s.maxLength {
+			return schemaError(path, "expected at most %d characters, found %d", *s.maxLength, n)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(v) {
+			return schemaError(path, "value does not match %q", s.pattern)
+		}
+	case float64:
+		if s.minimum != nil && v < *s.minimum {
+			return schemaError(path, "expected minimum %v, found %v", *s.minimum, v)
+		}
+		if s.maximum != nil && v > *s.maximum {
+			return schemaError(path, "expected maximum %v, found %v", *s.maximum, v)
+		}
+	}
+
+	for _, sub := range s.allOf {
+		if err := sub.validate(path, v); err != nil {
+			return err
+		}
+	}
+	if len(s.anyOf) > 0 {
+		matched := false
+		for _, sub := range s.anyOf {
+			if sub.validate(path, v) == nil {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return schemaError(path, "value matches none of anyOf")
+		}
+	}
+	if len(s.oneOf) > 0 {
+		var matched int
+		for _, sub := range s.oneOf {
+			if sub.validate(path, v) == nil {
+				matched++
+			}
+		}
+		if matched != 1 {
+			return schemaError(path, "value matches %d of oneOf", matched)
+		}
+	}
+	return nil
+}
+
+func (s *Schema) hasType(v interface{}) bool {
+	actual := jsonType(v)
+	for _, t := range s.types {
+		if t == actual {
+			return true
+		}
+		if f, ok := v.(float64); ok && t == "integer" && f == math.Trunc(f) {
+			return true
+		}
+	}
+	return false
+}
+
+func jsonType(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	}
+	return "object"
+}
+
+func schemaError(path, format string, args ...interface{}) error {
+	if path == "" {
+		path = "/"
+	}
+	return fmt.Errorf("%s: %s", path, fmt.Sprintf(format, args...))
+}
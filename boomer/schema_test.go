@@ -0,0 +1,104 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+const userSchema = `{
+	"type": "object",
+	"required": ["id", "name"],
+	"additionalProperties": false,
+	"properties": {
+		"id": {"type": "integer", "minimum": 1},
+		"name": {"type": "string", "minLength": 1},
+		"tags": {"type": "array", "items": {"enum": ["admin", "user"]}}
+	}
+}`
+
+func TestValidateJSON(t *testing.T) {
+	schema, err := ParseSchema([]byte(userSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		doc   string
+		valid bool
+	}{
+		{`{"id": 1, "name": "jbd", "tags": ["admin"]}`, true},
+		{`{"id": 1.5, "name": "jbd"}`, false},
+		{`{"id": 0, "name": "jbd"}`, false},
+		{`{"id": 1}`, false},
+		{`{"id": 1, "name": ""}`, false},
+		{`{"id": 1, "name": "jbd", "tags": ["root"]}`, false},
+		{`{"id": 1, "name": "jbd", "email": "x"}`, false},
+		{`[]`, false},
+		{`<html>`, false},
+	}
+	for _, tt := range tests {
+		if err := schema.ValidateJSON([]byte(tt.doc)); (err == nil) != tt.valid {
+			t.Errorf("Validating %v: expected valid = %v, found error %v", tt.doc, tt.valid, err)
+		}
+	}
+}
+
+func TestParseSchemaUnsupported(t *testing.T) {
+	for _, doc := range []string{
+		`{"$ref": "#/definitions/user"}`,
+		`{"type": "string", "format": "email"}`,
+		`{"properties": {"id": {"multipleOf": 2}}}`,
+		`{"items": {"not": {"type": "null"}}}`,
+	} {
+		if _, err := ParseSchema([]byte(doc)); err == nil {
+			t.Errorf("Parsing %v: expected an error", doc)
+		}
+	}
+	if _, err := ParseSchema([]byte(`{"$schema": "http://json-schema.org/draft-07/schema#", "title": "user", "type": "object"}`)); err != nil {
+		t.Errorf("expected annotations to be accepted, found %v", err)
+	}
+}
+
+func TestSchema(t *testing.T) {
+	var count int64
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt64(&count, 1)%2 == 0 {
+			w.Write([]byte(`{"id": 1}`))
+			return
+		}
+		w.Write([]byte(`{"id": 1, "name": "jbd"}`))
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	schema, _ := ParseSchema([]byte(userSchema))
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	boomer := &Boomer{
+		Request: req,
+		N:       10,
+		C:       2,
+		Schema:  schema,
+	}
+	report := runBoomer(t, boomer)
+	if len(report.Schema) != 1 {
+		t.Fatalf("Expected schema results for 1 endpoint, found %v", len(report.Schema))
+	}
+	if s := report.Schema[0]; s.Validated != 10 || s.Failed != 5 || s.FirstError == "" {
+		t.Errorf("Expected 5 of 10 responses to fail validation, found %+v", s)
+	}
+}
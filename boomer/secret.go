@@ -0,0 +1,122 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// VaultSecret returns a function reading field of the secret at path
+// from HashiCorp Vault, e.g. path "secret/data/app" and field "token".
+// Both KV version 1 and 2 secrets are supported. The server and token
+// are given by VAULT_ADDR and VAULT_TOKEN, or ~/.vault-token. The
+// secret is read again when its lease expires or, for secrets without
+// a lease, every ttl if positive.
+func VaultSecret(path, field string, ttl time.Duration) func() (Token, error) {
+	return func() (Token, error) {
+		addr := os.Getenv("VAULT_ADDR")
+		if addr == "" {
+			return Token{}, errors.New("vault: VAULT_ADDR is not set")
+		}
+		token := os.Getenv("VAULT_TOKEN")
+		if token == "" {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return Token{}, err
+			}
+			data, err := ioutil.ReadFile(filepath.Join(home, ".vault-token"))
+			if err != nil {
+				return Token{}, errors.New("vault: VAULT_TOKEN is not set")
+			}
+			token = strings.TrimSpace(string(data))
+		}
+
+		req, err := http.NewRequest("GET", strings.TrimSuffix(addr, "/")+"/v1/"+strings.TrimPrefix(path, "/"), nil)
+		if err != nil {
+			return Token{}, err
+		}
+		req.Header.Set("X-Vault-Token", token)
+		resp, err := (&http.Client{Timeout: 10 * time.Second}).Do(req)
+		if err != nil {
+			return Token{}, err
+		}
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return Token{}, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return Token{}, fmt.Errorf("vault: reading %s: %s", path, resp.Status)
+		}
+
+		var v struct {
+			LeaseDuration int                    `json:"lease_duration"`
+			Data          map[string]interface{} `json:"data"`
+		}
+		if err := json.Unmarshal(body, &v); err != nil {
+			return Token{}, fmt.Errorf("vault: %v", err)
+		}
+		data := v.Data
+		if inner, ok := data["data"].(map[string]interface{}); ok {
+			if _, ok := data["metadata"]; ok {
+				data = inner
+			}
+		}
+		value, ok := data[field].(string)
+		if !ok {
+			return Token{}, fmt.Errorf("vault: secret %s has no string field %q", path, field)
+		}
+		if v.LeaseDuration > 0 {
+			ttl = time.Duration(v.LeaseDuration) * time.Second
+		}
+		return newToken(value, ttl), nil
+	}
+}
+
+// CommandSecret returns a function running command with the shell and
+// using its output, stripped of surrounding white space, as the secret.
+// The command is run again every ttl if positive.
+func CommandSecret(command string, ttl time.Duration) func() (Token, error) {
+	return func() (Token, error) {
+		var stderr bytes.Buffer
+		cmd := exec.Command("sh", "-c", command)
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return Token{}, fmt.Errorf("secret command: %v: %s", err, strings.TrimSpace(stderr.String()))
+		}
+		return newToken(strings.TrimSpace(string(out)), ttl), nil
+	}
+}
+
+// newToken returns a token valid for ttl if positive, or one that never
+// expires.
+func newToken(value string, ttl time.Duration) Token {
+	tok := Token{Value: value}
+	if ttl > 0 {
+		tok.Expires = time.Now().Add(ttl)
+	}
+	return tok
+}
@@ -0,0 +1,75 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestVaultSecret(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "root" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/app":
+			w.Write([]byte(`{"lease_duration":0,"data":{"data":{"token":"kv2"},"metadata":{"version":1}}}`))
+		case "/v1/database/creds/app":
+			w.Write([]byte(`{"lease_duration":3600,"data":{"password":"dynamic"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+	t.Setenv("VAULT_ADDR", server.URL)
+	t.Setenv("VAULT_TOKEN", "root")
+
+	tok, err := VaultSecret("secret/data/app", "token", 0)()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.Value != "kv2" || !tok.Expires.IsZero() {
+		t.Errorf("expected a non-expiring kv2 token, found %v", tok)
+	}
+	tok, err = VaultSecret("database/creds/app", "password", time.Minute)()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.Value != "dynamic" || tok.Expires.Before(time.Now().Add(59*time.Minute)) {
+		t.Errorf("expected a token expiring with its lease, found %v", tok)
+	}
+	if _, err := VaultSecret("secret/data/app", "missing", 0)(); err == nil {
+		t.Errorf("expected an error for a missing field")
+	}
+}
+
+func TestCommandSecret(t *testing.T) {
+	s := &TokenSource{Header: "X-Api-Key", Fetch: CommandSecret("echo '  key  '", time.Hour)}
+	req, _ := http.NewRequest("GET", "http://example.com", nil)
+	if err := s.apply(req, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if got := req.Header.Get("X-Api-Key"); got != "key" {
+		t.Errorf("expected header X-Api-Key to be key, found %q", got)
+	}
+	if _, err := CommandSecret("exit 1", 0)(); err == nil {
+		t.Errorf("expected an error for a failing command")
+	}
+}
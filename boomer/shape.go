@@ -0,0 +1,110 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"fmt"
+	"time"
+)
+
+// sawtoothSteps is the number of steps of each tooth of a sawtooth.
+const sawtoothSteps = 10
+
+// ShapeParams are the parameters of a built-in scenario shape. Those
+// left zero take the defaults of the shape.
+type ShapeParams struct {
+	// Rate is the rate of a soak, or the base rate of a spike or a
+	// sawtooth, and Peak the rate spiked or risen to, in requests per
+	// second.
+	Rate, Peak int
+
+	// Duration is how long the scenario runs for, and Period how long
+	// a spike or a tooth of a sawtooth lasts.
+	Duration, Period time.Duration
+}
+
+// Shapes lists the names of the built-in scenario shapes.
+var Shapes = []string{"soak", "spike", "sawtooth"}
+
+// ShapeStages returns the Stages of the built-in scenario shape name:
+//
+//	soak      Rate, 10 by default, for Duration, an hour by default.
+//	spike     Rate, a tenth of Peak by default, then Peak for Period, a
+//	          tenth of Duration by default, then Rate again, over
+//	          Duration, 5 minutes by default.
+//	sawtooth  Rising from Rate, a tenth of Peak by default, to Peak in
+//	          10 steps every Period, a minute by default, for
+//	          Duration, 10 minutes by default.
+func ShapeStages(name string, p ShapeParams) ([]Stage, error) {
+	if p.Rate < 0 || p.Peak < 0 || p.Duration < 0 || p.Period < 0 {
+		return nil, fmt.Errorf("%s: parameters cannot be negative", name)
+	}
+	switch name {
+	case "soak":
+		if p.Rate == 0 {
+			p.Rate = 10
+		}
+		if p.Duration == 0 {
+			p.Duration = time.Hour
+		}
+		return []Stage{{Rate: p.Rate, Duration: p.Duration}}, nil
+	case "spike", "sawtooth":
+	default:
+		return nil, fmt.Errorf("unknown shape %q, expected one of %v", name, Shapes)
+	}
+	if p.Peak == 0 {
+		return nil, fmt.Errorf("%s: no peak rate given", name)
+	}
+	if p.Rate == 0 {
+		p.Rate = p.Peak / 10
+	}
+	if name == "spike" {
+		if p.Duration == 0 {
+			p.Duration = 5 * time.Minute
+		}
+		if p.Period == 0 {
+			p.Period = p.Duration / 10
+		}
+		if p.Period >= p.Duration {
+			return nil, fmt.Errorf("spike: the spike must be shorter than the duration")
+		}
+		before := (p.Duration - p.Period) / 2
+		return []Stage{
+			{Rate: p.Rate, Duration: before},
+			{Rate: p.Peak, Duration: p.Period},
+			{Rate: p.Rate, Duration: p.Duration - p.Period - before},
+		}, nil
+	}
+	if p.Duration == 0 {
+		p.Duration = 10 * time.Minute
+	}
+	if p.Period == 0 {
+		p.Period = time.Minute
+	}
+	step := p.Period / sawtoothSteps
+	if step <= 0 {
+		return nil, fmt.Errorf("sawtooth: period too short")
+	}
+	var stages []Stage
+	for left, i := p.Duration, 0; left > 0; left, i = left-step, i+1 {
+		d := step
+		if left < d {
+			d = left
+		}
+		k := i % sawtoothSteps
+		stages = append(stages, Stage{Rate: p.Rate + (p.Peak-p.Rate)*k/(sawtoothSteps-1), Duration: d})
+	}
+	return stages, nil
+}
@@ -0,0 +1,43 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"testing"
+	"time"
+)
+
+func TestShapeStages(t *testing.T) {
+	stages, err := ShapeStages("sawtooth", ShapeParams{Peak: 100, Duration: 25 * time.Second, Period: 10 * time.Second})
+	if err != nil || len(stages) != 25 {
+		t.Fatalf("expected 25 steps of a second, found %v, %v", stages, err)
+	}
+	for i, want := range map[int]int{0: 10, 9: 100, 10: 10, 14: 50, 24: 50} {
+		if stages[i].Rate != want || stages[i].Duration != time.Second {
+			t.Errorf("expected step %d at %d req/s, found %v", i, want, stages[i])
+		}
+	}
+	stages, _ = ShapeStages("spike", ShapeParams{Peak: 500})
+	var total time.Duration
+	for _, s := range stages {
+		total += s.Duration
+	}
+	if total != 5*time.Minute || stages[1] != (Stage{Rate: 500, Duration: 30 * time.Second}) || stages[0].Rate != 50 {
+		t.Errorf("unexpected spike %v", stages)
+	}
+	if _, err := ShapeStages("spike", ShapeParams{Peak: 500, Period: time.Hour}); err == nil {
+		t.Errorf("expected an error for a spike longer than the run")
+	}
+}
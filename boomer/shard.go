@@ -0,0 +1,117 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"net/http"
+	"sort"
+)
+
+// slowShard is how many times the median p99 latency of all shards a
+// shard's must exceed for it to be flagged as slow.
+const slowShard = 1.5
+
+// ShardStats describes the requests sent to one of the Hosts of a run.
+type ShardStats struct {
+	Host     string `json:"host"`
+	Requests int    `json:"requests"`
+	Errors   int    `json:"errors"`
+
+	// Average, P50 and P99 are latencies of the successful requests, in
+	// ms.
+	Average float64 `json:"average"`
+	P50     float64 `json:"p50"`
+	P99     float64 `json:"p99"`
+
+	// Slow reports whether the p99 latency of the shard stands out,
+	// being more than 1.5 times the median across shards.
+	Slow bool `json:"slow,omitempty"`
+}
+
+// shardSamples holds the latencies, in ms, of the successful requests
+// to a shard and the number of failed ones.
+type shardSamples struct {
+	lats   []float64
+	errors int
+}
+
+// setHost sends req to host, along with its Host header unless it was
+// overridden.
+func setHost(req *http.Request, host string) {
+	if req.Host == "" || req.Host == req.URL.Host {
+		req.Host = host
+	}
+	u := *req.URL
+	u.Host = host
+	req.URL = &u
+}
+
+// shard returns the host req was sent to if requests are spread over
+// Hosts.
+func (b *Boomer) shard(req *http.Request) string {
+	if len(b.Hosts) == 0 {
+		return ""
+	}
+	return req.URL.Host
+}
+
+func (r *Report) addShard(res *result) {
+	s, ok := r.shards[res.shard]
+	if !ok {
+		s = &shardSamples{}
+		r.shards[res.shard] = s
+	}
+	if res.err != nil || res.timeout != "" {
+		s.errors++
+		return
+	}
+	s.lats = append(s.lats, res.duration.Seconds()*1000)
+}
+
+func (r *Report) printShards() {
+	r.Shards = nil
+	for host, s := range r.shards {
+		st := ShardStats{Host: host, Requests: len(s.lats) + s.errors, Errors: s.errors}
+		if len(s.lats) > 0 {
+			sort.Float64s(s.lats)
+			var sum float64
+			for _, lat := range s.lats {
+				sum += lat
+			}
+			st.Average = sum / float64(len(s.lats))
+			st.P50 = nearestRank(s.lats, 50)
+			st.P99 = nearestRank(s.lats, 99)
+		}
+		r.Shards = append(r.Shards, st)
+	}
+	sort.Slice(r.Shards, func(i, j int) bool {
+		return r.Shards[i].Host < r.Shards[j].Host
+	})
+	if len(r.Shards) < 2 {
+		return
+	}
+	p99s := make([]float64, len(r.Shards))
+	for i, s := range r.Shards {
+		p99s[i] = s.P99
+	}
+	sort.Float64s(p99s)
+	median := p99s[len(p99s)/2]
+	if len(p99s)%2 == 0 {
+		median = (p99s[len(p99s)/2-1] + median) / 2
+	}
+	for i := range r.Shards {
+		r.Shards[i].Slow = r.Shards[i].P99 > slowShard*median
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestHosts(t *testing.T) {
+	var hosts []string
+	for _, delay := range []time.Duration{0, 0, 30 * time.Millisecond} {
+		delay := delay
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(delay)
+		}))
+		defer server.Close()
+		u, _ := url.Parse(server.URL)
+		hosts = append(hosts, u.Host)
+	}
+
+	req, _ := http.NewRequest("GET", "http://"+hosts[0]+"/health", nil)
+	r := runBoomer(t, &Boomer{Request: req, N: 30, C: 3, Hosts: hosts})
+	if len(r.Shards) != 3 {
+		t.Fatalf("expected 3 shards, found %+v", r.Shards)
+	}
+	for _, s := range r.Shards {
+		if s.Requests != 10 || s.Errors != 0 {
+			t.Errorf("expected 10 requests to %v, found %+v", s.Host, s)
+		}
+		if want := s.Host == hosts[2]; s.Slow != want {
+			t.Errorf("expected %v to be slow: %v, found %+v", s.Host, want, s)
+		}
+	}
+}
@@ -0,0 +1,392 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	sigV4Algorithm = "AWS4-HMAC-SHA256"
+	amzDateFormat  = "20060102T150405Z"
+
+	defaultIMDSEndpoint = "http://169.254.169.254"
+)
+
+// AWSCredentials are the credentials requests are signed with.
+type AWSCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+
+	// Expires is when temporary credentials stop being valid. It is
+	// zero for long-term credentials.
+	Expires time.Time
+}
+
+// SigV4 signs requests with AWS Signature Version 4, so that endpoints
+// behind IAM authorization such as API Gateway, S3 or OpenSearch can be
+// loaded directly.
+type SigV4 struct {
+	// Region and Service make up the credential scope, e.g. "us-east-1"
+	// and "execute-api".
+	Region  string
+	Service string
+
+	// Credentials retrieves the credentials to sign with. They are
+	// cached until shortly before they expire.
+	Credentials func() (AWSCredentials, error)
+
+	mu    sync.Mutex
+	creds *AWSCredentials
+}
+
+// retrieve returns the cached credentials, refreshing them if they are
+// about to expire.
+func (s *SigV4) retrieve(now time.Time) (AWSCredentials, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.creds != nil && (s.creds.Expires.IsZero() || now.Add(time.Minute).Before(s.creds.Expires)) {
+		return *s.creds, nil
+	}
+	creds, err := s.Credentials()
+	if err != nil {
+		return AWSCredentials{}, err
+	}
+	s.creds = &creds
+	return creds, nil
+}
+
+// Check retrieves the credentials, reporting whether requests can be
+// signed.
+func (s *SigV4) Check() error {
+	_, err := s.retrieve(time.Now())
+	return err
+}
+
+// Sign adds the signature headers to req. The body of req is read to
+// be hashed, and replaced.
+func (s *SigV4) Sign(req *http.Request, now time.Time) error {
+	creds, err := s.retrieve(now)
+	if err != nil {
+		return err
+	}
+	var body []byte
+	if req.Body != nil {
+		if body, err = ioutil.ReadAll(req.Body); err != nil {
+			return err
+		}
+		req.Body.Close()
+		setBody(req, bytes.NewReader(body))
+	}
+	sum := sha256.Sum256(body)
+	payloadHash := hex.EncodeToString(sum[:])
+
+	now = now.UTC()
+	amzDate := now.Format(amzDateFormat)
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+	if s.Service == "s3" {
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+
+	headers, signed := canonicalHeaders(req)
+	canonical := strings.Join([]string{
+		req.Method,
+		s.canonicalPath(req.URL),
+		canonicalQuery(req.URL),
+		headers,
+		signed,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{now.Format("20060102"), s.Region, s.Service, "aws4_request"}, "/")
+	sum = sha256.Sum256([]byte(canonical))
+	stringToSign := strings.Join([]string{sigV4Algorithm, amzDate, scope, hex.EncodeToString(sum[:])}, "\n")
+
+	key := []byte("AWS4" + creds.SecretAccessKey)
+	for _, v := range []string{now.Format("20060102"), s.Region, s.Service, "aws4_request"} {
+		key = hmacSHA256(key, v)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigV4Algorithm, creds.AccessKeyID, scope, signed, signature))
+	return nil
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// canonicalPath returns the URI-encoded path of u. Services other than
+// S3 expect every segment to be encoded twice.
+func (s *SigV4) canonicalPath(u *url.URL) string {
+	path := u.Path
+	if path == "" {
+		return "/"
+	}
+	segments := strings.Split(path, "/")
+	for i, seg := range segments {
+		seg = awsEscape(seg)
+		if s.Service != "s3" {
+			seg = awsEscape(seg)
+		}
+		segments[i] = seg
+	}
+	return strings.Join(segments, "/")
+}
+
+// canonicalQuery returns the query of u with its parameters encoded
+// and sorted by key, then value. Sorting the joined parameters instead
+// would put page2=b before page=a.
+func canonicalQuery(u *url.URL) string {
+	var params [][2]string
+	for k, vs := range u.Query() {
+		for _, v := range vs {
+			params = append(params, [2]string{awsEscape(k), awsEscape(v)})
+		}
+	}
+	sort.Slice(params, func(i, j int) bool {
+		if params[i][0] != params[j][0] {
+			return params[i][0] < params[j][0]
+		}
+		return params[i][1] < params[j][1]
+	})
+	joined := make([]string, len(params))
+	for i, p := range params {
+		joined[i] = p[0] + "=" + p[1]
+	}
+	return strings.Join(joined, "&")
+}
+
+// canonicalHeaders returns the canonical headers block and the list of
+// signed header names. All the headers of req are signed.
+func canonicalHeaders(req *http.Request) (string, string) {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	values := map[string]string{"host": host}
+	for k, vs := range req.Header {
+		trimmed := make([]string, len(vs))
+		for i, v := range vs {
+			trimmed[i] = strings.Join(strings.Fields(v), " ")
+		}
+		values[strings.ToLower(k)] = strings.Join(trimmed, ",")
+	}
+	names := make([]string, 0, len(values))
+	for k := range values {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, k := range names {
+		b.WriteString(k + ":" + values[k] + "\n")
+	}
+	return b.String(), strings.Join(names, ";")
+}
+
+// awsEscape percent-encodes every byte of s but the unreserved
+// characters, as SigV4 requires.
+func awsEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+// DefaultAWSCredentials returns a function retrieving credentials the
+// way the AWS CLI does: from the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY
+// and AWS_SESSION_TOKEN environment variables, else from the profile of
+// the shared credentials file, else from the EC2 instance metadata
+// service. If profile is empty, AWS_PROFILE or "default" is used.
+func DefaultAWSCredentials(profile string) func() (AWSCredentials, error) {
+	return func() (AWSCredentials, error) {
+		if id := os.Getenv("AWS_ACCESS_KEY_ID"); id != "" {
+			return AWSCredentials{
+				AccessKeyID:     id,
+				SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+				SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+			}, nil
+		}
+		creds, err := sharedCredentials(profile)
+		if err == nil || profile != "" {
+			return creds, err
+		}
+		creds, imdsErr := imdsCredentials()
+		if imdsErr != nil {
+			return AWSCredentials{}, fmt.Errorf("no AWS credentials found: %v; %v", err, imdsErr)
+		}
+		return creds, nil
+	}
+}
+
+// sharedCredentials reads the credentials of profile from the shared
+// credentials file, ~/.aws/credentials unless overridden by
+// AWS_SHARED_CREDENTIALS_FILE.
+func sharedCredentials(profile string) (AWSCredentials, error) {
+	if profile == "" {
+		if profile = os.Getenv("AWS_PROFILE"); profile == "" {
+			profile = "default"
+		}
+	}
+	path := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return AWSCredentials{}, err
+		}
+		path = filepath.Join(home, ".aws", "credentials")
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return AWSCredentials{}, err
+	}
+	defer f.Close()
+
+	var creds AWSCredentials
+	var section string
+	found := false
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		switch {
+		case line == "", line[0] == '#', line[0] == ';':
+		case line[0] == '[' && line[len(line)-1] == ']':
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			if section == profile {
+				found = true
+			}
+		case section == profile:
+			kv := strings.SplitN(line, "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			v := strings.TrimSpace(kv[1])
+			switch strings.TrimSpace(kv[0]) {
+			case "aws_access_key_id":
+				creds.AccessKeyID = v
+			case "aws_secret_access_key":
+				creds.SecretAccessKey = v
+			case "aws_session_token":
+				creds.SessionToken = v
+			}
+		}
+	}
+	if err := s.Err(); err != nil {
+		return AWSCredentials{}, err
+	}
+	if !found || creds.AccessKeyID == "" {
+		return AWSCredentials{}, fmt.Errorf("profile %q not found in %s", profile, path)
+	}
+	return creds, nil
+}
+
+// imdsCredentials retrieves the credentials of the instance role from
+// the EC2 instance metadata service, using IMDSv2 session tokens. The
+// endpoint can be overridden by AWS_EC2_METADATA_SERVICE_ENDPOINT.
+func imdsCredentials() (AWSCredentials, error) {
+	endpoint := os.Getenv("AWS_EC2_METADATA_SERVICE_ENDPOINT")
+	if endpoint == "" {
+		endpoint = defaultIMDSEndpoint
+	}
+	endpoint = strings.TrimSuffix(endpoint, "/")
+	client := &http.Client{Timeout: 2 * time.Second}
+
+	get := func(method, path string, header http.Header) ([]byte, error) {
+		req, err := http.NewRequest(method, endpoint+path, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header = header
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("instance metadata %s: %s", path, resp.Status)
+		}
+		return body, nil
+	}
+
+	token, err := get("PUT", "/latest/api/token", http.Header{
+		"X-Aws-Ec2-Metadata-Token-Ttl-Seconds": {"21600"},
+	})
+	if err != nil {
+		return AWSCredentials{}, err
+	}
+	header := http.Header{"X-Aws-Ec2-Metadata-Token": {string(token)}}
+	const credsPath = "/latest/meta-data/iam/security-credentials/"
+	role, err := get("GET", credsPath, header)
+	if err != nil {
+		return AWSCredentials{}, err
+	}
+	name := strings.TrimSpace(strings.SplitN(string(role), "\n", 2)[0])
+	if name == "" {
+		return AWSCredentials{}, errors.New("no instance role found")
+	}
+	data, err := get("GET", credsPath+name, header)
+	if err != nil {
+		return AWSCredentials{}, err
+	}
+	var v struct {
+		AccessKeyID     string `json:"AccessKeyId"`
+		SecretAccessKey string
+		Token           string
+		Expiration      time.Time
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return AWSCredentials{}, err
+	}
+	return AWSCredentials{
+		AccessKeyID:     v.AccessKeyID,
+		SecretAccessKey: v.SecretAccessKey,
+		SessionToken:    v.Token,
+		Expires:         v.Expiration,
+	}, nil
+}
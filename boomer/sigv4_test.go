@@ -0,0 +1,142 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// The test vectors come from the AWS SigV4 test suite, get-vanilla,
+// get-vanilla-query-order-key-case, get-vanilla-query-order-key and
+// get-vanilla-query-order-value, but the last, whose keys share a
+// prefix.
+func TestSigV4Sign(t *testing.T) {
+	s := &SigV4{
+		Region:  "us-east-1",
+		Service: "service",
+		Credentials: func() (AWSCredentials, error) {
+			return AWSCredentials{
+				AccessKeyID:     "AKIDEXAMPLE",
+				SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+			}, nil
+		},
+	}
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	tests := []struct {
+		url       string
+		signature string
+	}{
+		{"https://example.amazonaws.com/", "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"},
+		{"https://example.amazonaws.com/?Param2=value2&Param1=value1", "b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500"},
+		{"https://example.amazonaws.com/?Param1=value2&Param1=Value1", "eedbc4e291e521cf13422ffca22be7d2eb8146eecf653089df300a15b2382bd1"},
+		{"https://example.amazonaws.com/?Param1=value2&Param1=value1", "5772eed61e12b33fae39ee5e7012498b51d56abc0abb7c60486157bd471c4694"},
+		{"https://example.amazonaws.com/?page2=b&page=a", "069a4fa0e5f9bc1c7d84697602f590e9cb3a50264ad69f51c03a5fd35b41be9a"},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest("GET", tt.url, nil)
+		if err := s.Sign(req, now); err != nil {
+			t.Fatal(err)
+		}
+		want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+			"SignedHeaders=host;x-amz-date, Signature=" + tt.signature
+		if got := req.Header.Get("Authorization"); got != want {
+			t.Errorf("%v: expected Authorization %q, found %q", tt.url, want, got)
+		}
+	}
+}
+
+func TestSharedCredentials(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials")
+	data := "[default]\naws_access_key_id = A\naws_secret_access_key = B\n\n" +
+		"[load]\naws_access_key_id=C\naws_secret_access_key=D\naws_session_token=E\n"
+	if err := ioutil.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", path)
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+
+	creds, err := DefaultAWSCredentials("load")()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := AWSCredentials{AccessKeyID: "C", SecretAccessKey: "D", SessionToken: "E"}
+	if creds != want {
+		t.Errorf("expected %v, found %v", want, creds)
+	}
+	if _, err := DefaultAWSCredentials("missing")(); err == nil {
+		t.Errorf("expected an error for a missing profile")
+	}
+}
+
+func TestIMDSCredentials(t *testing.T) {
+	var fetches int
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/latest/api/token":
+			if r.Method != "PUT" {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			w.Write([]byte("token"))
+			return
+		}
+		if r.Header.Get("X-Aws-Ec2-Metadata-Token") != "token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/latest/meta-data/iam/security-credentials/":
+			w.Write([]byte("role\n"))
+		case "/latest/meta-data/iam/security-credentials/role":
+			fetches++
+			w.Write([]byte(`{"AccessKeyId":"A","SecretAccessKey":"B","Token":"C","Expiration":"2030-01-01T00:00:00Z"}`))
+		}
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+	t.Setenv("AWS_EC2_METADATA_SERVICE_ENDPOINT", server.URL)
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "missing"))
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_PROFILE", "")
+
+	s := &SigV4{Region: "us-east-1", Service: "s3", Credentials: DefaultAWSCredentials("")}
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest("GET", "https://bucket.s3.amazonaws.com/key", nil)
+		if err := s.Sign(req, time.Date(2029, 12, 31, 23, 0, 0, 0, time.UTC)); err != nil {
+			t.Fatal(err)
+		}
+		if got := req.Header.Get("X-Amz-Security-Token"); got != "C" {
+			t.Errorf("expected the session token to be sent, found %q", got)
+		}
+		if req.Header.Get("X-Amz-Content-Sha256") == "" {
+			t.Errorf("expected the payload hash to be sent to s3")
+		}
+	}
+	if fetches != 1 {
+		t.Errorf("expected credentials to be fetched once, found %d", fetches)
+	}
+	req, _ := http.NewRequest("GET", "https://bucket.s3.amazonaws.com/key", nil)
+	if err := s.Sign(req, time.Date(2029, 12, 31, 23, 59, 30, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+	if fetches != 2 {
+		t.Errorf("expected expiring credentials to be refreshed")
+	}
+}
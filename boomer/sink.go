@@ -0,0 +1,151 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Sink receives the Record of every request as its result is collected
+// and the report once the run is done, e.g. to write results out while
+// the run is in progress. Its methods are called from a single
+// goroutine. A sink that fails is no longer called, and its error is
+// noted in the report's SinkErrors.
+type Sink interface {
+	Record(Record) error
+	Close(*Report) error
+}
+
+// sinkFlushInterval is the longest buffered sinks hold records before
+// writing them.
+const sinkFlushInterval = time.Second
+
+// jsonlSink writes records as JSON lines.
+type jsonlSink struct {
+	w     *bufio.Writer
+	enc   *json.Encoder
+	flush time.Time
+
+	// requestsOnly leaves out the summary line.
+	requestsOnly bool
+}
+
+// NewJSONLSink returns a sink writing a JSON object per line to w: one
+// of type "request" per request, as it completes, and one of type
+// "summary" holding the report once the run is done. Lines are flushed
+// at least every second, so all but the last records survive a crash.
+func NewJSONLSink(w io.Writer) Sink {
+	bw := bufio.NewWriter(w)
+	return &jsonlSink{w: bw, enc: json.NewEncoder(bw), flush: time.Now()}
+}
+
+// NewRequestLogSink returns a sink writing the "request" lines of
+// NewJSONLSink to w, without the summary, to log the requests of a run
+// alongside its report.
+func NewRequestLogSink(w io.Writer) Sink {
+	s := NewJSONLSink(w).(*jsonlSink)
+	s.requestsOnly = true
+	return s
+}
+
+// jsonlRecord is the line a request is written as.
+type jsonlRecord struct {
+	Type       string            `json:"type"`
+	Start      time.Time         `json:"start"`
+	Duration   float64           `json:"duration"`
+	Endpoint   string            `json:"endpoint"`
+	StatusCode int               `json:"status_code,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	Size       int64             `json:"size"`
+	Aborted    string            `json:"aborted,omitempty"`
+	Reused     bool              `json:"reused,omitempty"`
+	Family     string            `json:"family,omitempty"`
+	Tags       map[string]string `json:"tags,omitempty"`
+}
+
+func (s *jsonlSink) Record(rec Record) error {
+	line := jsonlRecord{
+		Type:       "request",
+		Start:      rec.Start,
+		Duration:   rec.Duration.Seconds() * 1000,
+		Endpoint:   rec.Endpoint,
+		StatusCode: rec.StatusCode,
+		Size:       rec.Size,
+		Aborted:    rec.Aborted,
+		Reused:     rec.Reused,
+		Family:     rec.Family,
+		Tags:       rec.Tags,
+	}
+	if rec.Err != nil {
+		line.Error = rec.Err.Error()
+	}
+	if err := s.enc.Encode(line); err != nil {
+		return err
+	}
+	if now := time.Now(); now.Sub(s.flush) >= sinkFlushInterval {
+		s.flush = now
+		return s.w.Flush()
+	}
+	return nil
+}
+
+func (s *jsonlSink) Close(r *Report) error {
+	if s.requestsOnly {
+		return s.w.Flush()
+	}
+	err := s.enc.Encode(struct {
+		Type   string  `json:"type"`
+		Report *Report `json:"report"`
+	}{"summary", r})
+	if err != nil {
+		return err
+	}
+	return s.w.Flush()
+}
+
+// sinks holds the sinks of a run and the errors of those that failed.
+type sinks struct {
+	sinks  []Sink
+	failed []error
+}
+
+func newSinks(s []Sink) *sinks {
+	return &sinks{sinks: s, failed: make([]error, len(s))}
+}
+
+func (s *sinks) record(rec Record) {
+	for i, sink := range s.sinks {
+		if s.failed[i] == nil {
+			s.failed[i] = sink.Record(rec)
+		}
+	}
+}
+
+// close closes the sinks with r, noting the errors of all those that
+// failed in r.
+func (s *sinks) close(r *Report) {
+	for i, sink := range s.sinks {
+		if s.failed[i] == nil {
+			s.failed[i] = sink.Close(r)
+		}
+		if s.failed[i] != nil {
+			r.SinkErrors = append(r.SinkErrors, fmt.Sprintf("sink %d: %v", i, s.failed[i]))
+		}
+	}
+}
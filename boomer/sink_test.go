@@ -0,0 +1,146 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJSONLSink(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	var buf bytes.Buffer
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	boomer := &Boomer{
+		Request: req,
+		N:       5,
+		C:       2,
+		Tags:    map[string]string{"run": "a"},
+		Sinks:   []Sink{NewJSONLSink(&buf)},
+	}
+	runBoomer(t, boomer)
+
+	var requests int
+	var summary *Report
+	s := bufio.NewScanner(&buf)
+	for s.Scan() {
+		var line struct {
+			Type       string            `json:"type"`
+			StatusCode int               `json:"status_code"`
+			Tags       map[string]string `json:"tags"`
+			Report     *Report           `json:"report"`
+		}
+		if err := json.Unmarshal(s.Bytes(), &line); err != nil {
+			t.Fatalf("invalid line %q: %v", s.Text(), err)
+		}
+		switch line.Type {
+		case "request":
+			if summary != nil {
+				t.Errorf("unexpected request after the summary")
+			}
+			if line.StatusCode != 200 || line.Tags["run"] != "a" {
+				t.Errorf("unexpected request line %q", s.Text())
+			}
+			requests++
+		case "summary":
+			summary = line.Report
+		default:
+			t.Errorf("unexpected line %q", s.Text())
+		}
+	}
+	if requests != 5 || summary == nil || summary.Responses() != 5 {
+		t.Errorf("expected 5 requests and a summary of 5 responses, found %v and %+v", requests, summary)
+	}
+}
+
+func TestRequestLogSink(t *testing.T) {
+	var buf bytes.Buffer
+	s := NewRequestLogSink(&buf)
+	s.Record(Record{Start: time.Unix(100, 0).UTC(), Duration: 2 * time.Millisecond, Endpoint: "http://a/", StatusCode: 200, Size: 3})
+	s.Record(Record{Start: time.Unix(101, 0).UTC(), Duration: time.Millisecond, Endpoint: "http://a/", Err: errors.New("refused"), Size: -1})
+	if err := s.Close(testReport()); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"type":"request","start":"1970-01-01T00:01:40Z","duration":2,"endpoint":"http://a/","status_code":200,"size":3}
+{"type":"request","start":"1970-01-01T00:01:41Z","duration":1,"endpoint":"http://a/","error":"refused","size":-1}
+`
+	if buf.String() != want {
+		t.Errorf("expected\n%s\nfound\n%s", want, buf.String())
+	}
+}
+
+func TestCSVSink(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	var buf bytes.Buffer
+	req, _ := http.NewRequest("GET", server.URL+"/missing", nil)
+	runBoomer(t, &Boomer{Request: req, N: 3, C: 1, Sinks: []Sink{NewCSVSink(&buf)}})
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 4 || strings.Join(rows[0], ",") != "start,endpoint,latency_ms,status,error,bytes,aborted" {
+		t.Fatalf("expected a header and 3 rows, found %v", rows)
+	}
+	for _, row := range rows[1:] {
+		if _, err := time.Parse(time.RFC3339Nano, row[0]); err != nil || row[3] != "404" || row[4] != "" {
+			t.Errorf("unexpected row %v", row)
+		}
+		if lat, err := strconv.ParseFloat(row[2], 64); err != nil || lat <= 0 {
+			t.Errorf("expected a latency, found %q", row[2])
+		}
+	}
+}
+
+type failingSink struct{ records int }
+
+func (s *failingSink) Record(Record) error {
+	s.records++
+	return errors.New("disk full")
+}
+
+func (s *failingSink) Close(*Report) error { return nil }
+
+func TestFailingSink(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	sink := &failingSink{}
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	r := runBoomer(t, &Boomer{Request: req, N: 5, C: 1, Sinks: []Sink{sink}})
+	if sink.records != 1 {
+		t.Errorf("expected the failed sink to get 1 record, found %v", sink.records)
+	}
+	if len(r.SinkErrors) != 1 || r.SinkErrors[0] != "sink 0: disk full" {
+		t.Errorf("expected the sink error to be reported, found %v", r.SinkErrors)
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import "math"
+
+// sketchGrowth is how much wider each bucket of a Sketch is than the
+// previous one, bounding the relative error of its quantiles to half
+// of 1%.
+var sketchGrowth = math.Log(1.01)
+
+// Sketch counts latencies in buckets growing exponentially, each 1%
+// wider than the previous one. It estimates quantiles within 1% using
+// memory bounded by the range of the latencies rather than by their
+// number, about 17KB from a microsecond to an hour, and merges exactly.
+type Sketch struct {
+	// Counts holds the number of latencies in the buckets from Offset
+	// on. Bucket i holds latencies from 1.01^i to 1.01^(i+1)
+	// microseconds.
+	Offset int     `json:"offset"`
+	Counts []int64 `json:"counts"`
+
+	// Zero is the number of latencies under a microsecond.
+	Zero int64 `json:"zero,omitempty"`
+}
+
+// add counts the latency, in ms.
+func (s *Sketch) add(lat float64) {
+	s.addCount(lat*1000, 1)
+}
+
+func (s *Sketch) addCount(us float64, n int64) {
+	if us < 1 {
+		s.Zero += n
+		return
+	}
+	s.addBucket(int(math.Log(us)/sketchGrowth), n)
+}
+
+func (s *Sketch) addBucket(i int, n int64) {
+	switch {
+	case len(s.Counts) == 0:
+		s.Offset, s.Counts = i, []int64{0}
+	case i < s.Offset:
+		s.Counts = append(make([]int64, s.Offset-i), s.Counts...)
+		s.Offset = i
+	case i >= s.Offset+len(s.Counts):
+		s.Counts = append(s.Counts, make([]int64, i-s.Offset-len(s.Counts)+1)...)
+	}
+	s.Counts[i-s.Offset] += n
+}
+
+// merge adds the counts of o to s.
+func (s *Sketch) merge(o *Sketch) {
+	if o == nil {
+		return
+	}
+	s.Zero += o.Zero
+	for i, n := range o.Counts {
+		if n > 0 {
+			s.addBucket(o.Offset+i, n)
+		}
+	}
+}
+
+// count returns the number of latencies counted.
+func (s *Sketch) count() int64 {
+	if s == nil {
+		return 0
+	}
+	n := s.Zero
+	for _, c := range s.Counts {
+		n += c
+	}
+	return n
+}
+
+// value returns the latency, in ms, bucket i stands for: its geometric
+// middle.
+func (s *Sketch) value(i int) float64 {
+	return math.Exp((float64(i)+0.5)*sketchGrowth) / 1000
+}
+
+// quantile returns the p percentile, in ms, of the non-empty sketch by
+// nearest rank.
+func (s *Sketch) quantile(p float64) float64 {
+	rank := int64(math.Ceil(p/100*float64(s.count()) - 1e-9))
+	cum := s.Zero
+	if cum >= rank {
+		return 0
+	}
+	for i, c := range s.Counts {
+		if cum += c; cum >= rank {
+			return s.value(s.Offset + i)
+		}
+	}
+	return s.value(s.Offset + len(s.Counts) - 1)
+}
+
+// copy returns a copy of s that does not share its counts.
+func (s *Sketch) copy() *Sketch {
+	if s == nil {
+		return nil
+	}
+	c := *s
+	c.Counts = append([]int64(nil), s.Counts...)
+	return &c
+}
+
+// fromLats reports whether the latency statistics of the report are
+// computed from Lats, which is the case if they were all retained or
+// if there is no sketch, as in reports of earlier versions.
+func (r *Report) fromLats() bool {
+	return len(r.Lats) > 0 && int64(len(r.Lats)) >= r.Sketch.count()
+}
+
+// sketchPercentile returns the p percentile of the latencies counted
+// by the sketch, within the extremes observed.
+func (r *Report) sketchPercentile(p float64) float64 {
+	return math.Min(math.Max(r.Sketch.quantile(p), r.Fastest), r.Slowest)
+}
+
+// printSketch computes the latency distribution and histogram from the
+// sketch.
+func (r *Report) printSketch() {
+	for _, p := range []int{10, 25, 50, 75, 90, 95, 99} {
+		r.Percentiales = append(r.Percentiales, Percential{Percent: p, Count: r.sketchPercentile(float64(p))})
+	}
+
+	bc := 10
+	buckets := make([]float64, bc+1)
+	counts := make([]int64, bc+1)
+	bs := (r.Slowest - r.Fastest) / float64(bc)
+	for i := 0; i < bc; i++ {
+		buckets[i] = r.Fastest + bs*float64(i)
+	}
+	buckets[bc] = r.Slowest
+	counts[0] = r.Sketch.Zero
+	for i, c := range r.Sketch.Counts {
+		v := r.Sketch.value(r.Sketch.Offset + i)
+		bi := 0
+		for bi < bc && v > buckets[bi] {
+			bi++
+		}
+		counts[bi] += c
+	}
+	for i := range buckets {
+		r.Histogram = append(r.Histogram, Bucket{Bucket: buckets[i], Count: int(counts[i])})
+	}
+}
+
+// hasLatencies reports whether the report holds any latency.
+func (r *Report) hasLatencies() bool {
+	return len(r.Lats) > 0 || r.Sketch.count() > 0
+}
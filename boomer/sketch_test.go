@@ -0,0 +1,97 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestSketchQuantiles(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	var lats []float64
+	a, b := &Sketch{}, &Sketch{}
+	for i := 0; i < 100000; i++ {
+		lat := math.Exp(rnd.NormFloat64()) * 50
+		lats = append(lats, lat)
+		if i%2 == 0 {
+			a.add(lat)
+		} else {
+			b.add(lat)
+		}
+	}
+	a.merge(b)
+	sort.Float64s(lats)
+	if a.count() != 100000 {
+		t.Fatalf("expected 100000 latencies, found %v", a.count())
+	}
+	for _, p := range []float64{1, 10, 50, 90, 99, 99.9} {
+		want := nearestRank(lats, p)
+		if got := a.quantile(p); math.Abs(got-want)/want > 0.01 {
+			t.Errorf("p%v: expected %v within 1%%, found %v", p, want, got)
+		}
+	}
+}
+
+func TestSketchBounded(t *testing.T) {
+	s := &Sketch{}
+	for lat := 1e-3; lat < float64(time.Hour/time.Millisecond); lat *= 1.001 {
+		s.add(lat)
+	}
+	s.add(1e-6)
+	if len(s.Counts) > 2300 || s.Zero != 1 {
+		t.Errorf("expected at most 2300 buckets from 1µs to an hour, found %v", len(s.Counts))
+	}
+}
+
+func TestRunSketch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	var reports []*Report
+	for i := 0; i < 2; i++ {
+		r := runBoomer(t, &Boomer{Request: req, N: 50, C: 2})
+		if len(r.Lats) != 0 || r.Sketch.count() != 50 || len(r.Percentiales) != 7 || len(r.Histogram) != 11 {
+			t.Fatalf("expected 50 latencies in the sketch only, found %v in Lats and %+v", len(r.Lats), r.Sketch)
+		}
+		if p := r.Percentile(99); p < r.Fastest || p > r.Slowest {
+			t.Errorf("expected p99 between %v and %v, found %v", r.Fastest, r.Slowest, p)
+		}
+		data, err := json.Marshal(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded Report
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		reports = append(reports, &decoded)
+	}
+	m := Merge(reports...)
+	var n int
+	for _, b := range m.Histogram {
+		n += b.Count
+	}
+	if m.Sketch.count() != 100 || n != 100 || m.Percentile(50) <= 0 {
+		t.Errorf("expected the merged sketch to count 100 latencies, found %v in a histogram of %v", m.Sketch.count(), n)
+	}
+}
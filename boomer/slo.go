@@ -0,0 +1,151 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultSLOPeriod is the period error budgets are defined over if an
+// SLO gives none.
+const DefaultSLOPeriod = 30 * 24 * time.Hour
+
+// SLO is a service level objective: a Target fraction of requests, e.g.
+// 0.99, should get a response other than a server error, within Latency
+// if it is set. Target must be below 1, leaving an error budget.
+type SLO struct {
+	Target  float64
+	Latency time.Duration
+
+	// Period is the period the error budget is defined over,
+	// DefaultSLOPeriod if zero.
+	Period time.Duration
+}
+
+// good reports whether res meets the objective. Requests that failed,
+// timed out or got a server error do not.
+func (s SLO) good(res *result) bool {
+	if res.err != nil || res.timeout != "" || res.statusCode >= 500 {
+		return false
+	}
+	return s.Latency <= 0 || res.duration <= s.Latency
+}
+
+// burnWindows are the windows of the multiwindow burn rate alerts of
+// the SRE workbook, with the fraction of the period's error budget that
+// must be spent within each to alert.
+var burnWindows = []struct {
+	window time.Duration
+	budget float64
+}{
+	{time.Hour, 0.02},
+	{6 * time.Hour, 0.05},
+	{24 * time.Hour, 0.1},
+	{72 * time.Hour, 0.1},
+}
+
+// SLOStats describes the traffic of a run against an SLO.
+type SLOStats struct {
+	// Target and Latency, in ms, are those of the SLO.
+	Target  float64 `json:"target"`
+	Latency float64 `json:"latency,omitempty"`
+
+	// Period is the error budget period, in hours.
+	Period float64 `json:"period"`
+
+	// Total and Bad are the number of requests made and of those that
+	// did not meet the objective. Aborted requests are not counted.
+	Total int `json:"total"`
+	Bad   int `json:"bad"`
+
+	// BurnRate is the rate at which traffic like that of the run would
+	// spend the error budget: 1 spends it exactly over the period.
+	BurnRate float64 `json:"burn_rate"`
+
+	// Exhausted is the number of hours after which the budget would be
+	// spent at BurnRate, or zero if it never would.
+	Exhausted float64 `json:"exhausted,omitempty"`
+
+	// Windows lists the standard alerting windows.
+	Windows []BurnWindow `json:"windows"`
+
+	slo SLO
+}
+
+// BurnWindow describes the burn rate alert of a window.
+type BurnWindow struct {
+	// Window is the length of the window, in hours.
+	Window float64 `json:"window"`
+
+	// Threshold is the burn rate that alerts within the window.
+	Threshold float64 `json:"threshold"`
+
+	// BudgetSpent is the fraction of the error budget spent within the
+	// window at BurnRate.
+	BudgetSpent float64 `json:"budget_spent"`
+
+	// Alert reports whether BurnRate reaches Threshold.
+	Alert bool `json:"alert"`
+}
+
+func newSLOStats(slo SLO) *SLOStats {
+	if slo.Period <= 0 {
+		slo.Period = DefaultSLOPeriod
+	}
+	return &SLOStats{
+		Target:  slo.Target,
+		Latency: slo.Latency.Seconds() * 1000,
+		Period:  slo.Period.Hours(),
+		slo:     slo,
+	}
+}
+
+// String describes the objective, e.g. "99% under 300ms".
+func (s *SLOStats) String() string {
+	str := fmt.Sprintf("%v%%", s.Target*100)
+	if s.Latency > 0 {
+		str += fmt.Sprintf(" under %v", time.Duration(s.Latency*float64(time.Millisecond)))
+	}
+	return str
+}
+
+func (s *SLOStats) add(res *result) {
+	s.Total++
+	if !s.slo.good(res) {
+		s.Bad++
+	}
+}
+
+// compute derives the burn rates from the request counts.
+func (s *SLOStats) compute() {
+	s.BurnRate, s.Exhausted, s.Windows = 0, 0, nil
+	if budget := 1 - s.Target; s.Total > 0 && budget > 0 {
+		s.BurnRate = float64(s.Bad) / float64(s.Total) / budget
+	}
+	if s.BurnRate > 0 {
+		s.Exhausted = s.Period / s.BurnRate
+	}
+	for _, w := range burnWindows {
+		spent := s.BurnRate * w.window.Hours() / s.Period
+		threshold := w.budget * s.Period / w.window.Hours()
+		s.Windows = append(s.Windows, BurnWindow{
+			Window:      w.window.Hours(),
+			Threshold:   threshold,
+			BudgetSpent: spent,
+			Alert:       s.BurnRate >= threshold,
+		})
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSLOBurnRate(t *testing.T) {
+	var count int64
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		switch atomic.AddInt64(&count, 1) % 10 {
+		case 0:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 1:
+			time.Sleep(60 * time.Millisecond)
+		}
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	boomer := &Boomer{
+		Request: req,
+		N:       100,
+		C:       5,
+		SLO:     &SLO{Target: 0.9, Latency: 50 * time.Millisecond},
+	}
+	s := runBoomer(t, boomer).SLO
+	if s == nil || s.Total != 100 || s.Bad != 20 {
+		t.Fatalf("expected 20 of 100 bad requests, found %+v", s)
+	}
+	if math.Abs(s.BurnRate-2) > 1e-9 || math.Abs(s.Exhausted-360) > 1e-9 {
+		t.Errorf("expected burn rate 2 spending the budget in 360h, found %v and %v", s.BurnRate, s.Exhausted)
+	}
+	want := []BurnWindow{
+		{Window: 1, Threshold: 14.4, BudgetSpent: 2.0 / 720},
+		{Window: 6, Threshold: 6, BudgetSpent: 12.0 / 720},
+		{Window: 24, Threshold: 3, BudgetSpent: 48.0 / 720},
+		{Window: 72, Threshold: 1, BudgetSpent: 144.0 / 720, Alert: true},
+	}
+	for i, w := range s.Windows {
+		if w.Window != want[i].Window || w.Alert != want[i].Alert ||
+			math.Abs(w.Threshold-want[i].Threshold) > 1e-9 || math.Abs(w.BudgetSpent-want[i].BudgetSpent) > 1e-9 {
+			t.Errorf("expected window %+v, found %+v", want[i], w)
+		}
+	}
+	if got := s.String(); got != "90% under 50ms" {
+		t.Errorf("expected objective 90%% under 50ms, found %q", got)
+	}
+
+	m := Merge(runBoomer(t, boomer), runBoomer(t, boomer))
+	if m.SLO.Total != 200 || math.Abs(m.SLO.BurnRate-2) > 1e-9 {
+		t.Errorf("expected merged reports to keep the burn rate, found %+v", m.SLO)
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"io"
+	"time"
+)
+
+// slowReader limits the rate at which bytes can be read from r to rate
+// bytes per second, simulating a client on a slow link.
+type slowReader struct {
+	r     io.Reader
+	rate  int
+	start time.Time
+	n     int64
+}
+
+func newSlowReader(r io.Reader, rate int) *slowReader {
+	return &slowReader{r: r, rate: rate}
+}
+
+func (s *slowReader) Read(p []byte) (int, error) {
+	if s.start.IsZero() {
+		s.start = time.Now()
+	}
+	// Read in small chunks so that bytes trickle out evenly rather
+	// than in bursts once per second.
+	if max := s.rate/10 + 1; len(p) > max {
+		p = p[:max]
+	}
+	n, err := s.r.Read(p)
+	s.n += int64(n)
+	due := s.start.Add(time.Duration(s.n) * time.Second / time.Duration(s.rate))
+	if d := due.Sub(time.Now()); d > 0 {
+		time.Sleep(d)
+	}
+	return n, err
+}
+
+// slowReadCloser is a slowReader that can be used as a request body.
+type slowReadCloser struct {
+	*slowReader
+	io.Closer
+}
@@ -0,0 +1,133 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// Stage is a step of a run, making requests at Rate per second for
+// Duration.
+type Stage struct {
+	Rate     int           `json:"rate"`
+	Duration time.Duration `json:"duration"`
+}
+
+func (s Stage) String() string {
+	return fmt.Sprintf("%d req/s for %v", s.Rate, s.Duration)
+}
+
+// requests returns the number of requests made in the stage.
+func (s Stage) requests() int {
+	return int(float64(s.Rate) * s.Duration.Seconds())
+}
+
+// stagesRun returns the number of requests made in all the stages and
+// the highest rate of any.
+func stagesRun(stages []Stage) (n, rate int) {
+	for _, s := range stages {
+		n += s.requests()
+		if s.Rate > rate {
+			rate = s.Rate
+		}
+	}
+	return n, rate
+}
+
+// remainingStages returns the stages left once done requests were
+// made, the first cut short by those made in it, and the number of
+// stages done.
+func remainingStages(stages []Stage, done int) ([]Stage, int) {
+	for k, s := range stages {
+		n := s.requests()
+		if done < n {
+			s.Duration -= time.Duration(float64(done) / float64(s.Rate) * float64(time.Second))
+			return append([]Stage{s}, stages[k+1:]...), k
+		}
+		done -= n
+	}
+	return nil, len(stages)
+}
+
+// stageOf returns the index of the stage the i-th request is made in,
+// the index of the first request of the stage and when it starts.
+func (b *Boomer) stageOf(i int) (k, first int, start time.Duration) {
+	for k = 0; k < len(b.Stages)-1; k++ {
+		n := b.Stages[k].requests()
+		if i < first+n {
+			break
+		}
+		first += n
+		start += b.Stages[k].Duration
+	}
+	return k, first, start
+}
+
+// stageArrival returns the time since the start of the run the i-th
+// request of a run in stages is due at.
+func (b *Boomer) stageArrival(i int) time.Duration {
+	k, first, start := b.stageOf(i)
+	return start + time.Duration(float64(i-first)/float64(b.Stages[k].Rate)*float64(time.Second))
+}
+
+// newStageReports returns an empty report per stage of the run that
+// report collects the results of.
+func (b *Boomer) newStageReports(report *Report) []*Report {
+	var reports []*Report
+	var offset time.Duration
+	for k, s := range b.Stages {
+		r := newReport(s.requests(), nil, 0)
+		r.Name = fmt.Sprintf("stage %d: %v", b.stagesDone+k+1, s)
+		r.headerChecks = report.headerChecks
+		r.fieldChecks = report.fieldChecks
+		r.bodyChecks = report.bodyChecks
+		r.keepLats = report.keepLats
+		r.workers = report.workers
+		r.start = report.start.Add(offset)
+		offset += s.Duration
+		reports = append(reports, r)
+	}
+	return reports
+}
+
+// setStage marks the stage the i-th request of a run in stages is in.
+func (b *Boomer) setStage(i int) {
+	if len(b.Stages) == 0 {
+		return
+	}
+	k, _, _ := b.stageOf(i)
+	atomic.StoreInt32(&b.stage, int32(k+1))
+}
+
+func (r *Report) addStage(res *result) {
+	s := r.stages[res.stage-1]
+	s.add(res)
+	if end := res.start.Add(res.duration); end.After(s.end) {
+		s.end = end
+	}
+}
+
+// printStages makes a report of each stage started.
+func (r *Report) printStages() {
+	r.Stages = nil
+	for _, s := range r.stages {
+		if s.end.IsZero() {
+			continue
+		}
+		r.Stages = append(r.Stages, s.snapshot())
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// stallAfter returns how long a worker may spend on a request before
+// it is considered stalled: StallAfter if set, else ten times the
+// timeout, but at least a second. Zero disables the watchdog.
+func (b *Boomer) stallAfter() time.Duration {
+	if b.StallAfter != 0 {
+		return b.StallAfter
+	}
+	if b.Timeout <= 0 {
+		return 0
+	}
+	d := 10 * time.Duration(b.Timeout) * time.Millisecond
+	if d < time.Second {
+		d = time.Second
+	}
+	return d
+}
+
+// watch counts the workers stuck on a request for longer than after,
+// until stop is closed. Every stalled request is counted once, and the
+// stack of its worker written to StallLog.
+func (b *Boomer) watch(workers []*worker, after time.Duration, stop chan struct{}) int {
+	interval := after / 4
+	if interval > time.Second {
+		interval = time.Second
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	counted := make([]int64, len(workers))
+	var stalls int
+	for {
+		select {
+		case <-stop:
+			return stalls
+		case now := <-t.C:
+			var stalled []*worker
+			for i, w := range workers {
+				if w == nil {
+					continue
+				}
+				since := atomic.LoadInt64(&w.since)
+				if since == 0 || since == counted[i] || now.Sub(time.Unix(0, since)) < after {
+					continue
+				}
+				counted[i] = since
+				stalls++
+				stalled = append(stalled, w)
+			}
+			if len(stalled) > 0 && b.StallLog != nil {
+				b.logStalls(stalled, now)
+			}
+		}
+	}
+}
+
+// logStalls writes the stacks of the stalled workers to StallLog.
+func (b *Boomer) logStalls(stalled []*worker, now time.Time) {
+	buf := make([]byte, 1<<20)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	stacks := bytes.Split(buf, []byte("\n\n"))
+	for _, w := range stalled {
+		since := time.Unix(0, atomic.LoadInt64(&w.since))
+		fmt.Fprintf(b.StallLog, "worker %d stalled for %v:\n", w.id, now.Sub(since).Round(time.Millisecond))
+		prefix := []byte("goroutine " + strconv.FormatInt(w.gid, 10) + " [")
+		for _, s := range stacks {
+			if bytes.HasPrefix(s, prefix) {
+				fmt.Fprintf(b.StallLog, "%s\n\n", s)
+			}
+		}
+	}
+}
+
+// goroutineID returns the id of the calling goroutine, as found in
+// stack traces.
+func goroutineID() int64 {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	b = bytes.TrimPrefix(b, []byte("goroutine "))
+	if i := bytes.IndexByte(b, ' '); i >= 0 {
+		b = b[:i]
+	}
+	id, _ := strconv.ParseInt(string(b), 10, 64)
+	return id
+}
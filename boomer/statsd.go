@@ -0,0 +1,155 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+)
+
+// statsdPacketSize is the largest UDP payload sent, fitting in the MTU
+// of most networks.
+const statsdPacketSize = 1432
+
+// StatsD configures a sink emitting metrics to a StatsD server.
+type StatsD struct {
+	// Addr is the UDP address of the server, e.g. localhost:8125.
+	Addr string
+
+	// Prefix is prepended to the metric names, e.g. "boom." for
+	// boom.latency.
+	Prefix string
+
+	// DogStatsD enables the DogStatsD extension, tagging metrics with
+	// Tags and the tags of the run rather than naming them after the
+	// status code.
+	DogStatsD bool
+	Tags      map[string]string
+}
+
+// statsdSink writes metrics in StatsD packets.
+type statsdSink struct {
+	c     StatsD
+	conn  net.Conn
+	buf   bytes.Buffer
+	flush time.Time
+	tags  string
+}
+
+// NewStatsDSink returns a sink emitting, as every request completes,
+// its latency as a timing named latency if it succeeded, and counters
+// of the responses, by status code, errors and aborts. The rate of
+// responses is emitted as a gauge once the run is done. Metrics are
+// batched in packets sent at least every second.
+func NewStatsDSink(c StatsD) (Sink, error) {
+	conn, err := net.Dial("udp", c.Addr)
+	if err != nil {
+		return nil, err
+	}
+	s := &statsdSink{c: c, conn: conn, flush: time.Now()}
+	s.tags = statsdTags(c.Tags)
+	return s, nil
+}
+
+func (s *statsdSink) Record(rec Record) error {
+	tags := s.tags
+	if s.c.DogStatsD && len(rec.Tags) > 0 {
+		tags = joinTags(tags, statsdTags(rec.Tags))
+	}
+	var err error
+	switch {
+	case rec.Aborted != "":
+		err = s.metric("aborts", "1|c", tags)
+	case rec.Err != nil:
+		err = s.metric("errors", "1|c", tags)
+	default:
+		err = s.metric("latency", fmt.Sprintf("%s|ms", promFloat(rec.Duration.Seconds()*1000)), tags)
+		if err == nil && s.c.DogStatsD {
+			err = s.metric("responses", "1|c", joinTags(tags, fmt.Sprintf("status:%d", rec.StatusCode)))
+		} else if err == nil {
+			err = s.metric(fmt.Sprintf("status.%d", rec.StatusCode), "1|c", "")
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if now := time.Now(); now.Sub(s.flush) >= sinkFlushInterval {
+		s.flush = now
+		return s.send()
+	}
+	return nil
+}
+
+// metric adds a metric to the packet being batched, sending it first if
+// it would not fit.
+func (s *statsdSink) metric(name, value, tags string) error {
+	line := s.c.Prefix + name + ":" + value
+	if s.c.DogStatsD && tags != "" {
+		line += "|#" + tags
+	}
+	if s.buf.Len() > 0 && s.buf.Len()+1+len(line) > statsdPacketSize {
+		if err := s.send(); err != nil {
+			return err
+		}
+	}
+	if s.buf.Len() > 0 {
+		s.buf.WriteByte('\n')
+	}
+	s.buf.WriteString(line)
+	return nil
+}
+
+func (s *statsdSink) send() error {
+	if s.buf.Len() == 0 {
+		return nil
+	}
+	_, err := s.conn.Write(s.buf.Bytes())
+	s.buf.Reset()
+	return err
+}
+
+func (s *statsdSink) Close(r *Report) error {
+	defer s.conn.Close()
+	if err := s.metric("rps", promFloat(r.RPS)+"|g", s.tags); err != nil {
+		return err
+	}
+	return s.send()
+}
+
+// statsdTags returns the tags as a DogStatsD tag list, sorted by key.
+// Tags without a value are listed by key only.
+func statsdTags(tags map[string]string) string {
+	var list []string
+	for k, v := range tags {
+		if v == "" {
+			list = append(list, k)
+			continue
+		}
+		list = append(list, k+":"+v)
+	}
+	sort.Strings(list)
+	return strings.Join(list, ",")
+}
+
+func joinTags(a, b string) string {
+	if a == "" || b == "" {
+		return a + b
+	}
+	return a + "," + b
+}
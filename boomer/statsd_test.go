@@ -0,0 +1,94 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readStatsD returns the metrics received by conn until it is idle.
+func readStatsD(t *testing.T, conn net.PacketConn) []string {
+	var lines []string
+	buf := make([]byte, 65536)
+	for {
+		conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			return lines
+		}
+		if n > statsdPacketSize {
+			t.Errorf("expected packets of at most %v bytes, found %v", statsdPacketSize, n)
+		}
+		lines = append(lines, strings.Split(string(buf[:n]), "\n")...)
+	}
+}
+
+func TestStatsDSink(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	for _, dog := range []bool{false, true} {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		sink, err := NewStatsDSink(StatsD{
+			Addr:      conn.LocalAddr().String(),
+			Prefix:    "boom.",
+			DogStatsD: dog,
+			Tags:      map[string]string{"env": "staging"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		req, _ := http.NewRequest("GET", server.URL, nil)
+		r := runBoomer(t, &Boomer{Request: req, N: 100, C: 2, Tags: map[string]string{"run": "a"}, Sinks: []Sink{sink}})
+		lines := readStatsD(t, conn)
+		conn.Close()
+		if len(r.SinkErrors) != 0 {
+			t.Fatal(r.SinkErrors)
+		}
+
+		counts := make(map[string]int)
+		for _, l := range lines {
+			name := l[:strings.Index(l, ":")]
+			if dog {
+				name += l[strings.Index(l, "|#"):]
+			}
+			counts[name]++
+		}
+		want := map[string]int{"boom.latency": 100, "boom.status.200": 100, "boom.rps": 1}
+		if dog {
+			want = map[string]int{
+				"boom.latency|#env:staging,run:a":              100,
+				"boom.responses|#env:staging,run:a,status:200": 100,
+				"boom.rps|#env:staging":                        1,
+			}
+		}
+		if len(counts) != len(want) {
+			t.Errorf("dogstatsd %v: expected %v, found %v", dog, want, counts)
+		}
+		for name, n := range want {
+			if counts[name] != n {
+				t.Errorf("dogstatsd %v: expected %v %v, found %v", dog, n, name, counts[name])
+			}
+		}
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+// Snapshot returns the report of the run in progress as if it had
+// stopped now, the final report once the run is done, or nil if the
+// run has not started. It may be called concurrently with Run.
+func (b *Boomer) Snapshot() *Report {
+	r, _, _ := b.snapshot()
+	return r
+}
+
+// snapshot returns the current report, whether it is final, and the
+// number of requests the run makes, zero if it has not started.
+func (b *Boomer) snapshot() (*Report, bool, int) {
+	r, _ := b.live.Load().(*Report)
+	if r == nil {
+		return nil, false, 0
+	}
+	select {
+	case <-r.finished:
+		return r.final, true, r.n
+	default:
+	}
+	reply := make(chan *Report, 1)
+	select {
+	case r.snapshots <- reply:
+		return <-reply, false, r.n
+	case <-r.finished:
+		return r.final, true, r.n
+	}
+}
+
+// Status describes the progress of a run.
+type Status struct {
+	// State is "pending" before the run starts, "running" and then
+	// "done".
+	State string `json:"state"`
+
+	// Requests is the number of requests made so far, out of the N
+	// the run makes, including those of the run it resumes.
+	Requests int `json:"requests"`
+	N        int `json:"n,omitempty"`
+
+	Report *Report `json:"report,omitempty"`
+}
+
+// StatusHandler returns a handler serving the Status of the run as
+// JSON, with the report so far, its metrics for Prometheus to scrape at
+// /metrics and "ok" at /healthz, so that the progress of a run can be
+// polled.
+func (b *Boomer) StatusHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok\n")
+	})
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		b.writeMetrics(w)
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		report, finished, n := b.snapshot()
+		s := Status{State: "pending", N: n, Report: report}
+		if report != nil {
+			s.State, s.Requests = "running", report.requests()
+			if finished {
+				s.State = "done"
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(s)
+	})
+	return mux
+}
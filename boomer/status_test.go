@@ -0,0 +1,81 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func getStatus(t *testing.T, h http.Handler) Status {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	var s Status
+	if err := json.Unmarshal(w.Body.Bytes(), &s); err != nil {
+		t.Fatalf("invalid status %q: %v", w.Body.String(), err)
+	}
+	return s
+}
+
+func TestStatusHandler(t *testing.T) {
+	var count int64
+	release := make(chan struct{})
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt64(&count, 1) > 5 {
+			<-release
+		}
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	boomer := &Boomer{Request: req, N: 10, C: 1}
+	h := boomer.StatusHandler()
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/healthz", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "ok\n" {
+		t.Errorf("unexpected health check %v %q", w.Code, w.Body.String())
+	}
+	if s := getStatus(t, h); s.State != "pending" || s.Report != nil {
+		t.Errorf("expected a pending run, found %+v", s)
+	}
+
+	done := make(chan *Report)
+	go func() { done <- runBoomer(t, boomer) }()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		s := getStatus(t, h)
+		if s.State == "running" && s.Requests == 5 && s.N == 10 && s.Report.Responses() == 5 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected 5 of 10 requests running, found %+v", s)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	close(release)
+	r := <-done
+	if s := getStatus(t, h); s.State != "done" || s.Requests != 10 || s.Report.Responses() != 10 {
+		t.Errorf("expected 10 of 10 requests done, found %+v", s)
+	}
+	if boomer.Snapshot() != r {
+		t.Errorf("expected the final snapshot to be the report")
+	}
+}
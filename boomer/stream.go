@@ -0,0 +1,231 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// StreamStats describes the messages of the streaming calls, or
+// WebSocket sessions, of a run.
+type StreamStats struct {
+	// Streams is the number of calls or sessions that completed, and
+	// Messages the number of response messages they got.
+	Streams  int `json:"streams"`
+	Messages int `json:"messages"`
+
+	// FirstMessage is the latency from the start of a call to its first
+	// response message. Message is the latency between the response
+	// messages of server-streaming calls, or from sending each message
+	// of bidirectional-streaming calls or sessions to its response.
+	FirstMessage StreamLatency `json:"first_message"`
+	Message      StreamLatency `json:"message"`
+}
+
+// StreamLatency summarizes latencies of stream messages, in ms.
+type StreamLatency struct {
+	Average float64 `json:"average"`
+	P50     float64 `json:"p50"`
+	P90     float64 `json:"p90"`
+	P99     float64 `json:"p99"`
+
+	// Total is the sum of the latencies, and Sketch their distribution.
+	Total  float64 `json:"total"`
+	Sketch *Sketch `json:"sketch,omitempty"`
+}
+
+func (l *StreamLatency) add(lat float64) {
+	if l.Sketch == nil {
+		l.Sketch = &Sketch{}
+	}
+	l.Sketch.add(lat)
+	l.Total += lat
+}
+
+func (l *StreamLatency) merge(o StreamLatency) {
+	if o.Sketch != nil {
+		if l.Sketch == nil {
+			l.Sketch = &Sketch{}
+		}
+		l.Sketch.merge(o.Sketch)
+	}
+	l.Total += o.Total
+}
+
+func (l *StreamLatency) compute() {
+	n := l.Sketch.count()
+	if n == 0 {
+		return
+	}
+	l.Average = l.Total / float64(n)
+	l.P50, l.P90, l.P99 = l.Sketch.quantile(50), l.Sketch.quantile(90), l.Sketch.quantile(99)
+}
+
+func (s *StreamStats) copy() *StreamStats {
+	if s == nil {
+		return nil
+	}
+	c := *s
+	c.FirstMessage.Sketch, c.Message.Sketch = s.FirstMessage.Sketch.copy(), s.Message.Sketch.copy()
+	return &c
+}
+
+func (s *StreamStats) merge(o *StreamStats) {
+	s.Streams += o.Streams
+	s.Messages += o.Messages
+	s.FirstMessage.merge(o.FirstMessage)
+	s.Message.merge(o.Message)
+}
+
+func (s *StreamStats) compute() {
+	s.FirstMessage.compute()
+	s.Message.compute()
+}
+
+// streamResult holds the latencies of the messages of a call.
+type streamResult struct {
+	first    time.Duration
+	messages int
+	lats     []time.Duration
+}
+
+func (s *StreamStats) add(res *streamResult) {
+	s.Streams++
+	s.Messages += res.messages
+	if res.messages > 0 {
+		s.FirstMessage.add(res.first.Seconds() * 1000)
+	}
+	for _, lat := range res.lats {
+		s.Message.add(lat.Seconds() * 1000)
+	}
+}
+
+var errTruncatedMessage = errors.New("grpc: truncated message")
+
+// bidiStream sends the messages of a bidirectional-streaming call, each
+// once the response to the previous one was read.
+type bidiStream struct {
+	n    int
+	pw   *io.PipeWriter
+	sent chan time.Time
+	next chan struct{}
+	once sync.Once
+}
+
+// newBidiStream makes the body of the gRPC request req, a single
+// framed message, that of a stream sending it n times.
+func newBidiStream(req *http.Request, n int) (*bidiStream, error) {
+	frame, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	pr, pw := io.Pipe()
+	setBody(req, pr)
+	s := &bidiStream{n: n, pw: pw, sent: make(chan time.Time, n), next: make(chan struct{}, 1)}
+	go s.send(frame)
+	return s, nil
+}
+
+func (s *bidiStream) send(frame []byte) {
+	defer close(s.sent)
+	for i := 0; i < s.n; i++ {
+		if i > 0 {
+			if _, ok := <-s.next; !ok {
+				return
+			}
+		}
+		s.sent <- time.Now()
+		if _, err := s.pw.Write(frame); err != nil {
+			return
+		}
+	}
+}
+
+// close ends the stream of requests.
+func (s *bidiStream) close() {
+	s.once.Do(func() {
+		close(s.next)
+		s.pw.Close()
+	})
+}
+
+// readStream reads the response messages of the streaming call started
+// at start, and then the rest of the body for its trailers.
+func readStream(resp *http.Response, start time.Time, bidi *bidiStream) (*streamResult, error) {
+	res := &streamResult{}
+	br := bufio.NewReader(resp.Body)
+	last := start
+	for bidi == nil || res.messages < bidi.n {
+		var sent time.Time
+		if bidi != nil {
+			var ok bool
+			if sent, ok = <-bidi.sent; !ok {
+				break
+			}
+		}
+		if err := skipGRPCMessage(br); err == io.EOF {
+			break
+		} else if err != nil {
+			return res, err
+		}
+		now := time.Now()
+		if res.messages == 0 {
+			res.first = now.Sub(start)
+		}
+		switch {
+		case bidi != nil:
+			res.lats = append(res.lats, now.Sub(sent))
+			if res.messages+1 < bidi.n {
+				bidi.next <- struct{}{}
+			}
+		case res.messages > 0:
+			res.lats = append(res.lats, now.Sub(last))
+		}
+		last = now
+		res.messages++
+	}
+	if bidi != nil {
+		bidi.close()
+	}
+	_, err := io.Copy(ioutil.Discard, br)
+	return res, err
+}
+
+// skipGRPCMessage reads a message of a gRPC stream, returning io.EOF at
+// the end of the stream.
+func skipGRPCMessage(r *bufio.Reader) error {
+	var header [5]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return errTruncatedMessage
+		}
+		return err
+	}
+	n := int64(binary.BigEndian.Uint32(header[1:]))
+	if m, err := io.CopyN(ioutil.Discard, r, n); m < n {
+		if err == io.EOF {
+			return errTruncatedMessage
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"context"
+	"io"
+	"text/tabwriter"
+)
+
+// SuiteReport holds the reports of the cells of a suite, runs made one
+// after another with different configurations to compare them.
+type SuiteReport struct {
+	Cells []*Report `json:"cells"`
+}
+
+// RunSuite makes the runs of the boomers one after another, so that
+// they do not compete with each other for the target or the machine.
+// It blocks until all runs are done. Once ctx is done, the run in
+// progress stops early, as Run does, the remaining ones are not made
+// and the report of the cells run is returned with the error of ctx.
+// An error is returned without a report if any of the boomers is not a
+// valid run.
+func RunSuite(ctx context.Context, boomers ...*Boomer) (*SuiteReport, error) {
+	for _, b := range boomers {
+		if err := b.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	s := &SuiteReport{}
+	for _, b := range boomers {
+		if ctx.Err() != nil {
+			break
+		}
+		s.Cells = append(s.Cells, b.run(ctx))
+	}
+	return s, ctx.Err()
+}
+
+// WriteText writes a table comparing the throughput, latency and errors
+// of the cells to w, a row per cell.
+func (s *SuiteReport) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	ew := &errWriter{w: tw}
+	ew.printf("\nSuite:\n")
+	ew.printf("Cell\tRequests/sec\tAverage\tp50\tp90\tp99\tErrors\t\n")
+	for _, r := range s.Cells {
+		ew.printf("%s\t%4.4f\t%4.4f\t%4.4f\t%4.4f\t%4.4f\t%d\t\n", r.Name, r.RPS, r.Average,
+			r.Percentile(50)/1000, r.Percentile(90)/1000, r.Percentile(99)/1000, r.ErrorCount())
+	}
+	if ew.err != nil {
+		return ew.err
+	}
+	return tw.Flush()
+}
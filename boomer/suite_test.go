@@ -0,0 +1,66 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRunSuite(t *testing.T) {
+	var inFlight, maxInFlight int64
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt64(&inFlight, 1)
+		defer atomic.AddInt64(&inFlight, -1)
+		for {
+			max := atomic.LoadInt64(&maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt64(&maxInFlight, max, n) {
+				break
+			}
+		}
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	s, err := RunSuite(context.Background(),
+		&Boomer{Name: "c=1", Request: req, N: 10, C: 1},
+		&Boomer{Name: "c=2", Request: req, N: 20, C: 2},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if maxInFlight > 2 {
+		t.Errorf("expected the cells to run one after another, found %v requests at once", maxInFlight)
+	}
+	if len(s.Cells) != 2 || s.Cells[0].Responses() != 10 || s.Cells[1].Responses() != 20 {
+		t.Fatalf("expected a report per cell, found %+v", s.Cells)
+	}
+
+	var buf bytes.Buffer
+	if err := s.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 || strings.Join(strings.Fields(lines[1]), " ") != "Cell Requests/sec Average p50 p90 p99 Errors" ||
+		len(strings.Fields(lines[3])) != 7 || strings.Fields(lines[3])[0] != "c=2" {
+		t.Errorf("unexpected suite table:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"io"
+	"strconv"
+)
+
+// WriteTAP writes the checks of the run to w in the Test Anything
+// Protocol, version 13: a test per check, failing if the check did,
+// with its message and verdict as YAML diagnostics. Checks close to
+// failing pass, with a "warn" verdict.
+func (r *Report) WriteTAP(w io.Writer) error {
+	ew := &errWriter{w: w}
+	outcomes := r.Outcomes()
+	ew.printf("TAP version 13\n")
+	if len(outcomes) == 0 {
+		ew.printf("1..0 # SKIP no checks\n")
+		return ew.err
+	}
+	ew.printf("1..%d\n", len(outcomes))
+	for i, o := range outcomes {
+		status := "ok"
+		if o.Verdict == Fail {
+			status = "not ok"
+		}
+		ew.printf("%s %d - %s\n", status, i+1, tapEscape(r.tapName(o)))
+		ew.printf("  ---\n  message: %s\n  verdict: %s\n  ...\n", strconv.Quote(o.Message), o.Verdict)
+	}
+	return ew.err
+}
+
+func (r *Report) tapName(o Outcome) string {
+	if r.Name != "" {
+		return r.Name + ": " + o.Check
+	}
+	return o.Check
+}
+
+// tapEscape escapes the characters starting directives in test names.
+func tapEscape(s string) string {
+	out := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] == '#' || s[i] == '\\' {
+			out = append(out, '\\')
+		}
+		out = append(out, s[i])
+	}
+	return string(out)
+}
@@ -0,0 +1,341 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"io/ioutil"
+	mrand "math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
+)
+
+// worker holds the state of a single worker goroutine.
+type worker struct {
+	id int
+
+	// iter is the number of requests the worker has started.
+	iter int64
+
+	tmpl *requestTemplate
+	err  error
+
+	// gid is the id of the worker's goroutine.
+	gid int64
+
+	// since is the time, in Unix nanoseconds, the worker started its
+	// current request, or zero if it is idle. Accessed atomically.
+	since int64
+
+	// rows are the rows of the feeds drawn for the current request.
+	rows map[string]map[string]string
+
+	// vars are the values captured by the steps of the Scenario made
+	// so far.
+	vars map[string]string
+
+	// client is the client of the worker holding its cookie jar, or
+	// nil to use the client of the run.
+	client *http.Client
+
+	// seeded are the hosts the Cookies of the run were set in the jar
+	// for.
+	seeded map[string]bool
+}
+
+func (b *Boomer) newWorker(id int) *worker {
+	w := &worker{id: id}
+	if b.CookieJar || len(b.Cookies) > 0 {
+		w.client = b.jarClient()
+		w.seeded = make(map[string]bool)
+	}
+	if b.templated() {
+		w.tmpl, w.err = b.parseTemplate(w.funcs(b))
+	}
+	return w
+}
+
+// funcs returns the template functions bound to the worker.
+func (w *worker) funcs(b *Boomer) template.FuncMap {
+	return template.FuncMap{
+		// seq yields a strictly increasing number unique to the worker:
+		// workers are spaced apart by the most requests one can make.
+		"seq": func() int64 {
+			if b.warmingUp {
+				// Warm-up requests take values in turn, the run's
+				// following them.
+				return b.IDOffset + b.seqBase + atomic.AddInt64(&b.warmupSeq, 1) - 1
+			}
+			return b.IDOffset + b.seqBase + int64(w.id)*b.perWorker() + w.iter - 1
+		},
+		// counter yields the next value of a named counter shared by all
+		// workers, producing dense identifiers across the run.
+		"counter": func(name string) int64 {
+			return b.IDOffset + b.counters.next(name)
+		},
+		// feed yields a column of the row of the named feed drawn for
+		// the request.
+		"feed": func(name, column string) (string, error) {
+			return w.feedValue(b, name, column)
+		},
+		// var yields the value captured by an earlier step of the
+		// Scenario.
+		"var":     w.varValue,
+		"uuid":    newUUID,
+		"randInt": randInt,
+		"now":     time.Now,
+		"env":     os.Getenv,
+	}
+}
+
+// newUUID returns a random (version 4) UUID.
+func newUUID() (string, error) {
+	var u [16]byte
+	if _, err := rand.Read(u[:]); err != nil {
+		return "", err
+	}
+	u[6] = u[6]&0x0f | 0x40
+	u[8] = u[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:]), nil
+}
+
+// randInt returns a random integer in [min, max).
+func randInt(min, max int) (int, error) {
+	if max <= min {
+		return 0, fmt.Errorf("randInt: max %d not above min %d", max, min)
+	}
+	return min + mrand.Intn(max-min), nil
+}
+
+// templated reports whether requests are rendered from templates.
+func (b *Boomer) templated() bool {
+	return b.Template || len(b.Scenario) > 0
+}
+
+// IDRange returns the number of distinct values seq can yield in a run.
+// Runs given IDOffsets that are multiples of it never produce the same
+// seq or counter values. A Warmup shifts those of the run past the
+// values its requests took, which the range does not count.
+func (b *Boomer) IDRange() int64 {
+	return int64(b.maxWorkers()) * b.perWorker()
+}
+
+// perWorker returns the most requests a worker can make: N, or 2^32
+// for a run for a Duration.
+func (b *Boomer) perWorker() int64 {
+	if b.N == 0 {
+		return 1 << 32
+	}
+	return int64(b.N)
+}
+
+// counterSet holds named counters shared by all workers.
+type counterSet struct {
+	mu sync.Mutex
+	m  map[string]*int64
+}
+
+func newCounterSet() *counterSet {
+	return &counterSet{m: make(map[string]*int64)}
+}
+
+// next increments the named counter and returns its previous value,
+// starting from zero.
+func (c *counterSet) next(name string) int64 {
+	c.mu.Lock()
+	p, ok := c.m[name]
+	if !ok {
+		p = new(int64)
+		c.m[name] = p
+	}
+	c.mu.Unlock()
+	return atomic.AddInt64(p, 1) - 1
+}
+
+// values returns the values the counters yield next.
+func (c *counterSet) values() map[string]int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.m) == 0 {
+		return nil
+	}
+	m := make(map[string]int64, len(c.m))
+	for name, p := range c.m {
+		m[name] = atomic.LoadInt64(p)
+	}
+	return m
+}
+
+// requestTemplate renders the URL path, query, headers and body of
+// requests.
+type requestTemplate struct {
+	body   *template.Template
+	header headerTemplate
+
+	// endpoints hold the templates of the URL of Request, or of each of
+	// the Endpoints or steps of the Scenario requests are sent to.
+	endpoints []endpointTemplate
+}
+
+// endpointTemplate renders the path and query of the URL of an
+// endpoint, and the headers and body it has of its own. All its header
+// values are templates, so that they replace those of Request.
+type endpointTemplate struct {
+	path, query, body *template.Template
+	header            headerTemplate
+}
+
+// headerTemplate holds templates of header values by header name.
+type headerTemplate map[string][]*template.Template
+
+// parseTemplate parses the URL path, query, headers and body of the
+// request as templates using funcs.
+func (b *Boomer) parseTemplate(funcs template.FuncMap) (*requestTemplate, error) {
+	var t requestTemplate
+	var err error
+	if t.body, err = template.New("").Funcs(funcs).Parse(b.RequestBody); err != nil {
+		return nil, err
+	}
+	if t.header, err = parseHeader(b.Request.Header, false, funcs); err != nil {
+		return nil, err
+	}
+	endpoints := b.endpoints()
+	for i := 0; i == 0 || i < len(endpoints); i++ {
+		u := b.Request.URL
+		var e Endpoint
+		if len(endpoints) > 0 {
+			e = endpoints[i]
+			if u, err = url.Parse(e.URL); err != nil {
+				return nil, err
+			}
+		}
+		var et endpointTemplate
+		if et.path, err = template.New("").Funcs(funcs).Parse(u.Path); err != nil {
+			return nil, err
+		}
+		if et.query, err = template.New("").Funcs(funcs).Parse(u.RawQuery); err != nil {
+			return nil, err
+		}
+		if e.Body != "" {
+			if et.body, err = template.New("").Funcs(funcs).Parse(e.Body); err != nil {
+				return nil, err
+			}
+		}
+		if et.header, err = parseHeader(e.Header, true, funcs); err != nil {
+			return nil, err
+		}
+		t.endpoints = append(t.endpoints, et)
+	}
+	return &t, nil
+}
+
+// parseHeader parses the values of h as templates using funcs: all of
+// them, or only those that have any actions.
+func parseHeader(h http.Header, all bool, funcs template.FuncMap) (headerTemplate, error) {
+	t := make(headerTemplate)
+	for k, vs := range h {
+		if !all && !hasAction(vs) {
+			continue
+		}
+		tmpls := make([]*template.Template, len(vs))
+		for i, v := range vs {
+			var err error
+			if tmpls[i], err = template.New(k).Funcs(funcs).Parse(v); err != nil {
+				return nil, err
+			}
+		}
+		t[k] = tmpls
+	}
+	return t, nil
+}
+
+// hasAction reports whether any of vs may hold a template action.
+func hasAction(vs []string) bool {
+	for _, v := range vs {
+		if strings.Contains(v, "{{") {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseTemplates reports whether the URL, headers and body of the
+// request are valid templates. Only meaningful if Template is set.
+func (b *Boomer) ParseTemplates() error {
+	_, err := b.parseTemplate((&worker{}).funcs(b))
+	return err
+}
+
+// render applies the worker's templates to req, whose header must be
+// its own copy.
+func (w *worker) render(req *http.Request) error {
+	if w.err != nil {
+		return w.err
+	}
+	w.rows = nil
+	et := w.tmpl.endpoints[0]
+	if idx := endpointOf(req); idx >= 0 {
+		et = w.tmpl.endpoints[idx]
+	}
+	var path, query, body bytes.Buffer
+	if err := et.path.Execute(&path, nil); err != nil {
+		return err
+	}
+	if err := et.query.Execute(&query, nil); err != nil {
+		return err
+	}
+	bt := w.tmpl.body
+	if et.body != nil {
+		bt = et.body
+	}
+	if err := bt.Execute(&body, nil); err != nil {
+		return err
+	}
+	// The values of the endpoint replace those of Request.
+	for _, t := range []headerTemplate{w.tmpl.header, et.header} {
+		if err := t.render(req.Header); err != nil {
+			return err
+		}
+	}
+	u := *req.URL
+	u.Path, u.RawPath, u.RawQuery = path.String(), "", query.String()
+	req.URL = &u
+	req.Body = ioutil.NopCloser(&body)
+	return nil
+}
+
+// render sets the values of h rendered from t.
+func (t headerTemplate) render(h http.Header) error {
+	for k, tmpls := range t {
+		vs := make([]string, len(tmpls))
+		for i, t := range tmpls {
+			var v bytes.Buffer
+			if err := t.Execute(&v, nil); err != nil {
+				return err
+			}
+			vs[i] = v.String()
+		}
+		h[k] = vs
+	}
+	return nil
+}
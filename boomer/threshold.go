@@ -0,0 +1,141 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Threshold is a limit a metric of a run must stay below, or above, to
+// pass.
+type Threshold struct {
+	// Metric is the name of the metric: "average" or a percentile such
+	// as "p99" or "p99.9" for latencies, in ms, "error_rate", the
+	// fraction of requests that failed as Report.ErrorRate counts them,
+	// "rps" or "header_failure_rate", the fraction of header checks
+	// failed.
+	Metric string
+	Max    float64
+	// Min, if positive, is the limit the metric must instead stay
+	// above, and Max is ignored.
+	Min float64
+}
+
+func (t Threshold) String() string {
+	if t.Min > 0 {
+		return fmt.Sprintf("%s>%s", t.Metric, formatMetric(t.Metric, t.Min))
+	}
+	return fmt.Sprintf("%s<%s", t.Metric, formatMetric(t.Metric, t.Max))
+}
+
+// ThresholdResult is the value of the metric of a threshold at the end
+// of a run.
+type ThresholdResult struct {
+	Metric string  `json:"metric"`
+	Max    float64 `json:"max"`
+	Min    float64 `json:"min,omitempty"`
+	Value  float64 `json:"value"`
+	Failed bool    `json:"failed"`
+}
+
+// limit describes the limit of the threshold, e.g. "for at most 250ms".
+func (t ThresholdResult) limit() string {
+	if t.Min > 0 {
+		return "for at least " + formatMetric(t.Metric, t.Min)
+	}
+	return "for at most " + formatMetric(t.Metric, t.Max)
+}
+
+// String describes the threshold and its value for people, e.g.
+// "p99 312ms for at most 250ms".
+func (t ThresholdResult) String() string {
+	return fmt.Sprintf("%s %s %s", t.Metric, formatMetric(t.Metric, t.Value), t.limit())
+}
+
+// ValidMetric reports whether metric can be used in a threshold.
+func ValidMetric(metric string) bool {
+	_, ok := percentileOf(metric)
+	switch metric {
+	case "average", "error_rate", "rps", "header_failure_rate":
+		return true
+	}
+	return ok
+}
+
+// percentileOf returns the percentile of a metric such as "p99".
+func percentileOf(metric string) (float64, bool) {
+	if !strings.HasPrefix(metric, "p") {
+		return 0, false
+	}
+	p, err := strconv.ParseFloat(metric[1:], 64)
+	return p, err == nil && p > 0 && p <= 100
+}
+
+// Evaluate evaluates the thresholds against r. Latency thresholds fail
+// if r had no successful requests.
+func Evaluate(r *Report, thresholds []Threshold) []ThresholdResult {
+	var rs []ThresholdResult
+	for _, t := range thresholds {
+		res := ThresholdResult{Metric: t.Metric, Max: t.Max, Min: t.Min}
+		latency := false
+		switch t.Metric {
+		case "error_rate":
+			res.Value = r.ErrorRate()
+		case "header_failure_rate":
+			res.Value = r.HeaderFailureRate()
+		case "rps":
+			res.Value = r.RPS
+		case "average":
+			res.Value, latency = r.Average*1000, true
+		default:
+			p, _ := percentileOf(t.Metric)
+			res.Value, latency = r.Percentile(p), true
+		}
+		if t.Min > 0 {
+			res.Failed = res.Value <= t.Min
+		} else {
+			res.Failed = res.Value >= t.Max
+		}
+		if latency && !r.hasLatencies() {
+			res.Failed = true
+		}
+		rs = append(rs, res)
+	}
+	return rs
+}
+
+// ThresholdsFailed reports whether any of the thresholds of r failed.
+func (r *Report) ThresholdsFailed() bool {
+	for _, t := range r.Thresholds {
+		if t.Failed {
+			return true
+		}
+	}
+	return false
+}
+
+// formatMetric formats a value of the metric for people: latencies in
+// ms, rates of failures as percentages and the throughput in req/s.
+func formatMetric(metric string, v float64) string {
+	switch metric {
+	case "error_rate", "header_failure_rate":
+		return strconv.FormatFloat(v*100, 'g', 6, 64) + "%"
+	case "rps":
+		return strconv.FormatFloat(v, 'g', 6, 64) + " req/s"
+	}
+	return strconv.FormatFloat(v, 'g', 6, 64) + "ms"
+}
@@ -0,0 +1,105 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// clientCert returns a self-signed client certificate.
+func clientCert(t *testing.T) (tls.Certificate, *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "boom"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, cert
+}
+
+func TestMutualTLS(t *testing.T) {
+	cert, parsed := clientCert(t)
+	clients := x509.NewCertPool()
+	clients.AddCert(parsed)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clients}
+	server.StartTLS()
+	defer server.Close()
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	report := runBoomer(t, &Boomer{Request: req, N: 4, C: 2, ClientCert: &cert, RootCAs: roots})
+	if report.StatusCount(200) != 4 || report.HandshakeFailures != 0 {
+		t.Errorf("expected 4 responses, found %+v and errors %+v", report.StatusCodes, report.Errors)
+	}
+
+	report = runBoomer(t, &Boomer{Request: req, N: 4, C: 2, RootCAs: roots})
+	if report.HandshakeFailures != 4 || report.ErrorCount() != 4 {
+		t.Errorf("expected 4 handshake failures without a certificate, found %v and errors %+v", report.HandshakeFailures, report.Errors)
+	}
+	report = runBoomer(t, &Boomer{Request: req, N: 2, C: 1, ClientCert: &cert})
+	if report.HandshakeFailures != 2 {
+		t.Errorf("expected 2 handshake failures with an unknown authority, found %v and errors %+v", report.HandshakeFailures, report.Errors)
+	}
+}
+
+func TestServerName(t *testing.T) {
+	var hosts, names []string
+	var mu sync.Mutex
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hosts, names = append(hosts, r.Host), append(names, r.TLS.ServerName)
+		mu.Unlock()
+	}))
+	defer server.Close()
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+
+	// The certificate of the test server is valid for example.com.
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	req.Host = "vhost.test"
+	report := runBoomer(t, &Boomer{Request: req, N: 2, C: 1, RootCAs: roots, ServerName: "example.com"})
+	if report.StatusCount(200) != 2 || !reflect.DeepEqual(hosts, []string{"vhost.test", "vhost.test"}) || !reflect.DeepEqual(names, []string{"example.com", "example.com"}) {
+		t.Errorf("expected the Host header and server name to be overridden, found %v, %v and errors %+v", hosts, names, report.Errors)
+	}
+
+	report = runBoomer(t, &Boomer{Request: req, N: 2, C: 1, RootCAs: roots, ServerName: "other.test"})
+	if report.HandshakeFailures != 2 {
+		t.Errorf("expected the certificate to be verified for the server name, found %v handshake failures and errors %+v", report.HandshakeFailures, report.Errors)
+	}
+}
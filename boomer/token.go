@@ -0,0 +1,195 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// gcpMetadataHost and azureIMDSEndpoint are variables for tests.
+	gcpMetadataHost   = "metadata.google.internal"
+	azureIMDSEndpoint = "http://169.254.169.254"
+)
+
+// Token is a credential sent with requests until it expires.
+type Token struct {
+	Value string
+
+	// Expires is when the token stops being valid. It is zero for
+	// tokens that do not expire.
+	Expires time.Time
+}
+
+// TokenSource sets a token as a header of every request. The token is
+// retrieved on first use and retrieved again shortly before it expires,
+// keeping long runs authenticated.
+type TokenSource struct {
+	// Header is the name of the header set, Authorization by default.
+	Header string
+
+	// Prefix is prepended to the token, e.g. "Bearer ".
+	Prefix string
+
+	// Fetch retrieves a new token.
+	Fetch func() (Token, error)
+
+	mu      sync.Mutex
+	tok     *Token
+	refresh time.Time
+}
+
+// Token returns the cached token, fetching a new one if it is about to
+// expire: a minute before, or halfway through the lifetime of tokens
+// valid for less than two minutes.
+func (s *TokenSource) Token(now time.Time) (Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tok != nil && (s.tok.Expires.IsZero() || now.Before(s.refresh)) {
+		return *s.tok, nil
+	}
+	tok, err := s.Fetch()
+	if err != nil {
+		return Token{}, err
+	}
+	margin := time.Minute
+	if half := tok.Expires.Sub(now) / 2; half < margin {
+		margin = half
+	}
+	s.tok, s.refresh = &tok, tok.Expires.Add(-margin)
+	return tok, nil
+}
+
+// apply sets the token header of req.
+func (s *TokenSource) apply(req *http.Request, now time.Time) error {
+	tok, err := s.Token(now)
+	if err != nil {
+		return err
+	}
+	header := s.Header
+	if header == "" {
+		header = "Authorization"
+	}
+	req.Header.Set(header, s.Prefix+tok.Value)
+	return nil
+}
+
+// GCPIdentityToken returns a function fetching an identity token for
+// audience from the GCE metadata server, to be sent as a bearer token
+// to services such as Cloud Run or IAP. The metadata host can be
+// overridden by GCE_METADATA_HOST.
+func GCPIdentityToken(audience string) func() (Token, error) {
+	return func() (Token, error) {
+		host := os.Getenv("GCE_METADATA_HOST")
+		if host == "" {
+			host = gcpMetadataHost
+		}
+		u := "http://" + host + "/computeMetadata/v1/instance/service-accounts/default/identity?" +
+			url.Values{"audience": {audience}, "format": {"full"}}.Encode()
+		body, err := metadataGet(u, http.Header{"Metadata-Flavor": {"Google"}})
+		if err != nil {
+			return Token{}, err
+		}
+		tok := Token{Value: strings.TrimSpace(string(body))}
+		tok.Expires, err = jwtExpiry(tok.Value)
+		return tok, err
+	}
+}
+
+// AzureToken returns a function fetching an access token for resource
+// from the Azure instance metadata service, using the managed identity
+// of the VM.
+func AzureToken(resource string) func() (Token, error) {
+	return func() (Token, error) {
+		u := azureIMDSEndpoint + "/metadata/identity/oauth2/token?" +
+			url.Values{"api-version": {"2018-02-01"}, "resource": {resource}}.Encode()
+		body, err := metadataGet(u, http.Header{"Metadata": {"true"}})
+		if err != nil {
+			return Token{}, err
+		}
+		var v struct {
+			AccessToken string `json:"access_token"`
+			ExpiresOn   string `json:"expires_on"`
+		}
+		if err := json.Unmarshal(body, &v); err != nil {
+			return Token{}, err
+		}
+		tok := Token{Value: v.AccessToken}
+		if v.ExpiresOn != "" {
+			sec, err := strconv.ParseInt(v.ExpiresOn, 10, 64)
+			if err != nil {
+				return Token{}, fmt.Errorf("invalid token expiry %q", v.ExpiresOn)
+			}
+			tok.Expires = time.Unix(sec, 0)
+		}
+		return tok, nil
+	}
+}
+
+// metadataGet gets u from an instance metadata server.
+func metadataGet(u string, header http.Header) ([]byte, error) {
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header = header
+	resp, err := (&http.Client{Timeout: 2 * time.Second}).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("instance metadata: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
+
+// jwtExpiry returns the expiry of a JSON Web Token, without verifying
+// its signature.
+func jwtExpiry(jwt string) (time.Time, error) {
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		return time.Time{}, errors.New("invalid identity token")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid identity token: %v", err)
+	}
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return time.Time{}, fmt.Errorf("invalid identity token: %v", err)
+	}
+	if claims.Exp == 0 {
+		return time.Time{}, nil
+	}
+	return time.Unix(claims.Exp, 0), nil
+}
@@ -0,0 +1,73 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTokenSourceRefresh(t *testing.T) {
+	var fetches int64
+	exp := time.Now().Add(time.Hour).Unix()
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt64(&fetches, 1)
+		switch r.URL.Path {
+		case "/computeMetadata/v1/instance/service-accounts/default/identity":
+			if r.Header.Get("Metadata-Flavor") != "Google" || r.URL.Query().Get("audience") != "aud" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			claims := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"exp":%d}`, exp)))
+			fmt.Fprintf(w, "h.%s.s%d", claims, n)
+		case "/metadata/identity/oauth2/token":
+			if r.Header.Get("Metadata") != "true" || r.URL.Query().Get("resource") != "res" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			fmt.Fprintf(w, `{"access_token":"t%d","expires_on":"%d"}`, n, exp)
+		}
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+	t.Setenv("GCE_METADATA_HOST", strings.TrimPrefix(server.URL, "http://"))
+	defer func(v string) { azureIMDSEndpoint = v }(azureIMDSEndpoint)
+	azureIMDSEndpoint = server.URL
+
+	for _, fetch := range []func() (Token, error){GCPIdentityToken("aud"), AzureToken("res")} {
+		atomic.StoreInt64(&fetches, 0)
+		s := &TokenSource{Prefix: "Bearer ", Fetch: fetch}
+		var values []string
+		for _, now := range []time.Time{time.Now(), time.Now(), time.Unix(exp, 0)} {
+			req, _ := http.NewRequest("GET", "http://example.com", nil)
+			if err := s.apply(req, now); err != nil {
+				t.Fatal(err)
+			}
+			values = append(values, req.Header.Get("Authorization"))
+		}
+		if values[0] != values[1] || values[1] == values[2] || !strings.HasPrefix(values[0], "Bearer ") {
+			t.Errorf("expected the token to be reused until it expires, found %v", values)
+		}
+		if fetches != 2 {
+			t.Errorf("expected 2 fetches, found %d", fetches)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Phases of a request, in the order they occur.
+const (
+	phaseConnect int32 = iota // waiting for a connection from the pool
+	phaseDNS
+	phaseDial
+	phaseTLS
+	phaseHeaders // writing the request and waiting for response headers
+	phaseBody    // reading the response body
+)
+
+var phaseNames = []string{"connect", "dns", "dial", "tls", "headers", "body"}
+
+// Events of a request whose times are recorded.
+const (
+	markGetConn = iota
+	markDNSStart
+	markDNSDone
+	markConnectStart
+	markConnectDone
+	markTLSStart
+	markTLSDone
+	markGotConn
+	markFirstByte
+	numMarks
+)
+
+// withTrace returns a copy of req that records the connection events
+// of the request in res.
+func withTrace(req *http.Request, res *result) *http.Request {
+	// Dialing happens on another goroutine, and may still be going on
+	// when the request times out.
+	set := func(phase int32) {
+		atomic.StoreInt32(&res.phase, phase)
+	}
+	mark := func(m int) {
+		atomic.StoreInt64(&res.marks[m], time.Now().UnixNano())
+	}
+	trace := &httptrace.ClientTrace{
+		GetConn: func(string) { mark(markGetConn) },
+		DNSStart: func(httptrace.DNSStartInfo) {
+			atomic.AddInt32(&res.dnsLookups, 1)
+			mark(markDNSStart)
+			set(phaseDNS)
+		},
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			mark(markDNSDone)
+			if info.Err != nil {
+				atomic.AddInt32(&res.dnsFailures, 1)
+			}
+		},
+		ConnectStart: func(string, string) {
+			mark(markConnectStart)
+			set(phaseDial)
+		},
+		ConnectDone: func(string, string, error) { mark(markConnectDone) },
+		TLSHandshakeStart: func() {
+			mark(markTLSStart)
+			set(phaseTLS)
+		},
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			mark(markTLSDone)
+			if err != nil {
+				atomic.StoreInt32(&res.handshakeFailed, 1)
+			}
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			res.newConn = !info.Reused
+			if info.Conn != nil {
+				res.family = addrFamily(info.Conn.RemoteAddr())
+			}
+			mark(markGotConn)
+			set(phaseHeaders)
+		},
+		GotFirstResponseByte: func() {
+			mark(markFirstByte)
+			set(phaseBody)
+		},
+	}
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+}
+
+// phaseTimes splits the latency of a completed request by phase.
+func phaseTimes(res *result) PhaseTimes {
+	between := func(from, to int) float64 {
+		a, b := atomic.LoadInt64(&res.marks[from]), atomic.LoadInt64(&res.marks[to])
+		if a == 0 || b < a {
+			return 0
+		}
+		return float64(b-a) / float64(time.Millisecond)
+	}
+	end := res.start.Add(res.duration).UnixNano()
+	var transfer float64
+	if fb := atomic.LoadInt64(&res.marks[markFirstByte]); fb > 0 && end > fb {
+		transfer = float64(end-fb) / float64(time.Millisecond)
+	}
+	p := PhaseTimes{
+		DNS:      between(markDNSStart, markDNSDone),
+		Connect:  between(markConnectStart, markConnectDone),
+		TLS:      between(markTLSStart, markTLSDone),
+		Wait:     between(markGotConn, markFirstByte),
+		Transfer: transfer,
+	}
+	total := res.duration.Seconds() * 1000
+	if p.Other = total - p.DNS - p.Connect - p.TLS - p.Wait - p.Transfer; p.Other < 0 {
+		p.Other = 0
+	}
+	return p
+}
+
+// poolWaitThreshold is how long a request has to wait for a connection,
+// beyond the time taken to dial one, to count as blocked on the pool.
+const poolWaitThreshold = time.Millisecond
+
+// poolWait returns how long res waited for a connection, less the time
+// spent resolving, dialing and handshaking one for it. That is the time
+// spent queued for a free connection once MaxConnsPerHost were open.
+func poolWait(res *result) time.Duration {
+	load := func(m int) int64 { return atomic.LoadInt64(&res.marks[m]) }
+	get, got := load(markGetConn), load(markGotConn)
+	if get == 0 || got < get {
+		return 0
+	}
+	wait := got - get
+	for _, p := range [][2]int{
+		{markDNSStart, markDNSDone},
+		{markConnectStart, markConnectDone},
+		{markTLSStart, markTLSDone},
+	} {
+		if a, b := load(p[0]), load(p[1]); a >= get && b >= a && b <= got {
+			wait -= b - a
+		}
+	}
+	return time.Duration(wait)
+}
+
+// handshakeFailed reports whether the request failed as the TLS
+// handshake of its connection did, including the alerts of servers
+// rejecting the client certificate once the client completed the
+// handshake, as in TLS 1.3.
+func handshakeFailed(res *result) bool {
+	return atomic.LoadInt32(&res.handshakeFailed) != 0 ||
+		res.err != nil && strings.Contains(res.err.Error(), "remote error: tls: ")
+}
+
+// timeoutPhase returns the phase the request was in if err is a
+// timeout, or the empty string otherwise.
+func timeoutPhase(err error, res *result) string {
+	var ne net.Error
+	if !errors.Is(err, context.DeadlineExceeded) && !(errors.As(err, &ne) && ne.Timeout()) {
+		return ""
+	}
+	return phaseNames[atomic.LoadInt32(&res.phase)]
+}
+
+// timeoutLimit returns the timeout that expired for err, a timeout:
+// "dial", "tls" or "header" for DialTimeout, TLSTimeout and
+// HeaderTimeout, or "total" for Timeout and any other deadline.
+func timeoutLimit(err error) string {
+	msg := err.Error()
+	var op *net.OpError
+	switch {
+	case strings.Contains(msg, "Client.Timeout"):
+		return "total"
+	case strings.Contains(msg, "timeout awaiting response headers"):
+		return "header"
+	case strings.Contains(msg, "TLS handshake timeout"):
+		return "tls"
+	case errors.As(err, &op) && op.Op == "dial":
+		return "dial"
+	}
+	return "total"
+}
+
+// gotConn reports whether a connection was obtained for the request,
+// dialed or reused.
+func (res *result) gotConn() bool {
+	return atomic.LoadInt64(&res.marks[markGotConn]) != 0
+}
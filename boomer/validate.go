@@ -0,0 +1,54 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"crypto/sha256"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// validatorCache remembers the body digest first observed for each
+// strong ETag of a URL. A server must not return different bodies under
+// the same strong ETag, so a differing digest indicates an inconsistent
+// backend or a poisoned cache. Weak ETags and Last-Modified values only
+// promise semantically equivalent bodies and are not checked.
+type validatorCache struct {
+	mu      sync.Mutex
+	digests map[string][sha256.Size]byte
+}
+
+func newValidatorCache() *validatorCache {
+	return &validatorCache{digests: make(map[string][sha256.Size]byte)}
+}
+
+// check records the digest of a response body for the strong ETag found
+// in header and reports whether it is consistent with the digest
+// previously seen for the same ETag.
+func (c *validatorCache) check(url string, header http.Header, sum [sha256.Size]byte) bool {
+	etag := header.Get("ETag")
+	if etag == "" || strings.HasPrefix(etag, "W/") {
+		return true
+	}
+	key := url + "\x00" + etag
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	prev, seen := c.digests[key]
+	if !seen {
+		c.digests[key] = sum
+	}
+	return !seen || prev == sum
+}
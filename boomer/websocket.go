@@ -0,0 +1,205 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+	"unicode/utf8"
+)
+
+// WebSocket opcodes.
+const (
+	wsText   = 0x1
+	wsBinary = 0x2
+	wsClose  = 0x8
+	wsPing   = 0x9
+	wsPong   = 0xa
+)
+
+// wsGUID is appended to the key of a handshake to compute its accept
+// value.
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+var (
+	errWSClosed      = errors.New("websocket: closed by the server")
+	errWSFrameLength = errors.New("websocket: invalid frame length")
+)
+
+// websocketRequest makes req the opening handshake of a WebSocket
+// session, returning the key it was sent with.
+func websocketRequest(req *http.Request) string {
+	u := *req.URL
+	switch u.Scheme {
+	case "ws":
+		u.Scheme = "http"
+	case "wss":
+		u.Scheme = "https"
+	}
+	req.URL = &u
+	var nonce [16]byte
+	rand.Read(nonce[:])
+	key := base64.StdEncoding.EncodeToString(nonce[:])
+	req.Method = "GET"
+	req.Body, req.ContentLength = nil, 0
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	return key
+}
+
+// wsMessages returns the messages sent on each session.
+func (b *Boomer) wsMessages() []string {
+	if len(b.WSMessages) > 0 {
+		return b.WSMessages
+	}
+	return []string{b.RequestBody}
+}
+
+// session completes the WebSocket session opened at start by resp, the
+// response to a handshake with key: it sends each message, waiting for
+// a reply to every one, and closes the session.
+func (b *Boomer) session(resp *http.Response, key string, start time.Time) (*streamResult, error) {
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		return nil, fmt.Errorf("websocket: upgrade failed with status %s", resp.Status)
+	}
+	sum := sha1.Sum([]byte(key + wsGUID))
+	if resp.Header.Get("Sec-WebSocket-Accept") != base64.StdEncoding.EncodeToString(sum[:]) {
+		return nil, errors.New("websocket: upgrade failed with an invalid accept key")
+	}
+	conn, ok := resp.Body.(io.ReadWriteCloser)
+	if !ok {
+		return nil, errors.New("websocket: upgrade failed with a read-only connection")
+	}
+	br := bufio.NewReader(conn)
+	res := &streamResult{}
+	for _, msg := range b.wsMessages() {
+		opcode := wsText
+		if !utf8.ValidString(msg) {
+			opcode = wsBinary
+		}
+		sent := time.Now()
+		if err := writeWSFrame(conn, opcode, []byte(msg)); err != nil {
+			return res, err
+		}
+		if err := readWSMessage(br, conn); err != nil {
+			return res, err
+		}
+		now := time.Now()
+		if res.messages == 0 {
+			res.first = now.Sub(start)
+		}
+		res.lats = append(res.lats, now.Sub(sent))
+		res.messages++
+	}
+	return res, writeWSFrame(conn, wsClose, []byte{0x03, 0xe8}) // normal closure
+}
+
+// writeWSFrame writes a single masked frame, as clients must.
+func writeWSFrame(w io.Writer, opcode int, payload []byte) error {
+	header := []byte{0x80 | byte(opcode), 0x80}
+	switch n := len(payload); {
+	case n < 126:
+		header[1] |= byte(n)
+	case n <= 0xffff:
+		header[1] |= 126
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header[1] |= 127
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+	var mask [4]byte
+	rand.Read(mask[:])
+	frame := append(header, mask[:]...)
+	for i, c := range payload {
+		frame = append(frame, c^mask[i%4])
+	}
+	_, err := w.Write(frame)
+	return err
+}
+
+// readWSMessage reads the next data message from r, answering pings on
+// w.
+func readWSMessage(r *bufio.Reader, w io.Writer) error {
+	for {
+		var header [2]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return err
+		}
+		fin, opcode := header[0]&0x80 != 0, int(header[0]&0x0f)
+		n := uint64(header[1] & 0x7f)
+		switch n {
+		case 126:
+			var ext [2]byte
+			if _, err := io.ReadFull(r, ext[:]); err != nil {
+				return err
+			}
+			n = uint64(binary.BigEndian.Uint16(ext[:]))
+		case 127:
+			var ext [8]byte
+			if _, err := io.ReadFull(r, ext[:]); err != nil {
+				return err
+			}
+			n = binary.BigEndian.Uint64(ext[:])
+		}
+		// The most significant bit of a length must be 0, and control
+		// frames carry at most 125 bytes (RFC 6455 5.2 and 5.5).
+		if n>>63 != 0 || opcode >= wsClose && n > 125 {
+			return errWSFrameLength
+		}
+		var mask []byte
+		if header[1]&0x80 != 0 {
+			mask = make([]byte, 4)
+			if _, err := io.ReadFull(r, mask); err != nil {
+				return err
+			}
+		}
+
+		switch opcode {
+		case wsClose:
+			return errWSClosed
+		case wsPing:
+			payload := make([]byte, n)
+			if _, err := io.ReadFull(r, payload); err != nil {
+				return err
+			}
+			for i := range payload {
+				if mask != nil {
+					payload[i] ^= mask[i%4]
+				}
+			}
+			if err := writeWSFrame(w, wsPong, payload); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := io.CopyN(ioutil.Discard, r, int64(n)); err != nil {
+			return err
+		}
+		if fin && opcode != wsPong {
+			return nil
+		}
+	}
+}
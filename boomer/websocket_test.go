@@ -0,0 +1,80 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestWebSocket(t *testing.T) {
+	var messages int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/deny" || r.Header.Get("Upgrade") != "websocket" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		sum := sha1.Sum([]byte(r.Header.Get("Sec-WebSocket-Key") + wsGUID))
+		conn, rw, _ := w.(http.Hijacker).Hijack()
+		defer conn.Close()
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+		rw.WriteString("Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n\r\n")
+		rw.Flush()
+		for readWSMessage(rw.Reader, conn) == nil {
+			atomic.AddInt32(&messages, 1)
+			// A ping the client must answer, then the reply.
+			conn.Write([]byte{0x89, 0x00, 0x81, 0x02, 'o', 'k'})
+		}
+	}))
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+	req, _ := http.NewRequest("GET", url+"/chat", nil)
+	report := runBoomer(t, &Boomer{Request: req, N: 6, C: 3, WebSocket: true, WSMessages: []string{"hello", "\xff\x00"}})
+	st := report.Stream
+	if report.StatusCount(101) != 6 || len(report.Errors) != 0 || st == nil {
+		t.Fatalf("expected 6 sessions, found %+v and errors %+v", report.StatusCodes, report.Errors)
+	}
+	if st.Streams != 6 || st.Messages != 12 || st.Message.Sketch.count() != 12 || atomic.LoadInt32(&messages) != 12 {
+		t.Errorf("unexpected stream stats %+v for %d messages", st, messages)
+	}
+
+	req, _ = http.NewRequest("GET", url+"/deny", nil)
+	report = runBoomer(t, &Boomer{Request: req, N: 2, C: 1, WebSocket: true})
+	if len(report.Errors) != 1 || report.Errors[0].Error != "websocket: upgrade failed with status 403 Forbidden" {
+		t.Errorf("expected upgrade failures, found %+v", report.Errors)
+	}
+}
+
+func TestWSFrameLength(t *testing.T) {
+	for _, frame := range [][]byte{
+		// A ping of 126 bytes.
+		{0x89, 126, 0x00, 126},
+		// A text frame of 2^63 bytes.
+		{0x81, 127, 0x80, 0, 0, 0, 0, 0, 0, 0},
+	} {
+		if err := readWSMessage(bufio.NewReader(bytes.NewReader(frame)), ioutil.Discard); err != errWSFrameLength {
+			t.Errorf("%x: expected %v, found %v", frame, errWSFrameLength, err)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import "sort"
+
+// WorstLatency is the slowest successful request started within one
+// second of a run. A single stall, such as a GC pause or a failover,
+// shows in it even when it is too rare to move any percentile.
+type WorstLatency struct {
+	// Second is the second of the run, counted from zero, the request
+	// started in.
+	Second int `json:"second"`
+
+	// Latency is the latency of the request, in ms.
+	Latency float64 `json:"latency"`
+}
+
+// addWorst records the latency, in ms, of a successful request started
+// at the given second of the run.
+func (r *Report) addWorst(second int, lat float64) {
+	if r.worst == nil {
+		r.worst = make(map[int]float64)
+	}
+	if lat > r.worst[second] {
+		r.worst[second] = lat
+	}
+}
+
+func (r *Report) printWorst() {
+	r.Worst = nil
+	for sec, lat := range r.worst {
+		r.Worst = append(r.Worst, WorstLatency{Second: sec, Latency: lat})
+	}
+	sort.Slice(r.Worst, func(i, j int) bool {
+		return r.Worst[i].Second < r.Worst[j].Second
+	})
+}
+
+// shiftWorst returns the series moved later by the given number of
+// seconds.
+func shiftWorst(series []WorstLatency, seconds int) []WorstLatency {
+	shifted := make([]WorstLatency, len(series))
+	for i, w := range series {
+		shifted[i] = WorstLatency{Second: w.Second + seconds, Latency: w.Latency}
+	}
+	return shifted
+}
@@ -0,0 +1,62 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWorst(t *testing.T) {
+	var count int64
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt64(&count, 1) == 5 {
+			time.Sleep(50 * time.Millisecond)
+		}
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	r := runBoomer(t, &Boomer{Request: req, N: 20, C: 2})
+	if len(r.Worst) != 1 || r.Worst[0].Second != 0 || r.Worst[0].Latency != r.Slowest || r.Slowest < 50 {
+		t.Errorf("expected the slowest request as the worst of second 0, found %+v", r.Worst)
+	}
+}
+
+func TestMergeWorst(t *testing.T) {
+	a := newReport(0, nil, time.Second)
+	a.addWorst(0, 10)
+	a.addWorst(0, 30)
+	a.addWorst(1, 5)
+	a.finalize()
+	b := newReport(0, nil, time.Second)
+	b.addWorst(1, 20)
+	b.finalize()
+
+	m := Merge(a, b)
+	want := []WorstLatency{{0, 30}, {1, 20}}
+	if len(m.Worst) != 2 || m.Worst[0] != want[0] || m.Worst[1] != want[1] {
+		t.Errorf("expected %v, found %v", want, m.Worst)
+	}
+
+	a.TotalDuration = 3000
+	if m := resume(a, b); len(m.Worst) != 3 || m.Worst[2] != (WorstLatency{4, 20}) {
+		t.Errorf("expected the resuming run's series to follow, found %v", m.Worst)
+	}
+}
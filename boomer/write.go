@@ -0,0 +1,477 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"time"
+)
+
+// ReadReport reads a report written by WriteJSON from path.
+func ReadReport(path string) (*Report, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var r Report
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("invalid report %s: %v", path, err)
+	}
+	return &r, nil
+}
+
+// WriteJSON writes the report to w as indented JSON.
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteCSV writes the latency of every successful request to w in
+// comma-separated values format, in seconds. They are only retained
+// with KeepLatencies.
+func (r *Report) WriteCSV(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "response-time")
+	for _, lat := range r.Lats {
+		fmt.Fprintf(bw, "%4.4f\n", lat/1000)
+	}
+	return bw.Flush()
+}
+
+// WriteText writes a human readable summary of the report to w.
+func (r *Report) WriteText(w io.Writer) error {
+	ew := &errWriter{w: w}
+	if r.Name != "" {
+		ew.printf("\n%s:\n", r.Name)
+	}
+	ew.printf("\nSummary:\n")
+	ew.printf("  Total:\t%4.4f secs.\n", float64(r.TotalDuration)/1000)
+	ew.printf("  Slowest:\t%4.4f secs.\n", r.Slowest/1000)
+	ew.printf("  Fastest:\t%4.4f secs.\n", r.Fastest/1000)
+	ew.printf("  Average:\t%4.4f secs.\n", r.Average)
+	ew.printf("  Requests/sec:\t%4.4f\n", r.RPS)
+	if r.SizeTotal > 0 {
+		ew.printf("  Total data:\t%d bytes\n", r.SizeTotal)
+		ew.printf("  Size/request:\t%d bytes\n", r.SizeTotal/int64(r.Responses()))
+	}
+	if r.WireBytes > 0 {
+		ew.printf("  Wire data:\t%d bytes\n", r.WireBytes)
+		ew.printf("  Decoded data:\t%d bytes\n", r.BodyBytes)
+	}
+	if r.ExtraDials > 0 {
+		ew.printf("  Extra dials:\t%d of %d dials\n", r.ExtraDials, r.ConnsDialed)
+	}
+	if r.HandshakeFailures > 0 {
+		ew.printf("  TLS handshake failures:\t%d\n", r.HandshakeFailures)
+	}
+	if r.Warmup > 0 {
+		ew.printf("  Warm-up:\t%d requests, not counted\n", r.Warmup)
+	}
+	if r.Stalls > 0 {
+		ew.printf("  Stalls:\t%d requests\n", r.Stalls)
+	}
+	if r.Truncated > 0 {
+		ew.printf("  Truncated:\t%d responses\n", r.Truncated)
+	}
+	if r.ValidatorMismatches > 0 {
+		ew.printf("  Validator mismatches:\t%d\n", r.ValidatorMismatches)
+	}
+	if r.Stopped != "" {
+		ew.printf("  Stopped:\t%s\n", r.Stopped)
+	}
+	if r.Partial {
+		ew.printf("  Partial:\tthe run was interrupted, only the requests made until then are reported\n")
+	}
+	if r.Resumed > 0 {
+		ew.printf("  Resumed:\tafter %d requests\n", r.Resumed)
+	}
+	if r.CheckpointError != "" {
+		ew.printf("  Checkpoint error:\t%s\n", r.CheckpointError)
+	}
+	for _, e := range r.SinkErrors {
+		ew.printf("  Sink error:\t%s\n", e)
+	}
+	for _, d := range r.Degraded {
+		ew.printf("  Degraded:\t%s\n", d)
+	}
+
+	if len(r.Histogram) > 0 {
+		ew.printf("\nResponse time histogram:\n")
+		var max int
+		for _, b := range r.Histogram {
+			if b.Count > max {
+				max = b.Count
+			}
+		}
+		for _, b := range r.Histogram {
+			var bar int
+			if max > 0 {
+				bar = b.Count * 40 / max
+			}
+			ew.printf("  %4.3f [%v]\t|%v\n", b.Bucket/1000, b.Count, strings.Repeat(barChar, bar))
+		}
+	}
+
+	if len(r.Percentiales) > 0 {
+		ew.printf("\nLatency distribution:\n")
+		for _, p := range r.Percentiales {
+			ew.printf("  %v%% in %4.4f secs.\n", p.Percent, p.Count/1000)
+		}
+	}
+
+	if len(r.Worst) > 0 {
+		ew.printf("\nWorst latency per second:\n")
+		var max float64
+		for _, w := range r.Worst {
+			if w.Latency > max {
+				max = w.Latency
+			}
+		}
+		for _, w := range r.Worst {
+			var bar int
+			if max > 0 {
+				bar = int(w.Latency * 40 / max)
+			}
+			ew.printf("  %ds\t%4.4f secs.\t|%v\n", w.Second, w.Latency/1000, strings.Repeat(barChar, bar))
+		}
+	}
+
+	if len(r.Intervals) > 0 {
+		ew.printf("\nIntervals:\n")
+		for _, iv := range r.Intervals {
+			ew.printf("  %gs-%gs\t%d requests\t%4.1f req/s\tp50 %4.4f, p95 %4.4f, p99 %4.4f secs.\t%d errors\n",
+				iv.Start, iv.End, iv.Requests, iv.RPS, iv.P50/1000, iv.P95/1000, iv.P99/1000, iv.Errors)
+		}
+	}
+
+	if len(r.StatusCodes) > 0 {
+		ew.printf("\nStatus code distribution:\n")
+		for _, s := range r.StatusCodes {
+			lats := ""
+			if s.Average > 0 {
+				lats = fmt.Sprintf("\t%4.4f secs. average, %4.4f secs. p50, %4.4f secs. p99", s.Average/1000, s.P50/1000, s.P99/1000)
+			}
+			if name := GRPCCodeName(s.Code); r.GRPC && name != "" {
+				ew.printf("  [%d %s]\t%d responses%s\n", s.Code, name, s.Count, lats)
+				continue
+			}
+			ew.printf("  [%d]\t%d responses%s\n", s.Code, s.Count, lats)
+		}
+	}
+
+	if r.Failures != nil && r.Successes != nil {
+		ew.printf("\nLatency by outcome:\n")
+		for _, o := range []struct {
+			name string
+			s    *LatencyStats
+		}{{"successes", r.Successes}, {"failures", r.Failures}} {
+			ew.printf("  %s\t%d requests\t%4.4f secs. average, %4.4f secs. p50, %4.4f secs. p90, %4.4f secs. p99\n",
+				o.name, o.s.Count, o.s.Average/1000, o.s.P50/1000, o.s.P90/1000, o.s.P99/1000)
+		}
+	}
+
+	if r.NewConns != nil && r.ReusedConns != nil {
+		ew.printf("\nLatency by connection:\n")
+		for _, o := range []struct {
+			name string
+			s    *LatencyStats
+		}{{"new", r.NewConns}, {"reused", r.ReusedConns}} {
+			ew.printf("  %s\t%d responses\t%4.4f secs. average, %4.4f secs. p50, %4.4f secs. p90, %4.4f secs. p99\n",
+				o.name, o.s.Count, o.s.Average/1000, o.s.P50/1000, o.s.P90/1000, o.s.P99/1000)
+		}
+	}
+
+	if d := r.DNS; d != nil {
+		ew.printf("\nDNS lookups:\n")
+		ew.printf("  %d lookups\t%d failed\t%4.4f secs. average, %4.4f secs. p50, %4.4f secs. p99\n",
+			d.Lookups, d.Failures, d.Latency.Average/1000, d.Latency.P50/1000, d.Latency.P99/1000)
+	}
+
+	if s := r.PoolWaits; s != nil {
+		ew.printf("\nWaited for a connection:\n")
+		ew.printf("  %d requests\t%4.4f secs. average, %4.4f secs. p50, %4.4f secs. p90, %4.4f secs. p99\n",
+			s.Count, s.Average/1000, s.P50/1000, s.P90/1000, s.P99/1000)
+	}
+
+	if len(r.RedirectCodes) > 0 {
+		ew.printf("\nRedirects:\n")
+		for _, rc := range r.Redirects {
+			ew.printf("  %d redirects\t%d responses\n", rc.Hops, rc.Count)
+		}
+		for _, s := range r.RedirectCodes {
+			ew.printf("  [%d]\t%d redirects\n", s.Code, s.Count)
+		}
+	}
+
+	if len(r.Retries) > 0 {
+		ew.printf("\nRetries:\n")
+		for _, rc := range r.Retries {
+			ew.printf("  %d retries\t%d requests, %d succeeded\t%4.4f secs. average\n", rc.Retries, rc.Requests, rc.Succeeded, rc.Average/1000)
+		}
+	}
+
+	if len(r.Protocols) > 1 || len(r.Protocols) == 1 && r.Protocols[0].Proto != "HTTP/1.1" {
+		ew.printf("\nProtocols:\n")
+		for _, p := range r.Protocols {
+			ew.printf("  %s\t%d responses over %d new connections\n", p.Proto, p.Responses, p.Conns)
+		}
+	}
+
+	if len(r.Families) > 1 || len(r.Families) == 1 && r.Families[0].Family != "IPv4" {
+		ew.printf("\nAddress families:\n")
+		for _, f := range r.Families {
+			ew.printf("  %s\t%d responses over %d new connections\n", f.Family, f.Responses, f.Conns)
+		}
+	}
+
+	if st := r.Stream; st != nil && st.Streams > 0 {
+		ew.printf("\nStreams:\n")
+		ew.printf("  Messages:\t%d in %d streams\n", st.Messages, st.Streams)
+		for _, l := range []struct {
+			name string
+			l    StreamLatency
+		}{{"First message", st.FirstMessage}, {"Message", st.Message}} {
+			if l.l.Sketch.count() > 0 {
+				ew.printf("  %s:\t%4.4f secs. average, %4.4f secs. p50, %4.4f secs. p99\n",
+					l.name, l.l.Average/1000, l.l.P50/1000, l.l.P99/1000)
+			}
+		}
+	}
+
+	if len(r.Errors) > 0 {
+		ew.printf("\nError distribution:\n")
+		for _, e := range r.Errors {
+			ew.printf("  [%d]\t%s\n", e.Count, e.Error)
+		}
+	}
+
+	if len(r.Timeouts) > 0 {
+		ew.printf("\nTimeouts:\n")
+		for _, t := range r.Timeouts {
+			if t.Limit == "" {
+				ew.printf("  [%d]\tin %s\n", t.Count, t.Phase)
+				continue
+			}
+			ew.printf("  [%d]\tin %s\t%s timeout\n", t.Count, t.Phase, t.Limit)
+		}
+	}
+
+	if len(r.Aborts) > 0 {
+		ew.printf("\nAborted requests:\n")
+		for _, a := range r.Aborts {
+			ew.printf("  [%d]\t%s\n", a.Count, a.Phase)
+		}
+	}
+
+	if len(r.HeaderChecks) > 0 || len(r.FieldChecks) > 0 || len(r.BodyChecks) > 0 {
+		ew.printf("\nChecks:\n")
+		for _, c := range append(append(append([]CheckResult(nil), r.HeaderChecks...), r.FieldChecks...), r.BodyChecks...) {
+			ew.printf("  %s\t%d passed, %d failed\n", c.Check, c.Passed, c.Failed)
+		}
+		if r.DecodeErrors > 0 {
+			ew.printf("  %d bodies could not be decoded\n", r.DecodeErrors)
+		}
+		if r.AssertionFailures > 0 {
+			ew.printf("  %d responses failed body checks\n", r.AssertionFailures)
+		}
+	}
+
+	if len(r.Schema) > 0 {
+		ew.printf("\nSchema validation:\n")
+		for _, s := range r.Schema {
+			ew.printf("  %s\t%d of %d failed", s.Endpoint, s.Failed, s.Validated)
+			if s.FirstError != "" {
+				ew.printf(" (%s)", s.FirstError)
+			}
+			ew.printf("\n")
+		}
+	}
+
+	if len(r.Compression) > 0 {
+		ew.printf("\nCompression (ratio %4.2f):\n", r.CompressionRatio)
+		for _, c := range r.Compression {
+			ew.printf("  %s\tratio %4.2f, %d of %d uncompressed\n", c.Endpoint, c.Ratio, c.Uncompressed, c.Responses)
+		}
+	}
+
+	if len(r.Variants) > 0 {
+		ew.printf("\nResponse body variants:\n")
+		for _, v := range r.Variants {
+			ew.printf("  %s\t%d distinct in %d responses\n", v.Endpoint, v.Distinct, v.Responses)
+		}
+	}
+
+	if len(r.Budget) > 0 {
+		ew.printf("\nLatency budget (dns/connect/tls/wait/transfer/other):\n")
+		for _, b := range r.Budget {
+			ew.printf("  %s\n", b.Endpoint)
+			for _, l := range []struct {
+				name string
+				p    PhaseTimes
+			}{{"average", b.Average}, {"p99", b.P99}} {
+				total := l.p.Total()
+				share := func(v float64) float64 {
+					if total == 0 {
+						return 0
+					}
+					return v / total * 100
+				}
+				ew.printf("    %s\t%4.4f secs.\t%2.0f%% / %2.0f%% / %2.0f%% / %2.0f%% / %2.0f%% / %2.0f%%\n",
+					l.name, total/1000, share(l.p.DNS), share(l.p.Connect), share(l.p.TLS),
+					share(l.p.Wait), share(l.p.Transfer), share(l.p.Other))
+			}
+		}
+	}
+
+	if len(r.Shards) > 0 {
+		ew.printf("\nShards:\n")
+		for _, sh := range r.Shards {
+			slow := ""
+			if sh.Slow {
+				slow = ", slow"
+			}
+			ew.printf("  %s\t%d requests, %d errors\t%4.4f secs. average, %4.4f secs. p99%s\n",
+				sh.Host, sh.Requests, sh.Errors, sh.Average/1000, sh.P99/1000, slow)
+		}
+	}
+
+	if len(r.Endpoints) > 0 {
+		ew.printf("\nEndpoints:\n")
+		for _, e := range r.Endpoints {
+			label := e.Method + " " + e.URL
+			if e.Name != "" {
+				label = e.Name + ": " + label
+			}
+			ew.printf("  %s\t%d requests, %d errors, %d error responses, %d bytes\t%4.4f secs. average, %4.4f secs. p90, %4.4f secs. p99\n",
+				label, e.Requests, e.Errors, e.ErrorResponses, e.Bytes, e.Average/1000, e.P90/1000, e.P99/1000)
+		}
+	}
+
+	if len(r.Ramp) > 0 {
+		ew.printf("\nRamp:\n")
+		for _, p := range r.Ramp {
+			ew.printf("  %s\t%4.1fs-%4.1fs\t%d requests, %d errors, %4.1f req/s\t%4.4f secs. average, %4.4f secs. p99\n",
+				p.Phase, p.Start, p.End, p.Requests, p.Errors, p.RPS, p.Average/1000, p.P99/1000)
+		}
+	}
+
+	if len(r.Stages) > 0 {
+		ew.printf("\nStages:\n")
+		for _, s := range r.Stages {
+			ew.printf("  %s\t%d requests, %d errors, %4.1f req/s\t%4.4f secs. average, %4.4f secs. p99\n",
+				s.Name, s.Responses()+s.ErrorCount(), s.ErrorCount(), s.RPS, s.Average, s.Percentile(99)/1000)
+		}
+	}
+
+	if len(r.Baseline) > 0 {
+		ew.printf("\nBaseline comparison (positive changes are regressions):\n")
+		for _, c := range r.Baseline {
+			regressed := ""
+			if c.Regressed {
+				regressed = ", regressed"
+			}
+			change := fmt.Sprintf("%+4.1f%%", c.Change*100)
+			if c.Metric == "error_rate" {
+				change = fmt.Sprintf("%+4.1f points", c.Change*100)
+			}
+			ew.printf("  %s\t%4.4f vs %4.4f\t%s%s\n", c.Metric, c.Value, c.Baseline, change, regressed)
+		}
+	}
+
+	if len(r.Thresholds) > 0 {
+		ew.printf("\nThresholds:\n")
+		for _, t := range r.Thresholds {
+			verdict := "passed"
+			if t.Failed {
+				verdict = "failed"
+			}
+			ew.printf("  %s\t%s %s\t%s\n", t.Metric, formatMetric(t.Metric, t.Value), t.limit(), verdict)
+		}
+	}
+
+	if s := r.SLO; s != nil {
+		ew.printf("\nSLO (%s):\n", s)
+		ew.printf("  Bad requests:\t%d of %d\n", s.Bad, s.Total)
+		ew.printf("  Burn rate:\t%4.2f\n", s.BurnRate)
+		if s.Exhausted > 0 {
+			ew.printf("  Budget spent in:\t%v\n", time.Duration(s.Exhausted*float64(time.Hour)).Round(time.Minute))
+		}
+		for _, w := range s.Windows {
+			alert := ""
+			if w.Alert {
+				alert = ", alerts"
+			}
+			ew.printf("  %vh window\t%4.1f%% of budget, threshold %4.2f%s\n", w.Window, w.BudgetSpent*100, w.Threshold, alert)
+		}
+	}
+
+	if len(r.Scaling) > 0 {
+		ew.printf("\nWorker scaling:\n")
+		for i, iv := range r.Scaling {
+			if i > 0 && i < len(r.Scaling)-1 && iv.Workers == r.Scaling[i-1].Workers {
+				continue
+			}
+			ew.printf("  %4.1fs\t%d workers, %2.0f%% cpu, %4.1f req/s\n", iv.Second, iv.Workers, iv.CPU*100, iv.RPS)
+		}
+	}
+
+	if s := r.Schedule; s != nil {
+		ew.printf("\nSchedule:\n")
+		if s.Poisson {
+			ew.printf("  Arrivals:\tPoisson\n")
+		}
+		ew.printf("  Intended rate:\t%4.1f req/s\n", s.IntendedRPS)
+		ew.printf("  Achieved rate:\t%4.1f req/s\n", s.AchievedRPS)
+		ew.printf("  Late:\t%d (at most %4.4f secs.)\n", s.Late, s.MaxLag.Seconds())
+		ew.printf("  Workers:\t%d\n", s.Workers)
+		for _, g := range []struct {
+			name string
+			s    *LatencyStats
+		}{{"Intended gaps", s.IntendedGaps}, {"Delivered gaps", s.DeliveredGaps}} {
+			if g.s != nil {
+				ew.printf("  %s:\t%4.4f secs. average, %4.4f secs. p50, %4.4f secs. p99\n", g.name, g.s.Average/1000, g.s.P50/1000, g.s.P99/1000)
+			}
+		}
+	}
+
+	if c := r.Churn; c != nil {
+		ew.printf("\nConnection churn:\n")
+		ew.printf("  Attempts:\t%d (%d failed)\n", c.Attempts, c.Failures)
+		ew.printf("  Connect:\t%4.4f secs. average\n", c.AvgConnect/1000)
+		if c.AvgHandshake > 0 {
+			ew.printf("  Handshake:\t%4.4f secs. average\n", c.AvgHandshake/1000)
+		}
+	}
+	return ew.err
+}
+
+// errWriter remembers the first error of a series of writes.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (ew *errWriter) printf(format string, args ...interface{}) {
+	if ew.err != nil {
+		return
+	}
+	_, ew.err = fmt.Fprintf(ew.w, format, args...)
+}
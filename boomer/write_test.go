@@ -0,0 +1,173 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boomer
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testReport() *Report {
+	r := newReport(0, nil, 2*time.Second)
+	r.Lats = []float64{100, 200, 300, 400}
+	r.AvgTotal = 1
+	r.statusCodeDist[200] = 4
+	r.errorDist["connection refused"] = 1
+	r.printStatusCodes()
+	r.printErrors()
+	r.summarize()
+	return r
+}
+
+func TestWriteText(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testReport().WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"Requests/sec:\t2.0000",
+		"Slowest:\t0.4000 secs.",
+		"[200]\t4 responses",
+		"[1]\tconnection refused",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Expected text report to contain %q, found:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testReport().WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := "response-time\n0.1000\n0.2000\n0.3000\n0.4000\n"
+	if buf.String() != want {
+		t.Errorf("Expected CSV %q, found %q", want, buf.String())
+	}
+}
+
+func TestWriteSummaryCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testReport().WriteSummaryCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"metric,value\n", "rps,2\n", "slowest,0.4\n", "errors,1\n", "p25,0.3\n", "status_200,4\n"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Expected summary CSV to contain %q, found:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestWriteHTML(t *testing.T) {
+	r := testReport()
+	r.Name = "<api>"
+	var buf bytes.Buffer
+	if err := r.WriteHTML(&buf); err != nil {
+		t.Fatal(err)
+	}
+	page := buf.String()
+	for _, want := range []string{
+		"<title>boom report: &lt;api&gt;</title>",
+		"<h2>Response time histogram</h2>",
+		"<th>200</th><td>4 responses</td>",
+		"<td>1</td><td>connection refused</td>",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("Expected HTML report to contain %q, found:\n%s", want, page)
+		}
+	}
+	if n := strings.Count(page, "<rect "); n != len(r.Percentiales)+len(r.Histogram) {
+		t.Errorf("Expected a bar per percentile and bucket, found %v", n)
+	}
+	if strings.Contains(page, "<script") || strings.Contains(page, "src=") || strings.Contains(page, "href=") {
+		t.Errorf("Expected a self-contained page")
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testReport().WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var r Report
+	if err := json.Unmarshal(buf.Bytes(), &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.RPS != 2 || len(r.Lats) != 4 || len(r.Errors) != 1 {
+		t.Errorf("JSON report was not decoded correctly: %+v", r)
+	}
+}
+
+func TestWriteXML(t *testing.T) {
+	var buf bytes.Buffer
+	r := testReport()
+	if err := r.WriteXML(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		XMLName      xml.Name `xml:"report"`
+		RPS          float64  `xml:"rps"`
+		Percentiales []struct {
+			Percent int     `xml:"percent"`
+			Count   float64 `xml:"count"`
+		} `xml:"percentiales>item"`
+		Histogram []struct {
+			Bucket float64 `xml:"bucket"`
+			Count  int     `xml:"count"`
+		} `xml:"histogram>item"`
+		Lats        []float64 `xml:"lats>item"`
+		StatusCodes []struct {
+			Code  int `xml:"code"`
+			Count int `xml:"count"`
+		} `xml:"status_codes>item"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("invalid XML: %v\n%s", err, buf.String())
+	}
+	if got.RPS != r.RPS || len(got.Lats) != 4 || len(got.StatusCodes) != 1 || got.StatusCodes[0].Count != 4 {
+		t.Errorf("unexpected XML report:\n%s", buf.String())
+	}
+	if len(got.Percentiales) != len(r.Percentiales) || got.Percentiales[2].Count != r.Percentiales[2].Count {
+		t.Errorf("expected percentiles %v, found %v", r.Percentiales, got.Percentiales)
+	}
+	if len(got.Histogram) != len(r.Histogram) || got.Histogram[10].Bucket != r.Histogram[10].Bucket {
+		t.Errorf("expected histogram %v, found %v", r.Histogram, got.Histogram)
+	}
+}
+
+func TestWriteHgrm(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testReport().WriteHgrm(&buf); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(buf.String(), "\n")
+	for i, want := range map[int]string{
+		0:  "       Value     Percentile TotalCount 1/(1-Percentile)",
+		2:  "     100.000 0.000000000000          1           1.00",
+		7:  "     200.000 0.500000000000          2           2.00",
+		8:  "     300.000 0.550000000000          3           2.22",
+		13: "     400.000 1.000000000000          4",
+		14: "#[Mean    =      250.000, StdDeviation   =      111.803]",
+	} {
+		if lines[i] != want {
+			t.Errorf("line %d: expected %q, found %q", i, want, lines[i])
+		}
+	}
+}
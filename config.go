@@ -0,0 +1,361 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// configExpanded are the options whose values the command line
+// substitutes environment variables in, which are left to it.
+var configExpanded = map[string]bool{"url": true, "h": true, "d": true, "a": true, "secret-header": true}
+
+// configJoin are the separators the items of options that take a single
+// value are joined with when given as a list in a -f file.
+var configJoin = map[string]string{"h": ";"}
+
+// applyConfig sets the options of a -f run configuration file that are
+// not given on the command line. The file maps option names, without
+// the dash, to their values, and "url" to the url, e.g. in YAML
+//
+//	url: https://example.com/items
+//	c: 20
+//	z: 1m
+//	h:
+//	  - "Accept: application/json"
+//	  - "X-Team: ${TEAM}"
+//	threshold: [p95<300ms, error_rate<1%]
+//
+// Files ending in .toml are read as TOML, others as YAML, of which
+// JSON is a subset. Only flat mappings of scalars and lists of scalars
+// are supported. Environment variables are substituted in the values
+// as in the url. It returns the options set as arguments, and the url
+// of the file.
+func applyConfig(path string) ([]string, string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, "", err
+	}
+	var cfg map[string][]string
+	switch {
+	case filepath.Ext(path) == ".toml":
+		cfg, err = parseTOML(data)
+	case bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")):
+		cfg, err = parseJSONConfig(data)
+	default:
+		cfg, err = parseYAML(data)
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid config file %s: %v", path, err)
+	}
+
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	var args []string
+	var url string
+	for _, name := range sortedKeys(cfg) {
+		values := cfg[name]
+		if !configExpanded[name] {
+			for i, v := range values {
+				if values[i], err = expandEnv(v); err != nil {
+					return nil, "", fmt.Errorf("invalid config file %s: %s: %v", path, name, err)
+				}
+			}
+		}
+		if name == "url" {
+			if len(values) != 1 {
+				return nil, "", fmt.Errorf("invalid config file %s: url must be a single value", path)
+			}
+			url = values[0]
+			continue
+		}
+		if flag.Lookup(name) == nil || name == "f" {
+			return nil, "", fmt.Errorf("invalid config file %s: unknown option %q", path, name)
+		}
+		if set[name] {
+			continue
+		}
+		if sep, ok := configJoin[name]; ok {
+			values = []string{strings.Join(values, sep)}
+		}
+		for _, v := range values {
+			if err := flag.Set(name, v); err != nil {
+				return nil, "", fmt.Errorf("invalid config file %s: %s: %v", path, name, err)
+			}
+			args = append(args, "-"+name+"="+v)
+		}
+	}
+	return args, url, nil
+}
+
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// parseJSONConfig parses a -f file in JSON.
+func parseJSONConfig(data []byte) (map[string][]string, error) {
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	cfg := make(map[string][]string)
+	for k, v := range doc {
+		items, ok := v.([]interface{})
+		if !ok {
+			items = []interface{}{v}
+		}
+		for _, item := range items {
+			switch item.(type) {
+			case []interface{}, map[string]interface{}, nil:
+				return nil, fmt.Errorf("%s: only scalars and lists of scalars are supported", k)
+			}
+			cfg[k] = append(cfg[k], fmt.Sprint(item))
+		}
+	}
+	return cfg, nil
+}
+
+// parseYAML parses a -f file in YAML: a mapping of keys to scalars,
+// flow lists as [a, b], block lists of "- item" lines and literal
+// block scalars introduced by "|".
+func parseYAML(data []byte) (map[string][]string, error) {
+	cfg := make(map[string][]string)
+	var key string      // the key whose block list or scalar follows
+	var literal bool    // whether the block of key is a literal scalar
+	var block []string  // the lines of the literal scalar
+	var blockIndent int // the indentation of the block, once known
+	flush := func() {
+		if literal {
+			for len(block) > 0 && block[len(block)-1] == "" {
+				block = block[:len(block)-1]
+			}
+			cfg[key] = []string{strings.Join(block, "\n") + "\n"}
+		}
+		key, literal, block, blockIndent = "", false, nil, 0
+	}
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; s.Scan(); line++ {
+		text := strings.TrimRight(s.Text(), " \t\r")
+		indent := len(text) - len(strings.TrimLeft(text, " "))
+		if literal {
+			if text == "" {
+				block = append(block, "")
+				continue
+			}
+			if blockIndent == 0 {
+				blockIndent = indent
+			}
+			if indent > 0 && indent >= blockIndent {
+				block = append(block, text[blockIndent:])
+				continue
+			}
+			flush()
+		}
+		text = strings.TrimSpace(stripComment(text))
+		switch {
+		case text == "", text == "---":
+			continue
+		case strings.HasPrefix(text, "- ") || text == "-":
+			if key == "" {
+				return nil, fmt.Errorf("line %d: list item without a key", line)
+			}
+			v, err := yamlScalar(strings.TrimSpace(strings.TrimPrefix(text, "-")))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", line, err)
+			}
+			cfg[key] = append(cfg[key], v)
+			continue
+		case indent > 0:
+			return nil, fmt.Errorf("line %d: nested mappings are not supported", line)
+		}
+		flush()
+		i := strings.Index(text, ":")
+		if i <= 0 {
+			return nil, fmt.Errorf("line %d: expected key: value", line)
+		}
+		k, v := strings.TrimSpace(text[:i]), strings.TrimSpace(text[i+1:])
+		if _, ok := cfg[k]; ok {
+			return nil, fmt.Errorf("line %d: duplicate key %q", line, k)
+		}
+		switch {
+		case v == "":
+			key, cfg[k] = k, nil
+		case v == "|":
+			key, literal, cfg[k] = k, true, nil
+		case strings.HasPrefix(v, "["):
+			items, err := flowList(v, yamlScalar)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", line, err)
+			}
+			cfg[k] = items
+		default:
+			s, err := yamlScalar(v)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", line, err)
+			}
+			cfg[k] = []string{s}
+		}
+	}
+	flush()
+	return cfg, s.Err()
+}
+
+// yamlScalar returns the value of a YAML scalar, unquoting it.
+func yamlScalar(v string) (string, error) {
+	switch {
+	case strings.HasPrefix(v, `"`):
+		return strconv.Unquote(v)
+	case strings.HasPrefix(v, "'"):
+		if len(v) < 2 || !strings.HasSuffix(v, "'") {
+			return "", fmt.Errorf("unterminated string %s", v)
+		}
+		return strings.Replace(v[1:len(v)-1], "''", "'", -1), nil
+	case strings.HasPrefix(v, "{"), strings.HasPrefix(v, "["):
+		return "", fmt.Errorf("nested values are not supported")
+	}
+	return v, nil
+}
+
+// parseTOML parses a -f file in TOML: key = value lines, the values
+// being strings, numbers, booleans or arrays of them on one line.
+func parseTOML(data []byte) (map[string][]string, error) {
+	cfg := make(map[string][]string)
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; s.Scan(); line++ {
+		text := strings.TrimSpace(stripComment(s.Text()))
+		if text == "" {
+			continue
+		}
+		if strings.HasPrefix(text, "[") {
+			return nil, fmt.Errorf("line %d: tables are not supported", line)
+		}
+		i := strings.Index(text, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("line %d: expected key = value", line)
+		}
+		k, v := strings.TrimSpace(text[:i]), strings.TrimSpace(text[i+1:])
+		if uk, err := strconv.Unquote(k); err == nil {
+			k = uk
+		}
+		if _, ok := cfg[k]; ok {
+			return nil, fmt.Errorf("line %d: duplicate key %q", line, k)
+		}
+		var err error
+		if strings.HasPrefix(v, "[") {
+			cfg[k], err = flowList(v, tomlValue)
+		} else {
+			var s string
+			s, err = tomlValue(v)
+			cfg[k] = []string{s}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+	}
+	return cfg, s.Err()
+}
+
+// tomlValue returns the value of a TOML string, number or boolean.
+func tomlValue(v string) (string, error) {
+	switch {
+	case strings.HasPrefix(v, `"""`), strings.HasPrefix(v, "'''"):
+		return "", fmt.Errorf("multi-line strings are not supported")
+	case strings.HasPrefix(v, `"`):
+		return strconv.Unquote(v)
+	case strings.HasPrefix(v, "'"):
+		if len(v) < 2 || !strings.HasSuffix(v, "'") {
+			return "", fmt.Errorf("unterminated string %s", v)
+		}
+		return v[1 : len(v)-1], nil
+	case v == "true", v == "false":
+		return v, nil
+	}
+	if _, err := strconv.ParseFloat(strings.Replace(v, "_", "", -1), 64); err != nil {
+		return "", fmt.Errorf("invalid value %s", v)
+	}
+	return strings.Replace(v, "_", "", -1), nil
+}
+
+// flowList splits a one line list, [a, "b, c"], into the values of its
+// items.
+func flowList(v string, value func(string) (string, error)) ([]string, error) {
+	if !strings.HasSuffix(v, "]") {
+		return nil, fmt.Errorf("unterminated list %s", v)
+	}
+	var items []string
+	for _, item := range splitOutsideQuotes(v[1:len(v)-1], ',') {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		s, err := value(item)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, s)
+	}
+	return items, nil
+}
+
+// stripComment removes a comment from the line, starting with a #
+// outside quotes at the start of the line or after a space.
+func stripComment(line string) string {
+	start := 0
+	for _, part := range splitOutsideQuotes(line, '#') {
+		i := start + len(part)
+		if i < len(line) && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t') {
+			return line[:i]
+		}
+		start = i + 1
+	}
+	return line
+}
+
+// splitOutsideQuotes splits s at every sep outside single or double
+// quotes.
+func splitOutsideQuotes(s string, sep byte) []string {
+	var parts []string
+	var quote byte
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == sep:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
@@ -0,0 +1,66 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+
+	"github.com/rakyll/boom/boomer"
+)
+
+// diffMain runs "boom diff", which compares the JSON reports of two
+// runs, e.g. before and after a deploy. It exits with status 2 if any
+// metric regressed by more than -max-regression.
+func diffMain(args []string) {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	fs.Usage = flag.Usage
+	output := fs.String("o", "", "")
+	maxRegression := fs.String("max-regression", "10%", "")
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		usageAndExit("diff requires the JSON reports of two runs.")
+	}
+	max, err := parsePercent(*maxRegression)
+	if err != nil {
+		usageAndExit(err.Error())
+	}
+	before, err := boomer.ReadReport(fs.Arg(0))
+	if err != nil {
+		usageAndExit(err.Error())
+	}
+	after, err := boomer.ReadReport(fs.Arg(1))
+	if err != nil {
+		usageAndExit(err.Error())
+	}
+
+	cs := boomer.Compare(before, after, max)
+	switch *output {
+	case "":
+		printErr(boomer.WriteDiff(os.Stdout, cs))
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		printErr(enc.Encode(cs))
+	default:
+		usageAndExit(`diff only supports -o "json".`)
+	}
+	for _, c := range cs {
+		if c.Regressed {
+			os.Exit(2)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/rakyll/boom/boomer"
+)
+
+// loadEndpoints reads a -urls file, listing a URL per line, optionally
+// preceded by a method and followed by a weight, e.g.
+//
+//	GET http://localhost/items 8
+//	POST http://localhost/items 2
+//	http://localhost/health
+//
+// Blank lines and lines starting with # are skipped. Environment
+// variables are substituted for ${VAR}.
+func loadEndpoints(path string) ([]boomer.Endpoint, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var endpoints []boomer.Endpoint
+	s := bufio.NewScanner(f)
+	for line := 1; s.Scan(); line++ {
+		text := strings.TrimSpace(s.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		e, err := parseEndpoint(text)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+		endpoints = append(endpoints, e)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("no urls in %v", path)
+	}
+	return endpoints, nil
+}
+
+// parseEndpoint parses a line of a -urls file.
+func parseEndpoint(line string) (boomer.Endpoint, error) {
+	var e boomer.Endpoint
+	line, err := expandEnv(line)
+	if err != nil {
+		return e, err
+	}
+	fields := strings.Fields(line)
+	if len(fields) > 1 && !strings.Contains(fields[0], "://") {
+		e.Method, fields = strings.ToUpper(fields[0]), fields[1:]
+	}
+	switch len(fields) {
+	case 2:
+		if e.Weight, err = strconv.Atoi(fields[1]); err != nil || e.Weight <= 0 {
+			return e, fmt.Errorf("invalid weight %q", fields[1])
+		}
+	case 1:
+	default:
+		return e, fmt.Errorf("expected [method] url [weight], found %q", line)
+	}
+	e.URL = fields[0]
+	u, err := url.Parse(e.URL)
+	if err != nil {
+		return e, err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return e, fmt.Errorf("invalid url %q", e.URL)
+	}
+	return e, nil
+}
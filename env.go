@@ -0,0 +1,88 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// expandEnv replaces ${VAR} in s with the value of the environment
+// variable VAR, and ${VAR:-default} with default if VAR is unset or
+// empty. ${file:path} is replaced with the content of the file at path
+// without its trailing newline, e.g. a secret mounted in a container,
+// or the default if the file does not exist. $${ is left as a literal
+// ${. Other uses of $ are left alone, so that bodies need not be
+// escaped. All the variables referenced but not set are listed in the
+// error.
+func expandEnv(s string) (string, error) {
+	if !strings.Contains(s, "${") {
+		return s, nil
+	}
+	var b strings.Builder
+	var missing []string
+	for {
+		i := strings.Index(s, "${")
+		if i < 0 {
+			break
+		}
+		if i > 0 && s[i-1] == '$' {
+			b.WriteString(s[:i-1])
+			b.WriteString("${")
+			s = s[i+2:]
+			continue
+		}
+		end := strings.Index(s[i:], "}")
+		if end < 0 {
+			return "", fmt.Errorf("unterminated ${ in %q", s[i:])
+		}
+		b.WriteString(s[:i])
+		name, def := s[i+2:i+end], ""
+		hasDef := false
+		if j := strings.Index(name, ":-"); j >= 0 {
+			name, def, hasDef = name[:j], name[j+2:], true
+		}
+		var v string
+		var ok bool
+		if path := strings.TrimPrefix(name, "file:"); path != name {
+			data, err := ioutil.ReadFile(path)
+			if err != nil && !(hasDef && os.IsNotExist(err)) {
+				return "", err
+			}
+			v, ok = strings.TrimRight(string(data), "\r\n"), true
+		} else {
+			v, ok = os.LookupEnv(name)
+		}
+		switch {
+		case hasDef && v == "":
+			v = def
+		case !ok:
+			missing = append(missing, name)
+		}
+		b.WriteString(v)
+		s = s[i+end+1:]
+	}
+	b.WriteString(s)
+	switch len(missing) {
+	case 0:
+	case 1:
+		return "", fmt.Errorf("environment variable %s is not set", missing[0])
+	default:
+		return "", fmt.Errorf("environment variables %s are not set", strings.Join(missing, ", "))
+	}
+	return b.String(), nil
+}
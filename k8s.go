@@ -0,0 +1,177 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os/exec"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/rakyll/boom/boomer"
+)
+
+// jobPoll is how often the Jobs of a -k8s run are checked for
+// completion.
+var jobPoll = 2 * time.Second
+
+// job is the data -k8s-template is executed with for each Job, e.g.
+//
+//	apiVersion: batch/v1
+//	kind: Job
+//	metadata:
+//	  name: {{.Name}}
+//	spec:
+//	  backoffLimit: 0
+//	  template:
+//	    spec:
+//	      restartPolicy: Never
+//	      containers:
+//	      - name: boom
+//	        image: registry.example.com/boom:latest
+//	        args: {{json .Args}}
+//
+// The container must run boom with Args, which print the JSON report of
+// the share of the Job of the run.
+type job struct {
+	// Name is the name the Job must be given, unique to the run.
+	Name string
+
+	// Index is the number of the Job in the run, from 0.
+	Index int
+
+	// Args are the arguments of boom for the Job.
+	Args []string
+}
+
+// parseJobTemplate reads the Job template of a -k8s run.
+func parseJobTemplate(path string) (*template.Template, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return template.New(path).Funcs(template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+	}).Parse(string(data))
+}
+
+// runJobs makes the run described by args as n Kubernetes Jobs created
+// from tmpl with kubectl, each making its share of the requests, and
+// returns their reports read from the logs of the Jobs. The Jobs are
+// deleted once done, or once ctx is done.
+func runJobs(ctx context.Context, tmpl *template.Template, n int, args []string, num, conc, qps int) ([]*boomer.Report, error) {
+	prefix := "boom-" + strconv.FormatInt(time.Now().Unix(), 36)
+	var names []string
+	defer func() {
+		if len(names) > 0 {
+			kubectl(context.Background(), nil, append([]string{"delete", "job", "--wait=false"}, names...)...)
+		}
+	}()
+	for i := 0; i < n; i++ {
+		j := job{
+			Name:  fmt.Sprintf("%s-%d", prefix, i),
+			Index: i,
+			Args:  append([]string{"-o=json"}, agentArgs(args, i, n, num, conc, qps)...),
+		}
+		var manifest bytes.Buffer
+		if err := tmpl.Execute(&manifest, j); err != nil {
+			return nil, err
+		}
+		if _, err := kubectl(ctx, &manifest, "create", "-f", "-"); err != nil {
+			return nil, fmt.Errorf("job %s: %v", j.Name, err)
+		}
+		names = append(names, j.Name)
+	}
+
+	reports := make([]*boomer.Report, n)
+	for done := 0; done < n; {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(jobPoll):
+		}
+		for i, name := range names {
+			if reports[i] != nil {
+				continue
+			}
+			out, err := kubectl(ctx, nil, "get", "job", name, "-o", "jsonpath={.status.succeeded}/{.status.failed}")
+			if err != nil {
+				return nil, fmt.Errorf("job %s: %v", name, err)
+			}
+			var succeeded, failed int
+			fmt.Sscanf(string(out), "%d/%d", &succeeded, &failed)
+			if succeeded == 0 && failed == 0 {
+				continue
+			}
+			logs, err := kubectl(ctx, nil, "logs", "job/"+name)
+			if err != nil {
+				return nil, fmt.Errorf("job %s: %v", name, err)
+			}
+			if failed > 0 {
+				return nil, fmt.Errorf("job %s failed: %s", name, bytes.TrimSpace(logs))
+			}
+			if reports[i], err = parseJobLog(logs); err != nil {
+				return nil, fmt.Errorf("job %s: %v", name, err)
+			}
+			reports[i].Name = name
+			done++
+		}
+	}
+	return reports, nil
+}
+
+// parseJobLog returns the JSON report printed in the log of a Job,
+// which may be preceded by the lines boom writes to stderr.
+func parseJobLog(logs []byte) (*boomer.Report, error) {
+	i := 0
+	for !bytes.HasPrefix(logs[i:], []byte("{\n")) {
+		j := bytes.IndexByte(logs[i:], '\n')
+		if j < 0 {
+			return nil, errors.New("no report in the log")
+		}
+		i += j + 1
+	}
+	var r boomer.Report
+	if err := json.NewDecoder(bytes.NewReader(logs[i:])).Decode(&r); err != nil {
+		return nil, fmt.Errorf("invalid report in the log: %v", err)
+	}
+	return &r, nil
+}
+
+// kubectl runs kubectl with args and stdin, returning its output or an
+// error holding what it wrote to stderr.
+func kubectl(ctx context.Context, stdin io.Reader, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "kubectl", args...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = stdin, &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, errors.New(msg)
+		}
+		return nil, err
+	}
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,47 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"os"
+
+	"github.com/rakyll/boom/boomer"
+)
+
+// reportMain runs "boom report", which prints the report derived from
+// the requests of a -dump file.
+func reportMain(args []string) {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	fs.Usage = flag.Usage
+	output := fs.String("o", "", "")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		usageAndExit("report requires a -dump file.")
+	}
+	switch *output {
+	case "", "csv", "csv-summary", "json", "xml", "html", "github", "gitlab", "tap", "junit", "hgrm":
+	default:
+		usageAndExit("Invalid output type; only csv, csv-summary, json, xml, html, github, gitlab, tap, junit and hgrm are supported.")
+	}
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		usageAndExit(err.Error())
+	}
+	defer f.Close()
+	r, err := boomer.ReadDump(f)
+	printErr(err)
+	printReport(r, *output)
+}
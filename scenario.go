@@ -0,0 +1,140 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/rakyll/boom/boomer"
+)
+
+// step configures one of the steps of a -scenario file, e.g.
+//
+//	[
+//	  {"name": "login", "method": "POST", "url": "http://localhost/login",
+//	   "body": "{\"user\": \"u{{seq}}\"}", "extract": {"token": "json:$.token"}},
+//	  {"name": "profile", "url": "http://localhost/me",
+//	   "headers": {"Authorization": "Bearer {{var \"token\"}}"}}
+//	]
+//
+// Values are extracted from a "header:", "cookie:" or "json:" path of
+// the response. Environment variables are substituted for ${VAR} in the
+// string fields.
+type step struct {
+	Name    string            `json:"name"`
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
+	Body    string            `json:"body"`
+	Extract map[string]string `json:"extract"`
+}
+
+// loadScenario reads the steps of a -scenario file.
+func loadScenario(path string) ([]boomer.Step, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var steps []step
+	if err := json.Unmarshal(data, &steps); err != nil {
+		return nil, fmt.Errorf("invalid scenario file: %v", err)
+	}
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("no steps in %v", path)
+	}
+	var scenario []boomer.Step
+	for i, s := range steps {
+		st, err := s.step()
+		if err != nil {
+			return nil, fmt.Errorf("step %d: %v", i, err)
+		}
+		if st.Name == "" {
+			st.Name = fmt.Sprintf("%d", i)
+		}
+		scenario = append(scenario, st)
+	}
+	return scenario, nil
+}
+
+func (s *step) step() (boomer.Step, error) {
+	var st boomer.Step
+	for _, f := range []*string{&s.Name, &s.Method, &s.URL, &s.Body} {
+		v, err := expandEnv(*f)
+		if err != nil {
+			return st, err
+		}
+		*f = v
+	}
+	if s.URL == "" {
+		return st, fmt.Errorf("no url given")
+	}
+	st.Name, st.URL, st.Body = s.Name, s.URL, s.Body
+	st.Method = strings.ToUpper(s.Method)
+	if len(s.Headers) > 0 {
+		st.Header = make(http.Header)
+		for k, v := range s.Headers {
+			v, err := expandEnv(v)
+			if err != nil {
+				return st, err
+			}
+			st.Header.Set(k, v)
+		}
+	}
+	// Extractions are made in a stable order for errors to be
+	// reproducible.
+	vars := make([]string, 0, len(s.Extract))
+	for v := range s.Extract {
+		vars = append(vars, v)
+	}
+	sort.Strings(vars)
+	for _, v := range vars {
+		e, err := parseExtract(v, s.Extract[v])
+		if err != nil {
+			return st, err
+		}
+		st.Extract = append(st.Extract, e)
+	}
+	return st, nil
+}
+
+// parseExtract parses where the value of variable v is extracted from:
+// "header:name", "cookie:name" or "json:path". A path starting with $
+// may leave out "json:".
+func parseExtract(v, from string) (boomer.Extract, error) {
+	e := boomer.Extract{Var: v}
+	if strings.HasPrefix(from, "$") {
+		from = "json:" + from
+	}
+	parts := strings.SplitN(from, ":", 2)
+	if len(parts) == 2 && parts[1] != "" {
+		switch parts[0] {
+		case "header":
+			e.Header = parts[1]
+			return e, nil
+		case "cookie":
+			e.Cookie = parts[1]
+			return e, nil
+		case "json":
+			e.JSONPath = parts[1]
+			return e, nil
+		}
+	}
+	return e, fmt.Errorf("invalid extraction %q of %s, expected header:, cookie: or json: and a name or path", from, v)
+}
@@ -0,0 +1,271 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rakyll/boom/boomer"
+)
+
+// serveRun is the body of a request to create a run, a target as in
+// -targets files with the duration and thresholds of the run, e.g.
+//
+//	{"url": "http://localhost/items", "c": 20, "duration": "30s",
+//	 "thresholds": ["p95<300ms", "error_rate<1%"]}
+type serveRun struct {
+	target
+	Duration   string   `json:"duration"`
+	Thresholds []string `json:"thresholds"`
+}
+
+// run is a run created through the API.
+type run struct {
+	id     int
+	name   string
+	b      *boomer.Boomer
+	cancel context.CancelFunc
+	done   chan struct{}
+	report *boomer.Report
+}
+
+// runStatus describes a run in the replies of the API.
+type runStatus struct {
+	ID       int            `json:"id"`
+	Name     string         `json:"name,omitempty"`
+	State    string         `json:"state"`
+	Requests int            `json:"requests"`
+	N        int            `json:"n,omitempty"`
+	Report   *boomer.Report `json:"report,omitempty"`
+}
+
+// server serves the API of "boom serve".
+type server struct {
+	token string
+
+	mu   sync.Mutex
+	last int
+	runs map[int]*run
+}
+
+// serveMain runs "boom serve", which serves an HTTP API to create
+// runs, poll their progress, cancel them and fetch their reports.
+func serveMain(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	fs.Usage = flag.Usage
+	listen := fs.String("listen", ":8080", "")
+	token := fs.String("token", "", "")
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		usageAndExit("serve takes no arguments.")
+	}
+	*listen = localListen("serve", *listen, *token)
+	s := &server{token: *token, runs: make(map[int]*run)}
+	fmt.Fprintf(os.Stderr, "Serving the API on %s.\n", *listen)
+	printErr(http.ListenAndServe(*listen, s))
+}
+
+// ServeHTTP routes the requests of the API:
+//
+//	POST   /runs              creates a run and replies with its status
+//	GET    /runs              lists the runs
+//	GET    /runs/{id}         the status of the run, with its report so far
+//	GET    /runs/{id}/metrics the metrics of the run for Prometheus
+//	GET    /runs/{id}/report  the report of the run once it is done
+//	DELETE /runs/{id}         cancels the run, or forgets it once done
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !checkToken(s.token, w, r) {
+		return
+	}
+	path := strings.Trim(r.URL.Path, "/")
+	if path == "runs" {
+		switch r.Method {
+		case "GET":
+			s.list(w)
+		case "POST":
+			s.create(w, r)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+	parts := strings.SplitN(path, "/", 3)
+	if len(parts) < 2 || parts[0] != "runs" {
+		http.NotFound(w, r)
+		return
+	}
+	id, err := strconv.Atoi(parts[1])
+	s.mu.Lock()
+	run := s.runs[id]
+	s.mu.Unlock()
+	if err != nil || run == nil {
+		http.NotFound(w, r)
+		return
+	}
+	sub := ""
+	if len(parts) == 3 {
+		sub = parts[2]
+	}
+	switch {
+	case sub == "" && r.Method == "GET":
+		writeJSON(w, http.StatusOK, run.status(true))
+	case sub == "" && r.Method == "DELETE":
+		s.delete(w, run)
+	case sub == "metrics" && r.Method == "GET":
+		r.URL.Path = "/metrics"
+		run.b.StatusHandler().ServeHTTP(w, r)
+	case sub == "report" && r.Method == "GET":
+		select {
+		case <-run.done:
+			writeJSON(w, http.StatusOK, run.report)
+		default:
+			http.Error(w, "run is not done", http.StatusConflict)
+		}
+	case sub == "" || sub == "metrics" || sub == "report":
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// create starts the run described by the request.
+func (s *server) create(w http.ResponseWriter, r *http.Request) {
+	var spec serveRun
+	if err := json.NewDecoder(r.Body).Decode(&spec); err != nil {
+		http.Error(w, "invalid run: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	b, err := spec.boomer()
+	if err == nil {
+		err = b.Validate()
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	run := &run{name: b.Name, b: b, cancel: cancel, done: make(chan struct{})}
+	s.mu.Lock()
+	s.last++
+	run.id = s.last
+	s.runs[run.id] = run
+	s.mu.Unlock()
+	go func() {
+		defer close(run.done)
+		run.report, _ = b.Run(ctx)
+	}()
+	w.Header().Set("Location", fmt.Sprintf("/runs/%d", run.id))
+	writeJSON(w, http.StatusCreated, run.status(false))
+}
+
+// list replies with the status of every run, without their reports.
+func (s *server) list(w http.ResponseWriter) {
+	s.mu.Lock()
+	statuses := make([]runStatus, 0, len(s.runs))
+	for _, run := range s.runs {
+		statuses = append(statuses, run.status(false))
+	}
+	s.mu.Unlock()
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].ID < statuses[j].ID })
+	writeJSON(w, http.StatusOK, statuses)
+}
+
+// delete cancels run, which reports the requests made until then, or
+// forgets it if it is done.
+func (s *server) delete(w http.ResponseWriter, run *run) {
+	select {
+	case <-run.done:
+		s.mu.Lock()
+		delete(s.runs, run.id)
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		run.cancel()
+		writeJSON(w, http.StatusAccepted, run.status(false))
+	}
+}
+
+// status returns the status of the run, with its report so far if
+// withReport is set.
+func (run *run) status(withReport bool) runStatus {
+	s := runStatus{ID: run.id, Name: run.name, State: "pending", N: run.b.N}
+	var report *boomer.Report
+	select {
+	case <-run.done:
+		s.State, report = "done", run.report
+	default:
+		if report = run.b.Snapshot(); report != nil {
+			s.State = "running"
+		}
+	}
+	if report != nil {
+		s.Requests = report.Responses() + report.ErrorCount()
+		for _, a := range report.Aborts {
+			s.Requests += a.Count
+		}
+		if report.Partial {
+			s.State = "canceled"
+		}
+	}
+	if withReport {
+		s.Report = report
+	}
+	return s
+}
+
+// boomer returns the run described by the spec, with the defaults of
+// the command line. Unlike in -targets files, environment variables
+// and files are not substituted, as they would be disclosed to the
+// clients of the API.
+func (spec *serveRun) boomer() (*boomer.Boomer, error) {
+	b, err := spec.target.boomer(&boomer.Boomer{N: 200, C: 50, GracePeriod: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	if spec.Duration != "" {
+		if b.Duration, err = time.ParseDuration(spec.Duration); err != nil {
+			return nil, fmt.Errorf("invalid duration: %v", err)
+		}
+		if spec.N == 0 {
+			b.N = 0
+		}
+	}
+	for _, v := range spec.Thresholds {
+		t, err := parseThreshold(v)
+		if err != nil {
+			return nil, err
+		}
+		b.Thresholds = append(b.Thresholds, t)
+	}
+	return b, nil
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
@@ -0,0 +1,83 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxShards bounds the number of hosts a URL may expand to.
+const maxShards = 10000
+
+var shardRangeRegexp = regexp.MustCompile(`\[(\d+)-(\d+)\]`)
+
+// expandShards expands the numeric ranges in the host of u, such as
+// "https://shard-[1-32].example.com/health", returning u with the first
+// host and all the hosts in order. Ranges whose start has leading zeros,
+// e.g. [01-32], are zero-padded to its width. It returns no hosts if u
+// has no range.
+func expandShards(u string) (string, []string, error) {
+	i := strings.Index(u, "://")
+	if i < 0 {
+		return u, nil, nil
+	}
+	start := i + len("://")
+	end := len(u)
+	if j := strings.IndexAny(u[start:], "/?#"); j >= 0 {
+		end = start + j
+	}
+	authority := u[start:end]
+	userinfo := ""
+	if j := strings.LastIndex(authority, "@"); j >= 0 {
+		userinfo, authority = authority[:j+1], authority[j+1:]
+	}
+	if !shardRangeRegexp.MatchString(authority) {
+		return u, nil, nil
+	}
+
+	hosts := []string{""}
+	prev := 0
+	for _, m := range shardRangeRegexp.FindAllStringSubmatchIndex(authority, -1) {
+		prefix := authority[prev:m[0]]
+		from, to := authority[m[2]:m[3]], authority[m[4]:m[5]]
+		lo, err1 := strconv.Atoi(from)
+		hi, err2 := strconv.Atoi(to)
+		if err1 != nil || err2 != nil || lo > hi {
+			return "", nil, fmt.Errorf("invalid host range [%s-%s]", from, to)
+		}
+		if len(hosts)*(hi-lo+1) > maxShards {
+			return "", nil, fmt.Errorf("host ranges expand to more than %d hosts", maxShards)
+		}
+		width := 0
+		if len(from) > 1 && from[0] == '0' {
+			width = len(from)
+		}
+		var expanded []string
+		for _, h := range hosts {
+			for n := lo; n <= hi; n++ {
+				expanded = append(expanded, fmt.Sprintf("%s%s%0*d", h, prefix, width, n))
+			}
+		}
+		hosts = expanded
+		prev = m[1]
+	}
+	for i := range hosts {
+		hosts[i] += authority[prev:]
+	}
+	return u[:start] + userinfo + hosts[0] + u[end:], hosts, nil
+}
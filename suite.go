@@ -0,0 +1,114 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/rakyll/boom/boomer"
+)
+
+// suite configures the runs of a -suite file, made one after another
+// for every combination of the values of its matrix, e.g.
+//
+//	{
+//	  "url": "http://localhost/items", "n": 10000,
+//	  "matrix": {"c": [10, 50, 100], "keepalive": [true, false]}
+//	}
+//
+// The other fields are those of a target, applied to every cell. The
+// matrix may vary c, n, q and keepalive.
+type suite struct {
+	target
+	Matrix map[string][]json.RawMessage `json:"matrix"`
+}
+
+// loadSuite reads a -suite file and returns a boomer per cell of its
+// matrix, each a copy of base with the suite's and the cell's settings
+// applied and named after the cell's values, e.g. "c=10 keepalive=true".
+func loadSuite(path string, base *boomer.Boomer) ([]*boomer.Boomer, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var s suite
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("invalid suite file: %v", err)
+	}
+	if err := s.expandEnv(); err != nil {
+		return nil, err
+	}
+	b, err := s.boomer(base)
+	if err != nil {
+		return nil, err
+	}
+
+	var dims []string
+	for dim, values := range s.Matrix {
+		if len(values) == 0 {
+			return nil, fmt.Errorf("no values for %s in the matrix", dim)
+		}
+		dims = append(dims, dim)
+	}
+	sort.Strings(dims)
+	cells := []*boomer.Boomer{b}
+	for _, dim := range dims {
+		var next []*boomer.Boomer
+		for _, cell := range cells {
+			for _, v := range s.Matrix[dim] {
+				c := *cell
+				if err := setDimension(&c, dim, v); err != nil {
+					return nil, err
+				}
+				c.Name = strings.TrimSpace(fmt.Sprintf("%s %s=%s", cell.Name, dim, v))
+				next = append(next, &c)
+			}
+		}
+		cells = next
+	}
+	for _, c := range cells {
+		if c.N <= 0 || c.C <= 0 {
+			return nil, fmt.Errorf("%s: n and c cannot be smaller than 1", c.Name)
+		}
+	}
+	return cells, nil
+}
+
+// setDimension sets the setting of b named by dim to v.
+func setDimension(b *boomer.Boomer, dim string, v json.RawMessage) error {
+	var err error
+	switch dim {
+	case "c":
+		err = json.Unmarshal(v, &b.C)
+	case "n":
+		err = json.Unmarshal(v, &b.N)
+	case "q":
+		err = json.Unmarshal(v, &b.Qps)
+	case "keepalive":
+		var on bool
+		err = json.Unmarshal(v, &on)
+		b.DisableKeepAlives = !on
+	default:
+		return fmt.Errorf("unknown matrix dimension %q; only c, n, q and keepalive are supported", dim)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid %s %s in the matrix", dim, v)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/rakyll/boom/boomer"
+)
+
+// target configures one of the runs listed in a -targets file, e.g.
+//
+//	[
+//	  {"name": "read", "url": "http://localhost/items", "c": 50, "q": 200},
+//	  {"name": "write", "url": "http://localhost/items", "method": "POST",
+//	   "body": "{}", "headers": {"Content-Type": "application/json"}, "q": 20}
+//	]
+//
+// Fields left out are taken from the command line flags. Environment
+// variables are substituted for ${VAR} in the string fields.
+type target struct {
+	Name    string            `json:"name"`
+	URL     string            `json:"url"`
+	Method  string            `json:"method"`
+	Headers map[string]string `json:"headers"`
+	Body    *string           `json:"body"`
+	N       int               `json:"n"`
+	C       int               `json:"c"`
+	Q       int               `json:"q"`
+}
+
+// loadTargets reads a -targets file and returns a boomer per target,
+// each a copy of base with the target's settings applied.
+func loadTargets(path string, base *boomer.Boomer) ([]*boomer.Boomer, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var targets []target
+	if err := json.Unmarshal(data, &targets); err != nil {
+		return nil, fmt.Errorf("invalid targets file: %v", err)
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no targets in %v", path)
+	}
+
+	var boomers []*boomer.Boomer
+	for i, t := range targets {
+		if err := t.expandEnv(); err != nil {
+			return nil, fmt.Errorf("target %d: %v", i, err)
+		}
+		b, err := t.boomer(base)
+		if err != nil {
+			return nil, fmt.Errorf("target %d: %v", i, err)
+		}
+		if b.Name == "" {
+			b.Name = fmt.Sprintf("%d", i)
+		}
+		boomers = append(boomers, b)
+	}
+	return boomers, nil
+}
+
+// expandEnv substitutes environment variables in the string fields of
+// the target.
+func (t *target) expandEnv() error {
+	for _, f := range []*string{&t.Name, &t.URL, &t.Method, t.Body} {
+		if f == nil {
+			continue
+		}
+		v, err := expandEnv(*f)
+		if err != nil {
+			return err
+		}
+		*f = v
+	}
+	headers := make(map[string]string, len(t.Headers))
+	for k, v := range t.Headers {
+		k, err := expandEnv(k)
+		if err != nil {
+			return err
+		}
+		if headers[k], err = expandEnv(v); err != nil {
+			return err
+		}
+	}
+	t.Headers = headers
+	return nil
+}
+
+func (t *target) boomer(base *boomer.Boomer) (*boomer.Boomer, error) {
+	b := *base
+	b.Name = t.Name
+	method, url := "GET", t.URL
+	var header http.Header
+	var host string
+	if base.Request != nil {
+		method = base.Request.Method
+		header = base.Request.Header
+		if base.Request.Host != base.Request.URL.Host {
+			host = base.Request.Host
+		}
+		if url == "" {
+			url = base.Request.URL.String()
+		}
+	}
+	if t.Method != "" {
+		method = strings.ToUpper(t.Method)
+	}
+	if url == "" {
+		return nil, fmt.Errorf("no url given")
+	}
+	if t.URL != "" {
+		var err error
+		if url, b.Hosts, err = expandShards(url); err != nil {
+			return nil, err
+		}
+	}
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if host != "" {
+		req.Host = host
+	}
+	req.Header = make(http.Header)
+	for k, v := range header {
+		req.Header[k] = append([]string(nil), v...)
+	}
+	for k, v := range t.Headers {
+		req.Header.Set(k, v)
+	}
+	b.Request = req
+	if t.Body != nil {
+		b.RequestBody, b.BodyFile = *t.Body, ""
+	}
+	if t.N > 0 {
+		b.N = t.N
+	}
+	if t.C > 0 {
+		b.C = t.C
+	}
+	if t.Q > 0 {
+		b.Qps = t.Q
+	}
+	if b.Template {
+		if err := b.ParseTemplates(); err != nil {
+			return nil, err
+		}
+	}
+	return &b, nil
+}